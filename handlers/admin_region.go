@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"epss-backend/database"
+	"epss-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetAdminRegionList 获取管理员负责区域列表，支持通过admin_id参数筛选
+func GetAdminRegionList(c *fiber.Ctx) error {
+	query := `
+		SELECT
+			ar.ar_id, ar.admin_id, ar.province_id, ar.city_id, ar.remarks,
+			IFNULL(a.admin_code, '') as admin_code,
+			IFNULL(p.province_name, '') as province_name,
+			IFNULL(ct.city_name, '') as city_name
+		FROM
+			admin_region ar
+		LEFT JOIN
+			admins a ON ar.admin_id = a.admin_id
+		LEFT JOIN
+			grid_province p ON ar.province_id = p.province_id
+		LEFT JOIN
+			grid_city ct ON ar.city_id = ct.city_id
+	`
+	params := []interface{}{}
+
+	if adminID := c.Query("admin_id"); adminID != "" {
+		query += " WHERE ar.admin_id = ?"
+		params = append(params, adminID)
+	}
+	query += " ORDER BY ar.admin_id, ar.province_id, ar.city_id"
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取管理员负责区域失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var regionList []fiber.Map
+	for rows.Next() {
+		var region models.AdminRegion
+		var adminCode, provinceName, cityName string
+
+		err := rows.Scan(
+			&region.ArID, &region.AdminID, &region.ProvinceID, &region.CityID, &region.Remarks,
+			&adminCode, &provinceName, &cityName,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理管理员负责区域数据失败",
+				"details": err.Error(),
+			})
+		}
+
+		regionList = append(regionList, fiber.Map{
+			"id":            region.ArID,
+			"admin_id":      region.AdminID,
+			"admin_code":    adminCode,
+			"province_id":   region.ProvinceID,
+			"province_name": provinceName,
+			"city_id":       region.CityID,
+			"city_name":     cityName,
+			"remarks":       region.Remarks.String,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": regionList,
+	})
+}
+
+// AddAdminRegion 为管理员添加负责区域，city_id为0表示负责整个省
+func AddAdminRegion(c *fiber.Ctx) error {
+	var req struct {
+		AdminID    int64  `json:"admin_id"`
+		ProvinceID int64  `json:"province_id"`
+		CityID     int64  `json:"city_id"`
+		Remarks    string `json:"remarks"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的请求格式"})
+	}
+
+	if req.AdminID <= 0 || req.ProvinceID <= 0 || req.CityID < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "管理员ID和省份ID为必填项"})
+	}
+
+	// 检查管理员是否存在
+	var count int
+	err := database.DB.QueryRow("SELECT COUNT(*) FROM admins WHERE admin_id = ?", req.AdminID).Scan(&count)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "数据库查询失败"})
+	}
+	if count == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "管理员不存在"})
+	}
+
+	// 检查城市是否属于该省份
+	if req.CityID > 0 {
+		err = database.DB.QueryRow(
+			"SELECT COUNT(*) FROM grid_city WHERE city_id = ? AND province_id = ?",
+			req.CityID, req.ProvinceID,
+		).Scan(&count)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "数据库查询失败"})
+		}
+		if count == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "城市不属于该省份"})
+		}
+	}
+
+	// 检查是否已经添加过该区域
+	err = database.DB.QueryRow(
+		"SELECT COUNT(*) FROM admin_region WHERE admin_id = ? AND province_id = ? AND city_id = ?",
+		req.AdminID, req.ProvinceID, req.CityID,
+	).Scan(&count)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "数据库查询失败"})
+	}
+	if count > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "该管理员已负责此区域"})
+	}
+
+	var remarks interface{}
+	if req.Remarks != "" {
+		remarks = req.Remarks
+	}
+
+	result, err := database.DB.Exec(
+		"INSERT INTO admin_region (admin_id, province_id, city_id, remarks) VALUES (?, ?, ?, ?)",
+		req.AdminID, req.ProvinceID, req.CityID, remarks,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "添加负责区域失败"})
+	}
+
+	arID, _ := result.LastInsertId()
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "负责区域添加成功",
+		"id":      arID,
+	})
+}
+
+// DeleteAdminRegion 删除管理员负责区域
+func DeleteAdminRegion(c *fiber.Ctx) error {
+	regionID := c.Params("id")
+	if regionID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "缺少负责区域ID"})
+	}
+
+	var count int
+	err := database.DB.QueryRow("SELECT COUNT(*) FROM admin_region WHERE ar_id = ?", regionID).Scan(&count)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "数据库查询失败"})
+	}
+	if count == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "负责区域不存在"})
+	}
+
+	_, err = database.DB.Exec("DELETE FROM admin_region WHERE ar_id = ?", regionID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "删除负责区域失败"})
+	}
+
+	return c.JSON(fiber.Map{"message": "负责区域删除成功"})
+}
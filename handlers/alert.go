@@ -0,0 +1,783 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/database"
+	"epss-backend/models"
+	"epss-backend/notify"
+	"epss-backend/realtime"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 告警规则类型
+const (
+	alertRuleSingle    = 1 // 单次超标：一次实测值超过阈值
+	alertRuleFrequency = 2 // 频次超标：时间窗口内同一城市超过阈值的次数达到设定次数
+	alertRuleTrend     = 3 // 上升趋势：时间窗口内同一城市实测值连续上升设定次数，且最新值超过阈值
+)
+
+// 告警处理状态
+const (
+	alertStateOpen         = 0 // 未处理
+	alertStateAcknowledged = 1 // 已确认
+	alertStateResolved     = 2 // 已解决
+)
+
+// 告警规则的时间窗口最长7天
+const maxAlertWindowMinutes = 7 * 24 * 60
+
+// alertMetricColumns 告警指标对应的 statistics 表字段
+var alertMetricColumns = map[string]string{
+	"so2": "so2_value",
+	"co":  "co_value",
+	"spm": "spm_value",
+	"aqi": "aqi_id",
+}
+
+const alertRuleColumns = `ar_id, ar_name, rule_type, metric, threshold, occurrences, window_minutes,
+	province_id, city_id, enabled, admin_id, create_date, create_time, remarks`
+
+func scanAlertRule(row interface{ Scan(...interface{}) error }) (models.AlertRule, error) {
+	var rule models.AlertRule
+	err := row.Scan(
+		&rule.ArID, &rule.ArName, &rule.RuleType, &rule.Metric, &rule.Threshold, &rule.Occurrences, &rule.WindowMinutes,
+		&rule.ProvinceID, &rule.CityID, &rule.Enabled, &rule.AdminID, &rule.CreateDate, &rule.CreateTime, &rule.Remarks,
+	)
+	return rule, err
+}
+
+// loadAlertRules 读取启用的告警规则，cityID 大于0时只返回适用于该城市的规则
+func loadAlertRules(provinceID, cityID int64) ([]models.AlertRule, error) {
+	query := "SELECT " + alertRuleColumns + " FROM alert_rule WHERE enabled = 1"
+	params := []interface{}{}
+	if cityID > 0 {
+		query += " AND (province_id = 0 OR (province_id = ? AND (city_id = 0 OR city_id = ?)))"
+		params = append(params, provinceID, cityID)
+	}
+	query += " ORDER BY ar_id"
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.AlertRule
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// StartAlertMonitor 启动后台任务，定期评估频次超标和上升趋势规则
+// 检查间隔通过 ALERT_CHECK_INTERVAL 配置，默认每5分钟一次
+func StartAlertMonitor() {
+	startPeriodicTask("告警规则检查", "ALERT_CHECK_INTERVAL", 5*time.Minute, checkAlertRules)
+}
+
+// checkAlertRules 对时间窗口内有实测数据的城市评估频次超标和上升趋势规则
+// 单次超标规则只在提交实测数据时评估
+func checkAlertRules(now time.Time) error {
+	rules, err := loadAlertRules(0, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		if rule.RuleType == alertRuleSingle {
+			continue
+		}
+
+		query := "SELECT DISTINCT province_id, city_id FROM statistics s WHERE " + validStatisticsCondition("s") +
+			" AND CONCAT(confirm_date, ' ', confirm_time) >= ?"
+		params := []interface{}{alertWindowStart(rule, now)}
+		if rule.ProvinceID > 0 {
+			query += " AND province_id = ?"
+			params = append(params, rule.ProvinceID)
+			if rule.CityID > 0 {
+				query += " AND city_id = ?"
+				params = append(params, rule.CityID)
+			}
+		}
+
+		rows, err := database.DB.Query(query, params...)
+		if err != nil {
+			return err
+		}
+		type region struct{ provinceID, cityID int64 }
+		var regions []region
+		for rows.Next() {
+			var r region
+			if err := rows.Scan(&r.provinceID, &r.cityID); err != nil {
+				rows.Close()
+				return err
+			}
+			regions = append(regions, r)
+		}
+		rows.Close()
+
+		for _, r := range regions {
+			if err := evaluateAlertRule(rule, r.provinceID, r.cityID, 0, now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// evaluateMeasurementAlerts 实测数据保存后评估适用于该城市的所有告警规则
+// 时间窗口以测量时间为准，离线同步的实测数据按设备上的测量时间评估
+func evaluateMeasurementAlerts(statisticsID, provinceID, cityID int64, measuredAt time.Time) error {
+	rules, err := loadAlertRules(provinceID, cityID)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if err := evaluateAlertRule(rule, provinceID, cityID, statisticsID, measuredAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func alertWindowStart(rule models.AlertRule, now time.Time) string {
+	return now.Add(-time.Duration(rule.WindowMinutes) * time.Minute).Format(feedbackTimeLayout)
+}
+
+// evaluateAlertRule 评估一条规则在指定城市是否触发，statisticsID 为0时表示定时检查
+func evaluateAlertRule(rule models.AlertRule, provinceID, cityID, statisticsID int64, now time.Time) error {
+	column, ok := alertMetricColumns[rule.Metric]
+	if !ok {
+		return nil
+	}
+	metricText := getComplianceMetricText(rule.Metric)
+
+	switch rule.RuleType {
+	case alertRuleSingle:
+		if statisticsID == 0 {
+			return nil
+		}
+		var value int
+		err := database.DB.QueryRow("SELECT "+column+" FROM statistics WHERE id = ?", statisticsID).Scan(&value)
+		if err != nil {
+			return err
+		}
+		if value <= rule.Threshold {
+			return nil
+		}
+		message := fmt.Sprintf("%s实测值%d超过阈值%d", metricText, value, rule.Threshold)
+		return raiseAlert(rule, provinceID, cityID, value, statisticsID, message, now)
+
+	case alertRuleFrequency:
+		var count int
+		err := database.DB.QueryRow(
+			"SELECT COUNT(*) FROM statistics s WHERE city_id = ? AND "+column+" > ? AND "+validStatisticsCondition("s")+
+				" AND CONCAT(confirm_date, ' ', confirm_time) BETWEEN ? AND ?",
+			cityID, rule.Threshold, alertWindowStart(rule, now), now.Format(feedbackTimeLayout),
+		).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count < rule.Occurrences {
+			return nil
+		}
+		message := fmt.Sprintf("%d分钟内%s超过%d共%d次", rule.WindowMinutes, metricText, rule.Threshold, count)
+		return raiseAlert(rule, provinceID, cityID, count, statisticsID, message, now)
+
+	case alertRuleTrend:
+		// 连续上升 N 次需要最近的 N+1 次实测值
+		rows, err := database.DB.Query(
+			"SELECT "+column+" FROM statistics s WHERE city_id = ? AND "+validStatisticsCondition("s")+
+				" AND CONCAT(confirm_date, ' ', confirm_time) BETWEEN ? AND ? ORDER BY confirm_date DESC, confirm_time DESC, id DESC LIMIT ?",
+			cityID, alertWindowStart(rule, now), now.Format(feedbackTimeLayout), rule.Occurrences+1,
+		)
+		if err != nil {
+			return err
+		}
+		var values []int
+		for rows.Next() {
+			var value int
+			if err := rows.Scan(&value); err != nil {
+				rows.Close()
+				return err
+			}
+			values = append(values, value)
+		}
+		rows.Close()
+
+		if len(values) < rule.Occurrences+1 || values[0] <= rule.Threshold {
+			return nil
+		}
+		for i := 0; i+1 < len(values); i++ {
+			if values[i] <= values[i+1] {
+				return nil
+			}
+		}
+		message := fmt.Sprintf("%d分钟内%s连续%d次上升，最新实测值%d", rule.WindowMinutes, metricText, rule.Occurrences, values[0])
+		return raiseAlert(rule, provinceID, cityID, values[0], statisticsID, message, now)
+	}
+	return nil
+}
+
+// raiseAlert 产生告警并通知负责该区域的管理员
+// 同一规则在同一城市已有未解决的告警时不重复产生
+func raiseAlert(rule models.AlertRule, provinceID, cityID int64, value int, statisticsID int64, message string, now time.Time) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRow(
+		"SELECT COUNT(*) FROM alert_record WHERE ar_id = ? AND city_id = ? AND state <> ? FOR UPDATE",
+		rule.ArID, cityID, alertStateResolved,
+	).Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	alertDate, alertTime := now.Format("2006-01-02"), now.Format("15:04:05")
+	result, err := tx.Exec(`
+		INSERT INTO alert_record
+		(ar_id, rule_type, metric, province_id, city_id, value, statistics_id, message, state, alert_date, alert_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, rule.ArID, rule.RuleType, rule.Metric, provinceID, cityID, value, statisticsID, message, alertStateOpen, alertDate, alertTime)
+	if err != nil {
+		return err
+	}
+	alID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	var provinceName, cityName string
+	err = tx.QueryRow(`
+		SELECT IFNULL(p.province_name, ''), ct.city_name
+		FROM grid_city ct
+		LEFT JOIN grid_province p ON ct.province_id = p.province_id
+		WHERE ct.city_id = ?
+	`, cityID).Scan(&provinceName, &cityName)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	adminIDs, err := findRegionAdmins(tx, provinceID, cityID)
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"RuleName":     rule.ArName,
+		"ProvinceName": provinceName,
+		"CityName":     cityName,
+		"CityID":       cityID,
+		"Metric":       rule.Metric,
+		"Value":        value,
+		"Threshold":    rule.Threshold,
+		"Message":      message,
+	}
+	for _, adminID := range adminIDs {
+		if err := enqueueNotification(tx, notify.EventAlertTriggered, "admin", adminID, 0, data, now); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	realtime.Publish(realtime.Event{
+		Topic: realtime.TopicAlert,
+		Data: fiber.Map{
+			"alert_id":      alID,
+			"rule_id":       rule.ArID,
+			"rule_name":     rule.ArName,
+			"rule_type":     rule.RuleType,
+			"metric":        rule.Metric,
+			"province_id":   provinceID,
+			"province_name": provinceName,
+			"city_id":       cityID,
+			"city_name":     cityName,
+			"value":         value,
+			"statistics_id": statisticsID,
+			"message":       message,
+		},
+	})
+	return nil
+}
+
+// alertRuleRequest 添加和修改告警规则的请求，修改时只需提供要修改的字段
+type alertRuleRequest struct {
+	Name          *string `json:"name"`
+	RuleType      *int    `json:"rule_type"`
+	Metric        *string `json:"metric"`
+	Threshold     *int    `json:"threshold"`
+	Occurrences   *int    `json:"occurrences"`
+	WindowMinutes *int    `json:"window_minutes"`
+	ProvinceID    *int64  `json:"province_id"`
+	CityID        *int64  `json:"city_id"`
+	Enabled       *bool   `json:"enabled"`
+	Remarks       *string `json:"remarks"`
+}
+
+// apply 将请求中提供的字段写入规则
+func (req alertRuleRequest) apply(rule *models.AlertRule) {
+	if req.Name != nil {
+		rule.ArName = strings.TrimSpace(*req.Name)
+	}
+	if req.RuleType != nil {
+		rule.RuleType = *req.RuleType
+	}
+	if req.Metric != nil {
+		rule.Metric = *req.Metric
+	}
+	if req.Threshold != nil {
+		rule.Threshold = *req.Threshold
+	}
+	if req.Occurrences != nil {
+		rule.Occurrences = *req.Occurrences
+	}
+	if req.WindowMinutes != nil {
+		rule.WindowMinutes = *req.WindowMinutes
+	}
+	if req.ProvinceID != nil {
+		rule.ProvinceID = *req.ProvinceID
+	}
+	if req.CityID != nil {
+		rule.CityID = *req.CityID
+	}
+	if req.Enabled != nil {
+		rule.Enabled = 0
+		if *req.Enabled {
+			rule.Enabled = 1
+		}
+	}
+	if req.Remarks != nil {
+		rule.Remarks = sql.NullString{String: *req.Remarks, Valid: *req.Remarks != ""}
+	}
+}
+
+// validateAlertRule 校验告警规则，数据有效时返回空字符串
+func validateAlertRule(rule models.AlertRule) string {
+	if rule.ArName == "" || len([]rune(rule.ArName)) > 50 {
+		return "名称不能为空且不能超过50个字符"
+	}
+	if rule.RuleType < alertRuleSingle || rule.RuleType > alertRuleTrend {
+		return "规则类型必须为1-3"
+	}
+	if _, ok := alertMetricColumns[rule.Metric]; !ok {
+		return "指标必须为so2、co、spm或aqi"
+	}
+	if rule.Threshold < 0 || (rule.Metric == "aqi" && rule.Threshold > 6) {
+		return "阈值无效，AQI级别的阈值必须为0-6"
+	}
+	if rule.RuleType != alertRuleSingle {
+		if rule.Occurrences < 1 || rule.Occurrences > 100 {
+			return "次数必须为1-100"
+		}
+		if rule.WindowMinutes < 1 || rule.WindowMinutes > maxAlertWindowMinutes {
+			return fmt.Sprintf("时间窗口必须为1-%d分钟", maxAlertWindowMinutes)
+		}
+	}
+	if rule.ProvinceID < 0 || rule.CityID < 0 || (rule.CityID > 0 && rule.ProvinceID == 0) {
+		return "适用区域无效，指定城市时必须同时指定省份"
+	}
+	if len([]rune(rule.Remarks.String)) > 200 {
+		return "备注不能超过200个字符"
+	}
+	return ""
+}
+
+// checkAlertRuleRegion 检查规则的适用城市是否属于该省份
+func checkAlertRuleRegion(rule models.AlertRule) (bool, error) {
+	if rule.CityID == 0 {
+		return true, nil
+	}
+	var count int
+	err := database.DB.QueryRow(
+		"SELECT COUNT(*) FROM grid_city WHERE city_id = ? AND province_id = ?",
+		rule.CityID, rule.ProvinceID,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// GetAlertRuleList 获取告警规则列表
+func GetAlertRuleList(c *fiber.Ctx) error {
+	rows, err := database.DB.Query("SELECT " + alertRuleColumns + " FROM alert_rule ORDER BY ar_id")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取告警规则列表失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	ruleList := []fiber.Map{}
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理告警规则数据失败",
+				"details": err.Error(),
+			})
+		}
+
+		ruleList = append(ruleList, fiber.Map{
+			"id":             rule.ArID,
+			"name":           rule.ArName,
+			"rule_type":      rule.RuleType,
+			"rule_type_text": getAlertRuleTypeText(rule.RuleType),
+			"metric":         rule.Metric,
+			"metric_text":    getComplianceMetricText(rule.Metric),
+			"threshold":      rule.Threshold,
+			"occurrences":    rule.Occurrences,
+			"window_minutes": rule.WindowMinutes,
+			"province_id":    rule.ProvinceID,
+			"city_id":        rule.CityID,
+			"enabled":        rule.Enabled == 1,
+			"admin_id":       rule.AdminID,
+			"create_date":    rule.CreateDate,
+			"create_time":    rule.CreateTime,
+			"remarks":        rule.Remarks.String,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": ruleList,
+	})
+}
+
+// AddAlertRule 添加告警规则
+func AddAlertRule(c *fiber.Ctx) error {
+	var req alertRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的请求格式"})
+	}
+
+	rule := models.AlertRule{RuleType: alertRuleSingle, Occurrences: 1, Enabled: 1}
+	req.apply(&rule)
+	if message := validateAlertRule(rule); message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": message})
+	}
+	if ok, err := checkAlertRuleRegion(rule); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "数据库查询失败"})
+	} else if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "城市不属于该省份"})
+	}
+	if rule.RuleType == alertRuleSingle {
+		rule.Occurrences, rule.WindowMinutes = 1, 0
+	}
+
+	var remarks interface{}
+	if rule.Remarks.Valid {
+		remarks = rule.Remarks.String
+	}
+
+	now := time.Now()
+	result, err := database.DB.Exec(`
+		INSERT INTO alert_rule
+		(ar_name, rule_type, metric, threshold, occurrences, window_minutes, province_id, city_id, enabled, admin_id, create_date, create_time, remarks)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, rule.ArName, rule.RuleType, rule.Metric, rule.Threshold, rule.Occurrences, rule.WindowMinutes, rule.ProvinceID, rule.CityID,
+		rule.Enabled, c.Locals("user_id"), now.Format("2006-01-02"), now.Format("15:04:05"), remarks)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "添加告警规则失败",
+			"details": err.Error(),
+		})
+	}
+
+	arID, _ := result.LastInsertId()
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "告警规则添加成功",
+		"id":      arID,
+	})
+}
+
+// UpdateAlertRule 修改告警规则（只需提供要修改的字段）
+func UpdateAlertRule(c *fiber.Ctx) error {
+	ruleID := c.Params("id")
+	if ruleID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "缺少告警规则ID"})
+	}
+
+	var req alertRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的请求格式"})
+	}
+
+	rule, err := scanAlertRule(database.DB.QueryRow("SELECT "+alertRuleColumns+" FROM alert_rule WHERE ar_id = ?", ruleID))
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "告警规则不存在"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "数据库查询失败"})
+	}
+
+	req.apply(&rule)
+	if message := validateAlertRule(rule); message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": message})
+	}
+	if ok, err := checkAlertRuleRegion(rule); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "数据库查询失败"})
+	} else if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "城市不属于该省份"})
+	}
+	if rule.RuleType == alertRuleSingle {
+		rule.Occurrences, rule.WindowMinutes = 1, 0
+	}
+
+	var remarks interface{}
+	if rule.Remarks.Valid {
+		remarks = rule.Remarks.String
+	}
+
+	_, err = database.DB.Exec(`
+		UPDATE alert_rule
+		SET ar_name = ?, rule_type = ?, metric = ?, threshold = ?, occurrences = ?, window_minutes = ?,
+			province_id = ?, city_id = ?, enabled = ?, remarks = ?
+		WHERE ar_id = ?
+	`, rule.ArName, rule.RuleType, rule.Metric, rule.Threshold, rule.Occurrences, rule.WindowMinutes,
+		rule.ProvinceID, rule.CityID, rule.Enabled, remarks, rule.ArID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "修改告警规则失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{"message": "告警规则修改成功"})
+}
+
+// DeleteAlertRule 删除告警规则，已产生的告警保留
+func DeleteAlertRule(c *fiber.Ctx) error {
+	ruleID := c.Params("id")
+	if ruleID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "缺少告警规则ID"})
+	}
+
+	result, err := database.DB.Exec("DELETE FROM alert_rule WHERE ar_id = ?", ruleID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "删除告警规则失败"})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "告警规则不存在"})
+	}
+
+	return c.JSON(fiber.Map{"message": "告警规则删除成功"})
+}
+
+// GetAlertList 获取告警列表，支持state、rule_id、province_id、city_id参数筛选，limit默认100
+func GetAlertList(c *fiber.Ctx) error {
+	query := `
+		SELECT
+			al.al_id, al.ar_id, al.rule_type, al.metric, al.province_id, al.city_id, al.value, al.statistics_id,
+			al.message, al.state, al.alert_date, al.alert_time,
+			al.ack_admin_id, al.ack_date, al.ack_time,
+			al.resolve_admin_id, al.resolve_date, al.resolve_time, al.resolve_remarks,
+			IFNULL(ar.ar_name, '') as rule_name,
+			IFNULL(p.province_name, '') as province_name,
+			IFNULL(ct.city_name, '') as city_name
+		FROM
+			alert_record al
+		LEFT JOIN
+			alert_rule ar ON al.ar_id = ar.ar_id
+		LEFT JOIN
+			grid_province p ON al.province_id = p.province_id
+		LEFT JOIN
+			grid_city ct ON al.city_id = ct.city_id
+		WHERE 1 = 1
+	`
+	params := []interface{}{}
+
+	if state := c.Query("state"); state != "" {
+		query += " AND al.state = ?"
+		params = append(params, state)
+	}
+	if ruleID := c.Query("rule_id"); ruleID != "" {
+		query += " AND al.ar_id = ?"
+		params = append(params, ruleID)
+	}
+	if provinceID := c.Query("province_id"); provinceID != "" {
+		query += " AND al.province_id = ?"
+		params = append(params, provinceID)
+	}
+	if cityID := c.Query("city_id"); cityID != "" {
+		query += " AND al.city_id = ?"
+		params = append(params, cityID)
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	query += " ORDER BY al.al_id DESC LIMIT ?"
+	params = append(params, limit)
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取告警列表失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	alertList := []fiber.Map{}
+	for rows.Next() {
+		var al models.AlertRecord
+		var ruleName, provinceName, cityName string
+		err := rows.Scan(
+			&al.AlID, &al.ArID, &al.RuleType, &al.Metric, &al.ProvinceID, &al.CityID, &al.Value, &al.StatisticsID,
+			&al.Message, &al.State, &al.AlertDate, &al.AlertTime,
+			&al.AckAdminID, &al.AckDate, &al.AckTime,
+			&al.ResolveAdminID, &al.ResolveDate, &al.ResolveTime, &al.ResolveRemarks,
+			&ruleName, &provinceName, &cityName,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理告警数据失败",
+				"details": err.Error(),
+			})
+		}
+
+		alertList = append(alertList, fiber.Map{
+			"id":               al.AlID,
+			"rule_id":          al.ArID,
+			"rule_name":        ruleName,
+			"rule_type":        al.RuleType,
+			"rule_type_text":   getAlertRuleTypeText(al.RuleType),
+			"metric":           al.Metric,
+			"metric_text":      getComplianceMetricText(al.Metric),
+			"province_id":      al.ProvinceID,
+			"province_name":    provinceName,
+			"city_id":          al.CityID,
+			"city_name":        cityName,
+			"value":            al.Value,
+			"statistics_id":    al.StatisticsID,
+			"message":          al.Message,
+			"state":            al.State,
+			"state_text":       getAlertStateText(al.State),
+			"alert_date":       al.AlertDate,
+			"alert_time":       al.AlertTime,
+			"ack_admin_id":     al.AckAdminID.Int64,
+			"ack_date":         al.AckDate.String,
+			"ack_time":         al.AckTime.String,
+			"resolve_admin_id": al.ResolveAdminID.Int64,
+			"resolve_date":     al.ResolveDate.String,
+			"resolve_time":     al.ResolveTime.String,
+			"resolve_remarks":  al.ResolveRemarks.String,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": alertList,
+	})
+}
+
+// AcknowledgeAlert 确认未处理的告警，表示已有管理员跟进
+func AcknowledgeAlert(c *fiber.Ctx) error {
+	alertID := c.Params("id")
+	if alertID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "缺少告警ID"})
+	}
+
+	now := time.Now()
+	result, err := database.DB.Exec(
+		"UPDATE alert_record SET state = ?, ack_admin_id = ?, ack_date = ?, ack_time = ? WHERE al_id = ? AND state = ?",
+		alertStateAcknowledged, c.Locals("user_id"), now.Format("2006-01-02"), now.Format("15:04:05"), alertID, alertStateOpen,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "确认告警失败",
+			"details": err.Error(),
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "告警不存在或不是未处理状态"})
+	}
+
+	return c.JSON(fiber.Map{"message": "告警已确认"})
+}
+
+// ResolveAlert 将未解决的告警标记为已解决，未确认的告警同时记录确认信息
+// 告警解决后，同一规则在同一城市再次满足条件时会产生新的告警
+func ResolveAlert(c *fiber.Ctx) error {
+	alertID := c.Params("id")
+	if alertID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "缺少告警ID"})
+	}
+
+	var req struct {
+		Remarks string `json:"remarks"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的请求格式"})
+	}
+	req.Remarks = strings.TrimSpace(req.Remarks)
+	if len([]rune(req.Remarks)) > 200 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "处理说明不能超过200个字符"})
+	}
+	var remarks interface{}
+	if req.Remarks != "" {
+		remarks = req.Remarks
+	}
+
+	now := time.Now()
+	date, clock := now.Format("2006-01-02"), now.Format("15:04:05")
+	adminID := c.Locals("user_id")
+	result, err := database.DB.Exec(`
+		UPDATE alert_record
+		SET state = ?, resolve_admin_id = ?, resolve_date = ?, resolve_time = ?, resolve_remarks = ?,
+			ack_admin_id = IFNULL(ack_admin_id, ?), ack_date = IFNULL(ack_date, ?), ack_time = IFNULL(ack_time, ?)
+		WHERE al_id = ? AND state <> ?
+	`, alertStateResolved, adminID, date, clock, remarks, adminID, date, clock, alertID, alertStateResolved)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "解决告警失败",
+			"details": err.Error(),
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "告警不存在或已解决"})
+	}
+
+	return c.JSON(fiber.Map{"message": "告警已解决"})
+}
+
+// 获取告警规则类型文本描述
+func getAlertRuleTypeText(ruleType int) string {
+	switch ruleType {
+	case alertRuleSingle:
+		return "单次超标"
+	case alertRuleFrequency:
+		return "频次超标"
+	case alertRuleTrend:
+		return "上升趋势"
+	default:
+		return "未知类型"
+	}
+}
+
+// 获取告警处理状态文本描述
+func getAlertStateText(state int) string {
+	switch state {
+	case alertStateOpen:
+		return "未处理"
+	case alertStateAcknowledged:
+		return "已确认"
+	case alertStateResolved:
+		return "已解决"
+	default:
+		return "未知状态"
+	}
+}
@@ -21,7 +21,8 @@ func GetAllConfirmedAQI(c *fiber.Ctx) error {
 			s.so2_value, s.so2_level, s.co_value, s.co_level, 
 			s.spm_value, s.spm_level, s.aqi_id, 
 			s.confirm_date, s.confirm_time, s.gm_id, s.fd_id, 
-			s.information, IFNULL(s.remarks, '') as remarks,
+			s.information, IFNULL(s.remarks, '') as remarks, s.af_id,
+			s.latitude, s.longitude, s.location_accuracy, s.fence_distance, s.fence_state,
 			p.province_name, c.city_name, 
 			IFNULL(gm.gm_name, '') as grid_member_name,
 			IFNULL(sup.real_name, '') as supervisor_name,
@@ -89,7 +90,9 @@ func GetAllConfirmedAQI(c *fiber.Ctx) error {
 			&statistics.SO2Value, &statistics.SO2Level, &statistics.COValue, &statistics.COLevel,
 			&statistics.SPMValue, &statistics.SPMLevel, &statistics.AqiID,
 			&statistics.ConfirmDate, &statistics.ConfirmTime, &statistics.GmID, &statistics.FdID,
-			&statistics.Information, &remarks,
+			&statistics.Information, &remarks, &statistics.AfID,
+			&statistics.Latitude, &statistics.Longitude, &statistics.LocationAccuracy,
+			&statistics.FenceDistance, &statistics.FenceState,
 			&provinceName, &cityName, &gridMemberName, &supervisorName,
 			&chineseExplain, &aqiExplain, &color, &healthImpact, &takeSteps,
 		)
@@ -119,6 +122,13 @@ func GetAllConfirmedAQI(c *fiber.Ctx) error {
 			"fd_id":             statistics.FdID,
 			"information":       statistics.Information,
 			"remarks":           remarks.String,
+			"af_id":             statistics.AfID,
+			"latitude":          nullFloatValue(statistics.Latitude),
+			"longitude":         nullFloatValue(statistics.Longitude),
+			"accuracy":          nullFloatValue(statistics.LocationAccuracy),
+			"fence_distance":    nullIntValue(statistics.FenceDistance),
+			"fence_state":       statistics.FenceState,
+			"fence_state_text":  getFenceStateText(statistics.FenceState),
 			"province_name":     provinceName,
 			"city_name":         cityName,
 			"grid_member_name":  gridMemberName,
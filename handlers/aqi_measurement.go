@@ -1,14 +1,50 @@
 package handlers
 
 import (
+	"database/sql"
 	"epss-backend/database"
 	"epss-backend/models"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// errFeedbackNotInProgress 关联的反馈不存在、未指派给当前网格员或不在处理中
+var errFeedbackNotInProgress = errors.New("关联的反馈不存在、未指派给当前网格员或不在处理中，不能提交实测数据")
+
+// aqiMeasurementRequest 网格员提交的实测数据
+type aqiMeasurementRequest struct {
+	FeedbackID    int      `json:"feedback_id"`    // 关联的反馈ID
+	ProvinceID    int64    `json:"province_id"`    // 省份ID
+	CityID        int64    `json:"city_id"`        // 城市ID
+	Address       string   `json:"address"`        // 详细地址
+	SO2Value      int      `json:"so2_value"`      // 二氧化硫浓度值
+	COValue       int      `json:"co_value"`       // 一氧化碳浓度值
+	SPMValue      int      `json:"spm_value"`      // 悬浮颗粒物浓度值
+	Information   string   `json:"information"`    // 信息描述
+	SupervisorTel string   `json:"supervisor_tel"` // 反馈者手机号
+	Latitude      *float64 `json:"latitude"`       // 实测位置纬度（可选）
+	Longitude     *float64 `json:"longitude"`      // 实测位置经度（可选）
+	Accuracy      *float64 `json:"accuracy"`       // 定位精度，单位米（可选）
+}
+
+// validateAQIMeasurement 校验实测数据，数据有效时返回空字符串
+func validateAQIMeasurement(req aqiMeasurementRequest) string {
+	if req.ProvinceID <= 0 || req.CityID <= 0 || req.Address == "" ||
+		req.SO2Value < 0 || req.COValue < 0 || req.SPMValue < 0 {
+		return "请提供有效的测量数据"
+	}
+	location := GeoPoint{Latitude: req.Latitude, Longitude: req.Longitude, Accuracy: req.Accuracy}
+	if !location.Valid() {
+		return "定位信息无效，经纬度必须同时提供且在有效范围内"
+	}
+	return ""
+}
+
 // SubmitAQIMeasurement 网格员提交实测AQI数据
 func SubmitAQIMeasurement(c *fiber.Ctx) error {
 	// 从JWT中获取网格员ID
@@ -19,20 +55,7 @@ func SubmitAQIMeasurement(c *fiber.Ctx) error {
 		})
 	}
 
-	// 解析请求数据
-	type SubmitAQIRequest struct {
-		FeedbackID    int    `json:"feedback_id"`    // 关联的反馈ID
-		ProvinceID    int64  `json:"province_id"`    // 省份ID
-		CityID        int64  `json:"city_id"`        // 城市ID
-		Address       string `json:"address"`        // 详细地址
-		SO2Value      int    `json:"so2_value"`      // 二氧化硫浓度值
-		COValue       int    `json:"co_value"`       // 一氧化碳浓度值
-		SPMValue      int    `json:"spm_value"`      // 悬浮颗粒物浓度值
-		Information   string `json:"information"`    // 信息描述
-		SupervisorTel string `json:"supervisor_tel"` // 反馈者手机号
-	}
-
-	var req SubmitAQIRequest
+	var req aqiMeasurementRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "无效的请求格式",
@@ -40,51 +63,131 @@ func SubmitAQIMeasurement(c *fiber.Ctx) error {
 	}
 
 	// 验证输入
-	if req.ProvinceID <= 0 || req.CityID <= 0 || req.Address == "" || 
-	   req.SO2Value < 0 || req.COValue < 0 || req.SPMValue < 0 {
+	if message := validateAQIMeasurement(req); message != "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "请提供有效的测量数据",
+			"error": message,
 		})
 	}
 
-	// 根据浓度值确定各项指标的级别
-	so2Level, err := getAQILevelForPollutant("so2", req.SO2Value)
+	data, message, err := saveAQIMeasurement(gmID, req, time.Now())
+	if err == errFeedbackNotInProgress {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": fmt.Sprintf("计算二氧化硫级别失败: %v", err),
+			"error": err.Error(),
 		})
 	}
 
+	return c.JSON(fiber.Map{
+		"message": message,
+		"data":    data,
+	})
+}
+
+// savedMeasurement 已在事务中保存的实测数据，事务提交后调用 publish 推送实时事件
+type savedMeasurement struct {
+	id             int64
+	req            aqiMeasurementRequest
+	aqiID          int
+	aqi            models.Aqi
+	groupConfirmed int64
+	measuredAt     time.Time
+	data           fiber.Map
+	message        string
+}
+
+// saveAQIMeasurement 在一个事务中保存已校验的实测数据，measuredAt 为测量时间，返回响应数据和提示信息
+// 关联的反馈不是当前网格员处理中的任务时返回 errFeedbackNotInProgress，不保存实测数据
+func saveAQIMeasurement(gmID interface{}, req aqiMeasurementRequest, measuredAt time.Time) (fiber.Map, string, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, "", fmt.Errorf("启动数据库事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	saved, err := saveAQIMeasurementTx(tx, gmID, req, measuredAt)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, "", fmt.Errorf("提交数据库事务失败: %v", err)
+	}
+	saved.publish()
+	return saved.data, saved.message, nil
+}
+
+// saveAQIMeasurementTx 在事务中保存实测数据
+// 关联反馈时同时确认反馈、记录完成实测事件、确认所在事件组并通知监督员和外部系统，任何一步失败时由调用方回滚
+func saveAQIMeasurementTx(tx *sql.Tx, gmID interface{}, req aqiMeasurementRequest, measuredAt time.Time) (*savedMeasurement, error) {
+	location := GeoPoint{Latitude: req.Latitude, Longitude: req.Longitude, Accuracy: req.Accuracy}
+	latitude, longitude, accuracy := location.dbValues()
+
+	// 反馈带有定位时，校验实测位置是否在反馈位置附近，超出范围或缺少定位的提交标记为待审核
+	fenceState := fenceStateNone
+	var fenceDistance interface{}
+	if req.FeedbackID > 0 {
+		var feedbackLat, feedbackLng, feedbackAccuracy sql.NullFloat64
+		err := tx.QueryRow(
+			"SELECT latitude, longitude, location_accuracy FROM aqi_feedback WHERE af_id = ?",
+			req.FeedbackID,
+		).Scan(&feedbackLat, &feedbackLng, &feedbackAccuracy)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("查询反馈位置失败: %v", err)
+		}
+		if err == nil && feedbackLat.Valid && feedbackLng.Valid {
+			fenceState = fenceStatePending
+			if location.Present() {
+				distance := haversineDistance(feedbackLat.Float64, feedbackLng.Float64, *req.Latitude, *req.Longitude)
+				fenceDistance = int(math.Round(distance))
+
+				// 允许的偏差为配置的半径加上两次定位的精度
+				tolerance := geofenceRadius() + feedbackAccuracy.Float64
+				if req.Accuracy != nil {
+					tolerance += *req.Accuracy
+				}
+				if distance <= tolerance {
+					fenceState = fenceStateInside
+				}
+			}
+		}
+	}
+
+	// 根据浓度值确定各项指标的级别
+	so2Level, err := getAQILevelForPollutant("so2", req.SO2Value)
+	if err != nil {
+		return nil, fmt.Errorf("计算二氧化硫级别失败: %v", err)
+	}
+
 	coLevel, err := getAQILevelForPollutant("co", req.COValue)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": fmt.Sprintf("计算一氧化碳级别失败: %v", err),
-		})
+		return nil, fmt.Errorf("计算一氧化碳级别失败: %v", err)
 	}
 
 	spmLevel, err := getAQILevelForPollutant("spm", req.SPMValue)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": fmt.Sprintf("计算悬浮颗粒物级别失败: %v", err),
-		})
+		return nil, fmt.Errorf("计算悬浮颗粒物级别失败: %v", err)
 	}
 
 	// 确定综合AQI级别（取三者中的最高级别）
 	aqiID := getMaxLevel(so2Level, coLevel, spmLevel)
 
-	// 获取当前日期和时间
-	now := time.Now()
-	confirmDate := now.Format("2006-01-02")
-	confirmTime := now.Format("15:04:05")
+	confirmDate := measuredAt.Format("2006-01-02")
+	confirmTime := measuredAt.Format("15:04:05")
 
 	// 如果提供了反馈ID，则更新对应反馈的状态为已确认(2)
 	if req.FeedbackID > 0 {
-		updateQuery := "UPDATE aqi_feedback SET state = 2 WHERE af_id = ? AND gm_id = ?"
-		_, err = database.DB.Exec(updateQuery, req.FeedbackID, gmID)
+		updateQuery := "UPDATE aqi_feedback SET state = 2 WHERE af_id = ? AND gm_id = ? AND state = 1"
+		result, err := tx.Exec(updateQuery, req.FeedbackID, gmID)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": fmt.Sprintf("更新反馈状态失败: %v", err),
-			})
+			return nil, fmt.Errorf("更新反馈状态失败: %v", err)
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return nil, fmt.Errorf("更新反馈状态失败: %v", err)
+		} else if affected == 0 {
+			return nil, errFeedbackNotInProgress
 		}
 	}
 
@@ -95,57 +198,134 @@ func SubmitAQIMeasurement(c *fiber.Ctx) error {
 			so2_value, so2_level, co_value, co_level, 
 			spm_value, spm_level, aqi_id, 
 			confirm_date, confirm_time, gm_id, 
-			fd_id, information
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			fd_id, information, af_id,
+			latitude, longitude, location_accuracy,
+			fence_distance, fence_state
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	result, err := database.DB.Exec(
+	result, err := tx.Exec(
 		insertQuery,
 		req.ProvinceID, req.CityID, req.Address,
 		req.SO2Value, so2Level, req.COValue, coLevel,
 		req.SPMValue, spmLevel, aqiID,
 		confirmDate, confirmTime, gmID,
-		req.SupervisorTel, req.Information,
+		req.SupervisorTel, req.Information, req.FeedbackID,
+		latitude, longitude, accuracy,
+		fenceDistance, fenceState,
 	)
 
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": fmt.Sprintf("保存AQI数据失败: %v", err),
-		})
+		return nil, fmt.Errorf("保存AQI数据失败: %v", err)
 	}
 
 	// 获取新插入记录的ID
 	id, _ := result.LastInsertId()
 
+	// 记录任务完成实测事件，用于统计响应时间
+	if req.FeedbackID > 0 {
+		if _, err := recordTaskEvent(tx, req.FeedbackID, gmID, taskEventMeasured, measuredAt, location, fenceDistance, ""); err != nil {
+			return nil, fmt.Errorf("记录任务事件失败: %v", err)
+		}
+	}
+
+	// 反馈属于事件组时，本次实测同时确认组内其他反馈
+	var groupConfirmed int64
+	if req.FeedbackID > 0 {
+		groupConfirmed, err = confirmIncidentGroup(tx, req.FeedbackID, gmID, id, measuredAt)
+		if err != nil {
+			return nil, fmt.Errorf("确认事件组失败: %v", err)
+		}
+	}
+
 	// 查询AQI级别信息
 	var aqi models.Aqi
 	aqiQuery := "SELECT aqi_id, chinese_explain, color FROM aqi WHERE aqi_id = ?"
-	err = database.DB.QueryRow(aqiQuery, aqiID).Scan(&aqi.AqiID, &aqi.ChineseExplain, &aqi.Color)
+	err = tx.QueryRow(aqiQuery, aqiID).Scan(&aqi.AqiID, &aqi.ChineseExplain, &aqi.Color)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": fmt.Sprintf("获取AQI级别信息失败: %v", err),
-		})
+		return nil, fmt.Errorf("获取AQI级别信息失败: %v", err)
 	}
 
-	return c.JSON(fiber.Map{
-		"message": "AQI数据提交成功",
-		"data": fiber.Map{
-			"id":             id,
-			"so2_value":      req.SO2Value,
-			"so2_level":      so2Level,
-			"co_value":       req.COValue,
-			"co_level":       coLevel,
-			"spm_value":      req.SPMValue,
-			"spm_level":      spmLevel,
-			"aqi_id":         aqiID,
-			"aqi_level":      aqi.ChineseExplain,
-			"aqi_color":      aqi.Color,
-			"confirm_date":   confirmDate,
-			"confirm_time":   confirmTime,
-			"feedback_id":    req.FeedbackID,
-			"supervisor_tel": req.SupervisorTel,
+	// 通知监督员反馈已确认，与确认结果一起提交
+	if req.FeedbackID > 0 {
+		if err := notifyFeedbackConfirmed(tx, req.FeedbackID, groupConfirmed > 0, aqi, measuredAt); err != nil {
+			return nil, fmt.Errorf("写入反馈确认通知失败: %v", err)
+		}
+	}
+
+	message := "AQI数据提交成功"
+	if fenceState == fenceStatePending {
+		message = "AQI数据提交成功，实测位置超出反馈位置范围或缺少定位，已提交管理员审核"
+	}
+
+	// 通知订阅了实测数据的外部系统，与实测数据一起提交
+	measurement := fiber.Map{
+		"statistics_id": id,
+		"feedback_id":   req.FeedbackID,
+		"province_id":   req.ProvinceID,
+		"city_id":       req.CityID,
+		"address":       req.Address,
+		"so2_value":     req.SO2Value,
+		"so2_level":     so2Level,
+		"co_value":      req.COValue,
+		"co_level":      coLevel,
+		"spm_value":     req.SPMValue,
+		"spm_level":     spmLevel,
+		"aqi_id":        aqiID,
+		"aqi_level":     aqi.ChineseExplain,
+		"confirm_date":  confirmDate,
+		"confirm_time":  confirmTime,
+		"latitude":      req.Latitude,
+		"longitude":     req.Longitude,
+	}
+	if err := enqueueMeasurementWebhooks(tx, measurement, req.ProvinceID, aqiID, req.FeedbackID, groupConfirmed, measuredAt); err != nil {
+		return nil, fmt.Errorf("写入外部回调失败: %v", err)
+	}
+
+	return &savedMeasurement{
+		id:             id,
+		req:            req,
+		aqiID:          aqiID,
+		aqi:            aqi,
+		groupConfirmed: groupConfirmed,
+		measuredAt:     measuredAt,
+		data: fiber.Map{
+			"id":               id,
+			"so2_value":        req.SO2Value,
+			"so2_level":        so2Level,
+			"co_value":         req.COValue,
+			"co_level":         coLevel,
+			"spm_value":        req.SPMValue,
+			"spm_level":        spmLevel,
+			"aqi_id":           aqiID,
+			"aqi_level":        aqi.ChineseExplain,
+			"aqi_color":        aqi.Color,
+			"confirm_date":     confirmDate,
+			"confirm_time":     confirmTime,
+			"feedback_id":      req.FeedbackID,
+			"supervisor_tel":   req.SupervisorTel,
+			"fence_state":      fenceState,
+			"fence_state_text": getFenceStateText(fenceState),
+			"fence_distance":   fenceDistance,
+			"group_confirmed":  groupConfirmed,
 		},
-	})
+		message: message,
+	}, nil
+}
+
+// publish 在事务提交后推送实时事件并评估告警规则，失败时只记录日志
+func (m *savedMeasurement) publish() {
+	req := m.req
+	if req.FeedbackID > 0 {
+		publishFeedbackConfirmed(req.FeedbackID, m.groupConfirmed > 0)
+	}
+
+	// 推送实时统计数据变化
+	publishStatsDelta(m.aqiID, 1)
+
+	if err := evaluateMeasurementAlerts(m.id, req.ProvinceID, req.CityID, m.measuredAt); err != nil {
+		log.Printf("评估告警规则失败: %v", err)
+	}
 }
 
 // getAQILevelForPollutant 根据污染物浓度值确定其AQI级别
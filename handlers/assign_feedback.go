@@ -3,6 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"epss-backend/database"
+	"epss-backend/realtime"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -72,7 +73,7 @@ func AssignFeedback(c *fiber.Ctx) error {
         })
     }
     
-    // 2. 检查网格员是否存在且处于工作状态
+    // 2. 检查网格员是否存在且当前可以接受指派（根据签到、班次和请假判断）
     var gridMember struct {
         GmID       int
         ProvinceID int
@@ -81,14 +82,14 @@ func AssignFeedback(c *fiber.Ctx) error {
     }
     
     err = tx.QueryRow(
-        "SELECT gm_id, province_id, city_id, state FROM grid_member WHERE gm_id = ? AND state = 0",
+        "SELECT gm_id, province_id, city_id, state FROM grid_member WHERE gm_id = ?",
         req.GridMemberID,
     ).Scan(&gridMember.GmID, &gridMember.ProvinceID, &gridMember.CityID, &gridMember.State)
     
     if err != nil {
         if err == sql.ErrNoRows {
             return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-                "error": "网格员不存在或不处于工作状态",
+                "error": "网格员不存在",
             })
         }
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -97,6 +98,20 @@ func AssignFeedback(c *fiber.Ctx) error {
         })
     }
     
+    availability, err := checkMemberAvailability(tx, gridMember.GmID, gridMember.State, now)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "error": "查询网格员可用状态失败",
+            "details": err.Error(),
+        })
+    }
+    if !availability.Available {
+        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+            "error": unavailableMessage(availability),
+            "availability": availability,
+        })
+    }
+    
     // 3. 检查网格员负责区域是否与反馈信息区域匹配（如果不是异地指派）
     if !req.RemoteAssign && (gridMember.ProvinceID != feedback.ProvinceID || gridMember.CityID != feedback.CityID) {
         return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -117,6 +132,32 @@ func AssignFeedback(c *fiber.Ctx) error {
         })
     }
     
+    // 5. 记录指派日志
+    err = insertAssignLog(tx, req.FeedbackID, 0, req.GridMemberID, c.Locals("user_id"), assignDate, assignTime, req.Remarks)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "error": "记录指派日志失败",
+            "details": err.Error(),
+        })
+    }
+    
+    // 6. 反馈属于事件组时，组内其他未完成的反馈一并指派给该网格员
+    groupFeedbackIDs, err := assignIncidentGroupInTx(tx, req.FeedbackID, req.GridMemberID, c.Locals("user_id"), assignDate, assignTime, req.Remarks)
+    if err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "error": "指派事件组失败",
+            "details": err.Error(),
+        })
+    }
+    
+    // 7. 通知网格员有新的任务
+    if err := notifyTaskAssigned(tx, req.FeedbackID, req.GridMemberID, now); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+            "error": "写入通知失败",
+            "details": err.Error(),
+        })
+    }
+    
     // 提交事务
     if err := tx.Commit(); err != nil {
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -125,6 +166,13 @@ func AssignFeedback(c *fiber.Ctx) error {
         })
     }
     
+    // 推送指派事件
+    assignedIDs := []int64{int64(req.FeedbackID)}
+    for _, id := range groupFeedbackIDs {
+        assignedIDs = append(assignedIDs, int64(id))
+    }
+    publishFeedbackEvents(realtime.TopicFeedbackAssigned, assignedIDs)
+    
     // 返回成功响应
     return c.JSON(fiber.Map{
         "data": fiber.Map{
@@ -132,8 +180,29 @@ func AssignFeedback(c *fiber.Ctx) error {
             "grid_member_id": req.GridMemberID,
             "assign_date": assignDate,
             "assign_time": assignTime,
+            "group_feedback_ids": groupFeedbackIDs,
             "success": true,
             "message": "任务已成功指派给网格员",    
         },
     })
-}
\ No newline at end of file
+}
+
+// insertAssignLog 在事务中记录一次指派操作，prevGmID为0表示首次指派
+func insertAssignLog(tx *sql.Tx, feedbackID, prevGmID, gmID int, adminID interface{}, assignDate, assignTime, remarks string) error {
+    var remarksValue interface{}
+    if remarks != "" {
+        remarksValue = remarks
+    }
+
+    // 记录指派时是否为异地指派，网格员之后调整负责区域不影响绩效统计
+    _, err := tx.Exec(`
+        INSERT INTO feedback_assign_log (af_id, prev_gm_id, gm_id, admin_id, assign_date, assign_time, remarks, remote_assign)
+        VALUES (?, ?, ?, ?, ?, ?, ?, IFNULL((
+            SELECT gm.province_id <> af.province_id OR gm.city_id <> af.city_id
+            FROM grid_member gm JOIN aqi_feedback af ON af.af_id = ?
+            WHERE gm.gm_id = ?
+        ), 0))`,
+        feedbackID, prevGmID, gmID, adminID, assignDate, assignTime, remarksValue, feedbackID, gmID,
+    )
+    return err
+}
@@ -0,0 +1,469 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/database"
+	"epss-backend/realtime"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 批量指派单次最多处理的反馈数量
+const maxBatchAssignSize = 200
+
+// assignTarget 指派目标网格员的基本信息
+type assignTarget struct {
+	GmID         int
+	ProvinceID   int
+	CityID       int
+	Availability memberAvailability
+}
+
+// BatchAssignFeedback 批量指派或改派反馈任务
+// 所有条目在同一个事务中处理，单个条目校验失败不会影响其他条目，结果逐条返回
+func BatchAssignFeedback(c *fiber.Ctx) error {
+	var req struct {
+		FeedbackIDs  []int  `json:"feedback_ids"`
+		GridMemberID int    `json:"grid_member_id"`
+		Remarks      string `json:"remarks"`
+		RemoteAssign bool   `json:"remote_assign"` // 是否允许异地指派
+		Reassign     bool   `json:"reassign"`      // 是否允许改派已指派的任务
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+	}
+
+	// 参数验证
+	if len(req.FeedbackIDs) == 0 || req.GridMemberID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "反馈ID列表不能为空，网格员ID必须为正整数",
+		})
+	}
+	if len(req.FeedbackIDs) > maxBatchAssignSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "单次批量指派的反馈数量过多",
+		})
+	}
+
+	now := time.Now()
+	assignDate := now.Format("2006-01-02")
+	assignTime := now.Format("15:04:05")
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "启动数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	// 检查目标网格员是否存在且处于工作状态
+	target, err := loadAssignTarget(tx, req.GridMemberID, now)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "网格员不存在",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询网格员信息失败",
+			"details": err.Error(),
+		})
+	}
+	if !target.Availability.Available {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":        unavailableMessage(target.Availability),
+			"availability": target.Availability,
+		})
+	}
+
+	adminID := c.Locals("user_id")
+	results := make([]fiber.Map, 0, len(req.FeedbackIDs))
+	seen := make(map[int]bool)
+	successCount := 0
+
+	groupAssigned := make(map[int]bool)
+
+	for _, feedbackID := range req.FeedbackIDs {
+		if seen[feedbackID] {
+			results = append(results, assignItemResult(feedbackID, 0, false, "反馈ID重复"))
+			continue
+		}
+		seen[feedbackID] = true
+
+		if groupAssigned[feedbackID] {
+			results = append(results, assignItemResult(feedbackID, 0, true, "任务已随事件组一并指派"))
+			successCount++
+			continue
+		}
+
+		result, err := assignFeedbackInTx(tx, feedbackID, target, req.Remarks, req.RemoteAssign, req.Reassign, adminID, assignDate, assignTime)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":       "批量指派失败",
+				"feedback_id": feedbackID,
+				"details":     err.Error(),
+			})
+		}
+		if result["success"] == true {
+			successCount++
+		}
+		markGroupAssigned(groupAssigned, result)
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "提交数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+
+	publishFeedbackEvents(realtime.TopicFeedbackAssigned, assignedFeedbackIDs(results))
+
+	return c.JSON(fiber.Map{
+		"data": fiber.Map{
+			"grid_member_id": req.GridMemberID,
+			"assign_date":    assignDate,
+			"assign_time":    assignTime,
+			"total":          len(req.FeedbackIDs),
+			"success_count":  successCount,
+			"failed_count":   len(req.FeedbackIDs) - successCount,
+			"results":        results,
+		},
+	})
+}
+
+// TransferGridMemberTasks 将一个网格员名下所有未完成的任务转交给另一个网格员（如网格员离岗）
+func TransferGridMemberTasks(c *fiber.Ctx) error {
+	var req struct {
+		FromGridMemberID int    `json:"from_grid_member_id"`
+		ToGridMemberID   int    `json:"to_grid_member_id"`
+		Remarks          string `json:"remarks"`
+		RemoteAssign     bool   `json:"remote_assign"` // 是否允许异地指派
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+	}
+
+	if req.FromGridMemberID <= 0 || req.ToGridMemberID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "网格员ID必须为正整数",
+		})
+	}
+	if req.FromGridMemberID == req.ToGridMemberID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "转出和转入的网格员不能相同",
+		})
+	}
+
+	now := time.Now()
+	assignDate := now.Format("2006-01-02")
+	assignTime := now.Format("15:04:05")
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "启动数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	target, err := loadAssignTarget(tx, req.ToGridMemberID, now)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "接收任务的网格员不存在",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询网格员信息失败",
+			"details": err.Error(),
+		})
+	}
+	if !target.Availability.Available {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":        "接收任务的" + unavailableMessage(target.Availability),
+			"availability": target.Availability,
+		})
+	}
+
+	// 查询转出网格员名下所有已指派未确认的任务
+	rows, err := tx.Query(
+		"SELECT af_id FROM aqi_feedback WHERE gm_id = ? AND state = 1 ORDER BY af_id FOR UPDATE",
+		req.FromGridMemberID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询网格员任务失败",
+			"details": err.Error(),
+		})
+	}
+
+	var feedbackIDs []int
+	for rows.Next() {
+		var feedbackID int
+		if err := rows.Scan(&feedbackID); err != nil {
+			rows.Close()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理任务数据失败",
+				"details": err.Error(),
+			})
+		}
+		feedbackIDs = append(feedbackIDs, feedbackID)
+	}
+	rows.Close()
+
+	adminID := c.Locals("user_id")
+	results := make([]fiber.Map, 0, len(feedbackIDs))
+	successCount := 0
+
+	groupAssigned := make(map[int]bool)
+
+	for _, feedbackID := range feedbackIDs {
+		if groupAssigned[feedbackID] {
+			results = append(results, assignItemResult(feedbackID, req.FromGridMemberID, true, "任务已随事件组一并转交"))
+			successCount++
+			continue
+		}
+
+		result, err := assignFeedbackInTx(tx, feedbackID, target, req.Remarks, req.RemoteAssign, true, adminID, assignDate, assignTime)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":       "转交任务失败",
+				"feedback_id": feedbackID,
+				"details":     err.Error(),
+			})
+		}
+		if result["success"] == true {
+			successCount++
+		}
+		markGroupAssigned(groupAssigned, result)
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "提交数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+
+	publishFeedbackEvents(realtime.TopicFeedbackAssigned, assignedFeedbackIDs(results))
+
+	return c.JSON(fiber.Map{
+		"data": fiber.Map{
+			"from_grid_member_id": req.FromGridMemberID,
+			"to_grid_member_id":   req.ToGridMemberID,
+			"assign_date":         assignDate,
+			"assign_time":         assignTime,
+			"total":               len(feedbackIDs),
+			"success_count":       successCount,
+			"failed_count":        len(feedbackIDs) - successCount,
+			"results":             results,
+		},
+	})
+}
+
+// GetFeedbackAssignLog 获取指定反馈的指派历史
+func GetFeedbackAssignLog(c *fiber.Ctx) error {
+	feedbackID := c.Params("id")
+	if feedbackID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "缺少反馈ID",
+		})
+	}
+
+	query := `
+		SELECT
+			l.log_id, l.af_id, l.prev_gm_id, l.gm_id, l.admin_id,
+			l.assign_date, l.assign_time, l.remarks,
+			IFNULL(pgm.gm_name, '') as prev_grid_member_name,
+			IFNULL(gm.gm_name, '') as grid_member_name,
+			IFNULL(a.admin_code, '') as admin_code
+		FROM
+			feedback_assign_log l
+		LEFT JOIN
+			grid_member pgm ON l.prev_gm_id = pgm.gm_id
+		LEFT JOIN
+			grid_member gm ON l.gm_id = gm.gm_id
+		LEFT JOIN
+			admins a ON l.admin_id = a.admin_id
+		WHERE
+			l.af_id = ?
+		ORDER BY
+			l.log_id ASC
+	`
+
+	rows, err := database.DB.Query(query, feedbackID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取指派记录失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var logList []fiber.Map
+	for rows.Next() {
+		var entry struct {
+			LogID, AfID, PrevGmID, GmID, AdminID int64
+			AssignDate, AssignTime               string
+			Remarks                              sql.NullString
+		}
+		var prevGridMemberName, gridMemberName, adminCode string
+
+		err := rows.Scan(
+			&entry.LogID, &entry.AfID, &entry.PrevGmID, &entry.GmID, &entry.AdminID,
+			&entry.AssignDate, &entry.AssignTime, &entry.Remarks,
+			&prevGridMemberName, &gridMemberName, &adminCode,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理指派记录失败",
+				"details": err.Error(),
+			})
+		}
+
+		logList = append(logList, fiber.Map{
+			"id":                    entry.LogID,
+			"feedback_id":           entry.AfID,
+			"prev_gm_id":            entry.PrevGmID,
+			"prev_grid_member_name": prevGridMemberName,
+			"gm_id":                 entry.GmID,
+			"grid_member_name":      gridMemberName,
+			"admin_id":              entry.AdminID,
+			"admin_code":            adminCode,
+			"assign_date":           entry.AssignDate,
+			"assign_time":           entry.AssignTime,
+			"remarks":               entry.Remarks.String,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": logList,
+	})
+}
+
+// loadAssignTarget 查询网格员及其当前是否可以接受指派，不存在时返回sql.ErrNoRows
+func loadAssignTarget(tx *sql.Tx, gridMemberID int, now time.Time) (assignTarget, error) {
+	var target assignTarget
+	var state int
+	err := tx.QueryRow(
+		"SELECT gm_id, province_id, city_id, state FROM grid_member WHERE gm_id = ?",
+		gridMemberID,
+	).Scan(&target.GmID, &target.ProvinceID, &target.CityID, &state)
+	if err != nil {
+		return target, err
+	}
+	target.Availability, err = checkMemberAvailability(tx, target.GmID, state, now)
+	return target, err
+}
+
+// assignFeedbackInTx 在事务中指派单条反馈
+// 业务校验失败时返回success为false的结果，仅在数据库出错时返回error
+func assignFeedbackInTx(tx *sql.Tx, feedbackID int, target assignTarget, remarks string, remoteAssign, reassign bool, adminID interface{}, assignDate, assignTime string) (fiber.Map, error) {
+	if feedbackID <= 0 {
+		return assignItemResult(feedbackID, 0, false, "反馈ID必须为正整数"), nil
+	}
+
+	var feedback struct {
+		ProvinceID int
+		CityID     int
+		GmID       int
+		State      int
+	}
+	err := tx.QueryRow(
+		"SELECT province_id, city_id, gm_id, state FROM aqi_feedback WHERE af_id = ? FOR UPDATE",
+		feedbackID,
+	).Scan(&feedback.ProvinceID, &feedback.CityID, &feedback.GmID, &feedback.State)
+	if err == sql.ErrNoRows {
+		return assignItemResult(feedbackID, 0, false, "反馈信息不存在"), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case feedback.State == 2:
+		return assignItemResult(feedbackID, feedback.GmID, false, "反馈信息已确认，不能指派"), nil
+	case feedback.State == 1 && !reassign:
+		return assignItemResult(feedbackID, feedback.GmID, false, "反馈信息已被指派，如需改派请开启改派选项"), nil
+	case feedback.State == 1 && feedback.GmID == target.GmID:
+		return assignItemResult(feedbackID, feedback.GmID, false, "反馈信息已指派给该网格员"), nil
+	case feedback.State != 0 && feedback.State != 1:
+		return assignItemResult(feedbackID, feedback.GmID, false, "反馈信息状态不允许指派"), nil
+	}
+
+	if !remoteAssign && (target.ProvinceID != feedback.ProvinceID || target.CityID != feedback.CityID) {
+		return assignItemResult(feedbackID, feedback.GmID, false, "网格员负责区域与反馈信息区域不匹配，如需异地指派请开启异地指派选项"), nil
+	}
+
+	// 首次指派时原网格员编号为0
+	prevGmID := 0
+	if feedback.State == 1 {
+		prevGmID = feedback.GmID
+	}
+
+	_, err = tx.Exec(
+		"UPDATE aqi_feedback SET gm_id = ?, assign_date = ?, assign_time = ?, state = 1, remarks = ? WHERE af_id = ?",
+		target.GmID, assignDate, assignTime, remarks, feedbackID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertAssignLog(tx, feedbackID, prevGmID, target.GmID, adminID, assignDate, assignTime, remarks); err != nil {
+		return nil, err
+	}
+
+	groupFeedbackIDs, err := assignIncidentGroupInTx(tx, feedbackID, target.GmID, adminID, assignDate, assignTime, remarks)
+	if err != nil {
+		return nil, err
+	}
+
+	assignedAt, _ := parseFeedbackTime(assignDate, assignTime)
+	if err := notifyTaskAssigned(tx, feedbackID, target.GmID, assignedAt); err != nil {
+		return nil, err
+	}
+
+	message := "任务已成功指派给网格员"
+	if prevGmID > 0 {
+		message = "任务已成功改派给网格员"
+	}
+	result := assignItemResult(feedbackID, prevGmID, true, message)
+	if len(groupFeedbackIDs) > 0 {
+		result["group_feedback_ids"] = groupFeedbackIDs
+	}
+	return result, nil
+}
+
+// markGroupAssigned 记录随事件组一并指派的反馈，避免在同一批次中重复处理
+func markGroupAssigned(groupAssigned map[int]bool, result fiber.Map) {
+	if ids, ok := result["group_feedback_ids"].([]int); ok {
+		for _, id := range ids {
+			groupAssigned[id] = true
+		}
+	}
+}
+
+// assignItemResult 构建单条指派结果
+func assignItemResult(feedbackID, prevGmID int, success bool, message string) fiber.Map {
+	return fiber.Map{
+		"feedback_id": feedbackID,
+		"prev_gm_id":  prevGmID,
+		"success":     success,
+		"message":     message,
+	}
+}
@@ -0,0 +1,589 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"epss-backend/config"
+	"epss-backend/database"
+	"epss-backend/models"
+	"epss-backend/storage"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 单个附件的最大大小（字节）
+const maxAttachmentSize = 10 << 20
+
+// 缩略图最长边的像素数
+const thumbnailMaxSide = 256
+
+// 生成缩略图时允许的最大原图像素数，防止解码超大图片耗尽内存
+const thumbnailMaxPixels = 40000000
+
+// allowedAttachmentTypes 允许上传的文件类型及保存时使用的扩展名
+// 文件类型根据文件内容识别，不信任客户端提供的Content-Type
+var allowedAttachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+}
+
+// UploadFeedbackAttachment 公众监督员为自己的反馈上传附件（如现场照片）
+func UploadFeedbackAttachment(c *fiber.Ctx) error {
+	telID, ok := c.Locals("user_tel_id").(string)
+	if !ok || telID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	feedbackID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil || feedbackID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的反馈ID",
+		})
+	}
+
+	// 只能为自己提交的反馈上传附件
+	var count int
+	err = database.DB.QueryRow("SELECT COUNT(*) FROM aqi_feedback WHERE af_id = ? AND tel_id = ?", feedbackID, telID).Scan(&count)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "数据库查询失败",
+		})
+	}
+	if count == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "反馈信息不存在",
+		})
+	}
+
+	return saveAttachment(c, feedbackID, 0, "supervisor", telID)
+}
+
+// UploadMeasurementAttachment 网格员为自己提交的实测数据上传附件（如仪器读数照片）
+func UploadMeasurementAttachment(c *fiber.Ctx) error {
+	gmID := c.Locals("user_gm_id")
+	if gmID == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	statisticsID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil || statisticsID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的实测数据ID",
+		})
+	}
+
+	// 只能为自己提交的实测数据上传附件，同时关联实测数据对应的反馈
+	var afID int64
+	err = database.DB.QueryRow("SELECT af_id FROM statistics WHERE id = ? AND gm_id = ?", statisticsID, gmID).Scan(&afID)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "实测数据不存在",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "数据库查询失败",
+		})
+	}
+
+	return saveAttachment(c, afID, statisticsID, "member", fmt.Sprint(gmID))
+}
+
+// GetFeedbackAttachments 获取反馈的附件列表，返回带签名的下载地址
+// 管理员可查看全部反馈，监督员只能查看自己的反馈，网格员只能查看指派给自己的反馈
+func GetFeedbackAttachments(c *fiber.Ctx) error {
+	feedbackID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil || feedbackID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的反馈ID",
+		})
+	}
+
+	allowed, err := canAccessFeedback(c, feedbackID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "数据库查询失败",
+		})
+	}
+	if !allowed {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "反馈信息不存在",
+		})
+	}
+
+	return listAttachments(c, "af_id", feedbackID)
+}
+
+// GetMeasurementAttachments 获取实测数据的附件列表，返回带签名的下载地址
+// 管理员可查看全部数据，网格员只能查看自己提交的数据，监督员只能查看自己反馈对应的数据
+func GetMeasurementAttachments(c *fiber.Ctx) error {
+	statisticsID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil || statisticsID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的实测数据ID",
+		})
+	}
+
+	allowed, err := canAccessStatistics(c, statisticsID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "数据库查询失败",
+		})
+	}
+	if !allowed {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "实测数据不存在",
+		})
+	}
+
+	return listAttachments(c, "statistics_id", statisticsID)
+}
+
+// DownloadAttachment 通过签名地址下载附件或缩略图
+// 签名地址由附件列表接口生成，在有效期内无需再携带token，便于直接用于<img>标签
+func DownloadAttachment(c *fiber.Ctx) error {
+	attID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil || attID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的附件ID",
+		})
+	}
+
+	variant := c.Query("variant", "file")
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || (variant != "file" && variant != "thumb") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的下载地址",
+		})
+	}
+
+	expected := signAttachment(attID, expires, variant)
+	if !hmac.Equal([]byte(expected), []byte(c.Query("signature"))) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "下载地址签名无效",
+		})
+	}
+	if time.Now().Unix() > expires {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "下载地址已过期",
+		})
+	}
+
+	var attachment models.Attachment
+	err = database.DB.QueryRow(
+		"SELECT att_id, file_name, content_type, storage_key, thumb_key FROM attachment WHERE att_id = ?",
+		attID,
+	).Scan(&attachment.AttID, &attachment.FileName, &attachment.ContentType, &attachment.StorageKey, &attachment.ThumbKey)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "附件不存在",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "数据库查询失败",
+		})
+	}
+
+	key := attachment.StorageKey
+	contentType := attachment.ContentType
+	if variant == "thumb" {
+		if !attachment.ThumbKey.Valid {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "该附件没有缩略图",
+			})
+		}
+		key = attachment.ThumbKey.String
+		contentType = "image/jpeg"
+	}
+
+	reader, err := storage.Default.Open(key)
+	if err == storage.ErrNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "附件文件不存在",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "读取附件失败",
+			"details": err.Error(),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("inline", map[string]string{"filename": attachment.FileName}))
+	c.Set(fiber.HeaderCacheControl, "private, max-age=300")
+	return c.SendStream(reader)
+}
+
+// saveAttachment 校验上传的文件，保存到存储后端并写入附件记录
+func saveAttachment(c *fiber.Ctx, afID, statisticsID int64, uploaderType, uploaderID string) error {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "请通过file字段上传文件",
+		})
+	}
+
+	if fileHeader.Size <= 0 || fileHeader.Size > maxAttachmentSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("文件大小必须在1字节到%dMB之间", maxAttachmentSize>>20),
+		})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "读取上传文件失败",
+		})
+	}
+	content, err := io.ReadAll(io.LimitReader(file, maxAttachmentSize+1))
+	file.Close()
+	if err != nil || int64(len(content)) > maxAttachmentSize {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "读取上传文件失败",
+		})
+	}
+
+	// 根据文件内容识别类型
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(content))
+	ext, ok := allowedAttachmentTypes[contentType]
+	if !ok {
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
+			"error": "不支持的文件类型，仅支持JPEG、PNG、GIF、WebP图片以及PDF和文本文件",
+		})
+	}
+
+	now := time.Now()
+	baseKey, err := newAttachmentKey(now)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "生成存储路径失败",
+		})
+	}
+
+	storageKey := baseKey + ext
+	if err := storage.Default.Save(storageKey, bytes.NewReader(content), int64(len(content)), contentType); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "保存附件失败",
+			"details": err.Error(),
+		})
+	}
+
+	// 图片生成缩略图，失败时不影响附件上传
+	var thumbKey interface{}
+	if thumb, err := makeThumbnail(content); err != nil {
+		log.Printf("生成缩略图失败: %v", err)
+	} else if thumb != nil {
+		key := baseKey + "_thumb.jpg"
+		if err := storage.Default.Save(key, bytes.NewReader(thumb), int64(len(thumb)), "image/jpeg"); err != nil {
+			log.Printf("保存缩略图失败: %v", err)
+		} else {
+			thumbKey = key
+		}
+	}
+
+	fileName := filepath.Base(fileHeader.Filename)
+	if len([]rune(fileName)) > 200 {
+		fileName = string([]rune(fileName)[:200])
+	}
+	uploadDate := now.Format("2006-01-02")
+	uploadTime := now.Format("15:04:05")
+
+	result, err := database.DB.Exec(`
+		INSERT INTO attachment
+		(af_id, statistics_id, file_name, content_type, file_size, storage_key, thumb_key, uploader_type, uploader_id, upload_date, upload_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, afID, statisticsID, fileName, contentType, len(content), storageKey, thumbKey, uploaderType, uploaderID, uploadDate, uploadTime)
+	if err != nil {
+		storage.Default.Delete(storageKey)
+		if thumbKey != nil {
+			storage.Default.Delete(thumbKey.(string))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "保存附件记录失败",
+			"details": err.Error(),
+		})
+	}
+
+	attID, _ := result.LastInsertId()
+
+	data := fiber.Map{
+		"id":            attID,
+		"af_id":         afID,
+		"statistics_id": statisticsID,
+		"file_name":     fileName,
+		"content_type":  contentType,
+		"file_size":     len(content),
+		"upload_date":   uploadDate,
+		"upload_time":   uploadTime,
+		"url":           attachmentURL(attID, "file"),
+		"thumb_url":     "",
+	}
+	if thumbKey != nil {
+		data["thumb_url"] = attachmentURL(attID, "thumb")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "附件上传成功",
+		"data":    data,
+	})
+}
+
+// listAttachments 按关联字段查询附件列表
+func listAttachments(c *fiber.Ctx, column string, id int64) error {
+	query := `
+		SELECT att_id, af_id, statistics_id, file_name, content_type, file_size,
+		       thumb_key, uploader_type, uploader_id, upload_date, upload_time
+		FROM attachment
+		WHERE ` + column + ` = ?
+		ORDER BY att_id ASC
+	`
+
+	rows, err := database.DB.Query(query, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取附件列表失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var attachmentList []fiber.Map
+	for rows.Next() {
+		var attachment models.Attachment
+		err := rows.Scan(
+			&attachment.AttID, &attachment.AfID, &attachment.StatisticsID, &attachment.FileName,
+			&attachment.ContentType, &attachment.FileSize, &attachment.ThumbKey,
+			&attachment.UploaderType, &attachment.UploaderID, &attachment.UploadDate, &attachment.UploadTime,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理附件数据失败",
+				"details": err.Error(),
+			})
+		}
+
+		thumbURL := ""
+		if attachment.ThumbKey.Valid {
+			thumbURL = attachmentURL(attachment.AttID, "thumb")
+		}
+
+		attachmentList = append(attachmentList, fiber.Map{
+			"id":            attachment.AttID,
+			"af_id":         attachment.AfID,
+			"statistics_id": attachment.StatisticsID,
+			"file_name":     attachment.FileName,
+			"content_type":  attachment.ContentType,
+			"file_size":     attachment.FileSize,
+			"uploader_type": attachment.UploaderType,
+			"upload_date":   attachment.UploadDate,
+			"upload_time":   attachment.UploadTime,
+			"url":           attachmentURL(attachment.AttID, "file"),
+			"thumb_url":     thumbURL,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": attachmentList,
+	})
+}
+
+// canAccessFeedback 判断当前用户是否可以查看指定反馈
+func canAccessFeedback(c *fiber.Ctx, feedbackID int64) (bool, error) {
+	var query string
+	var owner interface{}
+
+	switch c.Locals("user_type") {
+	case "admin":
+		query = "SELECT COUNT(*) FROM aqi_feedback WHERE af_id = ?"
+	case "supervisor":
+		query = "SELECT COUNT(*) FROM aqi_feedback WHERE af_id = ? AND tel_id = ?"
+		owner = c.Locals("user_tel_id")
+	case "member":
+		query = "SELECT COUNT(*) FROM aqi_feedback WHERE af_id = ? AND gm_id = ?"
+		owner = c.Locals("user_id")
+	default:
+		return false, nil
+	}
+
+	args := []interface{}{feedbackID}
+	if owner != nil {
+		args = append(args, owner)
+	}
+
+	var count int
+	err := database.DB.QueryRow(query, args...).Scan(&count)
+	return count > 0, err
+}
+
+// canAccessStatistics 判断当前用户是否可以查看指定实测数据
+func canAccessStatistics(c *fiber.Ctx, statisticsID int64) (bool, error) {
+	var query string
+	var owner interface{}
+
+	switch c.Locals("user_type") {
+	case "admin":
+		query = "SELECT COUNT(*) FROM statistics WHERE id = ?"
+	case "member":
+		query = "SELECT COUNT(*) FROM statistics WHERE id = ? AND gm_id = ?"
+		owner = c.Locals("user_id")
+	case "supervisor":
+		query = `SELECT COUNT(*) FROM statistics s
+			JOIN aqi_feedback af ON s.af_id = af.af_id
+			WHERE s.id = ? AND af.tel_id = ?`
+		owner = c.Locals("user_tel_id")
+	default:
+		return false, nil
+	}
+
+	args := []interface{}{statisticsID}
+	if owner != nil {
+		args = append(args, owner)
+	}
+
+	var count int
+	err := database.DB.QueryRow(query, args...).Scan(&count)
+	return count > 0, err
+}
+
+// newAttachmentKey 生成按日期分目录的随机存储路径（不含扩展名）
+func newAttachmentKey(now time.Time) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("attachments/%s/%s", now.Format("2006/01/02"), hex.EncodeToString(random)), nil
+}
+
+// attachmentURL 生成带有效期和签名的附件下载地址
+// 有效期通过 ATTACHMENT_URL_TTL 配置，默认15分钟
+func attachmentURL(attID int64, variant string) string {
+	ttl := 15 * time.Minute
+	if value := config.Config("ATTACHMENT_URL_TTL"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			ttl = parsed
+		}
+	}
+
+	expires := time.Now().Add(ttl).Unix()
+	return fmt.Sprintf("/api/v1/public/attachment/download/%d?variant=%s&expires=%d&signature=%s",
+		attID, variant, expires, signAttachment(attID, expires, variant))
+}
+
+// signAttachment 计算附件下载地址的签名
+// 签名密钥通过 ATTACHMENT_URL_SECRET 配置，未配置时使用 JWT_SECRET
+func signAttachment(attID, expires int64, variant string) string {
+	secret := config.Config("ATTACHMENT_URL_SECRET")
+	if secret == "" {
+		secret = config.Config("JWT_SECRET")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d:%d:%s", attID, expires, variant)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// makeThumbnail 为图片生成JPEG缩略图，非图片或不支持解码的格式返回nil
+func makeThumbnail(content []byte) ([]byte, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		// WebP等标准库无法解码的格式不生成缩略图
+		return nil, nil
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > thumbnailMaxPixels {
+		return nil, fmt.Errorf("图片尺寸不支持生成缩略图: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("解码%s图片失败: %v", format, err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleDown(src, thumbnailMaxSide), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleDown 按区域平均等比缩小图片，使最长边不超过maxSide
+func scaleDown(src image.Image, maxSide int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	dstW, dstH := srcW, srcH
+	if srcW >= srcH && srcW > maxSide {
+		dstW, dstH = maxSide, srcH*maxSide/srcW
+	} else if srcH > srcW && srcH > maxSide {
+		dstW, dstH = srcW*maxSide/srcH, maxSide
+	}
+	if dstW < 1 {
+		dstW = 1
+	}
+	if dstH < 1 {
+		dstH = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := bounds.Min.Y + (y+1)*srcH/dstH
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := bounds.Min.X + (x+1)*srcW/dstW
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			// 颜色值为预乘透明度的结果，透明部分以白色背景填充（JPEG不支持透明）
+			background := 0xffff - a/n
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r/n + background),
+				G: uint16(g/n + background),
+				B: uint16(b/n + background),
+				A: 0xffff,
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,609 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/database"
+	"epss-backend/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 网格员状态
+const (
+	memberStateWorking = 0 // 工作状态
+	memberStateOff     = 1 // 非工作状态，启用考勤后由考勤系统自动维护
+	memberStateOther   = 2 // 其它，不参与自动维护
+)
+
+// 签到时允许提前于班次开始的时间
+const shiftEarlyCheckIn = 60 * time.Minute
+
+// 请假期间的不可用原因，签到时据此拒绝
+const availabilityOnLeave = "请假中"
+
+// sqlQueryer *sql.DB 和 *sql.Tx 共有的查询方法
+type sqlQueryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// memberAvailability 网格员当前是否可以接受指派
+type memberAvailability struct {
+	Available bool   `json:"available"`
+	Reason    string `json:"reason"`
+	Until     string `json:"until"`    // 可用状态预计发生变化的时间，未知时为空
+	ShiftID   int64  `json:"shift_id"` // 当前所在的班次，0 表示不在班次内
+
+	managed bool // 是否由考勤系统维护网格员状态，未启用考勤时保留手动设置的状态
+}
+
+// shiftWindow 计算班次的起止时间，下班时间不晚于上班时间时视为次日下班
+func shiftWindow(shift models.MemberShift) (time.Time, time.Time, error) {
+	start, err := parseFeedbackTime(shift.ShiftDate, shift.StartTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := parseFeedbackTime(shift.ShiftDate, shift.EndTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, nil
+}
+
+// findShiftAt 查找在指定时间进行中的班次，early 为允许提前的时间
+// 跨天班次可能属于前一天，因此同时查询前一天和当天的班次
+func findShiftAt(q sqlQueryer, gmID interface{}, at time.Time, early time.Duration) (models.MemberShift, time.Time, time.Time, bool, error) {
+	rows, err := q.Query(
+		"SELECT shift_id, gm_id, shift_date, start_time, end_time FROM member_shift WHERE gm_id = ? AND shift_date IN (?, ?) ORDER BY shift_date, start_time",
+		gmID, at.AddDate(0, 0, -1).Format("2006-01-02"), at.Format("2006-01-02"),
+	)
+	if err != nil {
+		return models.MemberShift{}, time.Time{}, time.Time{}, false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var shift models.MemberShift
+		if err := rows.Scan(&shift.ShiftID, &shift.GmID, &shift.ShiftDate, &shift.StartTime, &shift.EndTime); err != nil {
+			return models.MemberShift{}, time.Time{}, time.Time{}, false, err
+		}
+		start, end, err := shiftWindow(shift)
+		if err != nil {
+			continue
+		}
+		if !at.Before(start.Add(-early)) && at.Before(end) {
+			return shift, start, end, true, nil
+		}
+	}
+	return models.MemberShift{}, time.Time{}, time.Time{}, false, rows.Err()
+}
+
+// nextShiftStart 查询下一个班次的开始时间，没有时返回空字符串
+func nextShiftStart(q sqlQueryer, gmID interface{}, now time.Time) (string, error) {
+	var next string
+	err := q.QueryRow(`
+		SELECT CONCAT(shift_date, ' ', start_time) FROM member_shift
+		WHERE gm_id = ? AND CONCAT(shift_date, ' ', start_time) > ?
+		ORDER BY shift_date, start_time LIMIT 1
+	`, gmID, now.Format(feedbackTimeLayout)).Scan(&next)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return next, err
+}
+
+// checkMemberAvailability 根据请假、签到和班次判断网格员当前是否可以接受指派
+// 判断顺序：其它状态 > 已批准的请假 > 从未排班和签到（未启用考勤）时按网格员状态 > 已签到 > 班次内且未签退 > 非工作时间
+func checkMemberAvailability(q sqlQueryer, gmID interface{}, state int, now time.Time) (memberAvailability, error) {
+	if state == memberStateOther {
+		return memberAvailability{Reason: "其它状态"}, nil
+	}
+	nowText := now.Format(feedbackTimeLayout)
+
+	var records int
+	err := q.QueryRow(
+		"SELECT (SELECT COUNT(*) FROM member_shift WHERE gm_id = ?) + (SELECT COUNT(*) FROM member_attendance WHERE gm_id = ?)",
+		gmID, gmID,
+	).Scan(&records)
+	if err != nil {
+		return memberAvailability{}, err
+	}
+	managed := records > 0
+
+	var leaveEnd string
+	err = q.QueryRow(`
+		SELECT CONCAT(end_date, ' ', end_time) FROM member_leave
+		WHERE gm_id = ? AND state = ? AND CONCAT(start_date, ' ', start_time) <= ? AND CONCAT(end_date, ' ', end_time) > ?
+		ORDER BY end_date DESC, end_time DESC LIMIT 1
+	`, gmID, leaveStateApproved, nowText, nowText).Scan(&leaveEnd)
+	if err == nil {
+		return memberAvailability{Reason: availabilityOnLeave, Until: leaveEnd, managed: managed}, nil
+	}
+	if err != sql.ErrNoRows {
+		return memberAvailability{}, err
+	}
+
+	if !managed {
+		if state == memberStateWorking {
+			return memberAvailability{Available: true, Reason: "未启用考勤"}, nil
+		}
+		return memberAvailability{Reason: "非工作状态"}, nil
+	}
+
+	var shiftID int64
+	err = q.QueryRow(
+		"SELECT shift_id FROM member_attendance WHERE gm_id = ? AND check_out_date IS NULL ORDER BY att_id DESC LIMIT 1",
+		gmID,
+	).Scan(&shiftID)
+	if err == nil {
+		return memberAvailability{Available: true, Reason: "已签到", ShiftID: shiftID, managed: true}, nil
+	}
+	if err != sql.ErrNoRows {
+		return memberAvailability{}, err
+	}
+
+	shift, _, end, inShift, err := findShiftAt(q, gmID, now, 0)
+	if err != nil {
+		return memberAvailability{}, err
+	}
+	if inShift {
+		// 本班次已签退的不再视为可用
+		var checkedOut int
+		err = q.QueryRow(
+			"SELECT COUNT(*) FROM member_attendance WHERE gm_id = ? AND shift_id = ? AND check_out_date IS NOT NULL",
+			gmID, shift.ShiftID,
+		).Scan(&checkedOut)
+		if err != nil {
+			return memberAvailability{}, err
+		}
+		if checkedOut == 0 {
+			return memberAvailability{Available: true, Reason: "班次内", Until: end.Format(feedbackTimeLayout), ShiftID: shift.ShiftID, managed: true}, nil
+		}
+	}
+
+	next, err := nextShiftStart(q, gmID, now)
+	if err != nil {
+		return memberAvailability{}, err
+	}
+	reason := "非工作时间"
+	if inShift {
+		reason = "已签退"
+	}
+	return memberAvailability{Reason: reason, Until: next, managed: true}, nil
+}
+
+// unavailableMessage 生成网格员不可指派时的提示信息
+func unavailableMessage(availability memberAvailability) string {
+	message := "网格员当前不可指派（" + availability.Reason + "）"
+	if availability.Until != "" {
+		message += "，预计" + availability.Until + "后状态变化"
+	}
+	return message
+}
+
+// refreshMemberState 根据当前可用情况更新网格员状态，其它状态和未启用考勤的网格员保持不变
+func refreshMemberState(gmID interface{}, now time.Time) (memberAvailability, error) {
+	var state int
+	if err := database.DB.QueryRow("SELECT state FROM grid_member WHERE gm_id = ?", gmID).Scan(&state); err != nil {
+		return memberAvailability{}, err
+	}
+	availability, err := checkMemberAvailability(database.DB, gmID, state, now)
+	if err != nil || state == memberStateOther || !availability.managed {
+		return availability, err
+	}
+
+	newState := memberStateOff
+	if availability.Available {
+		newState = memberStateWorking
+	}
+	if newState != state {
+		if _, err := database.DB.Exec("UPDATE grid_member SET state = ? WHERE gm_id = ?", newState, gmID); err != nil {
+			return availability, err
+		}
+	}
+	return availability, nil
+}
+
+// StartAttendanceMonitor 启动后台任务，定期根据班次和请假自动切换网格员的工作状态
+// 检查间隔通过 ATTENDANCE_CHECK_INTERVAL 配置（如 30s、5m），默认每分钟一次
+func StartAttendanceMonitor() {
+	startPeriodicTask("网格员考勤状态更新", "ATTENDANCE_CHECK_INTERVAL", time.Minute, syncMemberStates)
+}
+
+// syncMemberStates 更新所有由考勤系统维护的网格员状态
+func syncMemberStates(now time.Time) error {
+	rows, err := database.DB.Query("SELECT gm_id FROM grid_member WHERE state IN (?, ?)", memberStateWorking, memberStateOff)
+	if err != nil {
+		return err
+	}
+	var memberIDs []int64
+	for rows.Next() {
+		var gmID int64
+		if err := rows.Scan(&gmID); err != nil {
+			rows.Close()
+			return err
+		}
+		memberIDs = append(memberIDs, gmID)
+	}
+	rows.Close()
+
+	for _, gmID := range memberIDs {
+		if _, err := refreshMemberState(gmID, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckIn 网格员签到，可携带latitude和longitude记录签到位置
+// 班次开始前一小时内或班次进行中签到时关联该班次
+func CheckIn(c *fiber.Ctx) error {
+	gmID := c.Locals("user_gm_id")
+	if gmID == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	var req struct {
+		GeoPoint
+		Remarks string `json:"remarks"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "请求数据格式错误",
+				"details": err.Error(),
+			})
+		}
+	}
+	if !req.GeoPoint.Valid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "定位信息无效",
+		})
+	}
+
+	now := time.Now()
+	checkInDate := now.Format("2006-01-02")
+	checkInTime := now.Format("15:04:05")
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "启动数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	// 锁定网格员记录，防止重复签到
+	var state int
+	if err := tx.QueryRow("SELECT state FROM grid_member WHERE gm_id = ? FOR UPDATE", gmID).Scan(&state); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询网格员信息失败",
+			"details": err.Error(),
+		})
+	}
+
+	var openCount int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM member_attendance WHERE gm_id = ? AND check_out_date IS NULL", gmID).Scan(&openCount); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询考勤记录失败",
+			"details": err.Error(),
+		})
+	}
+	if openCount > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "已签到，请先签退",
+		})
+	}
+
+	availability, err := checkMemberAvailability(tx, gmID, state, now)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询网格员可用状态失败",
+			"details": err.Error(),
+		})
+	}
+	if availability.Reason == availabilityOnLeave {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "请假期间不能签到",
+			"until": availability.Until,
+		})
+	}
+
+	shift, shiftStart, _, inShift, err := findShiftAt(tx, gmID, now, shiftEarlyCheckIn)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询班次失败",
+			"details": err.Error(),
+		})
+	}
+	lateMinutes := 0
+	if inShift && now.After(shiftStart) {
+		lateMinutes = int(now.Sub(shiftStart).Minutes())
+	}
+
+	var remarks interface{}
+	if req.Remarks != "" {
+		remarks = req.Remarks
+	}
+	lat, lng, _ := req.GeoPoint.dbValues()
+
+	result, err := tx.Exec(
+		"INSERT INTO member_attendance (gm_id, shift_id, check_in_date, check_in_time, latitude, longitude, remarks) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		gmID, shift.ShiftID, checkInDate, checkInTime, lat, lng, remarks,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "签到失败",
+			"details": err.Error(),
+		})
+	}
+	attID, _ := result.LastInsertId()
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "提交数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+
+	availability, err = refreshMemberState(gmID, now)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "更新网格员状态失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "签到成功",
+		"data": fiber.Map{
+			"id":            attID,
+			"shift_id":      shift.ShiftID,
+			"check_in_date": checkInDate,
+			"check_in_time": checkInTime,
+			"late_minutes":  lateMinutes,
+			"availability":  availability,
+		},
+	})
+}
+
+// CheckOut 网格员签退
+func CheckOut(c *fiber.Ctx) error {
+	gmID := c.Locals("user_gm_id")
+	if gmID == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	now := time.Now()
+	checkOutDate := now.Format("2006-01-02")
+	checkOutTime := now.Format("15:04:05")
+
+	var attendance models.MemberAttendance
+	err := database.DB.QueryRow(
+		"SELECT att_id, shift_id, check_in_date, check_in_time FROM member_attendance WHERE gm_id = ? AND check_out_date IS NULL ORDER BY att_id DESC LIMIT 1",
+		gmID,
+	).Scan(&attendance.AttID, &attendance.ShiftID, &attendance.CheckInDate, &attendance.CheckInTime)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "尚未签到",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询考勤记录失败",
+			"details": err.Error(),
+		})
+	}
+
+	result, err := database.DB.Exec(
+		"UPDATE member_attendance SET check_out_date = ?, check_out_time = ? WHERE att_id = ? AND check_out_date IS NULL",
+		checkOutDate, checkOutTime, attendance.AttID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "签退失败",
+			"details": err.Error(),
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "尚未签到",
+		})
+	}
+
+	// 班次结束前签退时记录早退时间
+	earlyLeaveMinutes := 0
+	if attendance.ShiftID > 0 {
+		var shift models.MemberShift
+		err := database.DB.QueryRow(
+			"SELECT shift_id, shift_date, start_time, end_time FROM member_shift WHERE shift_id = ?",
+			attendance.ShiftID,
+		).Scan(&shift.ShiftID, &shift.ShiftDate, &shift.StartTime, &shift.EndTime)
+		if err == nil {
+			if _, end, err := shiftWindow(shift); err == nil && now.Before(end) {
+				earlyLeaveMinutes = int(end.Sub(now).Minutes())
+			}
+		}
+	}
+
+	availability, err := refreshMemberState(gmID, now)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "更新网格员状态失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "签退成功",
+		"data": fiber.Map{
+			"id":                  attendance.AttID,
+			"shift_id":            attendance.ShiftID,
+			"check_in_date":       attendance.CheckInDate,
+			"check_in_time":       attendance.CheckInTime,
+			"check_out_date":      checkOutDate,
+			"check_out_time":      checkOutTime,
+			"early_leave_minutes": earlyLeaveMinutes,
+			"availability":        availability,
+		},
+	})
+}
+
+// GetAttendanceList 获取考勤记录，支持from、to参数按签到日期筛选
+// 网格员只能查看自己的记录，管理员可通过gm_id参数筛选
+func GetAttendanceList(c *fiber.Ctx) error {
+	query := `
+		SELECT
+			ma.att_id, ma.gm_id, ma.shift_id, ma.check_in_date, ma.check_in_time,
+			ma.check_out_date, ma.check_out_time, ma.latitude, ma.longitude, ma.remarks,
+			IFNULL(gm.gm_name, '') as gm_name,
+			IFNULL(ms.shift_date, '') as shift_date,
+			IFNULL(ms.start_time, '') as shift_start_time,
+			IFNULL(ms.end_time, '') as shift_end_time
+		FROM
+			member_attendance ma
+		LEFT JOIN
+			grid_member gm ON ma.gm_id = gm.gm_id
+		LEFT JOIN
+			member_shift ms ON ma.shift_id = ms.shift_id
+		WHERE 1 = 1
+	`
+	params := []interface{}{}
+
+	if c.Locals("user_type") == "member" {
+		query += " AND ma.gm_id = ?"
+		params = append(params, c.Locals("user_gm_id"))
+	} else if gmID := c.Query("gm_id"); gmID != "" {
+		query += " AND ma.gm_id = ?"
+		params = append(params, gmID)
+	}
+	if from := c.Query("from"); from != "" {
+		query += " AND ma.check_in_date >= ?"
+		params = append(params, from)
+	}
+	if to := c.Query("to"); to != "" {
+		query += " AND ma.check_in_date <= ?"
+		params = append(params, to)
+	}
+	query += " ORDER BY ma.att_id DESC"
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取考勤记录失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var attendanceList []fiber.Map
+	for rows.Next() {
+		var attendance models.MemberAttendance
+		var gmName, shiftDate, shiftStart, shiftEnd string
+
+		err := rows.Scan(
+			&attendance.AttID, &attendance.GmID, &attendance.ShiftID, &attendance.CheckInDate, &attendance.CheckInTime,
+			&attendance.CheckOutDate, &attendance.CheckOutTime, &attendance.Latitude, &attendance.Longitude, &attendance.Remarks,
+			&gmName, &shiftDate, &shiftStart, &shiftEnd,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理考勤记录失败",
+				"details": err.Error(),
+			})
+		}
+
+		attendanceList = append(attendanceList, fiber.Map{
+			"id":               attendance.AttID,
+			"gm_id":            attendance.GmID,
+			"gm_name":          gmName,
+			"shift_id":         attendance.ShiftID,
+			"shift_date":       shiftDate,
+			"shift_start_time": shiftStart,
+			"shift_end_time":   shiftEnd,
+			"check_in_date":    attendance.CheckInDate,
+			"check_in_time":    attendance.CheckInTime,
+			"check_out_date":   attendance.CheckOutDate.String,
+			"check_out_time":   attendance.CheckOutTime.String,
+			"latitude":         nullFloatValue(attendance.Latitude),
+			"longitude":        nullFloatValue(attendance.Longitude),
+			"remarks":          attendance.Remarks.String,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": attendanceList,
+	})
+}
+
+// GetMemberAvailabilityList 获取网格员当前是否可以接受指派，支持province_id和city_id参数
+func GetMemberAvailabilityList(c *fiber.Ctx) error {
+	query := "SELECT gm_id, gm_name, province_id, city_id, state FROM grid_member"
+	params := []interface{}{}
+
+	if provinceID := c.Query("province_id"); provinceID != "" {
+		query += " WHERE province_id = ?"
+		params = append(params, provinceID)
+
+		if cityID := c.Query("city_id"); cityID != "" {
+			query += " AND city_id = ?"
+			params = append(params, cityID)
+		}
+	}
+	query += " ORDER BY gm_id"
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取网格员列表失败",
+			"details": err.Error(),
+		})
+	}
+	var members []models.GridMember
+	for rows.Next() {
+		var member models.GridMember
+		if err := rows.Scan(&member.GmID, &member.GmName, &member.ProvinceID, &member.CityID, &member.State); err != nil {
+			rows.Close()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理网格员数据失败",
+				"details": err.Error(),
+			})
+		}
+		members = append(members, member)
+	}
+	rows.Close()
+
+	now := time.Now()
+	var memberList []fiber.Map
+	for _, member := range members {
+		availability, err := checkMemberAvailability(database.DB, member.GmID, member.State, now)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "查询网格员可用状态失败",
+				"details": err.Error(),
+			})
+		}
+
+		memberList = append(memberList, fiber.Map{
+			"gm_id":        member.GmID,
+			"gm_name":      member.GmName,
+			"province_id":  member.ProvinceID,
+			"city_id":      member.CityID,
+			"state":        member.State,
+			"availability": availability,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": memberList,
+	})
+}
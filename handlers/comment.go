@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"epss-backend/database"
+	"epss-backend/models"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 单条留言的最大长度（字符）
+const maxCommentLength = 1000
+
+// commentReader 返回当前用户在留言中的身份：类型和编号
+func commentReader(c *fiber.Ctx) (string, string, bool) {
+	switch userType := c.Locals("user_type"); userType {
+	case "admin", "member":
+		userID := c.Locals("user_id")
+		if userID == nil {
+			return "", "", false
+		}
+		return userType.(string), fmt.Sprint(userID), true
+	case "supervisor":
+		telID, ok := c.Locals("user_tel_id").(string)
+		if !ok || telID == "" {
+			return "", "", false
+		}
+		return "supervisor", telID, true
+	default:
+		return "", "", false
+	}
+}
+
+// unreadCommentsColumn 生成统计未读留言数量的子查询，需要依次传入阅读者类型、阅读者编号、阅读者类型、阅读者编号
+// 监督员只统计对其可见的留言，自己发表的留言不计入未读
+func unreadCommentsColumn(readerType string) string {
+	column := `(SELECT COUNT(*) FROM feedback_comment fc
+			LEFT JOIN feedback_comment_read fr ON fr.af_id = fc.af_id AND fr.reader_type = ? AND fr.reader_id = ?
+			WHERE fc.af_id = af.af_id AND fc.fc_id > IFNULL(fr.last_fc_id, 0)
+			AND NOT (fc.author_type = ? AND fc.author_id = ?)`
+	if readerType == "supervisor" {
+		column += " AND fc.internal = 0"
+	}
+	return column + ") as unread_comments"
+}
+
+// unreadCommentsArgs 返回 unreadCommentsColumn 子查询的参数
+func unreadCommentsArgs(readerType, readerID string) []interface{} {
+	return []interface{}{readerType, readerID, readerType, readerID}
+}
+
+// GetFeedbackComments 获取反馈的留言列表，并将其标记为已读
+// 监督员只能看到非内部留言
+func GetFeedbackComments(c *fiber.Ctx) error {
+	feedbackID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的反馈ID",
+		})
+	}
+
+	readerType, readerID, ok := commentReader(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	allowed, err := canAccessFeedback(c, feedbackID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询反馈信息失败",
+			"details": err.Error(),
+		})
+	}
+	if !allowed {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "反馈信息不存在或无权查看",
+		})
+	}
+
+	query := `
+		SELECT
+			fc.fc_id, fc.af_id, fc.author_type, fc.author_id, fc.content, fc.internal,
+			fc.comment_date, fc.comment_time,
+			COALESCE(a.admin_code, gm.gm_name, s.real_name, '') as author_name
+		FROM
+			feedback_comment fc
+		LEFT JOIN
+			admins a ON fc.author_type = 'admin' AND fc.author_id = a.admin_id
+		LEFT JOIN
+			grid_member gm ON fc.author_type = 'member' AND fc.author_id = gm.gm_id
+		LEFT JOIN
+			supervisor s ON fc.author_type = 'supervisor' AND fc.author_id = s.tel_id
+		WHERE
+			fc.af_id = ?
+	`
+	if readerType == "supervisor" {
+		query += " AND fc.internal = 0"
+	}
+	query += " ORDER BY fc.fc_id ASC"
+
+	rows, err := database.DB.Query(query, feedbackID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取留言列表失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var commentList []fiber.Map
+	var lastID int64
+	for rows.Next() {
+		var comment models.FeedbackComment
+		var authorName string
+
+		err := rows.Scan(
+			&comment.FcID, &comment.AfID, &comment.AuthorType, &comment.AuthorID, &comment.Content, &comment.Internal,
+			&comment.CommentDate, &comment.CommentTime, &authorName,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理留言数据失败",
+				"details": err.Error(),
+			})
+		}
+		lastID = comment.FcID
+
+		commentList = append(commentList, fiber.Map{
+			"id":           comment.FcID,
+			"feedback_id":  comment.AfID,
+			"author_type":  comment.AuthorType,
+			"author_id":    comment.AuthorID,
+			"author_name":  authorName,
+			"content":      comment.Content,
+			"internal":     comment.Internal == 1,
+			"mine":         comment.AuthorType == readerType && comment.AuthorID == readerID,
+			"comment_date": comment.CommentDate,
+			"comment_time": comment.CommentTime,
+		})
+	}
+	rows.Close()
+
+	// 记录已读位置
+	if lastID > 0 {
+		_, err = database.DB.Exec(
+			`INSERT INTO feedback_comment_read (af_id, reader_type, reader_id, last_fc_id) VALUES (?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE last_fc_id = GREATEST(last_fc_id, VALUES(last_fc_id))`,
+			feedbackID, readerType, readerID, lastID,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "更新留言已读状态失败",
+				"details": err.Error(),
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"data": commentList,
+	})
+}
+
+// AddFeedbackComment 在反馈下发表留言
+// 管理员和网格员可以发表仅内部可见的留言，监督员的留言始终对所有参与者可见
+func AddFeedbackComment(c *fiber.Ctx) error {
+	feedbackID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的反馈ID",
+		})
+	}
+
+	authorType, authorID, ok := commentReader(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	var req struct {
+		Content  string `json:"content"`
+		Internal bool   `json:"internal"` // 是否仅管理员和网格员可见
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的请求数据",
+		})
+	}
+
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Content == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "留言内容不能为空",
+		})
+	}
+	if len([]rune(req.Content)) > maxCommentLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("留言内容不能超过%d个字符", maxCommentLength),
+		})
+	}
+	if req.Internal && authorType == "supervisor" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "监督员不能发表内部留言",
+		})
+	}
+
+	allowed, err := canAccessFeedback(c, feedbackID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询反馈信息失败",
+			"details": err.Error(),
+		})
+	}
+	if !allowed {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "反馈信息不存在或无权留言",
+		})
+	}
+
+	internal := 0
+	if req.Internal {
+		internal = 1
+	}
+
+	now := time.Now()
+	commentDate := now.Format("2006-01-02")
+	commentTime := now.Format("15:04:05")
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "启动数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO feedback_comment (af_id, author_type, author_id, content, internal, comment_date, comment_time) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		feedbackID, authorType, authorID, req.Content, internal, commentDate, commentTime,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "发表留言失败",
+			"details": err.Error(),
+		})
+	}
+	commentID, _ := result.LastInsertId()
+
+	if err := notifyCommentAdded(tx, feedbackID, authorType, authorID, req.Content, req.Internal, now); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "写入站内通知失败",
+			"details": err.Error(),
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "提交事务失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "留言发表成功",
+		"data": fiber.Map{
+			"id":           commentID,
+			"feedback_id":  feedbackID,
+			"author_type":  authorType,
+			"author_id":    authorID,
+			"content":      req.Content,
+			"internal":     req.Internal,
+			"comment_date": commentDate,
+			"comment_time": commentTime,
+		},
+	})
+}
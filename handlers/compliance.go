@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"epss-backend/database"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 达标判定的指标
+const (
+	complianceMetricSO2 = "so2" // 二氧化硫浓度
+	complianceMetricCO  = "co"  // 一氧化碳浓度
+	complianceMetricSPM = "spm" // 悬浮颗粒物浓度
+	complianceMetricAQI = "aqi" // AQI级别
+)
+
+// defaultExceedLevel 数据库中未配置时，达到三级（轻度污染）视为超标
+const defaultExceedLevel = 3
+
+// complianceRule 一个指标的超标判定：实测值大于 Limit，即达到 ExceedLevel 级别时视为超标
+type complianceRule struct {
+	Metric          string `json:"metric"`
+	ExceedLevel     int    `json:"exceed_level"`
+	ExceedLevelText string `json:"exceed_level_text"`
+	Limit           int    `json:"limit"` // AQI指标的限值为级别编号
+}
+
+// complianceStandard 各指标的超标判定标准，超标限值由 aqi 表的级别浓度限值得出
+type complianceStandard struct {
+	SO2 complianceRule `json:"so2"`
+	CO  complianceRule `json:"co"`
+	SPM complianceRule `json:"spm"`
+	AQI complianceRule `json:"aqi"`
+}
+
+// aqiExceeds 判断AQI级别是否超标
+func (s complianceStandard) aqiExceeds(aqiID int) bool {
+	return aqiID > s.AQI.Limit
+}
+
+// aqiLevelLimits 一个AQI级别的说明和各污染物浓度最大限值
+type aqiLevelLimits struct {
+	text                  string
+	so2Max, coMax, spmMax int
+}
+
+// loadComplianceStandard 读取各指标视为超标的级别，并按 aqi 表计算超标限值
+func loadComplianceStandard() (complianceStandard, error) {
+	var standard complianceStandard
+
+	exceedLevels := map[string]int{
+		complianceMetricSO2: defaultExceedLevel,
+		complianceMetricCO:  defaultExceedLevel,
+		complianceMetricSPM: defaultExceedLevel,
+		complianceMetricAQI: defaultExceedLevel,
+	}
+	rows, err := database.DB.Query("SELECT metric, exceed_level FROM compliance_standard")
+	if err != nil {
+		return standard, err
+	}
+	for rows.Next() {
+		var metric string
+		var level int
+		if err := rows.Scan(&metric, &level); err != nil {
+			rows.Close()
+			return standard, err
+		}
+		if _, ok := exceedLevels[metric]; ok {
+			exceedLevels[metric] = level
+		}
+	}
+	rows.Close()
+
+	levels, err := loadAQILevelLimits()
+	if err != nil {
+		return standard, err
+	}
+
+	build := func(metric string, limitOf func(aqiID int, l aqiLevelLimits) int) (complianceRule, error) {
+		level := exceedLevels[metric]
+		current, ok := levels[level]
+		previous, okPrevious := levels[level-1]
+		if !ok || !okPrevious {
+			return complianceRule{}, fmt.Errorf("%s视为超标的级别%d无效", getComplianceMetricText(metric), level)
+		}
+		return complianceRule{
+			Metric:          metric,
+			ExceedLevel:     level,
+			ExceedLevelText: current.text,
+			Limit:           limitOf(level-1, previous),
+		}, nil
+	}
+
+	if standard.SO2, err = build(complianceMetricSO2, func(_ int, l aqiLevelLimits) int { return l.so2Max }); err != nil {
+		return standard, err
+	}
+	if standard.CO, err = build(complianceMetricCO, func(_ int, l aqiLevelLimits) int { return l.coMax }); err != nil {
+		return standard, err
+	}
+	if standard.SPM, err = build(complianceMetricSPM, func(_ int, l aqiLevelLimits) int { return l.spmMax }); err != nil {
+		return standard, err
+	}
+	if standard.AQI, err = build(complianceMetricAQI, func(aqiID int, _ aqiLevelLimits) int { return aqiID }); err != nil {
+		return standard, err
+	}
+	return standard, nil
+}
+
+// loadAQILevelLimits 读取 aqi 表各级别的浓度最大限值
+func loadAQILevelLimits() (map[int]aqiLevelLimits, error) {
+	rows, err := database.DB.Query("SELECT aqi_id, chinese_explain, so2_max, co_max, spm_max FROM aqi")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	levels := make(map[int]aqiLevelLimits)
+	for rows.Next() {
+		var aqiID int
+		var l aqiLevelLimits
+		if err := rows.Scan(&aqiID, &l.text, &l.so2Max, &l.coMax, &l.spmMax); err != nil {
+			return nil, err
+		}
+		levels[aqiID] = l
+	}
+	return levels, rows.Err()
+}
+
+// GetComplianceStandard 获取各指标的超标判定标准
+func GetComplianceStandard(c *fiber.Ctx) error {
+	standard, err := loadComplianceStandard()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取超标判定标准失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": standard,
+	})
+}
+
+// UpdateComplianceStandard 设置指标视为超标的最低AQI级别，超标限值随 aqi 表的级别限值变化
+func UpdateComplianceStandard(c *fiber.Ctx) error {
+	var req struct {
+		Metric      string `json:"metric"`
+		ExceedLevel int    `json:"exceed_level"`
+		Remarks     string `json:"remarks"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+	}
+
+	switch req.Metric {
+	case complianceMetricSO2, complianceMetricCO, complianceMetricSPM, complianceMetricAQI:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "指标必须为so2、co、spm或aqi",
+		})
+	}
+
+	// 第一级以下没有限值，视为超标的级别必须从第二级开始
+	var maxLevel int
+	if err := database.DB.QueryRow("SELECT IFNULL(MAX(aqi_id), 0) FROM aqi").Scan(&maxLevel); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "数据库查询失败",
+		})
+	}
+	if req.ExceedLevel < 2 || req.ExceedLevel > maxLevel {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("视为超标的级别必须在2-%d之间", maxLevel),
+		})
+	}
+
+	var remarks interface{}
+	if req.Remarks != "" {
+		remarks = req.Remarks
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO compliance_standard (metric, exceed_level, remarks) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE exceed_level = VALUES(exceed_level), remarks = VALUES(remarks)
+	`, req.Metric, req.ExceedLevel, remarks)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "更新超标判定标准失败",
+			"details": err.Error(),
+		})
+	}
+
+	standard, err := loadComplianceStandard()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取超标判定标准失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "超标判定标准更新成功",
+		"data":    standard,
+	})
+}
+
+// 获取达标判定指标文本描述
+func getComplianceMetricText(metric string) string {
+	switch metric {
+	case complianceMetricSO2:
+		return "二氧化硫浓度"
+	case complianceMetricCO:
+		return "一氧化碳浓度"
+	case complianceMetricSPM:
+		return "悬浮颗粒物浓度"
+	case complianceMetricAQI:
+		return "AQI级别"
+	default:
+		return "未知指标"
+	}
+}
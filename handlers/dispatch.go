@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/database"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 指派优先级因素
+const (
+	dispatchFactorGrade     = "grade"     // 预估等级
+	dispatchFactorTrust     = "trust"     // 监督员可信度
+	dispatchFactorCluster   = "cluster"   // 重复反馈数量
+	dispatchFactorWaiting   = "waiting"   // 等待时间
+	dispatchFactorSensitive = "sensitive" // 敏感区域
+)
+
+// defaultDispatchWeights 数据库中未配置时使用的默认权重
+var defaultDispatchWeights = map[string]float64{
+	dispatchFactorGrade:     40,
+	dispatchFactorTrust:     20,
+	dispatchFactorCluster:   15,
+	dispatchFactorWaiting:   15,
+	dispatchFactorSensitive: 10,
+}
+
+// 调度队列默认返回的数量
+const defaultDispatchQueueLimit = 50
+
+// dispatchCandidate 调度队列中的一条未指派反馈
+type dispatchCandidate struct {
+	AfID           int64
+	TelID          string
+	SupervisorName string
+	ProvinceID     int64
+	CityID         int64
+	ProvinceName   string
+	CityName       string
+	Address        string
+	EstimatedGrade int
+	AfDate         string
+	AfTime         string
+	Latitude       sql.NullFloat64
+	Longitude      sql.NullFloat64
+	IgID           int64
+	GroupSize      int
+}
+
+// loadDispatchWeights 读取各因素的权重，未配置的因素使用默认值
+func loadDispatchWeights() (map[string]float64, error) {
+	weights := make(map[string]float64, len(defaultDispatchWeights))
+	for factor, weight := range defaultDispatchWeights {
+		weights[factor] = weight
+	}
+
+	rows, err := database.DB.Query("SELECT factor, weight FROM dispatch_weight")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var factor string
+		var weight float64
+		if err := rows.Scan(&factor, &weight); err != nil {
+			return nil, err
+		}
+		if _, ok := weights[factor]; ok {
+			weights[factor] = weight
+		}
+	}
+	return weights, rows.Err()
+}
+
+// dispatchPriority 按权重合成优先级（0-100），各因素得分均在0-1之间
+func dispatchPriority(weights, factors map[string]float64) float64 {
+	var total, score float64
+	for factor, weight := range weights {
+		total += weight
+		score += weight * factors[factor]
+	}
+	if total == 0 {
+		return 0
+	}
+	return math.Round(score/total*10000) / 100
+}
+
+// clampUnit 将数值限制在0-1之间
+func clampUnit(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// GetDispatchQueue 获取按优先级排序的未指派反馈调度队列
+// 同一事件组的反馈只保留优先级最高的一条，指派时组内其他反馈会一并指派
+// 支持province_id、city_id和limit参数
+func GetDispatchQueue(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", defaultDispatchQueueLimit)
+	if limit <= 0 {
+		limit = defaultDispatchQueueLimit
+	}
+
+	weights, err := loadDispatchWeights()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取优先级权重失败",
+			"details": err.Error(),
+		})
+	}
+	slas, err := loadFeedbackSLA()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取时限配置失败",
+			"details": err.Error(),
+		})
+	}
+	areas, err := loadSensitiveAreas()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取敏感区域失败",
+			"details": err.Error(),
+		})
+	}
+
+	query := `
+		SELECT
+			af.af_id, af.tel_id, IFNULL(s.real_name, '') as supervisor_name,
+			af.province_id, af.city_id,
+			IFNULL(p.province_name, '') as province_name,
+			IFNULL(ct.city_name, '') as city_name,
+			af.address, af.estimated_grade, af.af_date, af.af_time,
+			af.latitude, af.longitude, af.ig_id,
+			IF(af.ig_id > 0, (SELECT COUNT(*) FROM aqi_feedback m WHERE m.ig_id = af.ig_id), 1) as group_size
+		FROM
+			aqi_feedback af
+		LEFT JOIN
+			supervisor s ON af.tel_id = s.tel_id
+		LEFT JOIN
+			grid_province p ON af.province_id = p.province_id
+		LEFT JOIN
+			grid_city ct ON af.city_id = ct.city_id
+		WHERE
+			af.state = 0
+	`
+	params := []interface{}{}
+
+	if provinceID := c.Query("province_id"); provinceID != "" {
+		query += " AND af.province_id = ?"
+		params = append(params, provinceID)
+
+		if cityID := c.Query("city_id"); cityID != "" {
+			query += " AND af.city_id = ?"
+			params = append(params, cityID)
+		}
+	}
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取调度队列失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	type queueItem struct {
+		data     fiber.Map
+		igID     int64
+		priority float64
+		waited   float64
+	}
+
+	var candidates []dispatchCandidate
+	var telIDs []string
+	for rows.Next() {
+		var f dispatchCandidate
+		err := rows.Scan(
+			&f.AfID, &f.TelID, &f.SupervisorName,
+			&f.ProvinceID, &f.CityID, &f.ProvinceName, &f.CityName,
+			&f.Address, &f.EstimatedGrade, &f.AfDate, &f.AfTime,
+			&f.Latitude, &f.Longitude, &f.IgID, &f.GroupSize,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理调度队列数据失败",
+				"details": err.Error(),
+			})
+		}
+		candidates = append(candidates, f)
+		telIDs = append(telIDs, f.TelID)
+	}
+	rows.Close()
+
+	// 只统计队列中涉及的监督员的可信度
+	reliability, err := loadSupervisorReliabilityFor(telIDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "计算公众监督员可信度失败",
+			"details": err.Error(),
+		})
+	}
+
+	now := time.Now()
+	var items []queueItem
+	for _, f := range candidates {
+		var waitedMinutes float64
+		if reportedAt, err := parseFeedbackTime(f.AfDate, f.AfTime); err == nil {
+			waitedMinutes = math.Max(0, now.Sub(reportedAt).Minutes())
+		}
+		trustScore := reliabilityOf(reliability, f.TelID).TrustScore
+
+		// 等待时间按指派时限的消耗比例计算，超过时限后得分不再增加
+		factors := map[string]float64{
+			dispatchFactorGrade:   clampUnit(float64(f.EstimatedGrade-1) / 5),
+			dispatchFactorTrust:   clampUnit(trustScore / 100),
+			dispatchFactorCluster: clampUnit(1 - 1/float64(max(f.GroupSize, 1))),
+			dispatchFactorWaiting: clampUnit(waitedMinutes / float64(slaForGrade(slas, f.EstimatedGrade).AssignMinutes)),
+		}
+
+		var nearestArea interface{}
+		var nearestDistance interface{}
+		if f.Latitude.Valid && f.Longitude.Valid {
+			if area, distance, closeness, ok := nearestSensitiveArea(areas, f.Latitude.Float64, f.Longitude.Float64); ok {
+				factors[dispatchFactorSensitive] = closeness
+				nearestArea = fiber.Map{
+					"id":        area.SaID,
+					"name":      area.SaName,
+					"type":      area.SaType,
+					"type_text": getSensitiveAreaTypeText(area.SaType),
+				}
+				nearestDistance = math.Round(distance)
+			}
+		}
+
+		priority := dispatchPriority(weights, factors)
+		for factor, value := range factors {
+			factors[factor] = math.Round(value*100) / 100
+		}
+
+		items = append(items, queueItem{
+			igID:     f.IgID,
+			priority: priority,
+			waited:   waitedMinutes,
+			data: fiber.Map{
+				"id":                 f.AfID,
+				"tel_id":             f.TelID,
+				"supervisor_name":    f.SupervisorName,
+				"province_id":        f.ProvinceID,
+				"province_name":      f.ProvinceName,
+				"city_id":            f.CityID,
+				"city_name":          f.CityName,
+				"address":            f.Address,
+				"estimated_grade":    f.EstimatedGrade,
+				"af_date":            f.AfDate,
+				"af_time":            f.AfTime,
+				"waiting_minutes":    int(waitedMinutes),
+				"latitude":           nullFloatValue(f.Latitude),
+				"longitude":          nullFloatValue(f.Longitude),
+				"incident_group_id":  f.IgID,
+				"group_size":         f.GroupSize,
+				"reporter_trust":     trustScore,
+				"sensitive_area":     nearestArea,
+				"sensitive_distance": nearestDistance,
+				"factors":            factors,
+				"priority":           priority,
+			},
+		})
+	}
+
+	// 优先级相同时等待时间长的靠前
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].priority != items[j].priority {
+			return items[i].priority > items[j].priority
+		}
+		return items[i].waited > items[j].waited
+	})
+
+	queue := []fiber.Map{}
+	seenGroups := make(map[int64]bool)
+	for _, item := range items {
+		if item.igID > 0 {
+			if seenGroups[item.igID] {
+				continue
+			}
+			seenGroups[item.igID] = true
+		}
+		queue = append(queue, item.data)
+		if len(queue) >= limit {
+			break
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"data":    queue,
+		"weights": weights,
+	})
+}
+
+// GetDispatchWeights 获取指派优先级各因素的权重
+func GetDispatchWeights(c *fiber.Ctx) error {
+	weights, err := loadDispatchWeights()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取优先级权重失败",
+			"details": err.Error(),
+		})
+	}
+
+	factors := make([]string, 0, len(weights))
+	for factor := range weights {
+		factors = append(factors, factor)
+	}
+	sort.Strings(factors)
+
+	var weightList []fiber.Map
+	for _, factor := range factors {
+		weightList = append(weightList, fiber.Map{
+			"factor":      factor,
+			"factor_text": getDispatchFactorText(factor),
+			"weight":      weights[factor],
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": weightList,
+	})
+}
+
+// UpdateDispatchWeight 更新指定因素的权重，权重为0表示不考虑该因素
+func UpdateDispatchWeight(c *fiber.Ctx) error {
+	var req struct {
+		Factor  string  `json:"factor"`
+		Weight  float64 `json:"weight"`
+		Remarks string  `json:"remarks"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+	}
+
+	if _, ok := defaultDispatchWeights[req.Factor]; !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "未知的优先级因素",
+		})
+	}
+	if req.Weight < 0 || req.Weight > 1000 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "权重必须在0-1000之间",
+		})
+	}
+
+	var remarks interface{}
+	if req.Remarks != "" {
+		remarks = req.Remarks
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO dispatch_weight (factor, weight, remarks) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE weight = VALUES(weight), remarks = VALUES(remarks)
+	`, req.Factor, req.Weight, remarks)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "更新优先级权重失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "优先级权重更新成功",
+		"data": fiber.Map{
+			"factor": req.Factor,
+			"weight": req.Weight,
+		},
+	})
+}
+
+// 获取优先级因素文本描述
+func getDispatchFactorText(factor string) string {
+	switch factor {
+	case dispatchFactorGrade:
+		return "预估等级"
+	case dispatchFactorTrust:
+		return "监督员可信度"
+	case dispatchFactorCluster:
+		return "重复反馈数量"
+	case dispatchFactorWaiting:
+		return "等待时间"
+	case dispatchFactorSensitive:
+		return "敏感区域"
+	default:
+		return "未知因素"
+	}
+}
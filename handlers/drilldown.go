@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/database"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 区县名称的后缀，地址中第一个后缀之前的部分视为区县
+var districtSuffixes = []rune{'区', '县', '旗', '市'}
+
+// 区县名称最多包含的字数，超过时视为地址中没有区县
+const maxDistrictNameLength = 8
+
+// 无法从地址中识别区县时的分组名称
+const unknownDistrict = "其他"
+
+// pollutantStats 一种污染物的超标数量、平均浓度和最大浓度
+type pollutantStats struct {
+	ExceedCount int     `json:"exceed_count"`
+	Avg         float64 `json:"avg"`
+	Max         int     `json:"max"`
+
+	sum int64
+}
+
+// drilldownStats 下钻统计中一个区域的实测数据和未完成反馈数量
+type drilldownStats struct {
+	MeasurementCount        int            `json:"measurement_count"`
+	AQIExceedCount          int            `json:"aqi_exceed_count"`
+	SO2                     pollutantStats `json:"so2"`
+	CO                      pollutantStats `json:"co"`
+	PM25                    pollutantStats `json:"pm25"`
+	OpenFeedbackCount       int            `json:"open_feedback_count"`
+	UnassignedFeedbackCount int            `json:"unassigned_feedback_count"`
+	AssignedFeedbackCount   int            `json:"assigned_feedback_count"`
+}
+
+// addMeasurement 累加一条实测数据，全部累加后调用 finish 计算平均浓度
+func (d *drilldownStats) addMeasurement(so2, co, spm, aqiID int, standard complianceStandard) {
+	d.MeasurementCount++
+	if standard.aqiExceeds(aqiID) {
+		d.AQIExceedCount++
+	}
+	for _, p := range []struct {
+		stats *pollutantStats
+		value int
+		limit int
+	}{
+		{&d.SO2, so2, standard.SO2.Limit},
+		{&d.CO, co, standard.CO.Limit},
+		{&d.PM25, spm, standard.SPM.Limit},
+	} {
+		if p.value > p.limit {
+			p.stats.ExceedCount++
+		}
+		if p.value > p.stats.Max {
+			p.stats.Max = p.value
+		}
+		p.stats.sum += int64(p.value)
+	}
+}
+
+func (d *drilldownStats) finish() {
+	if d.MeasurementCount == 0 {
+		return
+	}
+	for _, p := range []*pollutantStats{&d.SO2, &d.CO, &d.PM25} {
+		p.Avg = math.Round(float64(p.sum)/float64(d.MeasurementCount)*100) / 100
+	}
+}
+
+// addFeedback 累加一条未完成的反馈
+func (d *drilldownStats) addFeedback(state int) {
+	d.OpenFeedbackCount++
+	if state == 0 {
+		d.UnassignedFeedbackCount++
+	} else {
+		d.AssignedFeedbackCount++
+	}
+}
+
+// cityStats 城市下钻统计
+type cityStats struct {
+	CityID   int64  `json:"city_id"`
+	CityName string `json:"city_name"`
+	drilldownStats
+}
+
+// districtStats 区县下钻统计
+type districtStats struct {
+	District string `json:"district"`
+	drilldownStats
+}
+
+// addressDistrict 从地址中识别区县，地址需先经过 normalizeAddress 去除省市名称
+func addressDistrict(normalized string) string {
+	runes := []rune(normalized)
+	for i, r := range runes {
+		if i >= maxDistrictNameLength {
+			break
+		}
+		if i == 0 {
+			continue
+		}
+		for _, suffix := range districtSuffixes {
+			// “市场”不是县级市
+			if r == suffix && !(r == '市' && i+1 < len(runes) && runes[i+1] == '场') {
+				return string(runes[:i+1])
+			}
+		}
+	}
+	return unknownDistrict
+}
+
+// GetCityAQIStats 获取省份内各城市的实测数据统计，包括超标数量、各污染物的平均和最大浓度以及未完成的反馈数量
+// province_id为必填参数，支持from、to（确认日期）参数；未完成的反馈数量为当前数量，不受日期限制
+func GetCityAQIStats(c *fiber.Ctx) error {
+	db := database.DB
+
+	provinceID, err := strconv.ParseInt(c.Query("province_id"), 10, 64)
+	if err != nil || provinceID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "查询参数无效",
+			"error":   "province_id为必填的数字",
+		})
+	}
+
+	filters, filterParams, err := buildStatsFilters(c, "s")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "查询参数无效",
+			"error":   err.Error(),
+		})
+	}
+
+	var provinceName string
+	err = db.QueryRow("SELECT province_name FROM grid_province WHERE province_id = ?", provinceID).Scan(&provinceName)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "省份不存在",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取省份信息失败",
+			"error":   err.Error(),
+		})
+	}
+
+	standard, err := loadComplianceStandard()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取超标判定标准失败",
+			"error":   err.Error(),
+		})
+	}
+
+	// 没有实测数据的城市也返回，计数为0
+	query := `
+		SELECT
+			ct.city_id,
+			ct.city_name,
+			COUNT(s.id) as measurement_count,
+			SUM(CASE WHEN s.aqi_id > ? THEN 1 ELSE 0 END) as aqi_exceed_count,
+			SUM(CASE WHEN s.so2_value > ? THEN 1 ELSE 0 END) as so2_exceed_count,
+			IFNULL(AVG(s.so2_value), 0) as so2_avg,
+			IFNULL(MAX(s.so2_value), 0) as so2_max,
+			SUM(CASE WHEN s.co_value > ? THEN 1 ELSE 0 END) as co_exceed_count,
+			IFNULL(AVG(s.co_value), 0) as co_avg,
+			IFNULL(MAX(s.co_value), 0) as co_max,
+			SUM(CASE WHEN s.spm_value > ? THEN 1 ELSE 0 END) as pm25_exceed_count,
+			IFNULL(AVG(s.spm_value), 0) as pm25_avg,
+			IFNULL(MAX(s.spm_value), 0) as pm25_max
+		FROM
+			grid_city ct
+		LEFT JOIN
+			statistics s ON s.city_id = ct.city_id` + filters + `
+		WHERE
+			ct.province_id = ?
+		GROUP BY
+			ct.city_id, ct.city_name
+		ORDER BY
+			ct.city_id
+	`
+	params := append([]interface{}{standard.AQI.Limit, standard.SO2.Limit, standard.CO.Limit, standard.SPM.Limit}, filterParams...)
+	params = append(params, provinceID)
+
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取城市AQI统计数据失败",
+			"error":   err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	results := []*cityStats{}
+	cities := make(map[int64]*cityStats)
+	for rows.Next() {
+		stat := &cityStats{}
+		err := rows.Scan(
+			&stat.CityID, &stat.CityName, &stat.MeasurementCount, &stat.AQIExceedCount,
+			&stat.SO2.ExceedCount, &stat.SO2.Avg, &stat.SO2.Max,
+			&stat.CO.ExceedCount, &stat.CO.Avg, &stat.CO.Max,
+			&stat.PM25.ExceedCount, &stat.PM25.Avg, &stat.PM25.Max,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "解析城市AQI统计数据失败",
+				"error":   err.Error(),
+			})
+		}
+		for _, p := range []*pollutantStats{&stat.SO2, &stat.CO, &stat.PM25} {
+			p.Avg = math.Round(p.Avg*100) / 100
+		}
+		results = append(results, stat)
+		cities[stat.CityID] = stat
+	}
+	rows.Close()
+
+	feedbackRows, err := db.Query(
+		"SELECT city_id, state FROM aqi_feedback WHERE province_id = ? AND state IN (0, 1)",
+		provinceID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取未完成反馈数量失败",
+			"error":   err.Error(),
+		})
+	}
+	defer feedbackRows.Close()
+
+	for feedbackRows.Next() {
+		var cityID int64
+		var state int
+		if err := feedbackRows.Scan(&cityID, &state); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "解析未完成反馈数量失败",
+				"error":   err.Error(),
+			})
+		}
+		if stat, ok := cities[cityID]; ok {
+			stat.addFeedback(state)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success":       true,
+		"data":          results,
+		"province_id":   provinceID,
+		"province_name": provinceName,
+		"standard":      standard,
+	})
+}
+
+// GetDistrictAQIStats 获取城市内按地址中的区县分组的实测数据统计，字段与城市统计相同
+// city_id为必填参数，支持from、to（确认日期）参数；无法识别区县的地址归入“其他”
+func GetDistrictAQIStats(c *fiber.Ctx) error {
+	db := database.DB
+
+	cityID, err := strconv.ParseInt(c.Query("city_id"), 10, 64)
+	if err != nil || cityID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "查询参数无效",
+			"error":   "city_id为必填的数字",
+		})
+	}
+
+	filters, filterParams, err := buildStatsFilters(c, "s")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "查询参数无效",
+			"error":   err.Error(),
+		})
+	}
+
+	var provinceID int64
+	var provinceName, cityName string
+	err = db.QueryRow(`
+		SELECT ct.province_id, IFNULL(p.province_name, ''), ct.city_name
+		FROM grid_city ct
+		LEFT JOIN grid_province p ON ct.province_id = p.province_id
+		WHERE ct.city_id = ?
+	`, cityID).Scan(&provinceID, &provinceName, &cityName)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "城市不存在",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取城市信息失败",
+			"error":   err.Error(),
+		})
+	}
+
+	standard, err := loadComplianceStandard()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取超标判定标准失败",
+			"error":   err.Error(),
+		})
+	}
+
+	districts := make(map[string]*districtStats)
+	district := func(address string) *districtStats {
+		name := addressDistrict(normalizeAddress(address, provinceName, cityName))
+		stat, ok := districts[name]
+		if !ok {
+			stat = &districtStats{District: name}
+			districts[name] = stat
+		}
+		return stat
+	}
+
+	params := append([]interface{}{cityID}, filterParams...)
+	rows, err := db.Query(
+		"SELECT s.address, s.so2_value, s.co_value, s.spm_value, s.aqi_id FROM statistics s WHERE s.city_id = ?"+filters,
+		params...,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取区县AQI统计数据失败",
+			"error":   err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var address string
+		var so2, co, spm, aqiID int
+		if err := rows.Scan(&address, &so2, &co, &spm, &aqiID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "解析区县AQI统计数据失败",
+				"error":   err.Error(),
+			})
+		}
+		district(address).addMeasurement(so2, co, spm, aqiID, standard)
+	}
+	rows.Close()
+
+	feedbackRows, err := db.Query(
+		"SELECT address, state FROM aqi_feedback WHERE city_id = ? AND state IN (0, 1)",
+		cityID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取未完成反馈数量失败",
+			"error":   err.Error(),
+		})
+	}
+	defer feedbackRows.Close()
+
+	for feedbackRows.Next() {
+		var address string
+		var state int
+		if err := feedbackRows.Scan(&address, &state); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "解析未完成反馈数量失败",
+				"error":   err.Error(),
+			})
+		}
+		district(address).addFeedback(state)
+	}
+
+	// 按实测数量从多到少排列，“其他”排在最后
+	results := make([]*districtStats, 0, len(districts))
+	for _, stat := range districts {
+		stat.finish()
+		results = append(results, stat)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if (results[i].District == unknownDistrict) != (results[j].District == unknownDistrict) {
+			return results[j].District == unknownDistrict
+		}
+		if results[i].MeasurementCount != results[j].MeasurementCount {
+			return results[i].MeasurementCount > results[j].MeasurementCount
+		}
+		return results[i].District < results[j].District
+	})
+
+	return c.JSON(fiber.Map{
+		"success":       true,
+		"data":          results,
+		"province_id":   provinceID,
+		"province_name": provinceName,
+		"city_id":       cityID,
+		"city_name":     cityName,
+		"standard":      standard,
+	})
+}
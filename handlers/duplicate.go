@@ -0,0 +1,503 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/config"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 重复反馈检测的默认参数
+const (
+	defaultDuplicateWindow     = 24 * time.Hour // 只与该时间范围内的反馈比较
+	defaultDuplicateSimilarity = 0.6            // 地址相似度阈值
+	defaultDuplicateRadius     = 300.0          // 两条反馈都有定位时，距离在该半径（米）内视为同一地点
+)
+
+// 比较地址前去除的口语化描述
+var addressFillerWords = []string{"附近", "旁边", "周边", "周围", "对面", "一带", "门口", "这边", "那边", "大概", "左右"}
+
+// 行政区名称的后缀，去除后得到简称（如 河北省 -> 河北）
+var regionNameSuffixes = []string{"特别行政区", "自治区", "自治州", "省", "市", "地区", "盟"}
+
+// duplicateConfig 重复反馈检测参数
+type duplicateConfig struct {
+	Window     time.Duration
+	Similarity float64
+	Radius     float64
+}
+
+// duplicateCandidate 参与重复检测的反馈
+type duplicateCandidate struct {
+	AfID       int64
+	IgID       int64
+	GmID       int64
+	State      int
+	Address    string
+	Latitude   sql.NullFloat64
+	Longitude  sql.NullFloat64
+	ReportedAt time.Time
+}
+
+// incidentMatch 重复检测结果
+type incidentMatch struct {
+	IgID        int64 // 并入的事件组
+	DuplicateOf int64 // 判定重复的反馈
+	GmID        int64 // 事件组已指派时自动指派给的网格员，0表示未自动指派
+}
+
+// loadDuplicateConfig 读取 DUPLICATE_WINDOW、DUPLICATE_SIMILARITY、DUPLICATE_RADIUS 配置
+func loadDuplicateConfig() duplicateConfig {
+	cfg := duplicateConfig{
+		Window:     defaultDuplicateWindow,
+		Similarity: defaultDuplicateSimilarity,
+		Radius:     defaultDuplicateRadius,
+	}
+	if value := config.Config("DUPLICATE_WINDOW"); value != "" {
+		if window, err := time.ParseDuration(value); err == nil && window > 0 {
+			cfg.Window = window
+		}
+	}
+	if value := config.Config("DUPLICATE_SIMILARITY"); value != "" {
+		if similarity, err := strconv.ParseFloat(value, 64); err == nil && similarity > 0 && similarity <= 1 {
+			cfg.Similarity = similarity
+		}
+	}
+	if value := config.Config("DUPLICATE_RADIUS"); value != "" {
+		if radius, err := strconv.ParseFloat(value, 64); err == nil && radius > 0 {
+			cfg.Radius = radius
+		}
+	}
+	return cfg
+}
+
+// detectIncidentGroup 在事务中查找与新反馈重复的未完成反馈，找到时将新反馈并入事件组
+// 同一城市、时间窗口内的反馈，若都有定位则按距离判断，否则按地址相似度判断；未找到重复时返回nil
+func detectIncidentGroup(tx *sql.Tx, feedback duplicateCandidate, provinceID, cityID int64) (*incidentMatch, error) {
+	cfg := loadDuplicateConfig()
+
+	var provinceName, cityName string
+	err := tx.QueryRow(
+		"SELECT IFNULL((SELECT province_name FROM grid_province WHERE province_id = ?), ''), IFNULL((SELECT city_name FROM grid_city WHERE city_id = ?), '')",
+		provinceID, cityID,
+	).Scan(&provinceName, &cityName)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT af_id, ig_id, gm_id, state, address, latitude, longitude, af_date, af_time
+		FROM aqi_feedback
+		WHERE province_id = ? AND city_id = ? AND state IN (0, 1) AND af_id <> ? AND af_date >= ?
+		ORDER BY af_id
+		FOR UPDATE
+	`, provinceID, cityID, feedback.AfID, feedback.ReportedAt.Add(-cfg.Window).Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []duplicateCandidate
+	for rows.Next() {
+		var candidate duplicateCandidate
+		var afDate, afTime string
+		err := rows.Scan(
+			&candidate.AfID, &candidate.IgID, &candidate.GmID, &candidate.State, &candidate.Address,
+			&candidate.Latitude, &candidate.Longitude, &afDate, &afTime,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		reportedAt, err := parseFeedbackTime(afDate, afTime)
+		if err != nil {
+			continue
+		}
+		candidate.ReportedAt = reportedAt
+		candidates = append(candidates, candidate)
+	}
+	rows.Close()
+
+	normalized := normalizeAddress(feedback.Address, provinceName, cityName)
+
+	var best *duplicateCandidate
+	var bestScore float64
+	for i := range candidates {
+		candidate := &candidates[i]
+
+		gap := feedback.ReportedAt.Sub(candidate.ReportedAt)
+		if gap < 0 {
+			gap = -gap
+		}
+		if gap > cfg.Window {
+			continue
+		}
+
+		score, ok := duplicateScore(feedback, *candidate, normalized, provinceName, cityName, cfg)
+		if ok && score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+
+	return joinIncidentGroup(tx, feedback, *best, provinceID, cityID)
+}
+
+// duplicateScore 计算两条反馈的重复得分，有定位且在半径内时得分大于1
+// 两条反馈都有定位时以距离为准，超出半径直接判定为不重复
+func duplicateScore(feedback, candidate duplicateCandidate, normalized, provinceName, cityName string, cfg duplicateConfig) (float64, bool) {
+	similarity := addressSimilarity(normalized, normalizeAddress(candidate.Address, provinceName, cityName))
+
+	if feedback.Latitude.Valid && feedback.Longitude.Valid && candidate.Latitude.Valid && candidate.Longitude.Valid {
+		distance := haversineDistance(
+			feedback.Latitude.Float64, feedback.Longitude.Float64,
+			candidate.Latitude.Float64, candidate.Longitude.Float64,
+		)
+		if distance > cfg.Radius {
+			return 0, false
+		}
+		return 1 + similarity, true
+	}
+
+	return similarity, similarity >= cfg.Similarity
+}
+
+// joinIncidentGroup 将新反馈并入重复反馈所在的事件组，重复反馈尚未归组时新建事件组
+// 事件组已指派给网格员且该网格员可以接受本地指派时，新反馈自动指派给同一网格员
+func joinIncidentGroup(tx *sql.Tx, feedback, duplicate duplicateCandidate, provinceID, cityID int64) (*incidentMatch, error) {
+	now := time.Now()
+	date := now.Format("2006-01-02")
+	clock := now.Format("15:04:05")
+
+	igID := duplicate.IgID
+	if igID == 0 {
+		result, err := tx.Exec(
+			"INSERT INTO incident_group (lead_af_id, province_id, city_id, create_date, create_time, state) VALUES (?, ?, ?, ?, ?, 0)",
+			duplicate.AfID, provinceID, cityID, date, clock,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if igID, err = result.LastInsertId(); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("UPDATE aqi_feedback SET ig_id = ? WHERE af_id = ?", igID, duplicate.AfID); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE aqi_feedback SET ig_id = ? WHERE af_id = ?", igID, feedback.AfID); err != nil {
+		return nil, err
+	}
+
+	match := &incidentMatch{IgID: igID, DuplicateOf: duplicate.AfID}
+
+	// 查找事件组当前负责的网格员
+	gmID := int64(0)
+	if duplicate.State == 1 {
+		gmID = duplicate.GmID
+	} else {
+		err := tx.QueryRow(
+			"SELECT gm_id FROM aqi_feedback WHERE ig_id = ? AND state = 1 ORDER BY assign_date DESC, assign_time DESC LIMIT 1",
+			igID,
+		).Scan(&gmID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	if gmID == 0 {
+		return match, nil
+	}
+
+	// 与手动指派相同校验网格员当前是否可以接受指派；不可用或负责区域与反馈不同时保持未指派，等待管理员处理
+	target, err := loadAssignTarget(tx, int(gmID), now)
+	if err == sql.ErrNoRows {
+		return match, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !target.Availability.Available || int64(target.ProvinceID) != provinceID || int64(target.CityID) != cityID {
+		return match, nil
+	}
+
+	remarks := "重复反馈自动并入事件组"
+	_, err = tx.Exec(
+		"UPDATE aqi_feedback SET gm_id = ?, assign_date = ?, assign_time = ?, state = 1, remarks = ? WHERE af_id = ?",
+		gmID, date, clock, remarks, feedback.AfID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertAssignLog(tx, int(feedback.AfID), 0, int(gmID), 0, date, clock, remarks); err != nil {
+		return nil, err
+	}
+	match.GmID = gmID
+	return match, nil
+}
+
+// assignIncidentGroupInTx 反馈属于事件组时，将组内其他未完成的反馈一并指派给同一网格员
+// 返回随事件组一并指派的反馈ID
+func assignIncidentGroupInTx(tx *sql.Tx, feedbackID, gmID int, adminID interface{}, assignDate, assignTime, remarks string) ([]int, error) {
+	var igID int64
+	err := tx.QueryRow("SELECT ig_id FROM aqi_feedback WHERE af_id = ?", feedbackID).Scan(&igID)
+	if err != nil {
+		return nil, err
+	}
+	if igID == 0 {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(
+		"SELECT af_id, gm_id, state FROM aqi_feedback WHERE ig_id = ? AND af_id <> ? AND state IN (0, 1) FOR UPDATE",
+		igID, feedbackID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	type member struct {
+		AfID, GmID, State int
+	}
+	var members []member
+	for rows.Next() {
+		var m member
+		if err := rows.Scan(&m.AfID, &m.GmID, &m.State); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if m.State == 1 && m.GmID == gmID {
+			continue
+		}
+		members = append(members, m)
+	}
+	rows.Close()
+
+	var assigned []int
+	for _, m := range members {
+		_, err := tx.Exec(
+			"UPDATE aqi_feedback SET gm_id = ?, assign_date = ?, assign_time = ?, state = 1, remarks = ? WHERE af_id = ?",
+			gmID, assignDate, assignTime, remarks, m.AfID,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		prevGmID := 0
+		if m.State == 1 {
+			prevGmID = m.GmID
+		}
+		if err := insertAssignLog(tx, m.AfID, prevGmID, gmID, adminID, assignDate, assignTime, remarks); err != nil {
+			return nil, err
+		}
+		assigned = append(assigned, m.AfID)
+	}
+	return assigned, nil
+}
+
+// confirmIncidentGroup 实测确认反馈后，将同一事件组内其他未完成的反馈一并确认
+// 在保存实测数据的事务中调用，返回一并确认的反馈数量
+// 未指派或由其他网格员负责的组员先转交给实测的网格员并记录指派日志，再一并确认
+func confirmIncidentGroup(tx *sql.Tx, feedbackID int, gmID interface{}, statisticsID int64, measuredAt time.Time) (int64, error) {
+	var igID int64
+	var measurerID int
+	err := tx.QueryRow(
+		"SELECT ig_id, gm_id FROM aqi_feedback WHERE af_id = ? AND gm_id = ? AND state = 2",
+		feedbackID, gmID,
+	).Scan(&igID, &measurerID)
+	if err == sql.ErrNoRows || (err == nil && igID == 0) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(
+		"SELECT af_id, gm_id, state FROM aqi_feedback WHERE ig_id = ? AND state IN (0, 1) FOR UPDATE",
+		igID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	type member struct {
+		AfID, GmID, State int
+	}
+	var members []member
+	for rows.Next() {
+		var m member
+		if err := rows.Scan(&m.AfID, &m.GmID, &m.State); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		members = append(members, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	assignDate := measuredAt.Format("2006-01-02")
+	assignTime := measuredAt.Format("15:04:05")
+	remarks := "事件组已实测确认，转交实测网格员"
+
+	var confirmed int64
+	for _, m := range members {
+		if m.State == 1 && m.GmID == measurerID {
+			_, err = tx.Exec("UPDATE aqi_feedback SET state = 2 WHERE af_id = ?", m.AfID)
+		} else {
+			prevGmID := 0
+			if m.State == 1 {
+				prevGmID = m.GmID
+			}
+			_, err = tx.Exec(
+				"UPDATE aqi_feedback SET gm_id = ?, assign_date = ?, assign_time = ?, state = 2, remarks = ? WHERE af_id = ?",
+				measurerID, assignDate, assignTime, remarks, m.AfID,
+			)
+			if err == nil {
+				err = insertAssignLog(tx, m.AfID, prevGmID, measurerID, 0, assignDate, assignTime, remarks)
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+		confirmed++
+	}
+
+	_, err = tx.Exec("UPDATE incident_group SET state = 1, statistics_id = ? WHERE ig_id = ?", statisticsID, igID)
+	if err != nil {
+		return 0, err
+	}
+	return confirmed, nil
+}
+
+// leaveIncidentGroup 在事务中将反馈移出事件组，组内只剩一条反馈时解散事件组
+// 首条反馈被移出时由剩余最早的反馈接替
+func leaveIncidentGroup(tx *sql.Tx, feedbackID, igID, leadAfID int64) (bool, error) {
+	if _, err := tx.Exec("UPDATE aqi_feedback SET ig_id = 0 WHERE af_id = ?", feedbackID); err != nil {
+		return false, err
+	}
+
+	rows, err := tx.Query("SELECT af_id FROM aqi_feedback WHERE ig_id = ? ORDER BY af_id", igID)
+	if err != nil {
+		return false, err
+	}
+	var remaining []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return false, err
+		}
+		remaining = append(remaining, id)
+	}
+	rows.Close()
+
+	if len(remaining) <= 1 {
+		if _, err := tx.Exec("UPDATE aqi_feedback SET ig_id = 0 WHERE ig_id = ?", igID); err != nil {
+			return false, err
+		}
+		_, err := tx.Exec("DELETE FROM incident_group WHERE ig_id = ?", igID)
+		return true, err
+	}
+	if leadAfID == feedbackID {
+		_, err := tx.Exec("UPDATE incident_group SET lead_af_id = ? WHERE ig_id = ?", remaining[0], igID)
+		return false, err
+	}
+	return false, nil
+}
+
+// normalizeAddress 规范化地址用于比较：全角转半角、去除空白和标点、去除省市前缀和口语化描述
+func normalizeAddress(address, provinceName, cityName string) string {
+	var b strings.Builder
+	for _, r := range address {
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		r = unicode.ToLower(r)
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	normalized := b.String()
+
+	for _, name := range []string{provinceName, cityName} {
+		for _, variant := range regionNameVariants(name) {
+			if strings.HasPrefix(normalized, variant) {
+				normalized = strings.TrimPrefix(normalized, variant)
+				break
+			}
+		}
+	}
+	for _, word := range addressFillerWords {
+		normalized = strings.ReplaceAll(normalized, word, "")
+	}
+	return normalized
+}
+
+// regionNameVariants 返回行政区的全称和简称，全称在前
+func regionNameVariants(name string) []string {
+	if name == "" {
+		return nil
+	}
+	variants := []string{name}
+	for _, suffix := range regionNameSuffixes {
+		if short := strings.TrimSuffix(name, suffix); short != name && short != "" {
+			variants = append(variants, short)
+			break
+		}
+	}
+	return variants
+}
+
+// addressTokens 将规范化后的地址切分为词元：连续数字（门牌号等）作为一个词元，其余文字按相邻两字切分
+func addressTokens(address string) map[string]bool {
+	tokens := make(map[string]bool)
+	runes := []rune(address)
+
+	for i := 0; i < len(runes); {
+		j := i
+		if unicode.IsDigit(runes[i]) {
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			tokens["#"+string(runes[i:j])] = true
+			i = j
+			continue
+		}
+
+		for j < len(runes) && !unicode.IsDigit(runes[j]) {
+			j++
+		}
+		segment := runes[i:j]
+		if len(segment) == 1 {
+			tokens[string(segment)] = true
+		}
+		for k := 0; k+1 < len(segment); k++ {
+			tokens[string(segment[k:k+2])] = true
+		}
+		i = j
+	}
+	return tokens
+}
+
+// addressSimilarity 计算两个规范化地址的相似度（Dice系数），取值0-1
+func addressSimilarity(a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+
+	tokensA, tokensB := addressTokens(a), addressTokens(b)
+	common := 0
+	for token := range tokensA {
+		if tokensB[token] {
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(tokensA)+len(tokensB))
+}
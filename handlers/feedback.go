@@ -4,16 +4,20 @@ import (
 	"database/sql"
 	"epss-backend/database"
 	"epss-backend/models"
+	"fmt"
+	"sort"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // GetAllFeedbacks 获取所有公众反馈数据列表
+// sort=priority 时未指派的反馈排在前面，并按预估等级和监督员可信度计算的优先级排序
 func GetAllFeedbacks(c *fiber.Ctx) error {
 	// 获取查询参数
 	provinceID := c.Query("province_id")
 	cityID := c.Query("city_id")
+	sortByPriority := c.Query("sort") == "priority"
 
 	// 构建基础查询
 	baseQuery := `
@@ -21,8 +25,10 @@ func GetAllFeedbacks(c *fiber.Ctx) error {
 			af.af_id, af.tel_id, af.province_id, af.city_id, af.address, 
 			af.information, af.estimated_grade, af.af_date, af.af_time, 
 			af.gm_id, af.assign_date, af.assign_time, af.state, af.remarks,
+			af.latitude, af.longitude, af.location_accuracy, af.ig_id,
 			p.province_name, c.city_name, s.real_name as supervisor_name,
-			IFNULL(gm.gm_name, '') as grid_member_name
+			IFNULL(gm.gm_name, '') as grid_member_name,
+			` + unreadCommentsColumn("admin") + `
 		FROM 
 			aqi_feedback af
 		LEFT JOIN 
@@ -37,7 +43,8 @@ func GetAllFeedbacks(c *fiber.Ctx) error {
 
 	// 添加筛选条件
 	whereClause := ""
-	params := []interface{}{}
+	_, readerID, _ := commentReader(c)
+	params := unreadCommentsArgs("admin", readerID)
 
 	if provinceID != "" {
 		whereClause += " WHERE af.province_id = ?"
@@ -71,9 +78,12 @@ func GetAllFeedbacks(c *fiber.Ctx) error {
 
 	// 构建反馈列表
 	var feedbackList []fiber.Map
+	var states, grades []int
+	var telIDs []string
 	for rows.Next() {
 		var feedback models.AqiFeedback
 		var provinceName, cityName, supervisorName, gridMemberName string
+		var unreadComments int
 
 		// 使用临时变量接收可能为NULL的字段
 		var assignDate, assignTime, remarks sql.NullString
@@ -82,7 +92,8 @@ func GetAllFeedbacks(c *fiber.Ctx) error {
 			&feedback.AfID, &feedback.TelID, &feedback.ProvinceID, &feedback.CityID, &feedback.Address,
 			&feedback.Information, &feedback.EstimatedGrade, &feedback.AfDate, &feedback.AfTime,
 			&feedback.GmID, &assignDate, &assignTime, &feedback.State, &remarks,
-			&provinceName, &cityName, &supervisorName, &gridMemberName,
+			&feedback.Latitude, &feedback.Longitude, &feedback.LocationAccuracy, &feedback.IgID,
+			&provinceName, &cityName, &supervisorName, &gridMemberName, &unreadComments,
 		)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -91,27 +102,73 @@ func GetAllFeedbacks(c *fiber.Ctx) error {
 			})
 		}
 
+		states = append(states, feedback.State)
+		grades = append(grades, feedback.EstimatedGrade)
+		telIDs = append(telIDs, feedback.TelID)
+
 		// 构建返回数据
 		feedbackList = append(feedbackList, fiber.Map{
-			"id":               feedback.AfID,
-			"tel_id":           feedback.TelID,
-			"province_id":      feedback.ProvinceID,
-			"city_id":          feedback.CityID,
-			"address":          feedback.Address,
-			"information":      feedback.Information,
-			"estimated_grade":  feedback.EstimatedGrade,
-			"af_date":          feedback.AfDate,
-			"af_time":          feedback.AfTime,
-			"gm_id":            feedback.GmID,
-			"assign_date":      assignDate.String,
-			"assign_time":      assignTime.String,
-			"state":            feedback.State,
-			"remarks":          remarks.String,
-			"province_name":    provinceName,
-			"city_name":        cityName,
-			"supervisor_name":  supervisorName,
-			"grid_member_name": gridMemberName,
+			"id":                feedback.AfID,
+			"tel_id":            feedback.TelID,
+			"province_id":       feedback.ProvinceID,
+			"city_id":           feedback.CityID,
+			"address":           feedback.Address,
+			"information":       feedback.Information,
+			"estimated_grade":   feedback.EstimatedGrade,
+			"af_date":           feedback.AfDate,
+			"af_time":           feedback.AfTime,
+			"gm_id":             feedback.GmID,
+			"assign_date":       assignDate.String,
+			"assign_time":       assignTime.String,
+			"state":             feedback.State,
+			"remarks":           remarks.String,
+			"latitude":          nullFloatValue(feedback.Latitude),
+			"longitude":         nullFloatValue(feedback.Longitude),
+			"accuracy":          nullFloatValue(feedback.LocationAccuracy),
+			"incident_group_id": feedback.IgID,
+			"province_name":     provinceName,
+			"city_name":         cityName,
+			"supervisor_name":   supervisorName,
+			"grid_member_name":  gridMemberName,
+			"unread_comments":   unreadComments,
+		})
+	}
+	rows.Close()
+
+	// 只统计列表中涉及的监督员的可信度
+	reliability, err := loadSupervisorReliabilityFor(telIDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "计算公众监督员可信度失败",
+			"details": err.Error(),
+		})
+	}
+	priorities := make([]float64, len(feedbackList))
+	for i, feedback := range feedbackList {
+		trustScore := reliabilityOf(reliability, telIDs[i]).TrustScore
+		priorities[i] = severityPriority(grades[i], trustScore)
+		feedback["reporter_trust"] = trustScore
+		feedback["priority"] = priorities[i]
+	}
+
+	if sortByPriority {
+		// 按下标排序，同一优先级保持原有顺序
+		order := make([]int, len(feedbackList))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			a, b := order[i], order[j]
+			if (states[a] == 0) != (states[b] == 0) {
+				return states[a] == 0
+			}
+			return priorities[a] > priorities[b]
 		})
+		sorted := make([]fiber.Map, len(feedbackList))
+		for i, idx := range order {
+			sorted[i] = feedbackList[idx]
+		}
+		feedbackList = sorted
 	}
 
 	return c.JSON(fiber.Map{
@@ -135,8 +192,10 @@ func GetSupervisorFeedbacks(c *fiber.Ctx) error {
 			af.af_id, af.tel_id, af.province_id, af.city_id, af.address, 
 			af.information, af.estimated_grade, af.af_date, af.af_time, 
 			af.gm_id, af.assign_date, af.assign_time, af.state, af.remarks,
+			af.latitude, af.longitude, af.location_accuracy, af.ig_id,
 			p.province_name, c.city_name,
-			IFNULL(gm.gm_name, '') as grid_member_name
+			IFNULL(gm.gm_name, '') as grid_member_name,
+			` + unreadCommentsColumn("supervisor") + `
 		FROM 
 			aqi_feedback af
 		LEFT JOIN 
@@ -151,7 +210,8 @@ func GetSupervisorFeedbacks(c *fiber.Ctx) error {
 			af.af_id DESC
 	`
 
-	rows, err := database.DB.Query(query, telID)
+	params := append(unreadCommentsArgs("supervisor", fmt.Sprint(telID)), telID)
+	rows, err := database.DB.Query(query, params...)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "获取反馈列表失败",
@@ -164,6 +224,7 @@ func GetSupervisorFeedbacks(c *fiber.Ctx) error {
 	for rows.Next() {
 		var feedback models.AqiFeedback
 		var provinceName, cityName, gridMemberName string
+		var unreadComments int
 
 		// 使用临时变量接收可能为NULL的字段
 		var assignDate, assignTime, remarks sql.NullString
@@ -172,7 +233,8 @@ func GetSupervisorFeedbacks(c *fiber.Ctx) error {
 			&feedback.AfID, &feedback.TelID, &feedback.ProvinceID, &feedback.CityID, &feedback.Address,
 			&feedback.Information, &feedback.EstimatedGrade, &feedback.AfDate, &feedback.AfTime,
 			&feedback.GmID, &assignDate, &assignTime, &feedback.State, &remarks,
-			&provinceName, &cityName, &gridMemberName,
+			&feedback.Latitude, &feedback.Longitude, &feedback.LocationAccuracy, &feedback.IgID,
+			&provinceName, &cityName, &gridMemberName, &unreadComments,
 		)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -183,23 +245,28 @@ func GetSupervisorFeedbacks(c *fiber.Ctx) error {
 
 		// 构建返回数据
 		feedbackList = append(feedbackList, fiber.Map{
-			"id":               feedback.AfID,
-			"tel_id":           feedback.TelID,
-			"province_id":      feedback.ProvinceID,
-			"city_id":          feedback.CityID,
-			"address":          feedback.Address,
-			"information":      feedback.Information,
-			"estimated_grade":  feedback.EstimatedGrade,
-			"af_date":          feedback.AfDate,
-			"af_time":          feedback.AfTime,
-			"gm_id":            feedback.GmID,
-			"assign_date":      assignDate.String,
-			"assign_time":      assignTime.String,
-			"state":            feedback.State,
-			"remarks":          remarks.String,
-			"province_name":    provinceName,
-			"city_name":        cityName,
-			"grid_member_name": gridMemberName,
+			"id":                feedback.AfID,
+			"tel_id":            feedback.TelID,
+			"province_id":       feedback.ProvinceID,
+			"city_id":           feedback.CityID,
+			"address":           feedback.Address,
+			"information":       feedback.Information,
+			"estimated_grade":   feedback.EstimatedGrade,
+			"af_date":           feedback.AfDate,
+			"af_time":           feedback.AfTime,
+			"gm_id":             feedback.GmID,
+			"assign_date":       assignDate.String,
+			"assign_time":       assignTime.String,
+			"state":             feedback.State,
+			"remarks":           remarks.String,
+			"latitude":          nullFloatValue(feedback.Latitude),
+			"longitude":         nullFloatValue(feedback.Longitude),
+			"accuracy":          nullFloatValue(feedback.LocationAccuracy),
+			"incident_group_id": feedback.IgID,
+			"province_name":     provinceName,
+			"city_name":         cityName,
+			"grid_member_name":  gridMemberName,
+			"unread_comments":   unreadComments,
 		})
 	}
 
@@ -223,8 +290,8 @@ func GetGridMemberFeedbacks(c *fiber.Ctx) error {
 	var stateFilter string
 	var params []interface{}
 
-	// 添加网格员ID参数
-	params = append(params, gmID)
+	// 添加未读留言统计和网格员ID参数
+	params = append(unreadCommentsArgs("member", fmt.Sprint(gmID)), gmID)
 
 	// 构建状态筛选条件
 	if stateParam != "" {
@@ -241,7 +308,9 @@ func GetGridMemberFeedbacks(c *fiber.Ctx) error {
 			af.af_id, af.tel_id, af.province_id, af.city_id, af.address, 
 			af.information, af.estimated_grade, af.af_date, af.af_time, 
 			af.gm_id, af.assign_date, af.assign_time, af.state, af.remarks,
-			p.province_name, c.city_name, s.real_name as supervisor_name
+			af.latitude, af.longitude, af.location_accuracy, af.ig_id,
+			p.province_name, c.city_name, s.real_name as supervisor_name,
+			` + unreadCommentsColumn("member") + `
 		FROM 
 			aqi_feedback af
 		LEFT JOIN 
@@ -270,6 +339,7 @@ func GetGridMemberFeedbacks(c *fiber.Ctx) error {
 	for rows.Next() {
 		var feedback models.AqiFeedback
 		var provinceName, cityName, supervisorName string
+		var unreadComments int
 
 		// 使用临时变量接收可能为NULL的字段
 		var assignDate, assignTime, remarks sql.NullString
@@ -278,7 +348,8 @@ func GetGridMemberFeedbacks(c *fiber.Ctx) error {
 			&feedback.AfID, &feedback.TelID, &feedback.ProvinceID, &feedback.CityID, &feedback.Address,
 			&feedback.Information, &feedback.EstimatedGrade, &feedback.AfDate, &feedback.AfTime,
 			&feedback.GmID, &assignDate, &assignTime, &feedback.State, &remarks,
-			&provinceName, &cityName, &supervisorName,
+			&feedback.Latitude, &feedback.Longitude, &feedback.LocationAccuracy, &feedback.IgID,
+			&provinceName, &cityName, &supervisorName, &unreadComments,
 		)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -308,23 +379,28 @@ func GetGridMemberFeedbacks(c *fiber.Ctx) error {
 
 		// 构建返回数据
 		taskList = append(taskList, fiber.Map{
-			"id":              feedback.AfID,
-			"tel_id":          feedback.TelID,
-			"supervisor_name": supervisorName,
-			"province_id":     feedback.ProvinceID,
-			"city_id":         feedback.CityID,
-			"address":         feedback.Address,
-			"information":     feedback.Information,
-			"estimated_grade": aqiInfo,
-			"af_date":         feedback.AfDate,
-			"af_time":         feedback.AfTime,
-			"assign_date":     assignDate.String,
-			"assign_time":     assignTime.String,
-			"state":           feedback.State,
-			"state_text":      getStateText(feedback.State),
-			"remarks":         remarks.String,
-			"province_name":   provinceName,
-			"city_name":       cityName,
+			"id":                feedback.AfID,
+			"tel_id":            feedback.TelID,
+			"supervisor_name":   supervisorName,
+			"province_id":       feedback.ProvinceID,
+			"city_id":           feedback.CityID,
+			"address":           feedback.Address,
+			"information":       feedback.Information,
+			"estimated_grade":   aqiInfo,
+			"af_date":           feedback.AfDate,
+			"af_time":           feedback.AfTime,
+			"assign_date":       assignDate.String,
+			"assign_time":       assignTime.String,
+			"state":             feedback.State,
+			"state_text":        getStateText(feedback.State),
+			"remarks":           remarks.String,
+			"latitude":          nullFloatValue(feedback.Latitude),
+			"longitude":         nullFloatValue(feedback.Longitude),
+			"accuracy":          nullFloatValue(feedback.LocationAccuracy),
+			"incident_group_id": feedback.IgID,
+			"province_name":     provinceName,
+			"city_name":         cityName,
+			"unread_comments":   unreadComments,
 		})
 	}
 
@@ -342,6 +418,10 @@ func getStateText(state int) string {
 		return "已指派"
 	case 2:
 		return "已确认"
+	case feedbackStateWithdrawn:
+		return "已撤回"
+	case feedbackStateDisputed:
+		return "申诉复核中"
 	default:
 		return "未知状态"
 	}
@@ -0,0 +1,474 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/database"
+	"epss-backend/models"
+	"epss-backend/realtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 反馈被监督员撤回后的状态
+const feedbackStateWithdrawn = 3
+
+// 反馈修改记录的操作类型
+const (
+	feedbackEditUpdate   = 1 // 修改
+	feedbackEditWithdraw = 2 // 撤回
+)
+
+// editableFeedback 监督员可修改的反馈内容
+type editableFeedback struct {
+	Address        string
+	Information    string
+	EstimatedGrade int
+	IgID           int64
+}
+
+// lockOwnFeedback 在事务中锁定当前监督员的反馈并返回其内容和状态，不存在时返回sql.ErrNoRows
+func lockOwnFeedback(tx *sql.Tx, feedbackID int64, telID interface{}) (editableFeedback, int, error) {
+	var feedback editableFeedback
+	var state int
+	err := tx.QueryRow(
+		"SELECT address, information, estimated_grade, ig_id, state FROM aqi_feedback WHERE af_id = ? AND tel_id = ? FOR UPDATE",
+		feedbackID, telID,
+	).Scan(&feedback.Address, &feedback.Information, &feedback.EstimatedGrade, &feedback.IgID, &state)
+	return feedback, state, err
+}
+
+// insertFeedbackEditLog 在事务中记录一次修改或撤回操作
+func insertFeedbackEditLog(tx *sql.Tx, feedbackID int64, telID interface{}, action int, before, after editableFeedback, editDate, editTime, remarks string) error {
+	var remarksValue interface{}
+	if remarks != "" {
+		remarksValue = remarks
+	}
+
+	_, err := tx.Exec(
+		`INSERT INTO feedback_edit_log
+		(af_id, tel_id, action, old_address, old_information, old_estimated_grade,
+		 new_address, new_information, new_estimated_grade, edit_date, edit_time, remarks)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		feedbackID, telID, action, before.Address, before.Information, before.EstimatedGrade,
+		after.Address, after.Information, after.EstimatedGrade, editDate, editTime, remarksValue,
+	)
+	return err
+}
+
+// leaveFeedbackIncidentGroup 在事务中将反馈移出所在的事件组，事件组已不存在时只清除反馈的事件组编号
+func leaveFeedbackIncidentGroup(tx *sql.Tx, feedbackID, igID int64) error {
+	var leadAfID int64
+	err := tx.QueryRow("SELECT lead_af_id FROM incident_group WHERE ig_id = ? FOR UPDATE", igID).Scan(&leadAfID)
+	if err == sql.ErrNoRows {
+		_, err = tx.Exec("UPDATE aqi_feedback SET ig_id = 0 WHERE af_id = ?", feedbackID)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	_, err = leaveIncidentGroup(tx, feedbackID, igID, leadAfID)
+	return err
+}
+
+// regroupEditedFeedback 地址修改后将反馈移出原事件组，再按新地址重新检测重复反馈
+// 未找到重复反馈时返回nil，反馈保持独立
+func regroupEditedFeedback(tx *sql.Tx, feedbackID, igID int64) (*incidentMatch, error) {
+	if igID > 0 {
+		if err := leaveFeedbackIncidentGroup(tx, feedbackID, igID); err != nil {
+			return nil, err
+		}
+	}
+
+	candidate := duplicateCandidate{AfID: feedbackID}
+	var provinceID, cityID int64
+	var afDate, afTime string
+	err := tx.QueryRow(
+		"SELECT address, latitude, longitude, province_id, city_id, af_date, af_time FROM aqi_feedback WHERE af_id = ?",
+		feedbackID,
+	).Scan(&candidate.Address, &candidate.Latitude, &candidate.Longitude, &provinceID, &cityID, &afDate, &afTime)
+	if err != nil {
+		return nil, err
+	}
+	if candidate.ReportedAt, err = parseFeedbackTime(afDate, afTime); err != nil {
+		return nil, err
+	}
+	return detectIncidentGroup(tx, candidate, provinceID, cityID)
+}
+
+// UpdateSupervisorFeedback 公众监督员修改自己尚未指派的反馈，只需提供要修改的字段
+// 修改地址后重新判断是否与其他反馈重复，原事件组不再包含该反馈
+func UpdateSupervisorFeedback(c *fiber.Ctx) error {
+	telID := c.Locals("user_tel_id")
+	if telID == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	feedbackID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的反馈ID",
+		})
+	}
+
+	var req struct {
+		Address        *string `json:"address"`
+		Information    *string `json:"information"`
+		EstimatedGrade *int    `json:"estimated_grade"`
+		Reason         string  `json:"reason"` // 修改原因（可选）
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "请求数据格式错误",
+			"details": err.Error(),
+		})
+	}
+
+	if req.Address == nil && req.Information == nil && req.EstimatedGrade == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "请至少提供一项要修改的内容",
+		})
+	}
+	if (req.Address != nil && strings.TrimSpace(*req.Address) == "") ||
+		(req.Information != nil && strings.TrimSpace(*req.Information) == "") ||
+		(req.EstimatedGrade != nil && (*req.EstimatedGrade <= 0 || *req.EstimatedGrade > 6)) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "请求数据不完整或无效",
+		})
+	}
+	if len([]rune(req.Reason)) > 200 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "修改原因不能超过200个字符",
+		})
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "启动数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	// 只有未指派的反馈可以修改或撤回
+	before, state, err := lockOwnFeedback(tx, feedbackID, telID)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "反馈信息不存在",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询反馈信息失败",
+			"details": err.Error(),
+		})
+	}
+	if state != 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "反馈信息" + getStateText(state) + "，不能再修改或撤回",
+			"state": state,
+		})
+	}
+
+	after := before
+	if req.Address != nil {
+		after.Address = strings.TrimSpace(*req.Address)
+	}
+	if req.Information != nil {
+		after.Information = strings.TrimSpace(*req.Information)
+	}
+	if req.EstimatedGrade != nil {
+		after.EstimatedGrade = *req.EstimatedGrade
+	}
+	if after == before {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "反馈内容没有变化",
+		})
+	}
+
+	_, err = tx.Exec(
+		"UPDATE aqi_feedback SET address = ?, information = ?, estimated_grade = ? WHERE af_id = ? AND state = 0",
+		after.Address, after.Information, after.EstimatedGrade, feedbackID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "修改反馈信息失败",
+			"details": err.Error(),
+		})
+	}
+
+	now := time.Now()
+	editDate := now.Format("2006-01-02")
+	editTime := now.Format("15:04:05")
+
+	if err := insertFeedbackEditLog(tx, feedbackID, telID, feedbackEditUpdate, before, after, editDate, editTime, req.Reason); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "记录修改历史失败",
+			"details": err.Error(),
+		})
+	}
+
+	// 地址变化后原来的重复判断不再成立，按新地址重新归组
+	var match *incidentMatch
+	igID := before.IgID
+	if after.Address != before.Address {
+		match, err = regroupEditedFeedback(tx, feedbackID, before.IgID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "重新检测重复反馈失败",
+				"details": err.Error(),
+			})
+		}
+		igID = 0
+		if match != nil {
+			igID = match.IgID
+		}
+	}
+
+	// 并入已指派的事件组时自动指派给同一网格员，与新提交的反馈一样通知该网格员
+	if match != nil && match.GmID > 0 {
+		if err := notifyTaskAssigned(tx, int(feedbackID), int(match.GmID), now); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "写入通知失败",
+				"details": err.Error(),
+			})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "提交数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+
+	message := "反馈信息修改成功"
+	if match != nil {
+		message = "反馈信息修改成功，已有相同地点的反馈正在处理，已合并处理"
+		if match.GmID > 0 {
+			publishFeedbackEvents(realtime.TopicFeedbackAssigned, []int64{feedbackID})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"message": message,
+		"data": fiber.Map{
+			"feedback_id":       feedbackID,
+			"address":           after.Address,
+			"information":       after.Information,
+			"estimated_grade":   after.EstimatedGrade,
+			"incident_group_id": igID,
+			"edit_date":         editDate,
+			"edit_time":         editTime,
+		},
+	})
+}
+
+// WithdrawSupervisorFeedback 公众监督员撤回自己尚未指派的反馈
+func WithdrawSupervisorFeedback(c *fiber.Ctx) error {
+	telID := c.Locals("user_tel_id")
+	if telID == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	feedbackID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的反馈ID",
+		})
+	}
+
+	var req struct {
+		Reason string `json:"reason"` // 撤回原因（可选）
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "请求数据格式错误",
+				"details": err.Error(),
+			})
+		}
+	}
+	if len([]rune(req.Reason)) > 200 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "撤回原因不能超过200个字符",
+		})
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "启动数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	// 只有未指派的反馈可以修改或撤回
+	feedback, state, err := lockOwnFeedback(tx, feedbackID, telID)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "反馈信息不存在",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询反馈信息失败",
+			"details": err.Error(),
+		})
+	}
+	if state != 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "反馈信息" + getStateText(state) + "，不能再修改或撤回",
+			"state": state,
+		})
+	}
+
+	_, err = tx.Exec("UPDATE aqi_feedback SET state = ? WHERE af_id = ? AND state = 0", feedbackStateWithdrawn, feedbackID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "撤回反馈失败",
+			"details": err.Error(),
+		})
+	}
+
+	// 撤回的反馈不再参与事件组的合并处理
+	if feedback.IgID > 0 {
+		if err := leaveFeedbackIncidentGroup(tx, feedbackID, feedback.IgID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "移出事件组失败",
+				"details": err.Error(),
+			})
+		}
+	}
+
+	now := time.Now()
+	editDate := now.Format("2006-01-02")
+	editTime := now.Format("15:04:05")
+
+	if err := insertFeedbackEditLog(tx, feedbackID, telID, feedbackEditWithdraw, feedback, feedback, editDate, editTime, req.Reason); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "记录撤回历史失败",
+			"details": err.Error(),
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "提交数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "反馈已撤回",
+		"data": fiber.Map{
+			"feedback_id": feedbackID,
+			"state":       feedbackStateWithdrawn,
+			"state_text":  getStateText(feedbackStateWithdrawn),
+			"edit_date":   editDate,
+			"edit_time":   editTime,
+		},
+	})
+}
+
+// GetFeedbackEditHistory 获取反馈的修改和撤回历史
+func GetFeedbackEditHistory(c *fiber.Ctx) error {
+	feedbackID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的反馈ID",
+		})
+	}
+
+	allowed, err := canAccessFeedback(c, feedbackID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询反馈信息失败",
+			"details": err.Error(),
+		})
+	}
+	if !allowed {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "反馈信息不存在或无权查看",
+		})
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT
+			log_id, af_id, tel_id, action,
+			old_address, old_information, old_estimated_grade,
+			new_address, new_information, new_estimated_grade,
+			edit_date, edit_time, remarks
+		FROM
+			feedback_edit_log
+		WHERE
+			af_id = ?
+		ORDER BY
+			log_id ASC
+	`, feedbackID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取修改历史失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var historyList []fiber.Map
+	for rows.Next() {
+		var entry models.FeedbackEditLog
+		err := rows.Scan(
+			&entry.LogID, &entry.AfID, &entry.TelID, &entry.Action,
+			&entry.OldAddress, &entry.OldInformation, &entry.OldEstimatedGrade,
+			&entry.NewAddress, &entry.NewInformation, &entry.NewEstimatedGrade,
+			&entry.EditDate, &entry.EditTime, &entry.Remarks,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理修改历史失败",
+				"details": err.Error(),
+			})
+		}
+
+		historyList = append(historyList, fiber.Map{
+			"id":                  entry.LogID,
+			"feedback_id":         entry.AfID,
+			"tel_id":              entry.TelID,
+			"action":              entry.Action,
+			"action_text":         getFeedbackEditActionText(entry.Action),
+			"old_address":         entry.OldAddress,
+			"old_information":     entry.OldInformation,
+			"old_estimated_grade": entry.OldEstimatedGrade,
+			"new_address":         entry.NewAddress,
+			"new_information":     entry.NewInformation,
+			"new_estimated_grade": entry.NewEstimatedGrade,
+			"edit_date":           entry.EditDate,
+			"edit_time":           entry.EditTime,
+			"reason":              entry.Remarks.String,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": historyList,
+	})
+}
+
+// 获取修改记录操作类型的文本描述
+func getFeedbackEditActionText(action int) string {
+	switch action {
+	case feedbackEditUpdate:
+		return "修改"
+	case feedbackEditWithdraw:
+		return "撤回"
+	default:
+		return "未知操作"
+	}
+}
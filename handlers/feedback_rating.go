@@ -0,0 +1,593 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/database"
+	"epss-backend/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 监督员对确认结果提出申诉后，反馈进入复核状态
+const feedbackStateDisputed = 4
+
+// 申诉状态
+const (
+	disputeStatePending  = 0 // 待复核
+	disputeStateUpheld   = 1 // 维持原结果
+	disputeStateReopened = 2 // 重新处理
+)
+
+// RateFeedback 公众监督员对已确认的反馈处理结果进行满意度评价，重复评价时覆盖之前的评价
+func RateFeedback(c *fiber.Ctx) error {
+	telID := c.Locals("user_tel_id")
+	if telID == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	feedbackID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的反馈ID",
+		})
+	}
+
+	var req struct {
+		Rating  int    `json:"rating"`  // 满意度评分（1-5）
+		Comment string `json:"comment"` // 评价内容（可选）
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "请求数据格式错误",
+			"details": err.Error(),
+		})
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if req.Rating < 1 || req.Rating > 5 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "评分必须为1-5之间的整数",
+		})
+	}
+	if len([]rune(req.Comment)) > 400 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "评价内容不能超过400个字符",
+		})
+	}
+
+	var gmID int64
+	var state int
+	err = database.DB.QueryRow(
+		"SELECT gm_id, state FROM aqi_feedback WHERE af_id = ? AND tel_id = ?",
+		feedbackID, telID,
+	).Scan(&gmID, &state)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "反馈信息不存在",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询反馈信息失败",
+			"details": err.Error(),
+		})
+	}
+	if state != 2 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "只能评价已确认的反馈",
+			"state": state,
+		})
+	}
+
+	var comment interface{}
+	if req.Comment != "" {
+		comment = req.Comment
+	}
+
+	now := time.Now()
+	rateDate := now.Format("2006-01-02")
+	rateTime := now.Format("15:04:05")
+
+	_, err = database.DB.Exec(
+		`INSERT INTO feedback_rating (af_id, tel_id, gm_id, rating, comment, rate_date, rate_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE gm_id = VALUES(gm_id), rating = VALUES(rating), comment = VALUES(comment),
+			rate_date = VALUES(rate_date), rate_time = VALUES(rate_time)`,
+		feedbackID, telID, gmID, req.Rating, comment, rateDate, rateTime,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "保存评价失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "评价提交成功",
+		"data": fiber.Map{
+			"feedback_id": feedbackID,
+			"gm_id":       gmID,
+			"rating":      req.Rating,
+			"comment":     req.Comment,
+			"rate_date":   rateDate,
+			"rate_time":   rateTime,
+		},
+	})
+}
+
+// DisputeFeedback 公众监督员对已确认的结果提出申诉，反馈进入复核状态等待管理员处理
+func DisputeFeedback(c *fiber.Ctx) error {
+	telID := c.Locals("user_tel_id")
+	if telID == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	feedbackID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的反馈ID",
+		})
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "请求数据格式错误",
+			"details": err.Error(),
+		})
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "请填写申诉理由",
+		})
+	}
+	if len([]rune(req.Reason)) > 400 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "申诉理由不能超过400个字符",
+		})
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "启动数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	var gmID int64
+	var state int
+	err = tx.QueryRow(
+		"SELECT gm_id, state FROM aqi_feedback WHERE af_id = ? AND tel_id = ? FOR UPDATE",
+		feedbackID, telID,
+	).Scan(&gmID, &state)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "反馈信息不存在",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询反馈信息失败",
+			"details": err.Error(),
+		})
+	}
+	if state != 2 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "只能对已确认的反馈提出申诉",
+			"state": state,
+		})
+	}
+
+	now := time.Now()
+	disputeDate := now.Format("2006-01-02")
+	disputeTime := now.Format("15:04:05")
+
+	result, err := tx.Exec(
+		"INSERT INTO feedback_dispute (af_id, tel_id, gm_id, reason, dispute_date, dispute_time, state) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		feedbackID, telID, gmID, req.Reason, disputeDate, disputeTime, disputeStatePending,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "提交申诉失败",
+			"details": err.Error(),
+		})
+	}
+	disputeID, _ := result.LastInsertId()
+
+	if _, err := tx.Exec("UPDATE aqi_feedback SET state = ? WHERE af_id = ?", feedbackStateDisputed, feedbackID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "更新反馈状态失败",
+			"details": err.Error(),
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "提交数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "申诉已提交，等待管理员复核",
+		"data": fiber.Map{
+			"dispute_id":   disputeID,
+			"feedback_id":  feedbackID,
+			"state":        feedbackStateDisputed,
+			"state_text":   getStateText(feedbackStateDisputed),
+			"dispute_date": disputeDate,
+			"dispute_time": disputeTime,
+		},
+	})
+}
+
+// GetDisputeList 获取申诉列表，默认返回待复核的申诉，可通过state参数筛选
+func GetDisputeList(c *fiber.Ctx) error {
+	state := c.QueryInt("state", disputeStatePending)
+
+	query := `
+		SELECT
+			d.dispute_id, d.af_id, d.tel_id, d.gm_id, d.reason, d.dispute_date, d.dispute_time,
+			d.state, d.admin_id, d.review_date, d.review_time, d.review_remarks,
+			af.address, af.estimated_grade,
+			IFNULL(s.real_name, '') as supervisor_name,
+			IFNULL(gm.gm_name, '') as grid_member_name,
+			IFNULL(st.aqi_id, 0) as confirmed_aqi_id
+		FROM
+			feedback_dispute d
+		JOIN
+			aqi_feedback af ON d.af_id = af.af_id
+		LEFT JOIN
+			supervisor s ON d.tel_id = s.tel_id
+		LEFT JOIN
+			grid_member gm ON d.gm_id = gm.gm_id
+		LEFT JOIN
+			(SELECT af_id, MAX(aqi_id) as aqi_id FROM statistics WHERE af_id > 0 GROUP BY af_id) st ON d.af_id = st.af_id
+		WHERE
+			d.state = ?
+		ORDER BY
+			d.dispute_id DESC
+	`
+
+	rows, err := database.DB.Query(query, state)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取申诉列表失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var disputeList []fiber.Map
+	for rows.Next() {
+		var dispute models.FeedbackDispute
+		var address, supervisorName, gridMemberName string
+		var estimatedGrade, confirmedAqiID int
+
+		err := rows.Scan(
+			&dispute.DisputeID, &dispute.AfID, &dispute.TelID, &dispute.GmID, &dispute.Reason,
+			&dispute.DisputeDate, &dispute.DisputeTime,
+			&dispute.State, &dispute.AdminID, &dispute.ReviewDate, &dispute.ReviewTime, &dispute.ReviewRemarks,
+			&address, &estimatedGrade, &supervisorName, &gridMemberName, &confirmedAqiID,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理申诉数据失败",
+				"details": err.Error(),
+			})
+		}
+
+		disputeList = append(disputeList, fiber.Map{
+			"id":               dispute.DisputeID,
+			"feedback_id":      dispute.AfID,
+			"tel_id":           dispute.TelID,
+			"supervisor_name":  supervisorName,
+			"gm_id":            dispute.GmID,
+			"grid_member_name": gridMemberName,
+			"address":          address,
+			"estimated_grade":  estimatedGrade,
+			"confirmed_aqi_id": confirmedAqiID,
+			"reason":           dispute.Reason,
+			"dispute_date":     dispute.DisputeDate,
+			"dispute_time":     dispute.DisputeTime,
+			"state":            dispute.State,
+			"state_text":       getDisputeStateText(dispute.State),
+			"admin_id":         nullIntValue(dispute.AdminID),
+			"review_date":      dispute.ReviewDate.String,
+			"review_time":      dispute.ReviewTime.String,
+			"review_remarks":   dispute.ReviewRemarks.String,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": disputeList,
+	})
+}
+
+// ReviewDispute 管理员复核申诉
+// reopen为true时反馈退回未指派状态重新处理，否则维持原确认结果
+func ReviewDispute(c *fiber.Ctx) error {
+	disputeID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的申诉ID",
+		})
+	}
+
+	var req struct {
+		Reopen  bool   `json:"reopen"`
+		Remarks string `json:"remarks"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的请求数据",
+		})
+	}
+	req.Remarks = strings.TrimSpace(req.Remarks)
+	if req.Remarks == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "请填写复核意见",
+		})
+	}
+	if len([]rune(req.Remarks)) > 200 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "复核意见不能超过200个字符",
+		})
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "启动数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	var feedbackID int64
+	var state int
+	err = tx.QueryRow("SELECT af_id, state FROM feedback_dispute WHERE dispute_id = ? FOR UPDATE", disputeID).Scan(&feedbackID, &state)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "申诉不存在",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询申诉失败",
+			"details": err.Error(),
+		})
+	}
+	if state != disputeStatePending {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "该申诉已复核",
+		})
+	}
+
+	newState := disputeStateUpheld
+	if req.Reopen {
+		newState = disputeStateReopened
+	}
+
+	now := time.Now()
+	reviewDate := now.Format("2006-01-02")
+	reviewTime := now.Format("15:04:05")
+
+	_, err = tx.Exec(
+		"UPDATE feedback_dispute SET state = ?, admin_id = ?, review_date = ?, review_time = ?, review_remarks = ? WHERE dispute_id = ?",
+		newState, c.Locals("user_id"), reviewDate, reviewTime, req.Remarks, disputeID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "更新申诉失败",
+			"details": err.Error(),
+		})
+	}
+
+	// 维持原结果时恢复为已确认，重新处理时退回未指派状态等待重新指派
+	if req.Reopen {
+		_, err = tx.Exec(
+			`UPDATE aqi_feedback SET state = 0, gm_id = 0, assign_date = NULL, assign_time = NULL, remarks = ?,
+			        sla_round = sla_round + 1, sla_start = ?
+			 WHERE af_id = ? AND state = ?`,
+			req.Remarks, now.Format(feedbackTimeLayout), feedbackID, feedbackStateDisputed,
+		)
+	} else {
+		_, err = tx.Exec("UPDATE aqi_feedback SET state = 2 WHERE af_id = ? AND state = ?", feedbackID, feedbackStateDisputed)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "更新反馈状态失败",
+			"details": err.Error(),
+		})
+	}
+
+	// 重新处理的反馈保留上一轮的超时标记和升级记录，解除未解决的标记后从重新处理时间起算新一轮时限
+	// 原测量结果不再关联该反馈，记录在 superseded_af_id 中备查
+	if req.Reopen {
+		_, err = tx.Exec("UPDATE feedback_overdue SET resolved = 1 WHERE af_id = ? AND resolved = 0", feedbackID)
+		if err == nil {
+			_, err = tx.Exec("UPDATE statistics SET superseded_af_id = af_id, af_id = 0 WHERE af_id = ?", feedbackID)
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "更新上一轮处理记录失败",
+				"details": err.Error(),
+			})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "提交数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "申诉复核完成",
+		"data": fiber.Map{
+			"dispute_id":  disputeID,
+			"feedback_id": feedbackID,
+			"state":       newState,
+			"state_text":  getDisputeStateText(newState),
+			"review_date": reviewDate,
+			"review_time": reviewTime,
+		},
+	})
+}
+
+// GetGridMemberQualityStats 按网格员统计处理质量：满意度评分、申诉数量和申诉成立数量
+// 支持from、to参数，分别按评价日期和申诉日期筛选
+func GetGridMemberQualityStats(c *fiber.Ctx) error {
+	type memberQuality struct {
+		GmID             int64   `json:"gm_id"`
+		GmName           string  `json:"gm_name"`
+		RatingCount      int     `json:"rating_count"`
+		AverageRating    float64 `json:"average_rating"`
+		RatingDistribute [5]int  `json:"rating_distribution"` // 依次为1-5分的评价数量
+		DisputeCount     int     `json:"dispute_count"`
+		DisputeReopened  int     `json:"dispute_reopened_count"`
+		DisputeRate      float64 `json:"dispute_rate"` // 申诉成立数量占评价与申诉总数的百分比
+	}
+
+	from, to := c.Query("from"), c.Query("to")
+	dateFilter := func(column string) (string, []interface{}) {
+		condition := ""
+		params := []interface{}{}
+		if from != "" {
+			condition += " AND " + column + " >= ?"
+			params = append(params, from)
+		}
+		if to != "" {
+			condition += " AND " + column + " <= ?"
+			params = append(params, to)
+		}
+		return condition, params
+	}
+
+	statsByMember := make(map[int64]*memberQuality)
+	getMember := func(gmID int64, gmName string) *memberQuality {
+		if stat, ok := statsByMember[gmID]; ok {
+			return stat
+		}
+		stat := &memberQuality{GmID: gmID, GmName: gmName}
+		statsByMember[gmID] = stat
+		return stat
+	}
+
+	ratingCondition, ratingParams := dateFilter("r.rate_date")
+	rows, err := database.DB.Query(`
+		SELECT r.gm_id, IFNULL(gm.gm_name, '') as gm_name, r.rating, COUNT(*) as rating_count
+		FROM feedback_rating r
+		LEFT JOIN grid_member gm ON r.gm_id = gm.gm_id
+		WHERE 1 = 1`+ratingCondition+`
+		GROUP BY r.gm_id, gm.gm_name, r.rating
+	`, ratingParams...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取满意度统计失败",
+			"error":   err.Error(),
+		})
+	}
+	for rows.Next() {
+		var gmID int64
+		var gmName string
+		var rating, count int
+		if err := rows.Scan(&gmID, &gmName, &rating, &count); err != nil {
+			rows.Close()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "解析满意度统计失败",
+				"error":   err.Error(),
+			})
+		}
+		if rating < 1 || rating > 5 {
+			continue
+		}
+		stat := getMember(gmID, gmName)
+		stat.RatingDistribute[rating-1] += count
+		stat.RatingCount += count
+		stat.AverageRating += float64(rating * count)
+	}
+	rows.Close()
+
+	disputeCondition, disputeParams := dateFilter("d.dispute_date")
+	rows, err = database.DB.Query(`
+		SELECT d.gm_id, IFNULL(gm.gm_name, '') as gm_name,
+			COUNT(*) as dispute_count,
+			SUM(CASE WHEN d.state = ? THEN 1 ELSE 0 END) as reopened_count
+		FROM feedback_dispute d
+		LEFT JOIN grid_member gm ON d.gm_id = gm.gm_id
+		WHERE 1 = 1`+disputeCondition+`
+		GROUP BY d.gm_id, gm.gm_name
+	`, append([]interface{}{disputeStateReopened}, disputeParams...)...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取申诉统计失败",
+			"error":   err.Error(),
+		})
+	}
+	for rows.Next() {
+		var gmID int64
+		var gmName string
+		var disputeCount, reopenedCount int
+		if err := rows.Scan(&gmID, &gmName, &disputeCount, &reopenedCount); err != nil {
+			rows.Close()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "解析申诉统计失败",
+				"error":   err.Error(),
+			})
+		}
+		stat := getMember(gmID, gmName)
+		stat.DisputeCount = disputeCount
+		stat.DisputeReopened = reopenedCount
+	}
+	rows.Close()
+
+	results := make([]memberQuality, 0, len(statsByMember))
+	for _, stat := range statsByMember {
+		if stat.RatingCount > 0 {
+			stat.AverageRating = float64(int(stat.AverageRating/float64(stat.RatingCount)*100+0.5)) / 100
+		}
+		stat.DisputeRate = percentage(stat.DisputeReopened, stat.RatingCount+stat.DisputeCount)
+		results = append(results, *stat)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].GmID < results[j].GmID
+	})
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    results,
+	})
+}
+
+// 获取申诉状态文本描述
+func getDisputeStateText(state int) string {
+	switch state {
+	case disputeStatePending:
+		return "待复核"
+	case disputeStateUpheld:
+		return "维持原结果"
+	case disputeStateReopened:
+		return "重新处理"
+	default:
+		return "未知状态"
+	}
+}
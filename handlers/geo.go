@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/config"
+	"math"
+	"strconv"
+)
+
+// 位置校验状态
+const (
+	fenceStateNone     = 0 // 反馈没有定位，无需校验
+	fenceStateInside   = 1 // 实测位置在范围内
+	fenceStatePending  = 2 // 超出范围或缺少定位，待管理员审核
+	fenceStateApproved = 3 // 审核通过
+	fenceStateRejected = 4 // 审核驳回
+)
+
+// validStatisticsCondition 返回排除位置审核驳回的实测数据的查询条件，alias为statistics表的别名
+// 审核驳回的实测数据保留备查，但不计入统计、地图、预警、绩效和可信度
+func validStatisticsCondition(alias string) string {
+	return alias + ".fence_state <> " + strconv.Itoa(fenceStateRejected)
+}
+
+// 地球平均半径（米）
+const earthRadiusMeters = 6371000.0
+
+// 默认的实测位置允许偏离反馈位置的半径（米）
+const defaultGeofenceRadius = 500.0
+
+// GeoPoint 请求中携带的定位信息，未定位时各字段为nil
+type GeoPoint struct {
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Accuracy  *float64 `json:"accuracy"` // 定位精度（米）
+}
+
+// Valid 检查定位信息是否合法，经纬度必须同时提供
+func (p GeoPoint) Valid() bool {
+	if p.Latitude == nil && p.Longitude == nil {
+		return p.Accuracy == nil
+	}
+	if p.Latitude == nil || p.Longitude == nil {
+		return false
+	}
+	if *p.Latitude < -90 || *p.Latitude > 90 || *p.Longitude < -180 || *p.Longitude > 180 {
+		return false
+	}
+	return p.Accuracy == nil || *p.Accuracy >= 0
+}
+
+// Present 是否提供了经纬度
+func (p GeoPoint) Present() bool {
+	return p.Latitude != nil && p.Longitude != nil
+}
+
+// dbValues 转换为写入数据库的纬度、经度和精度，未提供的字段写入NULL
+func (p GeoPoint) dbValues() (interface{}, interface{}, interface{}) {
+	var lat, lng, accuracy interface{}
+	if p.Present() {
+		lat, lng = *p.Latitude, *p.Longitude
+		if p.Accuracy != nil {
+			accuracy = *p.Accuracy
+		}
+	}
+	return lat, lng, accuracy
+}
+
+// haversineDistance 计算两个经纬度之间的球面距离（米）
+func haversineDistance(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// geofenceRadius 获取实测位置允许偏离反馈位置的半径（米），通过 GEOFENCE_RADIUS 配置
+func geofenceRadius() float64 {
+	if value := config.Config("GEOFENCE_RADIUS"); value != "" {
+		if radius, err := strconv.ParseFloat(value, 64); err == nil && radius > 0 {
+			return radius
+		}
+	}
+	return defaultGeofenceRadius
+}
+
+// nullFloatValue 将可能为NULL的浮点数转换为JSON值，NULL时返回nil
+func nullFloatValue(v sql.NullFloat64) interface{} {
+	if v.Valid {
+		return v.Float64
+	}
+	return nil
+}
+
+// nullFloatFromPointer 将可选的浮点数转换为sql.NullFloat64
+func nullFloatFromPointer(v *float64) sql.NullFloat64 {
+	if v == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: *v, Valid: true}
+}
+
+// nullIntValue 将可能为NULL的整数转换为JSON值，NULL时返回nil
+func nullIntValue(v sql.NullInt64) interface{} {
+	if v.Valid {
+		return v.Int64
+	}
+	return nil
+}
+
+// 获取位置校验状态文本描述
+func getFenceStateText(state int) string {
+	switch state {
+	case fenceStateNone:
+		return "无需校验"
+	case fenceStateInside:
+		return "范围内"
+	case fenceStatePending:
+		return "待审核"
+	case fenceStateApproved:
+		return "审核通过"
+	case fenceStateRejected:
+		return "审核驳回"
+	default:
+		return "未知状态"
+	}
+}
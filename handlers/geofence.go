@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/database"
+	"epss-backend/models"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetGeofenceReviewList 获取位置校验的实测数据列表，默认返回待审核的记录，可通过state参数筛选
+func GetGeofenceReviewList(c *fiber.Ctx) error {
+	state := c.QueryInt("state", fenceStatePending)
+	if state < fenceStateNone || state > fenceStateRejected {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的位置校验状态",
+		})
+	}
+
+	query := `
+		SELECT
+			s.id, s.province_id, s.city_id, s.address, s.aqi_id,
+			s.confirm_date, s.confirm_time, s.gm_id, s.af_id,
+			s.latitude, s.longitude, s.location_accuracy, s.fence_distance, s.fence_state,
+			s.fence_reviewer_id, s.fence_review_date, s.fence_review_time, s.fence_review_remarks,
+			af.latitude, af.longitude, af.location_accuracy,
+			IFNULL(gm.gm_name, '') as gm_name,
+			IFNULL(p.province_name, '') as province_name,
+			IFNULL(ct.city_name, '') as city_name
+		FROM
+			statistics s
+		LEFT JOIN
+			aqi_feedback af ON s.af_id = af.af_id
+		LEFT JOIN
+			grid_member gm ON s.gm_id = gm.gm_id
+		LEFT JOIN
+			grid_province p ON s.province_id = p.province_id
+		LEFT JOIN
+			grid_city ct ON s.city_id = ct.city_id
+		WHERE
+			s.fence_state = ?
+		ORDER BY
+			s.confirm_date DESC, s.confirm_time DESC
+	`
+
+	rows, err := database.DB.Query(query, state)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取位置审核列表失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var reviewList []fiber.Map
+	for rows.Next() {
+		var s models.Statistics
+		var feedbackLat, feedbackLng, feedbackAccuracy sql.NullFloat64
+		var gmName, provinceName, cityName string
+
+		err := rows.Scan(
+			&s.ID, &s.ProvinceID, &s.CityID, &s.Address, &s.AqiID,
+			&s.ConfirmDate, &s.ConfirmTime, &s.GmID, &s.AfID,
+			&s.Latitude, &s.Longitude, &s.LocationAccuracy, &s.FenceDistance, &s.FenceState,
+			&s.FenceReviewerID, &s.FenceReviewDate, &s.FenceReviewTime, &s.FenceReviewRemarks,
+			&feedbackLat, &feedbackLng, &feedbackAccuracy,
+			&gmName, &provinceName, &cityName,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理位置审核数据失败",
+				"details": err.Error(),
+			})
+		}
+
+		reviewList = append(reviewList, fiber.Map{
+			"id":                 s.ID,
+			"province_id":        s.ProvinceID,
+			"province_name":      provinceName,
+			"city_id":            s.CityID,
+			"city_name":          cityName,
+			"address":            s.Address,
+			"aqi_id":             s.AqiID,
+			"confirm_date":       s.ConfirmDate,
+			"confirm_time":       s.ConfirmTime,
+			"gm_id":              s.GmID,
+			"gm_name":            gmName,
+			"feedback_id":        s.AfID,
+			"latitude":           nullFloatValue(s.Latitude),
+			"longitude":          nullFloatValue(s.Longitude),
+			"accuracy":           nullFloatValue(s.LocationAccuracy),
+			"feedback_latitude":  nullFloatValue(feedbackLat),
+			"feedback_longitude": nullFloatValue(feedbackLng),
+			"feedback_accuracy":  nullFloatValue(feedbackAccuracy),
+			"fence_distance":     nullIntValue(s.FenceDistance),
+			"fence_radius":       geofenceRadius(),
+			"fence_state":        s.FenceState,
+			"fence_state_text":   getFenceStateText(s.FenceState),
+			"reviewer_id":        nullIntValue(s.FenceReviewerID),
+			"review_date":        s.FenceReviewDate.String,
+			"review_time":        s.FenceReviewTime.String,
+			"review_remarks":     s.FenceReviewRemarks.String,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": reviewList,
+	})
+}
+
+// ReviewGeofence 管理员审核超出范围的实测数据
+func ReviewGeofence(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的实测数据ID",
+		})
+	}
+
+	var req struct {
+		Approved bool   `json:"approved"`
+		Remarks  string `json:"remarks"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的请求数据",
+		})
+	}
+	if len([]rune(req.Remarks)) > 200 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "审核意见不能超过200个字符",
+		})
+	}
+	if !req.Approved && req.Remarks == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "驳回时必须填写审核意见",
+		})
+	}
+
+	var state, aqiID int
+	err = database.DB.QueryRow("SELECT fence_state, aqi_id FROM statistics WHERE id = ?", id).Scan(&state, &aqiID)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "实测数据不存在",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询实测数据失败",
+			"details": err.Error(),
+		})
+	}
+	if state != fenceStatePending {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "该实测数据不需要审核或已审核",
+		})
+	}
+
+	newState := fenceStateRejected
+	if req.Approved {
+		newState = fenceStateApproved
+	}
+
+	now := time.Now()
+	reviewDate := now.Format("2006-01-02")
+	reviewTime := now.Format("15:04:05")
+
+	// 带上状态条件，避免重复审核
+	result, err := database.DB.Exec(
+		`UPDATE statistics
+		SET fence_state = ?, fence_reviewer_id = ?, fence_review_date = ?, fence_review_time = ?, fence_review_remarks = ?
+		WHERE id = ? AND fence_state = ?`,
+		newState, c.Locals("user_id"), reviewDate, reviewTime, req.Remarks, id, fenceStatePending,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "更新审核结果失败",
+			"details": err.Error(),
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "该实测数据已被其他管理员审核",
+		})
+	}
+
+	// 驳回的实测数据不再计入统计
+	if newState == fenceStateRejected {
+		publishStatsDelta(aqiID, -1)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "位置审核完成",
+		"data": fiber.Map{
+			"id":               id,
+			"fence_state":      newState,
+			"fence_state_text": getFenceStateText(newState),
+			"review_date":      reviewDate,
+			"review_time":      reviewTime,
+		},
+	})
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/database"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// geoJSONFeatureCollection GeoJSON要素集合
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+// geoJSONFeature GeoJSON点要素
+type geoJSONFeature struct {
+	Type       string          `json:"type"`
+	ID         int64           `json:"id"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Properties fiber.Map       `json:"properties"`
+}
+
+// geoJSONGeometry GeoJSON几何对象，坐标顺序为[经度, 纬度]
+type geoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+func newPointFeature(id int64, lat, lng float64, properties fiber.Map) geoJSONFeature {
+	return geoJSONFeature{
+		Type: "Feature",
+		ID:   id,
+		Geometry: geoJSONGeometry{
+			Type:        "Point",
+			Coordinates: []float64{lng, lat},
+		},
+		Properties: properties,
+	}
+}
+
+// parseBBox 解析bbox参数，格式与GeoJSON一致: 最小经度,最小纬度,最大经度,最大纬度
+func parseBBox(value string) (minLng, minLat, maxLng, maxLat float64, err error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return 0, 0, 0, 0, errors.New("bbox格式应为: 最小经度,最小纬度,最大经度,最大纬度")
+	}
+
+	var nums [4]float64
+	for i, part := range parts {
+		nums[i], err = strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return 0, 0, 0, 0, errors.New("bbox包含无效的数值")
+		}
+	}
+	minLng, minLat, maxLng, maxLat = nums[0], nums[1], nums[2], nums[3]
+	if minLat > maxLat || minLng > maxLng || minLat < -90 || maxLat > 90 || minLng < -180 || maxLng > 180 {
+		return 0, 0, 0, 0, errors.New("bbox范围无效")
+	}
+	return minLng, minLat, maxLng, maxLat, nil
+}
+
+// buildGeoFilters 根据bbox、from、to、level、province_id、city_id参数生成查询条件
+// alias为表别名，dateColumn为日期字段，levelColumn为等级字段
+func buildGeoFilters(c *fiber.Ctx, alias, dateColumn, levelColumn string) (string, []interface{}, error) {
+	conditions := " AND " + alias + ".latitude IS NOT NULL AND " + alias + ".longitude IS NOT NULL"
+	params := []interface{}{}
+
+	if bbox := c.Query("bbox"); bbox != "" {
+		minLng, minLat, maxLng, maxLat, err := parseBBox(bbox)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions += " AND " + alias + ".latitude BETWEEN ? AND ? AND " + alias + ".longitude BETWEEN ? AND ?"
+		params = append(params, minLat, maxLat, minLng, maxLng)
+	}
+	if from := c.Query("from"); from != "" {
+		conditions += " AND " + alias + "." + dateColumn + " >= ?"
+		params = append(params, from)
+	}
+	if to := c.Query("to"); to != "" {
+		conditions += " AND " + alias + "." + dateColumn + " <= ?"
+		params = append(params, to)
+	}
+	if level := c.Query("level"); level != "" {
+		// 支持单个等级或以逗号分隔的多个等级
+		var levels []string
+		for _, item := range strings.Split(level, ",") {
+			value, err := strconv.Atoi(strings.TrimSpace(item))
+			if err != nil || value < 1 || value > 6 {
+				return "", nil, errors.New("level必须为1-6之间的等级")
+			}
+			levels = append(levels, "?")
+			params = append(params, value)
+		}
+		conditions += " AND " + alias + "." + levelColumn + " IN (" + strings.Join(levels, ",") + ")"
+	}
+	if minLevel := c.Query("min_level"); minLevel != "" {
+		value, err := strconv.Atoi(minLevel)
+		if err != nil {
+			return "", nil, errors.New("min_level必须为数字")
+		}
+		conditions += " AND " + alias + "." + levelColumn + " >= ?"
+		params = append(params, value)
+	}
+	if provinceID := c.Query("province_id"); provinceID != "" {
+		conditions += " AND " + alias + ".province_id = ?"
+		params = append(params, provinceID)
+		if cityID := c.Query("city_id"); cityID != "" {
+			conditions += " AND " + alias + ".city_id = ?"
+			params = append(params, cityID)
+		}
+	}
+
+	return conditions, params, nil
+}
+
+// GetMeasurementGeoJSON 获取带定位的已确认实测数据，以GeoJSON FeatureCollection返回
+// 支持bbox、from、to（确认日期）、level、min_level（AQI等级）、province_id和city_id参数
+func GetMeasurementGeoJSON(c *fiber.Ctx) error {
+	filters, params, err := buildGeoFilters(c, "s", "confirm_date", "aqi_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "查询参数无效",
+			"error":   err.Error(),
+		})
+	}
+
+	// 位置审核被驳回的数据不在地图上展示
+	query := `
+		SELECT
+			s.id, s.latitude, s.longitude, s.address, s.province_id, s.city_id,
+			s.so2_value, s.so2_level, s.co_value, s.co_level, s.spm_value, s.spm_level,
+			s.aqi_id, IFNULL(a.chinese_explain, '') as aqi_level, IFNULL(a.color, '') as aqi_color,
+			s.confirm_date, s.confirm_time, s.af_id,
+			IFNULL(p.province_name, '') as province_name,
+			IFNULL(ct.city_name, '') as city_name
+		FROM
+			statistics s
+		LEFT JOIN
+			aqi a ON s.aqi_id = a.aqi_id
+		LEFT JOIN
+			grid_province p ON s.province_id = p.province_id
+		LEFT JOIN
+			grid_city ct ON s.city_id = ct.city_id
+		WHERE
+			` + validStatisticsCondition("s") + filters + `
+		ORDER BY
+			s.confirm_date DESC, s.confirm_time DESC
+	`
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取实测数据地图信息失败",
+			"error":   err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	collection := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+	for rows.Next() {
+		var id, provinceID, cityID, aqiID, afID int64
+		var lat, lng float64
+		var address, aqiLevel, aqiColor, confirmDate, confirmTime, provinceName, cityName string
+		var so2Value, so2Level, coValue, coLevel, spmValue, spmLevel int
+
+		err := rows.Scan(
+			&id, &lat, &lng, &address, &provinceID, &cityID,
+			&so2Value, &so2Level, &coValue, &coLevel, &spmValue, &spmLevel,
+			&aqiID, &aqiLevel, &aqiColor,
+			&confirmDate, &confirmTime, &afID,
+			&provinceName, &cityName,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "解析实测数据地图信息失败",
+				"error":   err.Error(),
+			})
+		}
+
+		collection.Features = append(collection.Features, newPointFeature(id, lat, lng, fiber.Map{
+			"address":       address,
+			"province_id":   provinceID,
+			"province_name": provinceName,
+			"city_id":       cityID,
+			"city_name":     cityName,
+			"so2_value":     so2Value,
+			"so2_level":     so2Level,
+			"co_value":      coValue,
+			"co_level":      coLevel,
+			"spm_value":     spmValue,
+			"spm_level":     spmLevel,
+			"aqi_id":        aqiID,
+			"aqi_level":     aqiLevel,
+			"aqi_color":     aqiColor,
+			"confirm_date":  confirmDate,
+			"confirm_time":  confirmTime,
+			"feedback_id":   afID,
+		}))
+	}
+
+	return c.JSON(collection)
+}
+
+// GetOpenFeedbackGeoJSON 获取带定位的未完成反馈（未指派和已指派），以GeoJSON FeatureCollection返回
+// 支持bbox、from、to（反馈日期）、level、min_level（预估等级）、state、province_id和city_id参数
+func GetOpenFeedbackGeoJSON(c *fiber.Ctx) error {
+	filters, params, err := buildGeoFilters(c, "af", "af_date", "estimated_grade")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "查询参数无效",
+			"error":   err.Error(),
+		})
+	}
+
+	stateCondition := "af.state IN (0, 1)"
+	if state := c.Query("state"); state != "" {
+		if state != "0" && state != "1" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "查询参数无效",
+				"error":   "state只能为0（未指派）或1（已指派）",
+			})
+		}
+		stateCondition = "af.state = " + state
+	}
+
+	query := `
+		SELECT
+			af.af_id, af.latitude, af.longitude, af.location_accuracy, af.address,
+			af.province_id, af.city_id, af.information, af.estimated_grade,
+			IFNULL(a.chinese_explain, '') as aqi_level, IFNULL(a.color, '') as aqi_color,
+			af.af_date, af.af_time, af.state, af.gm_id,
+			IFNULL(p.province_name, '') as province_name,
+			IFNULL(ct.city_name, '') as city_name
+		FROM
+			aqi_feedback af
+		LEFT JOIN
+			aqi a ON af.estimated_grade = a.aqi_id
+		LEFT JOIN
+			grid_province p ON af.province_id = p.province_id
+		LEFT JOIN
+			grid_city ct ON af.city_id = ct.city_id
+		WHERE
+			` + stateCondition + filters + `
+		ORDER BY
+			af.af_date DESC, af.af_time DESC
+	`
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取反馈地图信息失败",
+			"error":   err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	collection := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+	for rows.Next() {
+		var id, provinceID, cityID, gmID int64
+		var lat, lng float64
+		var accuracy sql.NullFloat64
+		var address, information, aqiLevel, aqiColor, afDate, afTime, provinceName, cityName string
+		var grade, state int
+
+		err := rows.Scan(
+			&id, &lat, &lng, &accuracy, &address,
+			&provinceID, &cityID, &information, &grade,
+			&aqiLevel, &aqiColor,
+			&afDate, &afTime, &state, &gmID,
+			&provinceName, &cityName,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "解析反馈地图信息失败",
+				"error":   err.Error(),
+			})
+		}
+
+		collection.Features = append(collection.Features, newPointFeature(id, lat, lng, fiber.Map{
+			"accuracy":        nullFloatValue(accuracy),
+			"address":         address,
+			"province_id":     provinceID,
+			"province_name":   provinceName,
+			"city_id":         cityID,
+			"city_name":       cityName,
+			"information":     information,
+			"estimated_grade": grade,
+			"aqi_level":       aqiLevel,
+			"aqi_color":       aqiColor,
+			"af_date":         afDate,
+			"af_time":         afTime,
+			"state":           state,
+			"state_text":      getStateText(state),
+			"gm_id":           gmID,
+		}))
+	}
+
+	return c.JSON(collection)
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"epss-backend/database"
+	"epss-backend/models"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 幂等键的最大长度
+const maxIdempotencyKeyLength = 100
+
+// 幂等记录的处理状态
+const (
+	idempotencyStateProcessing = 0 // 处理中
+	idempotencyStateCompleted  = 1 // 已完成
+)
+
+// idempotencyTTL 读取幂等键的有效期，默认24小时
+func idempotencyTTL() time.Duration {
+	return durationConfig("IDEMPOTENCY_TTL", 24*time.Hour)
+}
+
+// idempotencyLockTimeout 读取处理中的幂等记录的锁定时限，默认2分钟
+// 超过时限仍未完成的请求视为已中断（如进程退出），相同的重试请求可以接管
+func idempotencyLockTimeout() time.Duration {
+	return durationConfig("IDEMPOTENCY_LOCK_TIMEOUT", 2*time.Minute)
+}
+
+// IdempotencyMiddleware 幂等键中间件，需放在认证中间件之后
+// POST请求携带 Idempotency-Key 头时，有效期内同一用户使用相同的键重试会直接返回首次请求的响应；
+// 相同的键用于不同的请求返回422，首次请求仍在处理中返回409，超过锁定时限仍未完成时由重试请求接管；
+// 首次请求出现服务器错误时不保存，允许重试
+func IdempotencyMiddleware(c *fiber.Ctx) error {
+	key := c.Get("Idempotency-Key")
+	if c.Method() != fiber.MethodPost || key == "" {
+		return c.Next()
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Idempotency-Key 不能超过100个字符",
+		})
+	}
+
+	scope := fmt.Sprintf("%v:%v", c.Locals("user_type"), c.Locals("user_id"))
+	if c.Locals("user_type") == "supervisor" {
+		scope = fmt.Sprintf("supervisor:%v", c.Locals("user_tel_id"))
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(c.Method() + " " + c.OriginalURL() + "\n"))
+	hash.Write(c.Body())
+	fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+	now := time.Now()
+	expired := now.Add(-idempotencyTTL()).Format(feedbackTimeLayout)
+
+	// 已过期的同名记录视为不存在
+	_, err := database.DB.Exec(
+		"DELETE FROM idempotency_key WHERE scope = ? AND idem_key = ? AND CONCAT(create_date, ' ', create_time) < ?",
+		scope, key, expired,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询幂等记录失败",
+			"details": err.Error(),
+		})
+	}
+
+	ikID, lockExpire, err := claimIdempotencyKey(scope, key, fingerprint, now)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "保存幂等记录失败",
+			"details": err.Error(),
+		})
+	}
+	if ikID == 0 {
+		return replayIdempotentResponse(c, scope, key, fingerprint, now)
+	}
+
+	// 带上锁定时间条件，超时后被重试请求接管的记录由接管的请求负责更新
+	if err := c.Next(); err != nil {
+		database.DB.Exec("DELETE FROM idempotency_key WHERE ik_id = ? AND lock_expire = ?", ikID, lockExpire)
+		return err
+	}
+
+	status := c.Response().StatusCode()
+	if status >= fiber.StatusInternalServerError {
+		database.DB.Exec("DELETE FROM idempotency_key WHERE ik_id = ? AND lock_expire = ?", ikID, lockExpire)
+		return nil
+	}
+
+	_, err = database.DB.Exec(
+		"UPDATE idempotency_key SET state = ?, status_code = ?, content_type = ?, response_body = ? WHERE ik_id = ? AND lock_expire = ?",
+		idempotencyStateCompleted, status, string(c.Response().Header.ContentType()), string(c.Response().Body()), ikID, lockExpire,
+	)
+	if err != nil {
+		log.Printf("保存幂等响应失败: %v", err)
+	}
+	return nil
+}
+
+// claimIdempotencyKey 为本次请求锁定幂等键，返回幂等记录编号和锁定截止时间
+// 幂等键已被其他请求使用且未超过锁定时限时返回0；处理中的记录超过锁定时限时，相同内容的请求重新锁定并接管
+func claimIdempotencyKey(scope, key, fingerprint string, now time.Time) (int64, string, error) {
+	lockExpire := now.Add(idempotencyLockTimeout()).Format(feedbackTimeLayout)
+
+	result, err := database.DB.Exec(`
+		INSERT IGNORE INTO idempotency_key (scope, idem_key, fingerprint, state, create_date, create_time, lock_expire)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, scope, key, fingerprint, idempotencyStateProcessing, now.Format("2006-01-02"), now.Format("15:04:05"), lockExpire)
+	if err != nil {
+		return 0, "", err
+	}
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		ikID, err := result.LastInsertId()
+		return ikID, lockExpire, err
+	}
+
+	// 带上状态和锁定时间条件，多个重试同时到达时只有一个能接管
+	result, err = database.DB.Exec(
+		"UPDATE idempotency_key SET lock_expire = ? WHERE scope = ? AND idem_key = ? AND fingerprint = ? AND state = ? AND lock_expire < ?",
+		lockExpire, scope, key, fingerprint, idempotencyStateProcessing, now.Format(feedbackTimeLayout),
+	)
+	if err != nil {
+		return 0, "", err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return 0, "", nil
+	}
+
+	var ikID int64
+	err = database.DB.QueryRow("SELECT ik_id FROM idempotency_key WHERE scope = ? AND idem_key = ?", scope, key).Scan(&ikID)
+	if err != nil {
+		return 0, "", err
+	}
+	log.Printf("幂等键 %s 的首次请求超过处理时限，由重试请求接管", key)
+	return ikID, lockExpire, nil
+}
+
+// replayIdempotentResponse 返回同一幂等键首次请求的响应
+func replayIdempotentResponse(c *fiber.Ctx, scope, key, fingerprint string, now time.Time) error {
+	var record models.IdempotencyKey
+	err := database.DB.QueryRow(
+		"SELECT fingerprint, state, status_code, content_type, response_body, lock_expire FROM idempotency_key WHERE scope = ? AND idem_key = ?",
+		scope, key,
+	).Scan(&record.Fingerprint, &record.State, &record.StatusCode, &record.ContentType, &record.ResponseBody, &record.LockExpire)
+	if err == sql.ErrNoRows {
+		// 首次请求刚好失败并删除了记录
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "相同幂等键的请求处理失败，请重试",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询幂等记录失败",
+			"details": err.Error(),
+		})
+	}
+
+	if record.Fingerprint != fingerprint {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": "Idempotency-Key 已用于其他请求，请求内容不一致",
+		})
+	}
+	if record.State == idempotencyStateProcessing {
+		// 告知客户端锁定到期后可以重试
+		if lockExpire, err := time.ParseInLocation(feedbackTimeLayout, record.LockExpire, time.Local); err == nil {
+			retryAfter := int(lockExpire.Sub(now).Seconds()) + 1
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+		}
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "相同幂等键的请求正在处理中",
+		})
+	}
+
+	c.Set("Idempotent-Replayed", "true")
+	if record.ContentType.Valid {
+		c.Set(fiber.HeaderContentType, record.ContentType.String)
+	}
+	return c.Status(record.StatusCode).SendString(record.ResponseBody.String)
+}
+
+// StartIdempotencyCleanup 启动后台任务，每小时清理过期的幂等记录
+func StartIdempotencyCleanup() {
+	startPeriodicTask("清理过期幂等记录", "", time.Hour, cleanupIdempotencyKeys)
+}
+
+// cleanupIdempotencyKeys 删除超过有效期的幂等记录
+func cleanupIdempotencyKeys(now time.Time) error {
+	expired := now.Add(-idempotencyTTL()).Format(feedbackTimeLayout)
+	_, err := database.DB.Exec(
+		"DELETE FROM idempotency_key WHERE CONCAT(create_date, ' ', create_time) < ?",
+		expired,
+	)
+	return err
+}
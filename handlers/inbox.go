@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"epss-backend/database"
+	"epss-backend/models"
+	"epss-backend/notify"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 站内通知中留言内容的最大长度，超出部分省略
+const inboxCommentPreviewLength = 100
+
+// addInboxNotification 写入一条站内通知，标题和内容按 locale 渲染
+func addInboxNotification(db sqlExecer, event, userType string, userID, feedbackID interface{}, data map[string]interface{}, locale string, now time.Time) error {
+	title, content, err := notify.Render(event, locale, data)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO notification (user_type, user_id, event, title, content, af_id, is_read, create_date, create_time)
+		VALUES (?, ?, ?, ?, ?, ?, 0, ?, ?)
+	`, userType, fmt.Sprint(userID), event, title, content, feedbackID, now.Format("2006-01-02"), now.Format("15:04:05"))
+	return err
+}
+
+// notifyCommentAdded 在事务中通知反馈的监督员和网格员有新留言，不通知留言者本人，内部留言不通知监督员
+func notifyCommentAdded(tx sqlExecQueryer, feedbackID int64, authorType, authorID, content string, internal bool, now time.Time) error {
+	var telID, address string
+	var gmID int64
+	err := tx.QueryRow("SELECT tel_id, address, gm_id FROM aqi_feedback WHERE af_id = ?", feedbackID).Scan(&telID, &address, &gmID)
+	if err != nil {
+		return err
+	}
+
+	preview := []rune(content)
+	if len(preview) > inboxCommentPreviewLength {
+		preview = append(preview[:inboxCommentPreviewLength], []rune("……")...)
+	}
+	data := map[string]interface{}{
+		"FeedbackID": feedbackID,
+		"Address":    address,
+		"AuthorType": authorType,
+		"Content":    string(preview),
+	}
+
+	type recipient struct{ userType, userID string }
+	var recipients []recipient
+	if !internal && !(authorType == "supervisor" && authorID == telID) {
+		recipients = append(recipients, recipient{"supervisor", telID})
+	}
+	if gmID > 0 && !(authorType == "member" && authorID == fmt.Sprint(gmID)) {
+		recipients = append(recipients, recipient{"member", fmt.Sprint(gmID)})
+	}
+
+	for _, r := range recipients {
+		locale, err := notificationLocale(tx, r.userType, r.userID)
+		if err != nil {
+			return err
+		}
+		if err := addInboxNotification(tx, notify.EventCommentAdded, r.userType, r.userID, feedbackID, data, locale, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notificationLocale 返回用户通知设置中的语言，未设置时使用中文
+func notificationLocale(db sqlQueryer, userType, userID string) (string, error) {
+	targets, err := loadNotificationTargets(db, userType, userID)
+	if err != nil || len(targets) == 0 {
+		return notify.LocaleZh, err
+	}
+	return notify.NormalizeLocale(targets[0].Locale), nil
+}
+
+// countUnreadNotifications 统计用户的未读站内通知数量
+func countUnreadNotifications(userType, userID string) (int, error) {
+	var count int
+	err := database.DB.QueryRow(
+		"SELECT COUNT(*) FROM notification WHERE user_type = ? AND user_id = ? AND is_read = 0",
+		userType, userID,
+	).Scan(&count)
+	return count, err
+}
+
+// GetInboxNotifications 获取当前用户的站内通知，unread为1时只返回未读通知，limit默认50
+func GetInboxNotifications(c *fiber.Ctx) error {
+	userType, userID := currentNotificationUser(c)
+
+	query := `
+		SELECT nt_id, event, title, content, af_id, is_read, create_date, create_time, read_date, read_time
+		FROM notification
+		WHERE user_type = ? AND user_id = ?
+	`
+	params := []interface{}{userType, userID}
+	if c.Query("unread") == "1" {
+		query += " AND is_read = 0"
+	}
+
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	query += " ORDER BY nt_id DESC LIMIT ?"
+	params = append(params, limit)
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取通知列表失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	notificationList := []fiber.Map{}
+	for rows.Next() {
+		var n models.Notification
+		err := rows.Scan(&n.NtID, &n.Event, &n.Title, &n.Content, &n.AfID, &n.IsRead, &n.CreateDate, &n.CreateTime, &n.ReadDate, &n.ReadTime)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理通知数据失败",
+				"details": err.Error(),
+			})
+		}
+		notificationList = append(notificationList, fiber.Map{
+			"id":          n.NtID,
+			"event":       n.Event,
+			"title":       n.Title,
+			"content":     n.Content,
+			"feedback_id": n.AfID,
+			"read":        n.IsRead == 1,
+			"create_date": n.CreateDate,
+			"create_time": n.CreateTime,
+			"read_date":   n.ReadDate.String,
+			"read_time":   n.ReadTime.String,
+		})
+	}
+	rows.Close()
+
+	unread, err := countUnreadNotifications(userType, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "统计未读通知失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data":         notificationList,
+		"unread_count": unread,
+	})
+}
+
+// MarkNotificationRead 将当前用户的一条站内通知标记为已读
+func MarkNotificationRead(c *fiber.Ctx) error {
+	notificationID := c.Params("id")
+	if notificationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "缺少通知ID"})
+	}
+	userType, userID := currentNotificationUser(c)
+
+	var count int
+	err := database.DB.QueryRow(
+		"SELECT COUNT(*) FROM notification WHERE nt_id = ? AND user_type = ? AND user_id = ?",
+		notificationID, userType, userID,
+	).Scan(&count)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "数据库查询失败"})
+	}
+	if count == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "通知不存在"})
+	}
+
+	now := time.Now()
+	_, err = database.DB.Exec(
+		"UPDATE notification SET is_read = 1, read_date = ?, read_time = ? WHERE nt_id = ? AND is_read = 0",
+		now.Format("2006-01-02"), now.Format("15:04:05"), notificationID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "标记已读失败"})
+	}
+
+	return c.JSON(fiber.Map{"message": "已标记为已读"})
+}
+
+// MarkAllNotificationsRead 将当前用户的所有站内通知标记为已读
+func MarkAllNotificationsRead(c *fiber.Ctx) error {
+	userType, userID := currentNotificationUser(c)
+
+	now := time.Now()
+	result, err := database.DB.Exec(
+		"UPDATE notification SET is_read = 1, read_date = ?, read_time = ? WHERE user_type = ? AND user_id = ? AND is_read = 0",
+		now.Format("2006-01-02"), now.Format("15:04:05"), userType, userID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "标记已读失败",
+			"details": err.Error(),
+		})
+	}
+	updated, _ := result.RowsAffected()
+
+	return c.JSON(fiber.Map{
+		"message": "已全部标记为已读",
+		"updated": updated,
+	})
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/database"
+	"epss-backend/models"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetIncidentGroupList 获取事件组列表，支持state（0:处理中; 1:已确认）、province_id和city_id参数
+func GetIncidentGroupList(c *fiber.Ctx) error {
+	query := `
+		SELECT
+			ig.ig_id, ig.lead_af_id, ig.province_id, ig.city_id, ig.create_date, ig.create_time,
+			ig.state, ig.statistics_id, ig.remarks,
+			IFNULL(af.address, '') as address,
+			(SELECT COUNT(*) FROM aqi_feedback m WHERE m.ig_id = ig.ig_id) as member_count,
+			IFNULL(p.province_name, '') as province_name,
+			IFNULL(ct.city_name, '') as city_name
+		FROM
+			incident_group ig
+		LEFT JOIN
+			aqi_feedback af ON ig.lead_af_id = af.af_id
+		LEFT JOIN
+			grid_province p ON ig.province_id = p.province_id
+		LEFT JOIN
+			grid_city ct ON ig.city_id = ct.city_id
+		WHERE 1 = 1
+	`
+	params := []interface{}{}
+
+	if stateParam := c.Query("state"); stateParam != "" {
+		if state, err := strconv.Atoi(stateParam); err == nil && (state == 0 || state == 1) {
+			query += " AND ig.state = ?"
+			params = append(params, state)
+		}
+	}
+	if provinceID := c.Query("province_id"); provinceID != "" {
+		query += " AND ig.province_id = ?"
+		params = append(params, provinceID)
+		if cityID := c.Query("city_id"); cityID != "" {
+			query += " AND ig.city_id = ?"
+			params = append(params, cityID)
+		}
+	}
+	query += " ORDER BY ig.ig_id DESC"
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取事件组列表失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var groupList []fiber.Map
+	for rows.Next() {
+		var group models.IncidentGroup
+		var address, provinceName, cityName string
+		var memberCount int
+
+		err := rows.Scan(
+			&group.IgID, &group.LeadAfID, &group.ProvinceID, &group.CityID, &group.CreateDate, &group.CreateTime,
+			&group.State, &group.StatisticsID, &group.Remarks,
+			&address, &memberCount, &provinceName, &cityName,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理事件组数据失败",
+				"details": err.Error(),
+			})
+		}
+
+		groupList = append(groupList, fiber.Map{
+			"id":            group.IgID,
+			"lead_af_id":    group.LeadAfID,
+			"address":       address,
+			"province_id":   group.ProvinceID,
+			"province_name": provinceName,
+			"city_id":       group.CityID,
+			"city_name":     cityName,
+			"member_count":  memberCount,
+			"create_date":   group.CreateDate,
+			"create_time":   group.CreateTime,
+			"state":         group.State,
+			"state_text":    getIncidentStateText(group.State),
+			"statistics_id": group.StatisticsID,
+			"remarks":       group.Remarks.String,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": groupList,
+	})
+}
+
+// GetIncidentGroupMembers 获取事件组内的全部反馈
+func GetIncidentGroupMembers(c *fiber.Ctx) error {
+	igID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的事件组ID",
+		})
+	}
+
+	query := `
+		SELECT
+			af.af_id, af.tel_id, af.address, af.information, af.estimated_grade,
+			af.af_date, af.af_time, af.gm_id, af.state,
+			af.latitude, af.longitude,
+			IFNULL(gm.gm_name, '') as gm_name
+		FROM
+			aqi_feedback af
+		LEFT JOIN
+			grid_member gm ON af.gm_id = gm.gm_id
+		WHERE
+			af.ig_id = ?
+		ORDER BY
+			af.af_id ASC
+	`
+
+	rows, err := database.DB.Query(query, igID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取事件组反馈失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var memberList []fiber.Map
+	for rows.Next() {
+		var feedback models.AqiFeedback
+		var gmName string
+
+		err := rows.Scan(
+			&feedback.AfID, &feedback.TelID, &feedback.Address, &feedback.Information, &feedback.EstimatedGrade,
+			&feedback.AfDate, &feedback.AfTime, &feedback.GmID, &feedback.State,
+			&feedback.Latitude, &feedback.Longitude,
+			&gmName,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理事件组反馈失败",
+				"details": err.Error(),
+			})
+		}
+
+		memberList = append(memberList, fiber.Map{
+			"af_id":            feedback.AfID,
+			"tel_id":           feedback.TelID,
+			"address":          feedback.Address,
+			"information":      feedback.Information,
+			"estimated_grade":  feedback.EstimatedGrade,
+			"af_date":          feedback.AfDate,
+			"af_time":          feedback.AfTime,
+			"gm_id":            feedback.GmID,
+			"grid_member_name": gmName,
+			"state":            feedback.State,
+			"state_text":       getStateText(feedback.State),
+			"latitude":         nullFloatValue(feedback.Latitude),
+			"longitude":        nullFloatValue(feedback.Longitude),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": memberList,
+	})
+}
+
+// UnlinkIncidentFeedback 将误判为重复的反馈移出事件组，组内只剩一条反馈时解散事件组
+func UnlinkIncidentFeedback(c *fiber.Ctx) error {
+	feedbackID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的反馈ID",
+		})
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "启动数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	var igID, leadAfID int64
+	var groupState int
+	err = tx.QueryRow(`
+		SELECT ig.ig_id, ig.lead_af_id, ig.state
+		FROM aqi_feedback af
+		JOIN incident_group ig ON af.ig_id = ig.ig_id
+		WHERE af.af_id = ?
+		FOR UPDATE
+	`, feedbackID).Scan(&igID, &leadAfID, &groupState)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "反馈不存在或不属于任何事件组",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询事件组失败",
+			"details": err.Error(),
+		})
+	}
+	if groupState != 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "事件组已确认，不能移出反馈",
+		})
+	}
+
+	dissolved, err := leaveIncidentGroup(tx, feedbackID, igID, leadAfID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "移出事件组失败",
+			"details": err.Error(),
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "提交数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+
+	message := "反馈已移出事件组"
+	if dissolved {
+		message = "反馈已移出事件组，事件组已解散"
+	}
+	return c.JSON(fiber.Map{
+		"message": message,
+		"data": fiber.Map{
+			"feedback_id":       feedbackID,
+			"incident_group_id": igID,
+			"dissolved":         dissolved,
+		},
+	})
+}
+
+// 获取事件组状态文本描述
+func getIncidentStateText(state int) string {
+	switch state {
+	case 0:
+		return "处理中"
+	case 1:
+		return "已确认"
+	default:
+		return "未知状态"
+	}
+}
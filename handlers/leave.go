@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/database"
+	"epss-backend/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 请假审批状态
+const (
+	leaveStatePending   = 0 // 待审批
+	leaveStateApproved  = 1 // 已批准
+	leaveStateRejected  = 2 // 已驳回
+	leaveStateCancelled = 3 // 已取消
+)
+
+// ApplyLeave 网格员提交请假申请
+func ApplyLeave(c *fiber.Ctx) error {
+	gmID := c.Locals("user_gm_id")
+	if gmID == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	var req struct {
+		LeaveType int    `json:"leave_type"`
+		StartDate string `json:"start_date"`
+		StartTime string `json:"start_time"`
+		EndDate   string `json:"end_date"`
+		EndTime   string `json:"end_time"`
+		Reason    string `json:"reason"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "请求数据格式错误",
+			"details": err.Error(),
+		})
+	}
+
+	if req.LeaveType == 0 {
+		req.LeaveType = 1
+	}
+	if req.LeaveType < 1 || req.LeaveType > 4 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "请假类型必须为1-4",
+		})
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "请填写请假事由",
+		})
+	}
+	if len([]rune(req.Reason)) > 400 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "请假事由不能超过400个字符",
+		})
+	}
+
+	// 未填写时间时从开始日期的0点请到结束日期的24点
+	if req.StartTime == "" {
+		req.StartTime = "00:00:00"
+	}
+	if req.EndTime == "" {
+		req.EndTime = "23:59:59"
+	}
+	startTime, okStart := normalizeClock(req.StartTime)
+	endTime, okEnd := normalizeClock(req.EndTime)
+	if !validDate(req.StartDate) || !validDate(req.EndDate) || !okStart || !okEnd {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "日期格式必须为YYYY-MM-DD，时间格式必须为HH:MM或HH:MM:SS",
+		})
+	}
+	start := req.StartDate + " " + startTime
+	end := req.EndDate + " " + endTime
+	if end <= start {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "结束时间必须晚于开始时间",
+		})
+	}
+
+	// 不能与待审批或已批准的请假时间重叠
+	var overlap int
+	err := database.DB.QueryRow(`
+		SELECT COUNT(*) FROM member_leave
+		WHERE gm_id = ? AND state IN (?, ?)
+		AND CONCAT(start_date, ' ', start_time) < ? AND CONCAT(end_date, ' ', end_time) > ?
+	`, gmID, leaveStatePending, leaveStateApproved, end, start).Scan(&overlap)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询请假记录失败",
+			"details": err.Error(),
+		})
+	}
+	if overlap > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "与已有的请假时间重叠",
+		})
+	}
+
+	now := time.Now()
+	applyDate := now.Format("2006-01-02")
+	applyTime := now.Format("15:04:05")
+
+	result, err := database.DB.Exec(`
+		INSERT INTO member_leave (gm_id, leave_type, start_date, start_time, end_date, end_time, reason, state, apply_date, apply_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, gmID, req.LeaveType, req.StartDate, startTime, req.EndDate, endTime, req.Reason, leaveStatePending, applyDate, applyTime)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "提交请假申请失败",
+			"details": err.Error(),
+		})
+	}
+	leaveID, _ := result.LastInsertId()
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "请假申请已提交，等待管理员审批",
+		"data": fiber.Map{
+			"id":         leaveID,
+			"leave_type": req.LeaveType,
+			"start_date": req.StartDate,
+			"start_time": startTime,
+			"end_date":   req.EndDate,
+			"end_time":   endTime,
+			"state":      leaveStatePending,
+			"state_text": getLeaveStateText(leaveStatePending),
+		},
+	})
+}
+
+// CancelLeave 网格员取消待审批或尚未开始的请假
+func CancelLeave(c *fiber.Ctx) error {
+	gmID := c.Locals("user_gm_id")
+	if gmID == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	leaveID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的请假申请ID",
+		})
+	}
+
+	var state int
+	var start string
+	err = database.DB.QueryRow(
+		"SELECT state, CONCAT(start_date, ' ', start_time) FROM member_leave WHERE leave_id = ? AND gm_id = ?",
+		leaveID, gmID,
+	).Scan(&state, &start)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "请假申请不存在",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询请假申请失败",
+			"details": err.Error(),
+		})
+	}
+
+	now := time.Now()
+	started := start <= now.Format(feedbackTimeLayout)
+	if state != leaveStatePending && !(state == leaveStateApproved && !started) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "只能取消待审批或尚未开始的请假",
+		})
+	}
+
+	result, err := database.DB.Exec(
+		"UPDATE member_leave SET state = ? WHERE leave_id = ? AND state = ?",
+		leaveStateCancelled, leaveID, state,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "取消请假失败",
+			"details": err.Error(),
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "请假申请状态已变化，请刷新后重试",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "请假已取消",
+		"data": fiber.Map{
+			"id":         leaveID,
+			"state":      leaveStateCancelled,
+			"state_text": getLeaveStateText(leaveStateCancelled),
+		},
+	})
+}
+
+// GetLeaveList 获取请假申请列表，支持state参数筛选
+// 网格员只能查看自己的申请，管理员可通过gm_id参数筛选
+func GetLeaveList(c *fiber.Ctx) error {
+	query := `
+		SELECT
+			ml.leave_id, ml.gm_id, ml.leave_type, ml.start_date, ml.start_time, ml.end_date, ml.end_time,
+			ml.reason, ml.state, ml.apply_date, ml.apply_time,
+			ml.admin_id, ml.review_date, ml.review_time, ml.review_remarks,
+			IFNULL(gm.gm_name, '') as gm_name
+		FROM
+			member_leave ml
+		LEFT JOIN
+			grid_member gm ON ml.gm_id = gm.gm_id
+		WHERE 1 = 1
+	`
+	params := []interface{}{}
+
+	if c.Locals("user_type") == "member" {
+		query += " AND ml.gm_id = ?"
+		params = append(params, c.Locals("user_gm_id"))
+	} else if gmID := c.Query("gm_id"); gmID != "" {
+		query += " AND ml.gm_id = ?"
+		params = append(params, gmID)
+	}
+	if stateParam := c.Query("state"); stateParam != "" {
+		if state, err := strconv.Atoi(stateParam); err == nil {
+			query += " AND ml.state = ?"
+			params = append(params, state)
+		}
+	}
+	query += " ORDER BY ml.leave_id DESC"
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取请假申请列表失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var leaveList []fiber.Map
+	for rows.Next() {
+		var leave models.MemberLeave
+		var gmName string
+
+		err := rows.Scan(
+			&leave.LeaveID, &leave.GmID, &leave.LeaveType, &leave.StartDate, &leave.StartTime, &leave.EndDate, &leave.EndTime,
+			&leave.Reason, &leave.State, &leave.ApplyDate, &leave.ApplyTime,
+			&leave.AdminID, &leave.ReviewDate, &leave.ReviewTime, &leave.ReviewRemarks,
+			&gmName,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理请假申请数据失败",
+				"details": err.Error(),
+			})
+		}
+
+		leaveList = append(leaveList, fiber.Map{
+			"id":              leave.LeaveID,
+			"gm_id":           leave.GmID,
+			"gm_name":         gmName,
+			"leave_type":      leave.LeaveType,
+			"leave_type_text": getLeaveTypeText(leave.LeaveType),
+			"start_date":      leave.StartDate,
+			"start_time":      leave.StartTime,
+			"end_date":        leave.EndDate,
+			"end_time":        leave.EndTime,
+			"reason":          leave.Reason,
+			"state":           leave.State,
+			"state_text":      getLeaveStateText(leave.State),
+			"apply_date":      leave.ApplyDate,
+			"apply_time":      leave.ApplyTime,
+			"admin_id":        nullIntValue(leave.AdminID),
+			"review_date":     leave.ReviewDate.String,
+			"review_time":     leave.ReviewTime.String,
+			"review_remarks":  leave.ReviewRemarks.String,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": leaveList,
+	})
+}
+
+// ReviewLeave 管理员审批请假申请，批准后请假期间网格员自动转为非工作状态
+func ReviewLeave(c *fiber.Ctx) error {
+	leaveID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的请假申请ID",
+		})
+	}
+
+	var req struct {
+		Approved bool   `json:"approved"`
+		Remarks  string `json:"remarks"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的请求数据",
+		})
+	}
+	req.Remarks = strings.TrimSpace(req.Remarks)
+	if !req.Approved && req.Remarks == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "驳回时请填写审批意见",
+		})
+	}
+	if len([]rune(req.Remarks)) > 200 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "审批意见不能超过200个字符",
+		})
+	}
+
+	var gmID int64
+	var state int
+	err = database.DB.QueryRow("SELECT gm_id, state FROM member_leave WHERE leave_id = ?", leaveID).Scan(&gmID, &state)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "请假申请不存在",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询请假申请失败",
+			"details": err.Error(),
+		})
+	}
+	if state != leaveStatePending {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "该请假申请已处理",
+		})
+	}
+
+	newState := leaveStateRejected
+	if req.Approved {
+		newState = leaveStateApproved
+	}
+
+	var remarks interface{}
+	if req.Remarks != "" {
+		remarks = req.Remarks
+	}
+
+	now := time.Now()
+	reviewDate := now.Format("2006-01-02")
+	reviewTime := now.Format("15:04:05")
+
+	result, err := database.DB.Exec(
+		"UPDATE member_leave SET state = ?, admin_id = ?, review_date = ?, review_time = ?, review_remarks = ? WHERE leave_id = ? AND state = ?",
+		newState, c.Locals("user_id"), reviewDate, reviewTime, remarks, leaveID, leaveStatePending,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "审批请假申请失败",
+			"details": err.Error(),
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "该请假申请已处理",
+		})
+	}
+
+	availability, err := refreshMemberState(gmID, now)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "更新网格员状态失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "请假申请审批完成",
+		"data": fiber.Map{
+			"id":           leaveID,
+			"gm_id":        gmID,
+			"state":        newState,
+			"state_text":   getLeaveStateText(newState),
+			"review_date":  reviewDate,
+			"review_time":  reviewTime,
+			"availability": availability,
+		},
+	})
+}
+
+// 获取请假审批状态文本描述
+func getLeaveStateText(state int) string {
+	switch state {
+	case leaveStatePending:
+		return "待审批"
+	case leaveStateApproved:
+		return "已批准"
+	case leaveStateRejected:
+		return "已驳回"
+	case leaveStateCancelled:
+		return "已取消"
+	default:
+		return "未知状态"
+	}
+}
+
+// 获取请假类型文本描述
+func getLeaveTypeText(leaveType int) string {
+	switch leaveType {
+	case 1:
+		return "事假"
+	case 2:
+		return "病假"
+	case 3:
+		return "年假"
+	case 4:
+		return "其他"
+	default:
+		return "未知类型"
+	}
+}
@@ -0,0 +1,687 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/config"
+	"epss-backend/database"
+	"epss-backend/models"
+	"epss-backend/notify"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 通知发送状态
+const (
+	notificationStatePending = 0 // 待发送
+	notificationStateSent    = 1 // 已发送
+	notificationStateFailed  = 2 // 超过重试次数，发送失败
+)
+
+// 每轮最多发送的通知数量
+const notificationBatchSize = 100
+
+// 发件箱记录被领取后推迟的时间，超过单轮发送的最长用时
+const outboxClaimLease = 5 * time.Minute
+
+// 每轮同时发送的数量
+const outboxSendWorkers = 8
+
+var phonePattern = regexp.MustCompile(`^\+?[0-9]{5,20}$`)
+
+// sqlExecQueryer *sql.DB 和 *sql.Tx 共有的查询和执行方法
+type sqlExecQueryer interface {
+	sqlExecer
+	sqlQueryer
+}
+
+// notificationMaxAttempts 读取通知的最大发送次数，默认5次
+func notificationMaxAttempts() int {
+	attempts := 5
+	if value := config.Config("NOTIFY_MAX_ATTEMPTS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("警告: NOTIFY_MAX_ATTEMPTS 配置无效 (%s), 使用默认值 %d", value, attempts)
+		} else {
+			attempts = parsed
+		}
+	}
+	return attempts
+}
+
+// notificationRetryDelay 第 attempts 次发送失败后的重试间隔：从1分钟开始每次翻倍，最长1小时
+func notificationRetryDelay(attempts int) time.Duration {
+	delay := time.Minute
+	for i := 1; i < attempts && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > time.Hour {
+		delay = time.Hour
+	}
+	return delay
+}
+
+// loadNotificationTargets 查询用户启用的通知渠道
+// 用户没有任何设置时，网格员和监督员默认通过短信接收中文通知，管理员需要自行设置
+func loadNotificationTargets(db sqlQueryer, userType, userID string) ([]models.NotificationPreference, error) {
+	rows, err := db.Query(
+		"SELECT np_id, channel, address, locale, enabled FROM notification_preference WHERE user_type = ? AND user_id = ?",
+		userType, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var targets []models.NotificationPreference
+	configured := false
+	for rows.Next() {
+		pref := models.NotificationPreference{UserType: userType, UserID: userID}
+		if err := rows.Scan(&pref.NpID, &pref.Channel, &pref.Address, &pref.Locale, &pref.Enabled); err != nil {
+			return nil, err
+		}
+		configured = true
+		// 回调渠道只对管理员开放，忽略其他用户之前保存的回调地址
+		if pref.Channel == notify.ChannelWebhook && userType != "admin" {
+			continue
+		}
+		if pref.Enabled == 1 {
+			targets = append(targets, pref)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if configured {
+		return targets, nil
+	}
+
+	var phone string
+	switch userType {
+	case "member":
+		err := db.QueryRow("SELECT tel FROM grid_member WHERE gm_id = ?", userID).Scan(&phone)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+	case "supervisor":
+		phone = userID
+	}
+	if phone == "" {
+		return nil, nil
+	}
+	return []models.NotificationPreference{{
+		UserType: userType,
+		UserID:   userID,
+		Channel:  notify.ChannelSMS,
+		Address:  phone,
+		Locale:   notify.LocaleZh,
+		Enabled:  1,
+	}}, nil
+}
+
+// enqueueNotification 将通知写入发件箱，每个启用的渠道一条，由后台任务发送，同时写入一条站内通知
+// 服务端未启用的渠道（如未配置短信网关）不写入发件箱，只保留站内通知
+// 在业务事务中调用时，通知与业务数据一起提交或回滚
+func enqueueNotification(db sqlExecQueryer, event, userType string, userID, feedbackID interface{}, data map[string]interface{}, now time.Time) error {
+	recipient := fmt.Sprint(userID)
+	targets, err := loadNotificationTargets(db, userType, recipient)
+	if err != nil {
+		return err
+	}
+
+	inboxLocale := notify.LocaleZh
+	if len(targets) > 0 {
+		inboxLocale = notify.NormalizeLocale(targets[0].Locale)
+	}
+	if err := addInboxNotification(db, event, userType, recipient, feedbackID, data, inboxLocale, now); err != nil {
+		return err
+	}
+
+	date, clock := now.Format("2006-01-02"), now.Format("15:04:05")
+	for _, target := range targets {
+		if _, err := notify.Get(target.Channel); err != nil {
+			continue
+		}
+		locale := notify.NormalizeLocale(target.Locale)
+		subject, content, err := notify.Render(event, locale, data)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(`
+			INSERT INTO notification_outbox
+			(event, user_type, user_id, channel, address, locale, subject, content, af_id, state, attempts,
+			 next_date, next_time, create_date, create_time)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?, ?)
+		`, event, userType, recipient, target.Channel, target.Address, locale, subject, content, feedbackID,
+			notificationStatePending, date, clock, date, clock)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyTaskAssigned 通知网格员有新的任务
+func notifyTaskAssigned(tx sqlExecQueryer, feedbackID, gmID int, now time.Time) error {
+	var address string
+	var grade int
+	err := tx.QueryRow("SELECT address, estimated_grade FROM aqi_feedback WHERE af_id = ?", feedbackID).Scan(&address, &grade)
+	if err != nil {
+		return err
+	}
+	return enqueueNotification(tx, notify.EventTaskAssigned, "member", gmID, feedbackID, map[string]interface{}{
+		"FeedbackID": feedbackID,
+		"Address":    address,
+		"Grade":      grade,
+	}, now)
+}
+
+// notifyFeedbackConfirmed 通知监督员反馈已实测确认，includeGroup 为 true 时同时通知同一事件组中已确认反馈的监督员
+func notifyFeedbackConfirmed(db sqlExecQueryer, feedbackID int, includeGroup bool, aqi models.Aqi, now time.Time) error {
+	query := "SELECT af_id, tel_id, address, af_date FROM aqi_feedback WHERE af_id = ? AND state = 2"
+	params := []interface{}{feedbackID}
+	if includeGroup {
+		query += " UNION SELECT af_id, tel_id, address, af_date FROM aqi_feedback WHERE state = 2 AND ig_id > 0 AND ig_id = (SELECT ig_id FROM aqi_feedback WHERE af_id = ?)"
+		params = append(params, feedbackID)
+	}
+
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return err
+	}
+	type confirmedFeedback struct {
+		afID                 int64
+		telID, address, date string
+	}
+	var feedbacks []confirmedFeedback
+	for rows.Next() {
+		var f confirmedFeedback
+		if err := rows.Scan(&f.afID, &f.telID, &f.address, &f.date); err != nil {
+			rows.Close()
+			return err
+		}
+		feedbacks = append(feedbacks, f)
+	}
+	rows.Close()
+
+	for _, f := range feedbacks {
+		err := enqueueNotification(db, notify.EventFeedbackConfirmed, "supervisor", f.telID, f.afID, map[string]interface{}{
+			"FeedbackID": f.afID,
+			"Address":    f.address,
+			"Date":       f.date,
+			"Grade":      aqi.AqiID,
+			"GradeName":  aqi.ChineseExplain,
+		}, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyFeedbackEscalated 通知管理员反馈已超时
+func notifyFeedbackEscalated(tx sqlExecQueryer, feedbackID, adminID int64, stage int, deadline, now time.Time) error {
+	var address string
+	if err := tx.QueryRow("SELECT address FROM aqi_feedback WHERE af_id = ?", feedbackID).Scan(&address); err != nil {
+		return err
+	}
+	return enqueueNotification(tx, notify.EventFeedbackEscalated, "admin", adminID, feedbackID, map[string]interface{}{
+		"FeedbackID": feedbackID,
+		"Address":    address,
+		"Stage":      stage,
+		"Deadline":   deadline.Format(feedbackTimeLayout),
+	}, now)
+}
+
+// StartNotificationDispatcher 启动后台任务，定期发送发件箱中的通知，失败时按递增间隔重试
+// 发送间隔通过 NOTIFY_DISPATCH_INTERVAL 配置，默认每10秒一次
+func StartNotificationDispatcher() {
+	startPeriodicTask("发送通知", "NOTIFY_DISPATCH_INTERVAL", 10*time.Second, dispatchNotifications)
+}
+
+// dispatchNotifications 领取并发送到期的待发送通知
+func dispatchNotifications(now time.Time) error {
+	ids, err := claimOutboxRows("notification_outbox", "no_id", notificationStatePending, notificationBatchSize, now)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	rows, err := database.DB.Query(
+		"SELECT no_id, event, channel, address, subject, content, attempts FROM notification_outbox WHERE no_id IN ("+placeholders+") ORDER BY no_id ASC",
+		ids...,
+	)
+	if err != nil {
+		return err
+	}
+	var pending []models.NotificationOutbox
+	for rows.Next() {
+		var n models.NotificationOutbox
+		if err := rows.Scan(&n.NoID, &n.Event, &n.Channel, &n.Address, &n.Subject, &n.Content, &n.Attempts); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, n)
+	}
+	rows.Close()
+
+	maxAttempts := notificationMaxAttempts()
+	return forEachConcurrently(len(pending), func(i int) error {
+		n := pending[i]
+		ch, err := notify.Get(n.Channel)
+		if err == nil {
+			err = ch.Send(notify.Message{Event: n.Event, Address: n.Address, Subject: n.Subject, Content: n.Content})
+		}
+
+		attempts := n.Attempts + 1
+		sentAt := time.Now()
+		if err == nil {
+			_, err = database.DB.Exec(
+				"UPDATE notification_outbox SET state = ?, attempts = ?, last_error = NULL, sent_date = ?, sent_time = ? WHERE no_id = ?",
+				notificationStateSent, attempts, sentAt.Format("2006-01-02"), sentAt.Format("15:04:05"), n.NoID,
+			)
+			return err
+		}
+
+		// 渠道在写入后被停用时重试也不会成功，直接标记为发送失败
+		state := notificationStatePending
+		if attempts >= maxAttempts || err == notify.ErrChannelDisabled {
+			state = notificationStateFailed
+		}
+		next := sentAt.Add(notificationRetryDelay(attempts))
+		message := err.Error()
+		if len([]rune(message)) > 500 {
+			message = string([]rune(message)[:500])
+		}
+		_, err = database.DB.Exec(
+			"UPDATE notification_outbox SET state = ?, attempts = ?, last_error = ?, next_date = ?, next_time = ? WHERE no_id = ?",
+			state, attempts, message, next.Format("2006-01-02"), next.Format("15:04:05"), n.NoID,
+		)
+		return err
+	})
+}
+
+// claimOutboxRows 领取到期的待发送记录：锁定后将下次发送时间推迟一个租约时间，返回领取的记录编号
+// 多个实例或相邻两轮同时发送时，已领取的记录不会被重复发送；发送中断时租约到期后重新发送
+func claimOutboxRows(table, idColumn string, pendingState, limit int, now time.Time) ([]interface{}, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		"SELECT "+idColumn+" FROM "+table+" WHERE state = ? AND CONCAT(next_date, ' ', next_time) <= ? ORDER BY "+idColumn+" ASC LIMIT ? FOR UPDATE",
+		pendingState, now.Format(feedbackTimeLayout), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var ids []interface{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	lease := now.Add(outboxClaimLease)
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	_, err = tx.Exec(
+		"UPDATE "+table+" SET next_date = ?, next_time = ? WHERE "+idColumn+" IN ("+placeholders+")",
+		append([]interface{}{lease.Format("2006-01-02"), lease.Format("15:04:05")}, ids...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return ids, tx.Commit()
+}
+
+// forEachConcurrently 以 outboxSendWorkers 个并发执行 fn，避免单个缓慢的接收方阻塞整批发送，返回第一个错误
+func forEachConcurrently(n int, fn func(i int) error) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	indexes := make(chan int)
+	for w := 0; w < outboxSendWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(i); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return firstErr
+}
+
+// currentNotificationUser 返回当前登录用户的类型和在通知中使用的编号
+func currentNotificationUser(c *fiber.Ctx) (string, string) {
+	userType := fmt.Sprint(c.Locals("user_type"))
+	if userType == "supervisor" {
+		return userType, fmt.Sprint(c.Locals("user_tel_id"))
+	}
+	return userType, fmt.Sprint(c.Locals("user_id"))
+}
+
+// GetNotificationPreferences 获取当前用户的通知设置
+// 没有任何设置时返回默认设置（网格员和监督员默认通过短信接收）
+func GetNotificationPreferences(c *fiber.Ctx) error {
+	userType, userID := currentNotificationUser(c)
+
+	rows, err := database.DB.Query(
+		"SELECT np_id, channel, address, locale, enabled FROM notification_preference WHERE user_type = ? AND user_id = ? ORDER BY np_id",
+		userType, userID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取通知设置失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	preferenceList := []fiber.Map{}
+	for rows.Next() {
+		var pref models.NotificationPreference
+		if err := rows.Scan(&pref.NpID, &pref.Channel, &pref.Address, &pref.Locale, &pref.Enabled); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理通知设置失败",
+				"details": err.Error(),
+			})
+		}
+		preferenceList = append(preferenceList, fiber.Map{
+			"id":           pref.NpID,
+			"channel":      pref.Channel,
+			"channel_text": getNotificationChannelText(pref.Channel),
+			"address":      pref.Address,
+			"locale":       pref.Locale,
+			"enabled":      pref.Enabled == 1,
+		})
+	}
+	rows.Close()
+
+	isDefault := len(preferenceList) == 0
+	if isDefault {
+		targets, err := loadNotificationTargets(database.DB, userType, userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "获取通知设置失败",
+				"details": err.Error(),
+			})
+		}
+		for _, target := range targets {
+			preferenceList = append(preferenceList, fiber.Map{
+				"id":           0,
+				"channel":      target.Channel,
+				"channel_text": getNotificationChannelText(target.Channel),
+				"address":      target.Address,
+				"locale":       target.Locale,
+				"enabled":      true,
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"data": fiber.Map{
+			"default":     isDefault,
+			"preferences": preferenceList,
+		},
+	})
+}
+
+// UpdateNotificationPreference 设置当前用户在某个渠道的通知接收方式
+// 设置任意渠道后不再使用默认设置，未设置的渠道不接收通知
+func UpdateNotificationPreference(c *fiber.Ctx) error {
+	var req struct {
+		Channel string `json:"channel"`
+		Address string `json:"address"`
+		Locale  string `json:"locale"`
+		Enabled *bool  `json:"enabled"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+	}
+
+	if message := validateNotificationAddress(req.Channel, req.Address); message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+	if req.Locale != "" && req.Locale != notify.LocaleZh && req.Locale != notify.LocaleEn {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "语言必须为zh或en",
+		})
+	}
+	locale := notify.NormalizeLocale(req.Locale)
+	enabled := 1
+	if req.Enabled != nil && !*req.Enabled {
+		enabled = 0
+	}
+
+	// 回调请求由服务端发出，只允许管理员设置，防止借此访问内部网络
+	userType, userID := currentNotificationUser(c)
+	if req.Channel == notify.ChannelWebhook && userType != "admin" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "只有管理员可以设置回调通知",
+		})
+	}
+	_, err := database.DB.Exec(`
+		INSERT INTO notification_preference (user_type, user_id, channel, address, locale, enabled)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE address = VALUES(address), locale = VALUES(locale), enabled = VALUES(enabled)
+	`, userType, userID, req.Channel, req.Address, locale, enabled)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "保存通知设置失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "通知设置已保存",
+		"data": fiber.Map{
+			"channel": req.Channel,
+			"address": req.Address,
+			"locale":  locale,
+			"enabled": enabled == 1,
+		},
+	})
+}
+
+// validateNotificationAddress 校验渠道和接收地址，有效时返回空字符串
+func validateNotificationAddress(channel, address string) string {
+	switch channel {
+	case notify.ChannelSMS:
+		if !phonePattern.MatchString(address) {
+			return "请提供有效的手机号码"
+		}
+	case notify.ChannelEmail:
+		parsed, err := mail.ParseAddress(address)
+		if err != nil || parsed.Address != address {
+			return "请提供有效的邮箱地址"
+		}
+	case notify.ChannelWebhook:
+		parsed, err := url.ParseRequestURI(address)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return "回调地址必须为http或https地址"
+		}
+	default:
+		return "渠道必须为sms、email或webhook"
+	}
+	if len(address) > 200 {
+		return "接收地址不能超过200个字符"
+	}
+	return ""
+}
+
+// GetNotificationList 管理员查看通知发件箱，支持state、event、user_type参数筛选，limit默认100
+func GetNotificationList(c *fiber.Ctx) error {
+	query := `
+		SELECT
+			no_id, event, user_type, user_id, channel, address, locale, subject, content, af_id,
+			state, attempts, next_date, next_time, last_error, create_date, create_time, sent_date, sent_time
+		FROM
+			notification_outbox
+		WHERE 1 = 1
+	`
+	params := []interface{}{}
+
+	if state := c.Query("state"); state != "" {
+		query += " AND state = ?"
+		params = append(params, state)
+	}
+	if event := c.Query("event"); event != "" {
+		query += " AND event = ?"
+		params = append(params, event)
+	}
+	if userType := c.Query("user_type"); userType != "" {
+		query += " AND user_type = ?"
+		params = append(params, userType)
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	query += " ORDER BY no_id DESC LIMIT ?"
+	params = append(params, limit)
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取通知列表失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	notificationList := []fiber.Map{}
+	for rows.Next() {
+		var n models.NotificationOutbox
+		err := rows.Scan(
+			&n.NoID, &n.Event, &n.UserType, &n.UserID, &n.Channel, &n.Address, &n.Locale, &n.Subject, &n.Content, &n.AfID,
+			&n.State, &n.Attempts, &n.NextDate, &n.NextTime, &n.LastError, &n.CreateDate, &n.CreateTime, &n.SentDate, &n.SentTime,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理通知数据失败",
+				"details": err.Error(),
+			})
+		}
+
+		notificationList = append(notificationList, fiber.Map{
+			"id":           n.NoID,
+			"event":        n.Event,
+			"user_type":    n.UserType,
+			"user_id":      n.UserID,
+			"channel":      n.Channel,
+			"channel_text": getNotificationChannelText(n.Channel),
+			"address":      n.Address,
+			"locale":       n.Locale,
+			"subject":      n.Subject,
+			"content":      n.Content,
+			"feedback_id":  n.AfID,
+			"state":        n.State,
+			"state_text":   getNotificationStateText(n.State),
+			"attempts":     n.Attempts,
+			"next_date":    n.NextDate,
+			"next_time":    n.NextTime,
+			"last_error":   n.LastError.String,
+			"create_date":  n.CreateDate,
+			"create_time":  n.CreateTime,
+			"sent_date":    n.SentDate.String,
+			"sent_time":    n.SentTime.String,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": notificationList,
+	})
+}
+
+// RetryNotification 重新发送发送失败的通知
+func RetryNotification(c *fiber.Ctx) error {
+	notificationID := c.Params("id")
+	if notificationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "缺少通知ID"})
+	}
+
+	now := time.Now()
+	result, err := database.DB.Exec(
+		"UPDATE notification_outbox SET state = ?, attempts = 0, next_date = ?, next_time = ? WHERE no_id = ? AND state = ?",
+		notificationStatePending, now.Format("2006-01-02"), now.Format("15:04:05"), notificationID, notificationStateFailed,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "重新发送通知失败",
+			"details": err.Error(),
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "通知不存在或不是发送失败状态",
+		})
+	}
+
+	return c.JSON(fiber.Map{"message": "通知已重新加入发送队列"})
+}
+
+// 获取通知渠道文本描述
+func getNotificationChannelText(channel string) string {
+	switch channel {
+	case notify.ChannelSMS:
+		return "短信"
+	case notify.ChannelEmail:
+		return "邮件"
+	case notify.ChannelWebhook:
+		return "回调地址"
+	default:
+		return "未知"
+	}
+}
+
+// 获取通知发送状态文本描述
+func getNotificationStateText(state int) string {
+	switch state {
+	case notificationStatePending:
+		return "待发送"
+	case notificationStateSent:
+		return "已发送"
+	case notificationStateFailed:
+		return "发送失败"
+	default:
+		return "未知"
+	}
+}
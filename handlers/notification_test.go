@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"epss-backend/database"
+	"epss-backend/notify"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeResultSet 测试数据库对某条查询返回的结果
+type fakeResultSet struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+// fakeStatement 测试数据库记录的一次执行
+type fakeStatement struct {
+	query string
+	args  []driver.Value
+}
+
+// fakeDB 记录执行的语句，查询结果由 query 函数按语句返回，用于不连接数据库测试发件箱
+type fakeDB struct {
+	mu    sync.Mutex
+	execs []fakeStatement
+	query func(query string, args []driver.Value) fakeResultSet
+}
+
+// useFakeDB 将 database.DB 替换为测试数据库，测试结束后恢复
+func useFakeDB(t *testing.T, query func(query string, args []driver.Value) fakeResultSet) *fakeDB {
+	t.Helper()
+	db := &fakeDB{query: query}
+	previous := database.DB
+	database.DB = sql.OpenDB(db)
+	t.Cleanup(func() {
+		database.DB.Close()
+		database.DB = previous
+	})
+	return db
+}
+
+// execsMatching 返回语句中包含 fragment 的执行记录
+func (db *fakeDB) execsMatching(fragment string) []fakeStatement {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var matched []fakeStatement
+	for _, exec := range db.execs {
+		if strings.Contains(exec.query, fragment) {
+			matched = append(matched, exec)
+		}
+	}
+	return matched
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.execs = append(s.db.execs, fakeStatement{query: s.query, args: args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	var result fakeResultSet
+	if s.db.query != nil {
+		result = s.db.query(s.query, args)
+	}
+	return &fakeRows{result: result}, nil
+}
+
+type fakeRows struct {
+	result fakeResultSet
+	next   int
+}
+
+func (r *fakeRows) Columns() []string { return r.result.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
+
+// outboxQuery 模拟发件箱：领取时返回 id 为 1 的记录，查询详情时返回指定渠道和已发送次数的通知
+func outboxQuery(channel string, attempts int) func(string, []driver.Value) fakeResultSet {
+	return func(query string, args []driver.Value) fakeResultSet {
+		if strings.Contains(query, "FOR UPDATE") {
+			return fakeResultSet{columns: []string{"no_id"}, rows: [][]driver.Value{{int64(1)}}}
+		}
+		return fakeResultSet{
+			columns: []string{"no_id", "event", "channel", "address", "subject", "content", "attempts"},
+			rows: [][]driver.Value{{
+				int64(1), notify.EventTaskAssigned, channel, "13800000000", "新任务：反馈1", "您有新的任务", int64(attempts),
+			}},
+		}
+	}
+}
+
+// useFakeChannel 注册测试渠道，测试结束后停用
+func useFakeChannel(t *testing.T, name string) *notify.FakeChannel {
+	t.Helper()
+	ch := notify.NewFakeChannel(name)
+	notify.Register(name, ch)
+	t.Cleanup(func() { notify.Register(name, nil) })
+	return ch
+}
+
+// outboxUpdate 返回发送后更新通知状态的语句
+func outboxUpdate(t *testing.T, db *fakeDB) fakeStatement {
+	t.Helper()
+	updates := db.execsMatching("UPDATE notification_outbox SET state")
+	if len(updates) != 1 {
+		t.Fatalf("应更新1条通知状态, 实际 %d 条", len(updates))
+	}
+	return updates[0]
+}
+
+func TestNotificationRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{6, 32 * time.Minute},
+		{7, time.Hour},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := notificationRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("notificationRetryDelay(%d) = %s, 期望 %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestClaimOutboxRowsLeasesClaimedRows(t *testing.T) {
+	now := time.Date(2026, 3, 1, 23, 58, 0, 0, time.Local)
+	var selectArgs []driver.Value
+	db := useFakeDB(t, func(query string, args []driver.Value) fakeResultSet {
+		selectArgs = args
+		return fakeResultSet{columns: []string{"no_id"}, rows: [][]driver.Value{{int64(3)}, {int64(5)}}}
+	})
+
+	ids, err := claimOutboxRows("notification_outbox", "no_id", notificationStatePending, 10, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != int64(3) || ids[1] != int64(5) {
+		t.Fatalf("领取的记录 = %v, 期望 [3 5]", ids)
+	}
+	if len(selectArgs) != 3 || selectArgs[1] != "2026-03-01 23:58:00" {
+		t.Errorf("只应领取到期的记录, 查询参数 = %v", selectArgs)
+	}
+
+	// 租约跨过零点时日期和时间一起推迟
+	updates := db.execsMatching("SET next_date = ?, next_time = ?")
+	if len(updates) != 1 {
+		t.Fatalf("应推迟1次下次发送时间, 实际 %d 次", len(updates))
+	}
+	want := []driver.Value{"2026-03-02", "00:03:00", int64(3), int64(5)}
+	if len(updates[0].args) != len(want) {
+		t.Fatalf("推迟参数 = %v, 期望 %v", updates[0].args, want)
+	}
+	for i := range want {
+		if updates[0].args[i] != want[i] {
+			t.Errorf("推迟参数 = %v, 期望 %v", updates[0].args, want)
+			break
+		}
+	}
+}
+
+func TestClaimOutboxRowsWithoutDueRows(t *testing.T) {
+	db := useFakeDB(t, nil)
+
+	ids, err := claimOutboxRows("webhook_delivery", "wd_id", webhookStatePending, 10, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("没有到期的记录时不应领取, 得到 %v", ids)
+	}
+	if execs := db.execsMatching("UPDATE"); len(execs) != 0 {
+		t.Errorf("没有领取记录时不应更新, 得到 %v", execs)
+	}
+}
+
+func TestDispatchNotificationsSendsThroughChannel(t *testing.T) {
+	ch := useFakeChannel(t, notify.ChannelSMS)
+	db := useFakeDB(t, outboxQuery(notify.ChannelSMS, 0))
+
+	if err := dispatchNotifications(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := ch.Sent()
+	if len(sent) != 1 || sent[0].Address != "13800000000" || sent[0].Event != notify.EventTaskAssigned {
+		t.Fatalf("渠道发送的通知 = %+v", sent)
+	}
+	update := outboxUpdate(t, db)
+	if update.args[0] != int64(notificationStateSent) || update.args[1] != int64(1) {
+		t.Errorf("发送成功后的状态和次数 = %v, 期望 [%d 1 ...]", update.args, notificationStateSent)
+	}
+}
+
+func TestDispatchNotificationsRetriesWithBackoff(t *testing.T) {
+	t.Setenv("NOTIFY_MAX_ATTEMPTS", "5")
+	ch := useFakeChannel(t, notify.ChannelSMS)
+	ch.FailWith(errors.New("网关超时"))
+	db := useFakeDB(t, outboxQuery(notify.ChannelSMS, 2))
+
+	before := time.Now()
+	if err := dispatchNotifications(before); err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now()
+
+	if len(ch.Sent()) != 0 {
+		t.Errorf("发送失败时不应记录为已发送")
+	}
+	update := outboxUpdate(t, db)
+	if update.args[0] != int64(notificationStatePending) || update.args[1] != int64(3) || update.args[2] != "网关超时" {
+		t.Fatalf("发送失败后的状态、次数和错误 = %v", update.args)
+	}
+
+	// 第3次失败后间隔4分钟重试
+	next, err := time.ParseInLocation(feedbackTimeLayout, update.args[3].(string)+" "+update.args[4].(string), time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	earliest := before.Add(4 * time.Minute).Truncate(time.Second)
+	latest := after.Add(4 * time.Minute)
+	if next.Before(earliest) || next.After(latest) {
+		t.Errorf("下次发送时间 = %s, 期望在 %s 和 %s 之间", next, earliest, latest)
+	}
+}
+
+func TestDispatchNotificationsFailsAfterMaxAttempts(t *testing.T) {
+	t.Setenv("NOTIFY_MAX_ATTEMPTS", "3")
+	ch := useFakeChannel(t, notify.ChannelSMS)
+	ch.FailWith(errors.New("网关超时"))
+	db := useFakeDB(t, outboxQuery(notify.ChannelSMS, 2))
+
+	if err := dispatchNotifications(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	update := outboxUpdate(t, db)
+	if update.args[0] != int64(notificationStateFailed) || update.args[1] != int64(3) {
+		t.Errorf("达到最大次数后的状态和次数 = %v, 期望 [%d 3 ...]", update.args, notificationStateFailed)
+	}
+
+	// 恢复后重新发送成功
+	ch.FailWith(nil)
+	db = useFakeDB(t, outboxQuery(notify.ChannelSMS, 0))
+	if err := dispatchNotifications(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.Sent()) != 1 {
+		t.Errorf("恢复后应发送成功, 已发送 %d 条", len(ch.Sent()))
+	}
+}
+
+func TestDispatchNotificationsFailsDisabledChannel(t *testing.T) {
+	t.Setenv("NOTIFY_MAX_ATTEMPTS", "5")
+	notify.Register(notify.ChannelEmail, nil)
+	db := useFakeDB(t, outboxQuery(notify.ChannelEmail, 0))
+
+	if err := dispatchNotifications(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	update := outboxUpdate(t, db)
+	if update.args[0] != int64(notificationStateFailed) || update.args[1] != int64(1) {
+		t.Errorf("渠道未启用时应直接标记为失败, 得到 %v", update.args)
+	}
+}
@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"epss-backend/database"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 尚未改派的指派的负责时段截止时间
+const openAssignmentEnd = "9999-12-31 23:59:59"
+
+// memberPerformance 一个网格员或城市的工作绩效
+type memberPerformance struct {
+	id                  int64
+	name                string
+	assignedCount       int // 指派的任务数量
+	confirmedCount      int // 已确认的任务数量
+	confirmMinutes      float64
+	confirmTimedCount   int // 有实测数据、可计算确认用时的任务数量
+	overdueCount        int // 确认超时的任务数量
+	remoteAssignedCount int // 异地指派的任务数量
+	remoteAcceptedCount int // 已接单或已实测的异地任务数量
+	measurementCount    int
+	activeDays          int // 有实测数据的天数，按城市统计时为各网格员天数之和
+	ratingCount         int
+	ratingTotal         int
+}
+
+func (p *memberPerformance) avgConfirmMinutes() interface{} {
+	if p.confirmTimedCount == 0 {
+		return nil
+	}
+	return math.Round(p.confirmMinutes/float64(p.confirmTimedCount)*10) / 10
+}
+
+func (p *memberPerformance) measurementsPerDay() float64 {
+	if p.activeDays == 0 {
+		return 0
+	}
+	return math.Round(float64(p.measurementCount)/float64(p.activeDays)*100) / 100
+}
+
+func (p *memberPerformance) averageRating() float64 {
+	if p.ratingCount == 0 {
+		return 0
+	}
+	return math.Round(float64(p.ratingTotal)/float64(p.ratingCount)*100) / 100
+}
+
+// GetGridMemberPerformanceStats 统计网格员工作绩效，按网格员（group_by=member，默认）或城市（group_by=city）分组
+// 包括指派和确认的任务数量、平均确认用时（从指派到提交实测数据，分钟）、确认超时数量、异地指派和接单数量、
+// 实测数量、每个出勤日（有实测数据的日期）的平均实测数量以及监督员满意度评分
+// 支持from、to参数，任务按指派日期、实测数据按确认日期、评价按评价日期筛选；支持province_id、city_id参数
+// format=csv时导出为CSV文件
+func GetGridMemberPerformanceStats(c *fiber.Ctx) error {
+	groupByCity := c.Query("group_by") == "city"
+
+	// 同时校验from、to、province_id和city_id参数
+	measurementFilters, measurementParams, err := buildStatsFilters(c, "s")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "查询参数无效",
+			"error":   err.Error(),
+		})
+	}
+	filter := func(dateColumn string) (string, []interface{}) {
+		condition := ""
+		params := []interface{}{}
+		if from := c.Query("from"); from != "" {
+			condition += " AND " + dateColumn + " >= ?"
+			params = append(params, from)
+		}
+		if to := c.Query("to"); to != "" {
+			condition += " AND " + dateColumn + " <= ?"
+			params = append(params, to)
+		}
+		if provinceID := c.Query("province_id"); provinceID != "" {
+			condition += " AND af.province_id = ?"
+			params = append(params, provinceID)
+		}
+		if cityID := c.Query("city_id"); cityID != "" {
+			condition += " AND af.city_id = ?"
+			params = append(params, cityID)
+		}
+		return condition, params
+	}
+
+	groups := make(map[int64]*memberPerformance)
+	getGroup := func(gmID int64, gmName string, cityID int64, cityName string) *memberPerformance {
+		id, name := gmID, gmName
+		if groupByCity {
+			id, name = cityID, cityName
+		}
+		if group, ok := groups[id]; ok {
+			return group
+		}
+		group := &memberPerformance{id: id, name: name}
+		groups[id] = group
+		return group
+	}
+
+	// 任务：按指派日志统计每一次指派，改派前的任务仍计入原网格员
+	// 每次指派的负责时段从指派时间到下一次指派（改派、转交）为止，接单、超时和实测按时段归属；
+	// 异地指派按指派时记录的区域判断；没有指派日志的历史反馈按当前指派计为一次
+	taskFilters, taskParams := filter("a.assign_date")
+	rows, err := database.DB.Query(`
+		SELECT
+			a.gm_id, IFNULL(gm.gm_name, '') as gm_name, af.city_id, IFNULL(ct.city_name, '') as city_name,
+			a.assign_at, a.remote_assign,
+			(a.end_at = ? AND af.state = 2 AND af.gm_id = a.gm_id) as holds_confirmed,
+			EXISTS(SELECT 1 FROM task_event te WHERE te.af_id = a.af_id AND te.gm_id = a.gm_id AND te.event_type IN (?, ?)
+				AND CONCAT(te.event_date, ' ', te.event_time) >= a.assign_at AND CONCAT(te.event_date, ' ', te.event_time) < a.end_at) as accepted,
+			EXISTS(SELECT 1 FROM feedback_overdue fo WHERE fo.af_id = a.af_id AND fo.stage = ?
+				AND CONCAT(fo.flag_date, ' ', fo.flag_time) >= a.assign_at AND CONCAT(fo.flag_date, ' ', fo.flag_time) < a.end_at) as overdue,
+			IFNULL((SELECT MIN(CONCAT(s.confirm_date, ' ', s.confirm_time)) FROM statistics s
+				WHERE (s.af_id = a.af_id OR s.superseded_af_id = a.af_id) AND s.gm_id = a.gm_id AND `+validStatisticsCondition("s")+`
+				AND CONCAT(s.confirm_date, ' ', s.confirm_time) >= a.assign_at AND CONCAT(s.confirm_date, ' ', s.confirm_time) < a.end_at), '') as confirmed_at
+		FROM
+			(
+				SELECT
+					l.af_id, l.gm_id, l.assign_date, CONCAT(l.assign_date, ' ', l.assign_time) as assign_at, l.remote_assign,
+					IFNULL((SELECT MIN(CONCAT(n.assign_date, ' ', n.assign_time)) FROM feedback_assign_log n
+						WHERE n.af_id = l.af_id AND n.log_id > l.log_id), ?) as end_at
+				FROM
+					feedback_assign_log l
+				UNION ALL
+				SELECT
+					h.af_id, h.gm_id, h.assign_date, CONCAT(h.assign_date, ' ', h.assign_time) as assign_at,
+					CASE WHEN hm.gm_id IS NULL OR (hm.province_id = h.province_id AND hm.city_id = h.city_id) THEN 0 ELSE 1 END as remote_assign,
+					? as end_at
+				FROM
+					aqi_feedback h
+				LEFT JOIN
+					grid_member hm ON h.gm_id = hm.gm_id
+				WHERE
+					h.gm_id > 0 AND h.assign_date IS NOT NULL
+					AND NOT EXISTS(SELECT 1 FROM feedback_assign_log hl WHERE hl.af_id = h.af_id)
+			) a
+		JOIN
+			aqi_feedback af ON a.af_id = af.af_id
+		LEFT JOIN
+			grid_member gm ON a.gm_id = gm.gm_id
+		LEFT JOIN
+			grid_city ct ON af.city_id = ct.city_id
+		WHERE
+			a.gm_id > 0`+taskFilters,
+		append([]interface{}{openAssignmentEnd, taskEventAccepted, taskEventMeasured, slaStageConfirm, openAssignmentEnd, openAssignmentEnd}, taskParams...)...,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取任务统计失败",
+			"error":   err.Error(),
+		})
+	}
+	for rows.Next() {
+		var gmID, cityID int64
+		var gmName, cityName, assignedAt, confirmedAt string
+		var remote, holdsConfirmed, accepted, overdue bool
+		if err := rows.Scan(&gmID, &gmName, &cityID, &cityName, &assignedAt, &remote, &holdsConfirmed, &accepted, &overdue, &confirmedAt); err != nil {
+			rows.Close()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "解析任务统计失败",
+				"error":   err.Error(),
+			})
+		}
+
+		group := getGroup(gmID, gmName, cityID, cityName)
+		group.assignedCount++
+		if overdue {
+			group.overdueCount++
+		}
+		if remote {
+			group.remoteAssignedCount++
+			if accepted {
+				group.remoteAcceptedCount++
+			}
+		}
+		// 负责期间提交了实测数据，或作为最后一次指派随事件组一并确认
+		if confirmedAt == "" && !holdsConfirmed {
+			continue
+		}
+		group.confirmedCount++
+
+		// 随事件组一并确认的反馈没有自己的实测数据，不计算确认用时
+		assigned, err := time.ParseInLocation(feedbackTimeLayout, assignedAt, time.Local)
+		if err != nil || confirmedAt == "" {
+			continue
+		}
+		confirmed, err := time.ParseInLocation(feedbackTimeLayout, confirmedAt, time.Local)
+		if err != nil || confirmed.Before(assigned) {
+			continue
+		}
+		group.confirmMinutes += confirmed.Sub(assigned).Minutes()
+		group.confirmTimedCount++
+	}
+	rows.Close()
+
+	// 实测数据：按网格员统计时不区分城市，同一天在多个城市实测只计一个出勤日
+	cityColumns, cityGroupBy := "0 as city_id, '' as city_name", ""
+	if groupByCity {
+		cityColumns, cityGroupBy = "s.city_id, IFNULL(ct.city_name, '') as city_name", ", s.city_id, ct.city_name"
+	}
+	rows, err = database.DB.Query(`
+		SELECT
+			s.gm_id, IFNULL(gm.gm_name, '') as gm_name, `+cityColumns+`,
+			COUNT(*) as measurement_count,
+			COUNT(DISTINCT s.confirm_date) as active_days
+		FROM
+			statistics s
+		LEFT JOIN
+			grid_member gm ON s.gm_id = gm.gm_id
+		LEFT JOIN
+			grid_city ct ON s.city_id = ct.city_id
+		WHERE 1 = 1`+measurementFilters+`
+		GROUP BY
+			s.gm_id, gm.gm_name`+cityGroupBy+`
+	`, measurementParams...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取实测数据统计失败",
+			"error":   err.Error(),
+		})
+	}
+	for rows.Next() {
+		var gmID, cityID int64
+		var gmName, cityName string
+		var count, days int
+		if err := rows.Scan(&gmID, &gmName, &cityID, &cityName, &count, &days); err != nil {
+			rows.Close()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "解析实测数据统计失败",
+				"error":   err.Error(),
+			})
+		}
+		group := getGroup(gmID, gmName, cityID, cityName)
+		group.measurementCount += count
+		group.activeDays += days
+	}
+	rows.Close()
+
+	// 监督员满意度评价
+	ratingFilters, ratingParams := filter("r.rate_date")
+	rows, err = database.DB.Query(`
+		SELECT
+			r.gm_id, IFNULL(gm.gm_name, '') as gm_name, af.city_id, IFNULL(ct.city_name, '') as city_name,
+			COUNT(*) as rating_count,
+			SUM(r.rating) as rating_total
+		FROM
+			feedback_rating r
+		JOIN
+			aqi_feedback af ON r.af_id = af.af_id
+		LEFT JOIN
+			grid_member gm ON r.gm_id = gm.gm_id
+		LEFT JOIN
+			grid_city ct ON af.city_id = ct.city_id
+		WHERE 1 = 1`+ratingFilters+`
+		GROUP BY
+			r.gm_id, gm.gm_name, af.city_id, ct.city_name
+	`, ratingParams...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取满意度统计失败",
+			"error":   err.Error(),
+		})
+	}
+	for rows.Next() {
+		var gmID, cityID int64
+		var gmName, cityName string
+		var count, total int
+		if err := rows.Scan(&gmID, &gmName, &cityID, &cityName, &count, &total); err != nil {
+			rows.Close()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "解析满意度统计失败",
+				"error":   err.Error(),
+			})
+		}
+		group := getGroup(gmID, gmName, cityID, cityName)
+		group.ratingCount += count
+		group.ratingTotal += total
+	}
+	rows.Close()
+
+	results := make([]*memberPerformance, 0, len(groups))
+	for _, group := range groups {
+		results = append(results, group)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].id < results[j].id
+	})
+
+	if c.Query("format") == "csv" {
+		return sendPerformanceCSV(c, results, groupByCity)
+	}
+
+	idKey, nameKey := "gm_id", "gm_name"
+	if groupByCity {
+		idKey, nameKey = "city_id", "city_name"
+	}
+	data := make([]fiber.Map, 0, len(results))
+	for _, p := range results {
+		data = append(data, fiber.Map{
+			idKey:                   p.id,
+			nameKey:                 p.name,
+			"assigned_count":        p.assignedCount,
+			"confirmed_count":       p.confirmedCount,
+			"confirm_rate":          percentage(p.confirmedCount, p.assignedCount),
+			"avg_confirm_minutes":   p.avgConfirmMinutes(),
+			"overdue_count":         p.overdueCount,
+			"remote_assigned_count": p.remoteAssignedCount,
+			"remote_accepted_count": p.remoteAcceptedCount,
+			"measurement_count":     p.measurementCount,
+			"active_days":           p.activeDays,
+			"measurements_per_day":  p.measurementsPerDay(),
+			"rating_count":          p.ratingCount,
+			"average_rating":        p.averageRating(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// sendPerformanceCSV 以CSV文件返回工作绩效，带UTF-8 BOM以便Excel正确显示中文
+func sendPerformanceCSV(c *fiber.Ctx, results []*memberPerformance, groupByCity bool) error {
+	idHeader, nameHeader, filename := "网格员编号", "网格员姓名", "member_performance"
+	if groupByCity {
+		idHeader, nameHeader, filename = "城市编号", "城市名称", "city_performance"
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff")
+	w := csv.NewWriter(&buf)
+	w.Write([]string{
+		idHeader, nameHeader, "指派任务数", "确认任务数", "确认率(%)", "平均确认用时(分钟)", "确认超时数",
+		"异地指派数", "异地接单数", "实测数量", "出勤天数", "日均实测数量", "评价数量", "平均评分",
+	})
+	for _, p := range results {
+		avgConfirm := ""
+		if v := p.avgConfirmMinutes(); v != nil {
+			avgConfirm = fmt.Sprint(v)
+		}
+		w.Write([]string{
+			fmt.Sprint(p.id), p.name,
+			fmt.Sprint(p.assignedCount), fmt.Sprint(p.confirmedCount),
+			fmt.Sprint(percentage(p.confirmedCount, p.assignedCount)), avgConfirm,
+			fmt.Sprint(p.overdueCount), fmt.Sprint(p.remoteAssignedCount), fmt.Sprint(p.remoteAcceptedCount),
+			fmt.Sprint(p.measurementCount), fmt.Sprint(p.activeDays), fmt.Sprint(p.measurementsPerDay()),
+			fmt.Sprint(p.ratingCount), fmt.Sprint(p.averageRating()),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "生成CSV文件失败",
+			"error":   err.Error(),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s_%s.csv"`, filename, time.Now().Format("20060102")))
+	return c.Send(buf.Bytes())
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"epss-backend/config"
+	"log"
+	"time"
+)
+
+// durationConfig 读取时长配置（如 30s、5m），未配置或配置无效时使用默认值
+func durationConfig(key string, def time.Duration) time.Duration {
+	value := config.Config(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		log.Printf("警告: %s 配置无效 (%s), 使用默认值 %s", key, value, def)
+		return def
+	}
+	return parsed
+}
+
+// startPeriodicTask 启动后台任务，立即执行一次 fn，之后按间隔定期执行，执行失败时记录日志
+// 间隔通过 envKey 配置，envKey 为空时固定使用默认间隔
+func startPeriodicTask(name, envKey string, def time.Duration, fn func(time.Time) error) {
+	interval := def
+	if envKey != "" {
+		interval = durationConfig(envKey, def)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := fn(time.Now()); err != nil {
+				log.Printf("%s失败: %v", name, err)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"epss-backend/database"
+	"epss-backend/realtime"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// 推送连接的心跳间隔，防止代理因长时间无数据断开连接
+const streamHeartbeatInterval = 25 * time.Second
+
+// StreamTokenAuth 浏览器的 EventSource 和 WebSocket 无法设置请求头，允许通过 token 查询参数传递JWT
+// 需要放在 JWTMiddleware 之前
+func StreamTokenAuth(c *fiber.Ctx) error {
+	if c.Get("Authorization") == "" {
+		if token := c.Query("token"); token != "" {
+			c.Request().Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	return c.Next()
+}
+
+// streamTopics 解析 topics 参数（逗号分隔）并过滤为当前用户可以订阅的主题，为空时订阅允许的所有主题
+func streamTopics(c *fiber.Ctx, userType string) []string {
+	var topics []string
+	for _, topic := range strings.Split(c.Query("topics"), ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			topics = append(topics, topic)
+		}
+	}
+	return realtime.FilterTopics(userType, topics)
+}
+
+// initialStreamEvents 连接建立时推送的事件：订阅了统计主题时先推送一次完整的统计数据
+func initialStreamEvents(sub *realtime.Subscriber) []realtime.Event {
+	for _, topic := range sub.Topics {
+		if topic != realtime.TopicStats {
+			continue
+		}
+		standard, err := loadComplianceStandard()
+		if err != nil {
+			log.Printf("实时推送: 获取超标判定标准失败: %v", err)
+			return nil
+		}
+		stats, message, err := loadRealtimeStats(standard)
+		if err != nil {
+			log.Printf("实时推送: %s: %v", message, err)
+			return nil
+		}
+		return []realtime.Event{{
+			Topic: realtime.TopicStats,
+			Data:  fiber.Map{"stats": stats, "delta": nil},
+			Time:  time.Now().Format(feedbackTimeLayout),
+		}}
+	}
+	return nil
+}
+
+// StreamEvents 通过SSE推送实时事件
+// topics 参数指定订阅的主题，管理员接收所有事件，监督员和网格员只接收与自己相关的事件
+func StreamEvents(c *fiber.Ctx) error {
+	userType, userID := currentNotificationUser(c)
+	sub, err := realtime.Default.Subscribe(userType, userID, streamTopics(c, userType))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":          "没有可订阅的主题",
+			"allowed_topics": realtime.AllowedTopics(userType),
+		})
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	initial := initialStreamEvents(sub)
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+
+		ready, _ := json.Marshal(fiber.Map{"topics": sub.Topics})
+		fmt.Fprintf(w, "event: ready\ndata: %s\n\n", ready)
+		for _, e := range initial {
+			writeSSEEvent(w, e)
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(streamHeartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case e, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+				writeSSEEvent(w, e)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}))
+	return nil
+}
+
+func writeSSEEvent(w *bufio.Writer, e realtime.Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("实时推送: 序列化事件失败: %v", err)
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Topic, data)
+}
+
+// RequireWebSocketUpgrade 只接受WebSocket升级请求，并提前校验订阅的主题
+func RequireWebSocketUpgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+
+	userType, userID := currentNotificationUser(c)
+	topics := streamTopics(c, userType)
+	if len(topics) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":          "没有可订阅的主题",
+			"allowed_topics": realtime.AllowedTopics(userType),
+		})
+	}
+
+	c.Locals("stream_user_type", userType)
+	c.Locals("stream_user_id", userID)
+	c.Locals("stream_topics", topics)
+	return c.Next()
+}
+
+// StreamWebSocket 通过WebSocket推送实时事件，订阅规则与 StreamEvents 相同
+// 每条消息为一个JSON事件：{"id","topic","data","time"}
+var StreamWebSocket = websocket.New(func(conn *websocket.Conn) {
+	userType, _ := conn.Locals("stream_user_type").(string)
+	userID, _ := conn.Locals("stream_user_id").(string)
+	topics, _ := conn.Locals("stream_topics").([]string)
+
+	sub, err := realtime.Default.Subscribe(userType, userID, topics)
+	if err != nil {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()))
+		return
+	}
+	defer sub.Close()
+
+	// 客户端不发送业务消息，读取只用于处理控制帧和发现连接断开
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, e := range initialStreamEvents(sub) {
+		if err := conn.WriteJSON(e); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "处理过慢，请重新连接"))
+				return
+			}
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+})
+
+// publishFeedbackEvents 反馈变化并提交后推送实时事件，事件发送给管理员以及反馈的监督员和网格员
+// 推送失败不影响业务，只记录日志
+func publishFeedbackEvents(topic string, feedbackIDs []int64) {
+	if len(feedbackIDs) == 0 || !realtime.Default.HasSubscribers(topic) {
+		return
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(feedbackIDs)), ",")
+	args := make([]interface{}, len(feedbackIDs))
+	for i, id := range feedbackIDs {
+		args[i] = id
+	}
+	rows, err := database.DB.Query(`
+		SELECT af_id, tel_id, province_id, city_id, address, estimated_grade, gm_id, state
+		FROM aqi_feedback
+		WHERE af_id IN (`+placeholders+`)
+	`, args...)
+	if err != nil {
+		log.Printf("实时推送: 查询反馈信息失败: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var afID, provinceID, cityID, gmID int64
+		var grade, state int
+		var telID, address string
+		if err := rows.Scan(&afID, &telID, &provinceID, &cityID, &address, &grade, &gmID, &state); err != nil {
+			log.Printf("实时推送: 读取反馈信息失败: %v", err)
+			return
+		}
+
+		recipients := map[string]string{"supervisor": telID}
+		if gmID > 0 {
+			recipients["member"] = fmt.Sprint(gmID)
+		}
+		realtime.Publish(realtime.Event{
+			Topic: topic,
+			Data: fiber.Map{
+				"feedback_id":     afID,
+				"province_id":     provinceID,
+				"city_id":         cityID,
+				"address":         address,
+				"estimated_grade": grade,
+				"gm_id":           gmID,
+				"state":           state,
+				"state_text":      getStateText(state),
+			},
+			Recipients: recipients,
+		})
+	}
+}
+
+// publishStatsDelta 实测数据计入或移出统计后推送实时统计的变化量和最新数据
+// count 为 1 表示新增实测数据，-1 表示实测数据的位置审核被驳回
+func publishStatsDelta(aqiID, count int) {
+	if !realtime.Default.HasSubscribers(realtime.TopicStats) {
+		return
+	}
+
+	standard, err := loadComplianceStandard()
+	if err != nil {
+		log.Printf("实时推送: 获取超标判定标准失败: %v", err)
+		return
+	}
+
+	delta := realtimeStats{TotalCount: count}
+	if standard.aqiExceeds(aqiID) {
+		delta.ExceedingCount = count
+	} else {
+		delta.GoodCount = count
+	}
+
+	stats, message, err := loadRealtimeStats(standard)
+	if err != nil {
+		log.Printf("实时推送: %s: %v", message, err)
+		return
+	}
+	realtime.Publish(realtime.Event{
+		Topic: realtime.TopicStats,
+		Data:  fiber.Map{"stats": stats, "delta": delta},
+	})
+}
+
+// publishFeedbackConfirmed 推送反馈确认事件，includeGroup 为 true 时同时推送同一事件组中已确认的反馈
+func publishFeedbackConfirmed(feedbackID int, includeGroup bool) {
+	if !realtime.Default.HasSubscribers(realtime.TopicFeedbackConfirmed) {
+		return
+	}
+
+	feedbackIDs := []int64{int64(feedbackID)}
+	if includeGroup {
+		rows, err := database.DB.Query(
+			"SELECT af_id FROM aqi_feedback WHERE state = 2 AND af_id <> ? AND ig_id > 0 AND ig_id = (SELECT ig_id FROM aqi_feedback WHERE af_id = ?)",
+			feedbackID, feedbackID,
+		)
+		if err != nil {
+			log.Printf("实时推送: 查询事件组反馈失败: %v", err)
+		} else {
+			for rows.Next() {
+				var afID int64
+				if err := rows.Scan(&afID); err == nil {
+					feedbackIDs = append(feedbackIDs, afID)
+				}
+			}
+			rows.Close()
+		}
+	}
+	publishFeedbackEvents(realtime.TopicFeedbackConfirmed, feedbackIDs)
+}
+
+// assignedFeedbackIDs 从指派结果中取出指派成功的反馈（含随事件组一并指派的反馈）
+func assignedFeedbackIDs(results []fiber.Map) []int64 {
+	var feedbackIDs []int64
+	seen := make(map[int]bool)
+	add := func(id int) {
+		if !seen[id] {
+			seen[id] = true
+			feedbackIDs = append(feedbackIDs, int64(id))
+		}
+	}
+	for _, result := range results {
+		if result["success"] != true {
+			continue
+		}
+		if id, ok := result["feedback_id"].(int); ok {
+			add(id)
+		}
+		if ids, ok := result["group_feedback_ids"].([]int); ok {
+			for _, id := range ids {
+				add(id)
+			}
+		}
+	}
+	return feedbackIDs
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"epss-backend/database"
+	"math"
+	"strings"
+)
+
+// 监督员可信度评分参数
+const (
+	// 无历史记录时的默认可信度
+	defaultTrustScore = 60.0
+	// 默认可信度相当于多少条历史记录，历史记录越多，评分越接近实际表现
+	trustPriorWeight = 5
+	// 预估等级比实测等级高出该值及以上时视为误报
+	falseAlarmGap = 2
+)
+
+// supervisorReliability 监督员的预估准确度和可信度
+type supervisorReliability struct {
+	ConfirmedCount  int     `json:"confirmed_count"`   // 已确认且有实测数据的反馈数量
+	ExactCount      int     `json:"exact_count"`       // 预估等级与实测等级一致的数量
+	FalseAlarmCount int     `json:"false_alarm_count"` // 误报数量
+	Accuracy        float64 `json:"accuracy"`          // 预估准确率（百分比）
+	MeanDeviation   float64 `json:"mean_deviation"`    // 预估等级与实测等级的平均偏差
+	FalseAlarmRate  float64 `json:"false_alarm_rate"`  // 误报率（百分比）
+	TrustScore      float64 `json:"trust_score"`       // 可信度（0-100）
+}
+
+// newSupervisorReliability 根据历史记录计算可信度
+// 表现分由平均偏差和误报率决定，再与默认可信度按记录数量加权，避免少量记录导致评分大幅波动
+func newSupervisorReliability(confirmed, exact, deviation, falseAlarm int) supervisorReliability {
+	r := supervisorReliability{
+		ConfirmedCount:  confirmed,
+		ExactCount:      exact,
+		FalseAlarmCount: falseAlarm,
+		TrustScore:      defaultTrustScore,
+	}
+	if confirmed == 0 {
+		return r
+	}
+
+	meanDeviation := float64(deviation) / float64(confirmed)
+	r.Accuracy = percentage(exact, confirmed)
+	r.MeanDeviation = math.Round(meanDeviation*100) / 100
+	r.FalseAlarmRate = percentage(falseAlarm, confirmed)
+
+	// 等级共六级，最大偏差为5
+	performance := (1 - meanDeviation/5) * (1 - float64(falseAlarm)/float64(confirmed)) * 100
+	score := (defaultTrustScore*trustPriorWeight + performance*float64(confirmed)) / float64(trustPriorWeight+confirmed)
+	r.TrustScore = math.Round(score*100) / 100
+	return r
+}
+
+// loadSupervisorReliability 统计所有监督员的可信度，以手机号为键
+func loadSupervisorReliability() (map[string]supervisorReliability, error) {
+	return querySupervisorReliability("", nil)
+}
+
+// loadSupervisorReliabilityFor 只统计指定监督员的可信度，列表只涉及部分监督员时避免统计全部反馈
+func loadSupervisorReliabilityFor(telIDs []string) (map[string]supervisorReliability, error) {
+	seen := make(map[string]bool)
+	var args []interface{}
+	for _, telID := range telIDs {
+		if !seen[telID] {
+			seen[telID] = true
+			args = append(args, telID)
+		}
+	}
+	if len(args) == 0 {
+		return map[string]supervisorReliability{}, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(args)), ",")
+	return querySupervisorReliability(" AND af.tel_id IN ("+placeholders+")", args)
+}
+
+// querySupervisorReliability 按附加条件统计监督员的可信度
+// 实测数据优先取反馈自身关联的最新记录，事件组成员取事件组确认时的记录；位置审核驳回的实测数据不计入
+func querySupervisorReliability(filters string, args []interface{}) (map[string]supervisorReliability, error) {
+	rows, err := database.DB.Query(`
+		SELECT
+			af.tel_id,
+			COUNT(*) as confirmed_count,
+			SUM(CASE WHEN af.estimated_grade = st.aqi_id THEN 1 ELSE 0 END) as exact_count,
+			SUM(ABS(af.estimated_grade - st.aqi_id)) as deviation,
+			SUM(CASE WHEN af.estimated_grade - st.aqi_id >= ? THEN 1 ELSE 0 END) as false_alarm_count
+		FROM
+			aqi_feedback af
+		LEFT JOIN
+			incident_group ig ON af.ig_id = ig.ig_id AND ig.state = 1
+		JOIN
+			statistics st ON st.id = COALESCE(
+				(SELECT MAX(s2.id) FROM statistics s2 WHERE s2.af_id = af.af_id),
+				NULLIF(ig.statistics_id, 0)
+			)
+		WHERE
+			af.state = 2 AND `+validStatisticsCondition("st")+filters+`
+		GROUP BY
+			af.tel_id
+	`, append([]interface{}{falseAlarmGap}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]supervisorReliability)
+	for rows.Next() {
+		var telID string
+		var confirmed, exact, deviation, falseAlarm int
+		if err := rows.Scan(&telID, &confirmed, &exact, &deviation, &falseAlarm); err != nil {
+			return nil, err
+		}
+		result[telID] = newSupervisorReliability(confirmed, exact, deviation, falseAlarm)
+	}
+	return result, rows.Err()
+}
+
+// reliabilityOf 获取监督员的可信度，无历史记录时返回默认值
+func reliabilityOf(reliability map[string]supervisorReliability, telID string) supervisorReliability {
+	if r, ok := reliability[telID]; ok {
+		return r
+	}
+	return newSupervisorReliability(0, 0, 0, 0)
+}
+
+// severityPriority 按预估等级和监督员可信度计算指派优先级，可信度越高的严重反馈越靠前
+func severityPriority(estimatedGrade int, trustScore float64) float64 {
+	return math.Round(float64(estimatedGrade)*trustScore) / 100
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"epss-backend/database"
+	"epss-backend/models"
+	"math"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 2-opt 优化的最大轮数，防止任务较多时耗时过长
+const maxTwoOptRounds = 50
+
+// planVisitOrder 从起点出发规划访问所有地点的顺序（不返回起点）
+// dist 为距离矩阵，下标0为起点，返回地点下标（1..n）的访问顺序
+// 先用最近邻法得到初始路线，再用 2-opt 反复翻转路段消除交叉
+func planVisitOrder(dist [][]float64) []int {
+	n := len(dist) - 1
+	if n <= 0 {
+		return nil
+	}
+
+	// 最近邻法
+	route := make([]int, 0, n+1)
+	route = append(route, 0)
+	visited := make([]bool, n+1)
+	visited[0] = true
+	for len(route) <= n {
+		last := route[len(route)-1]
+		next := -1
+		for j := 1; j <= n; j++ {
+			if !visited[j] && (next == -1 || dist[last][j] < dist[last][next]) {
+				next = j
+			}
+		}
+		visited[next] = true
+		route = append(route, next)
+	}
+
+	// 2-opt：起点固定，终点开放
+	for round := 0; round < maxTwoOptRounds; round++ {
+		improved := false
+		for i := 1; i < n; i++ {
+			for k := i + 1; k <= n; k++ {
+				delta := dist[route[i-1]][route[k]] - dist[route[i-1]][route[i]]
+				if k < n {
+					delta += dist[route[i]][route[k+1]] - dist[route[k]][route[k+1]]
+				}
+				if delta < -1e-6 {
+					for a, b := i, k; a < b; a, b = a+1, b-1 {
+						route[a], route[b] = route[b], route[a]
+					}
+					improved = true
+				}
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+
+	return route[1:]
+}
+
+// routeDistance 计算从起点依次访问各地点的总距离
+func routeDistance(dist [][]float64, order []int) float64 {
+	total, last := 0.0, 0
+	for _, idx := range order {
+		total += dist[last][idx]
+		last = idx
+	}
+	return total
+}
+
+// GetGridMemberRoute 根据网格员当前位置规划未完成任务的访问顺序
+// 需要latitude和longitude参数，没有定位的任务不参与规划，单独列出
+func GetGridMemberRoute(c *fiber.Ctx) error {
+	gmID := c.Locals("user_gm_id")
+	if gmID == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	lat, latErr := strconv.ParseFloat(c.Query("latitude"), 64)
+	lng, lngErr := strconv.ParseFloat(c.Query("longitude"), 64)
+	if latErr != nil || lngErr != nil || !(GeoPoint{Latitude: &lat, Longitude: &lng}).Valid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "请提供有效的当前位置（latitude和longitude）",
+		})
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT
+			af_id, address, information, estimated_grade, af_date, af_time,
+			assign_date, assign_time, latitude, longitude, ig_id
+		FROM
+			aqi_feedback
+		WHERE
+			gm_id = ? AND state = 1
+		ORDER BY
+			af_id ASC
+	`, gmID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取任务列表失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var located, unlocated []models.AqiFeedback
+	for rows.Next() {
+		var task models.AqiFeedback
+		err := rows.Scan(
+			&task.AfID, &task.Address, &task.Information, &task.EstimatedGrade, &task.AfDate, &task.AfTime,
+			&task.AssignDate, &task.AssignTime, &task.Latitude, &task.Longitude, &task.IgID,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理任务数据失败",
+				"details": err.Error(),
+			})
+		}
+		if task.Latitude.Valid && task.Longitude.Valid {
+			located = append(located, task)
+		} else {
+			unlocated = append(unlocated, task)
+		}
+	}
+	rows.Close()
+
+	// 距离矩阵，下标0为当前位置
+	points := make([][2]float64, 0, len(located)+1)
+	points = append(points, [2]float64{lat, lng})
+	for _, task := range located {
+		points = append(points, [2]float64{task.Latitude.Float64, task.Longitude.Float64})
+	}
+	dist := make([][]float64, len(points))
+	for i := range points {
+		dist[i] = make([]float64, len(points))
+		for j := range points {
+			if i != j {
+				dist[i][j] = haversineDistance(points[i][0], points[i][1], points[j][0], points[j][1])
+			}
+		}
+	}
+
+	order := planVisitOrder(dist)
+
+	stops := []fiber.Map{}
+	cumulative, last := 0.0, 0
+	for i, idx := range order {
+		task := located[idx-1]
+		leg := dist[last][idx]
+		cumulative += leg
+		last = idx
+
+		stops = append(stops, fiber.Map{
+			"order":               i + 1,
+			"id":                  task.AfID,
+			"address":             task.Address,
+			"information":         task.Information,
+			"estimated_grade":     task.EstimatedGrade,
+			"af_date":             task.AfDate,
+			"af_time":             task.AfTime,
+			"assign_date":         task.AssignDate.String,
+			"assign_time":         task.AssignTime.String,
+			"incident_group_id":   task.IgID,
+			"latitude":            task.Latitude.Float64,
+			"longitude":           task.Longitude.Float64,
+			"leg_distance":        math.Round(leg),
+			"cumulative_distance": math.Round(cumulative),
+		})
+	}
+
+	unlocatedList := []fiber.Map{}
+	for _, task := range unlocated {
+		unlocatedList = append(unlocatedList, fiber.Map{
+			"id":                task.AfID,
+			"address":           task.Address,
+			"information":       task.Information,
+			"estimated_grade":   task.EstimatedGrade,
+			"af_date":           task.AfDate,
+			"af_time":           task.AfTime,
+			"assign_date":       task.AssignDate.String,
+			"assign_time":       task.AssignTime.String,
+			"incident_group_id": task.IgID,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": fiber.Map{
+			"start": fiber.Map{
+				"latitude":  lat,
+				"longitude": lng,
+			},
+			"stops":          stops,
+			"unlocated":      unlocatedList,
+			"total_distance": math.Round(routeDistance(dist, order)),
+		},
+	})
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"epss-backend/database"
+	"epss-backend/models"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// loadSensitiveAreas 读取全部敏感区域
+func loadSensitiveAreas() ([]models.SensitiveArea, error) {
+	rows, err := database.DB.Query(`
+		SELECT sa_id, sa_name, sa_type, province_id, city_id, latitude, longitude, radius, remarks
+		FROM sensitive_area
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var areas []models.SensitiveArea
+	for rows.Next() {
+		var area models.SensitiveArea
+		err := rows.Scan(
+			&area.SaID, &area.SaName, &area.SaType, &area.ProvinceID, &area.CityID,
+			&area.Latitude, &area.Longitude, &area.Radius, &area.Remarks,
+		)
+		if err != nil {
+			return nil, err
+		}
+		areas = append(areas, area)
+	}
+	return areas, rows.Err()
+}
+
+// nearestSensitiveArea 查找影响范围内离指定位置最近的敏感区域
+// 返回区域、距离（米）和接近程度（区域中心为1，影响半径边缘为0）
+func nearestSensitiveArea(areas []models.SensitiveArea, lat, lng float64) (models.SensitiveArea, float64, float64, bool) {
+	var nearest models.SensitiveArea
+	var nearestDistance, closeness float64
+	found := false
+
+	for _, area := range areas {
+		if area.Radius <= 0 {
+			continue
+		}
+		distance := haversineDistance(lat, lng, area.Latitude, area.Longitude)
+		if distance > float64(area.Radius) {
+			continue
+		}
+		if value := 1 - distance/float64(area.Radius); !found || value > closeness {
+			nearest, nearestDistance, closeness, found = area, distance, value, true
+		}
+	}
+	return nearest, nearestDistance, closeness, found
+}
+
+// GetSensitiveAreaList 获取敏感区域列表，支持province_id和city_id参数
+func GetSensitiveAreaList(c *fiber.Ctx) error {
+	query := `
+		SELECT
+			sa.sa_id, sa.sa_name, sa.sa_type, sa.province_id, sa.city_id,
+			sa.latitude, sa.longitude, sa.radius, sa.remarks,
+			IFNULL(p.province_name, '') as province_name,
+			IFNULL(ct.city_name, '') as city_name
+		FROM
+			sensitive_area sa
+		LEFT JOIN
+			grid_province p ON sa.province_id = p.province_id
+		LEFT JOIN
+			grid_city ct ON sa.city_id = ct.city_id
+	`
+	params := []interface{}{}
+
+	if provinceID := c.Query("province_id"); provinceID != "" {
+		query += " WHERE sa.province_id = ?"
+		params = append(params, provinceID)
+
+		if cityID := c.Query("city_id"); cityID != "" {
+			query += " AND sa.city_id = ?"
+			params = append(params, cityID)
+		}
+	}
+	query += " ORDER BY sa.province_id, sa.city_id, sa.sa_id"
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取敏感区域列表失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var areaList []fiber.Map
+	for rows.Next() {
+		var area models.SensitiveArea
+		var provinceName, cityName string
+
+		err := rows.Scan(
+			&area.SaID, &area.SaName, &area.SaType, &area.ProvinceID, &area.CityID,
+			&area.Latitude, &area.Longitude, &area.Radius, &area.Remarks,
+			&provinceName, &cityName,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理敏感区域数据失败",
+				"details": err.Error(),
+			})
+		}
+
+		areaList = append(areaList, fiber.Map{
+			"id":            area.SaID,
+			"name":          area.SaName,
+			"type":          area.SaType,
+			"type_text":     getSensitiveAreaTypeText(area.SaType),
+			"province_id":   area.ProvinceID,
+			"province_name": provinceName,
+			"city_id":       area.CityID,
+			"city_name":     cityName,
+			"latitude":      area.Latitude,
+			"longitude":     area.Longitude,
+			"radius":        area.Radius,
+			"remarks":       area.Remarks.String,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": areaList,
+	})
+}
+
+// AddSensitiveArea 添加敏感区域
+func AddSensitiveArea(c *fiber.Ctx) error {
+	var req struct {
+		Name       string   `json:"name"`
+		Type       int      `json:"type"`
+		ProvinceID int64    `json:"province_id"`
+		CityID     int64    `json:"city_id"`
+		Latitude   *float64 `json:"latitude"`
+		Longitude  *float64 `json:"longitude"`
+		Radius     int      `json:"radius"`
+		Remarks    string   `json:"remarks"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的请求格式"})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || req.ProvinceID <= 0 || req.CityID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "名称、省份ID和城市ID为必填项"})
+	}
+	if len([]rune(req.Name)) > 50 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "名称不能超过50个字符"})
+	}
+	if req.Type == 0 {
+		req.Type = 1
+	}
+	if req.Type < 1 || req.Type > 4 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "类型必须为1-4"})
+	}
+	point := GeoPoint{Latitude: req.Latitude, Longitude: req.Longitude}
+	if !point.Present() || !point.Valid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "请提供有效的经纬度"})
+	}
+	if req.Radius == 0 {
+		req.Radius = 1000
+	}
+	if req.Radius < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "影响半径必须为正整数"})
+	}
+
+	// 检查城市是否属于该省份
+	var count int
+	err := database.DB.QueryRow(
+		"SELECT COUNT(*) FROM grid_city WHERE city_id = ? AND province_id = ?",
+		req.CityID, req.ProvinceID,
+	).Scan(&count)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "数据库查询失败",
+			"details": err.Error(),
+		})
+	}
+	if count == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "城市不属于该省份"})
+	}
+
+	var remarks interface{}
+	if req.Remarks != "" {
+		remarks = req.Remarks
+	}
+
+	result, err := database.DB.Exec(
+		"INSERT INTO sensitive_area (sa_name, sa_type, province_id, city_id, latitude, longitude, radius, remarks) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		req.Name, req.Type, req.ProvinceID, req.CityID, *req.Latitude, *req.Longitude, req.Radius, remarks,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "添加敏感区域失败",
+			"details": err.Error(),
+		})
+	}
+
+	saID, _ := result.LastInsertId()
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "敏感区域添加成功",
+		"id":      saID,
+	})
+}
+
+// DeleteSensitiveArea 删除敏感区域
+func DeleteSensitiveArea(c *fiber.Ctx) error {
+	areaID := c.Params("id")
+	if areaID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "缺少敏感区域ID"})
+	}
+
+	result, err := database.DB.Exec("DELETE FROM sensitive_area WHERE sa_id = ?", areaID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "删除敏感区域失败",
+			"details": err.Error(),
+		})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "敏感区域不存在"})
+	}
+
+	return c.JSON(fiber.Map{"message": "敏感区域删除成功"})
+}
+
+// 获取敏感区域类型文本描述
+func getSensitiveAreaTypeText(areaType int) string {
+	switch areaType {
+	case 1:
+		return "学校"
+	case 2:
+		return "医院"
+	case 3:
+		return "养老机构"
+	case 4:
+		return "其他"
+	default:
+		return "未知类型"
+	}
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/database"
+	"epss-backend/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 一次排班最多包含的日期数量
+const maxShiftDates = 62
+
+// normalizeClock 校验时间格式，支持 HH:MM 和 HH:MM:SS，统一转换为 HH:MM:SS
+func normalizeClock(value string) (string, bool) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("15:04:05"), true
+		}
+	}
+	return "", false
+}
+
+// validDate 校验日期格式是否为 YYYY-MM-DD
+func validDate(value string) bool {
+	_, err := time.Parse("2006-01-02", value)
+	return err == nil
+}
+
+// GetShiftList 获取排班列表，支持from、to参数按班次日期筛选
+// 网格员只能查看自己的排班，管理员可通过gm_id参数筛选
+func GetShiftList(c *fiber.Ctx) error {
+	query := `
+		SELECT
+			ms.shift_id, ms.gm_id, ms.shift_date, ms.start_time, ms.end_time, ms.admin_id, ms.remarks,
+			IFNULL(gm.gm_name, '') as gm_name
+		FROM
+			member_shift ms
+		LEFT JOIN
+			grid_member gm ON ms.gm_id = gm.gm_id
+		WHERE 1 = 1
+	`
+	params := []interface{}{}
+
+	if c.Locals("user_type") == "member" {
+		query += " AND ms.gm_id = ?"
+		params = append(params, c.Locals("user_gm_id"))
+	} else if gmID := c.Query("gm_id"); gmID != "" {
+		query += " AND ms.gm_id = ?"
+		params = append(params, gmID)
+	}
+	if from := c.Query("from"); from != "" {
+		query += " AND ms.shift_date >= ?"
+		params = append(params, from)
+	}
+	if to := c.Query("to"); to != "" {
+		query += " AND ms.shift_date <= ?"
+		params = append(params, to)
+	}
+	query += " ORDER BY ms.shift_date, ms.start_time, ms.gm_id"
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取排班列表失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var shiftList []fiber.Map
+	for rows.Next() {
+		var shift models.MemberShift
+		var gmName string
+
+		err := rows.Scan(
+			&shift.ShiftID, &shift.GmID, &shift.ShiftDate, &shift.StartTime, &shift.EndTime, &shift.AdminID, &shift.Remarks,
+			&gmName,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理排班数据失败",
+				"details": err.Error(),
+			})
+		}
+
+		shiftList = append(shiftList, fiber.Map{
+			"id":         shift.ShiftID,
+			"gm_id":      shift.GmID,
+			"gm_name":    gmName,
+			"shift_date": shift.ShiftDate,
+			"start_time": shift.StartTime,
+			"end_time":   shift.EndTime,
+			"overnight":  shift.EndTime <= shift.StartTime,
+			"admin_id":   shift.AdminID,
+			"remarks":    shift.Remarks.String,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": shiftList,
+	})
+}
+
+// AddShifts 为网格员排班，dates中的每个日期生成一个班次
+// 下班时间不晚于上班时间表示次日下班；同一日期同一上班时间已存在的班次会被跳过
+func AddShifts(c *fiber.Ctx) error {
+	var req struct {
+		GmID      int64    `json:"gm_id"`
+		Dates     []string `json:"dates"`
+		StartTime string   `json:"start_time"`
+		EndTime   string   `json:"end_time"`
+		Remarks   string   `json:"remarks"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+	}
+
+	if req.GmID <= 0 || len(req.Dates) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "网格员ID和排班日期为必填项",
+		})
+	}
+	if len(req.Dates) > maxShiftDates {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "一次最多为62天排班",
+		})
+	}
+	for _, date := range req.Dates {
+		if !validDate(date) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "日期格式必须为YYYY-MM-DD",
+				"date":  date,
+			})
+		}
+	}
+	startTime, okStart := normalizeClock(req.StartTime)
+	endTime, okEnd := normalizeClock(req.EndTime)
+	if !okStart || !okEnd {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "上下班时间格式必须为HH:MM或HH:MM:SS",
+		})
+	}
+	if startTime == endTime {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "上班时间和下班时间不能相同",
+		})
+	}
+
+	var count int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM grid_member WHERE gm_id = ?", req.GmID).Scan(&count); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询网格员信息失败",
+			"details": err.Error(),
+		})
+	}
+	if count == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "网格员不存在",
+		})
+	}
+
+	var remarks interface{}
+	if req.Remarks != "" {
+		remarks = req.Remarks
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "启动数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	adminID := c.Locals("user_id")
+	createdIDs := []int64{}
+	skippedDates := []string{}
+	for _, date := range req.Dates {
+		result, err := tx.Exec(
+			"INSERT IGNORE INTO member_shift (gm_id, shift_date, start_time, end_time, admin_id, remarks) VALUES (?, ?, ?, ?, ?, ?)",
+			req.GmID, date, startTime, endTime, adminID, remarks,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "添加班次失败",
+				"details": err.Error(),
+			})
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			skippedDates = append(skippedDates, date)
+			continue
+		}
+		shiftID, _ := result.LastInsertId()
+		createdIDs = append(createdIDs, shiftID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "提交数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+
+	// 新班次可能覆盖当前时间，立即更新网格员状态
+	if _, err := refreshMemberState(req.GmID, time.Now()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "更新网格员状态失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "排班成功",
+		"data": fiber.Map{
+			"gm_id":         req.GmID,
+			"shift_ids":     createdIDs,
+			"skipped_dates": skippedDates,
+			"start_time":    startTime,
+			"end_time":      endTime,
+		},
+	})
+}
+
+// DeleteShift 删除班次
+func DeleteShift(c *fiber.Ctx) error {
+	shiftID := c.Params("id")
+	if shiftID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "缺少班次ID"})
+	}
+
+	var gmID int64
+	err := database.DB.QueryRow("SELECT gm_id FROM member_shift WHERE shift_id = ?", shiftID).Scan(&gmID)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "班次不存在"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "数据库查询失败"})
+	}
+
+	if _, err := database.DB.Exec("DELETE FROM member_shift WHERE shift_id = ?", shiftID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "删除班次失败"})
+	}
+
+	if _, err := refreshMemberState(gmID, time.Now()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "更新网格员状态失败"})
+	}
+
+	return c.JSON(fiber.Map{"message": "班次删除成功"})
+}
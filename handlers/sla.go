@@ -0,0 +1,758 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/database"
+	"epss-backend/models"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 超时环节
+const (
+	slaStageAssign  = 1 // 指派超时
+	slaStageConfirm = 2 // 确认超时
+)
+
+// 反馈日期和时间拼接后的格式
+const feedbackTimeLayout = "2006-01-02 15:04:05"
+
+// defaultFeedbackSLA 数据库中未配置时使用的默认时限（分钟）
+var defaultFeedbackSLA = map[int]models.FeedbackSLA{
+	1: {Grade: 1, AssignMinutes: 1440, ConfirmMinutes: 4320},
+	2: {Grade: 2, AssignMinutes: 1440, ConfirmMinutes: 4320},
+	3: {Grade: 3, AssignMinutes: 720, ConfirmMinutes: 2880},
+	4: {Grade: 4, AssignMinutes: 240, ConfirmMinutes: 1440},
+	5: {Grade: 5, AssignMinutes: 120, ConfirmMinutes: 1440},
+	6: {Grade: 6, AssignMinutes: 60, ConfirmMinutes: 1440},
+}
+
+// slaFeedback 参与时限计算的反馈信息
+type slaFeedback struct {
+	AfID           int64
+	ProvinceID     int64
+	CityID         int64
+	EstimatedGrade int
+	State          int
+	Round          int
+	ReportedAt     time.Time
+}
+
+// StartSLAMonitor 启动后台任务，定期检查超时未处理的反馈并升级给区域管理员
+// 检查间隔通过 SLA_CHECK_INTERVAL 配置（如 30s、5m），默认每分钟一次
+func StartSLAMonitor() {
+	startPeriodicTask("反馈超时检查", "SLA_CHECK_INTERVAL", time.Minute, checkFeedbackSLA)
+}
+
+// checkFeedbackSLA 标记超时的反馈，并解除已处理反馈的超时标记
+func checkFeedbackSLA(now time.Time) error {
+	slas, err := loadFeedbackSLA()
+	if err != nil {
+		return err
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT af_id, province_id, city_id, estimated_grade, af_date, af_time, state,
+		       sla_round, IFNULL(sla_start, '')
+		FROM aqi_feedback
+		WHERE state IN (0, 1)
+	`)
+	if err != nil {
+		return err
+	}
+
+	var feedbacks []slaFeedback
+	for rows.Next() {
+		var f slaFeedback
+		var afDate, afTime, slaStart string
+		if err := rows.Scan(&f.AfID, &f.ProvinceID, &f.CityID, &f.EstimatedGrade, &afDate, &afTime, &f.State,
+			&f.Round, &slaStart); err != nil {
+			rows.Close()
+			return err
+		}
+		// 申诉重新处理的反馈从重新处理时间起算本轮时限
+		reportedAt, err := parseFeedbackTime(afDate, afTime)
+		if slaStart != "" {
+			reportedAt, err = time.ParseInLocation(feedbackTimeLayout, slaStart, time.Local)
+		}
+		if err != nil {
+			continue
+		}
+		f.ReportedAt = reportedAt
+		feedbacks = append(feedbacks, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, f := range feedbacks {
+		sla := slaForGrade(slas, f.EstimatedGrade)
+
+		assignDeadline := f.ReportedAt.Add(time.Duration(sla.AssignMinutes) * time.Minute)
+		if f.State == 0 && now.After(assignDeadline) {
+			if err := flagOverdueFeedback(f, slaStageAssign, assignDeadline, now); err != nil {
+				return err
+			}
+		}
+
+		confirmDeadline := f.ReportedAt.Add(time.Duration(sla.ConfirmMinutes) * time.Minute)
+		if now.After(confirmDeadline) {
+			if err := flagOverdueFeedback(f, slaStageConfirm, confirmDeadline, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 反馈已指派或已结束后解除对应环节的超时标记，重新处理前的轮次标记一并解除
+	_, err = database.DB.Exec(`
+		UPDATE feedback_overdue fo
+		JOIN aqi_feedback af ON fo.af_id = af.af_id
+		SET fo.resolved = 1
+		WHERE fo.resolved = 0
+		  AND (fo.sla_round < af.sla_round
+		       OR (fo.stage = 1 AND af.state <> 0) OR (fo.stage = 2 AND af.state NOT IN (0, 1)))
+	`)
+	return err
+}
+
+// flagOverdueFeedback 记录超时标记，首次标记时升级给反馈所在区域的管理员
+func flagOverdueFeedback(f slaFeedback, stage int, deadline, now time.Time) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	flagDate := now.Format("2006-01-02")
+	flagTime := now.Format("15:04:05")
+
+	result, err := tx.Exec(
+		"INSERT IGNORE INTO feedback_overdue (af_id, sla_round, stage, deadline, flag_date, flag_time) VALUES (?, ?, ?, ?, ?, ?)",
+		f.AfID, f.Round, stage, deadline.Format(feedbackTimeLayout), flagDate, flagTime,
+	)
+	if err != nil {
+		return err
+	}
+
+	// 已经标记过的反馈不重复升级
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return err
+	}
+	foID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	adminIDs, err := findRegionAdmins(tx, f.ProvinceID, f.CityID)
+	if err != nil {
+		return err
+	}
+
+	for _, adminID := range adminIDs {
+		_, err = tx.Exec(
+			"INSERT INTO feedback_escalation (fo_id, af_id, admin_id, escalate_date, escalate_time, state) VALUES (?, ?, ?, ?, ?, 0)",
+			foID, f.AfID, adminID, flagDate, flagTime,
+		)
+		if err != nil {
+			return err
+		}
+		if err := notifyFeedbackEscalated(tx, f.AfID, adminID, stage, deadline, now); err != nil {
+			return err
+		}
+	}
+
+	if len(adminIDs) > 0 {
+		if _, err = tx.Exec("UPDATE feedback_overdue SET escalated = 1 WHERE fo_id = ?", foID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// findRegionAdmins 查询负责指定区域的管理员，未配置负责区域时返回所有管理员
+func findRegionAdmins(tx *sql.Tx, provinceID, cityID int64) ([]int64, error) {
+	adminIDs, err := queryAdminIDs(tx,
+		"SELECT DISTINCT admin_id FROM admin_region WHERE province_id = ? AND (city_id = 0 OR city_id = ?)",
+		provinceID, cityID,
+	)
+	if err != nil || len(adminIDs) > 0 {
+		return adminIDs, err
+	}
+	return queryAdminIDs(tx, "SELECT admin_id FROM admins")
+}
+
+func queryAdminIDs(tx *sql.Tx, query string, args ...interface{}) ([]int64, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var adminIDs []int64
+	for rows.Next() {
+		var adminID int64
+		if err := rows.Scan(&adminID); err != nil {
+			return nil, err
+		}
+		adminIDs = append(adminIDs, adminID)
+	}
+	return adminIDs, rows.Err()
+}
+
+// loadFeedbackSLA 加载各预估等级的处理时限，未配置的等级使用默认值
+func loadFeedbackSLA() (map[int]models.FeedbackSLA, error) {
+	slas := make(map[int]models.FeedbackSLA, len(defaultFeedbackSLA))
+	for grade, sla := range defaultFeedbackSLA {
+		slas[grade] = sla
+	}
+
+	rows, err := database.DB.Query("SELECT grade, assign_minutes, confirm_minutes, remarks FROM feedback_sla")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sla models.FeedbackSLA
+		if err := rows.Scan(&sla.Grade, &sla.AssignMinutes, &sla.ConfirmMinutes, &sla.Remarks); err != nil {
+			return nil, err
+		}
+		slas[sla.Grade] = sla
+	}
+	return slas, rows.Err()
+}
+
+// slaForGrade 获取指定等级的时限，等级无效时按最宽松的一级处理
+func slaForGrade(slas map[int]models.FeedbackSLA, grade int) models.FeedbackSLA {
+	if sla, ok := slas[grade]; ok {
+		return sla
+	}
+	return slas[1]
+}
+
+// parseFeedbackTime 将数据库中分开存储的日期和时间解析为本地时间
+func parseFeedbackTime(date, clock string) (time.Time, error) {
+	return time.ParseInLocation(feedbackTimeLayout, date+" "+clock, time.Local)
+}
+
+// percentage 计算百分比，保留两位小数
+func percentage(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)*10000/float64(total)) / 100
+}
+
+// GetSLAConfig 获取各预估等级的处理时限配置
+func GetSLAConfig(c *fiber.Ctx) error {
+	slas, err := loadFeedbackSLA()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取时限配置失败",
+			"details": err.Error(),
+		})
+	}
+
+	var slaList []fiber.Map
+	for grade := 1; grade <= 6; grade++ {
+		sla := slaForGrade(slas, grade)
+		slaList = append(slaList, fiber.Map{
+			"grade":           grade,
+			"assign_minutes":  sla.AssignMinutes,
+			"confirm_minutes": sla.ConfirmMinutes,
+			"remarks":         sla.Remarks.String,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": slaList,
+	})
+}
+
+// UpdateSLAConfig 更新指定预估等级的处理时限
+func UpdateSLAConfig(c *fiber.Ctx) error {
+	var req struct {
+		Grade          int    `json:"grade"`
+		AssignMinutes  int    `json:"assign_minutes"`
+		ConfirmMinutes int    `json:"confirm_minutes"`
+		Remarks        string `json:"remarks"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "无效的请求数据",
+			"details": err.Error(),
+		})
+	}
+
+	if req.Grade < 1 || req.Grade > 6 || req.AssignMinutes <= 0 || req.ConfirmMinutes <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "等级必须为1-6，时限必须为正整数",
+		})
+	}
+	if req.ConfirmMinutes < req.AssignMinutes {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "确认时限不能短于指派时限",
+		})
+	}
+
+	var remarks interface{}
+	if req.Remarks != "" {
+		remarks = req.Remarks
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO feedback_sla (grade, assign_minutes, confirm_minutes, remarks) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE assign_minutes = VALUES(assign_minutes), confirm_minutes = VALUES(confirm_minutes), remarks = VALUES(remarks)
+	`, req.Grade, req.AssignMinutes, req.ConfirmMinutes, remarks)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "更新时限配置失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "时限配置更新成功",
+		"data": fiber.Map{
+			"grade":           req.Grade,
+			"assign_minutes":  req.AssignMinutes,
+			"confirm_minutes": req.ConfirmMinutes,
+		},
+	})
+}
+
+// GetOverdueFeedbacks 获取当前仍处于超时状态的反馈列表
+// 支持通过stage（1:指派超时; 2:确认超时）、province_id和city_id参数筛选
+func GetOverdueFeedbacks(c *fiber.Ctx) error {
+	query := `
+		SELECT
+			fo.fo_id, fo.af_id, fo.stage, fo.deadline, fo.flag_date, fo.flag_time, fo.escalated,
+			af.tel_id, af.province_id, af.city_id, af.address, af.estimated_grade,
+			af.af_date, af.af_time, af.gm_id, af.state,
+			IFNULL(p.province_name, '') as province_name,
+			IFNULL(ct.city_name, '') as city_name,
+			IFNULL(gm.gm_name, '') as grid_member_name
+		FROM
+			feedback_overdue fo
+		JOIN
+			aqi_feedback af ON fo.af_id = af.af_id
+		LEFT JOIN
+			grid_province p ON af.province_id = p.province_id
+		LEFT JOIN
+			grid_city ct ON af.city_id = ct.city_id
+		LEFT JOIN
+			grid_member gm ON af.gm_id = gm.gm_id
+		WHERE
+			fo.resolved = 0
+	`
+	params := []interface{}{}
+
+	if stage := c.Query("stage"); stage != "" {
+		query += " AND fo.stage = ?"
+		params = append(params, stage)
+	}
+	if provinceID := c.Query("province_id"); provinceID != "" {
+		query += " AND af.province_id = ?"
+		params = append(params, provinceID)
+
+		if cityID := c.Query("city_id"); cityID != "" {
+			query += " AND af.city_id = ?"
+			params = append(params, cityID)
+		}
+	}
+	query += " ORDER BY fo.deadline ASC"
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取超时反馈列表失败",
+			"error":   err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	var overdueList []fiber.Map
+	for rows.Next() {
+		var overdue models.FeedbackOverdue
+		var feedback models.AqiFeedback
+		var provinceName, cityName, gridMemberName string
+
+		err := rows.Scan(
+			&overdue.FoID, &overdue.AfID, &overdue.Stage, &overdue.Deadline, &overdue.FlagDate, &overdue.FlagTime, &overdue.Escalated,
+			&feedback.TelID, &feedback.ProvinceID, &feedback.CityID, &feedback.Address, &feedback.EstimatedGrade,
+			&feedback.AfDate, &feedback.AfTime, &feedback.GmID, &feedback.State,
+			&provinceName, &cityName, &gridMemberName,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "解析超时反馈数据失败",
+				"error":   err.Error(),
+			})
+		}
+
+		overdueMinutes := 0
+		if deadline, err := time.ParseInLocation(feedbackTimeLayout, overdue.Deadline, time.Local); err == nil {
+			overdueMinutes = int(now.Sub(deadline).Minutes())
+		}
+
+		overdueList = append(overdueList, fiber.Map{
+			"fo_id":            overdue.FoID,
+			"feedback_id":      overdue.AfID,
+			"stage":            overdue.Stage,
+			"stage_text":       getSLAStageText(overdue.Stage),
+			"deadline":         overdue.Deadline,
+			"overdue_minutes":  overdueMinutes,
+			"flag_date":        overdue.FlagDate,
+			"flag_time":        overdue.FlagTime,
+			"escalated":        overdue.Escalated == 1,
+			"tel_id":           feedback.TelID,
+			"province_id":      feedback.ProvinceID,
+			"city_id":          feedback.CityID,
+			"province_name":    provinceName,
+			"city_name":        cityName,
+			"address":          feedback.Address,
+			"estimated_grade":  feedback.EstimatedGrade,
+			"af_date":          feedback.AfDate,
+			"af_time":          feedback.AfTime,
+			"gm_id":            feedback.GmID,
+			"grid_member_name": gridMemberName,
+			"state":            feedback.State,
+			"state_text":       getStateText(feedback.State),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    overdueList,
+	})
+}
+
+// GetSLAComplianceStats 获取反馈处理时限达标率统计
+// 支持通过from、to（反馈日期，格式YYYY-MM-DD）和province_id参数筛选
+func GetSLAComplianceStats(c *fiber.Ctx) error {
+	slas, err := loadFeedbackSLA()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取时限配置失败",
+			"error":   err.Error(),
+		})
+	}
+
+	// 首次指派时间取指派日志中最早的一条，没有日志时使用反馈表中的指派时间
+	query := `
+		SELECT
+			af.estimated_grade, af.af_date, af.af_time, af.state,
+			COALESCE(al.first_assign_at, CONCAT(af.assign_date, ' ', af.assign_time)) as assign_at,
+			st.confirm_at
+		FROM
+			aqi_feedback af
+		LEFT JOIN
+			(SELECT af_id, MIN(CONCAT(assign_date, ' ', assign_time)) as first_assign_at
+			 FROM feedback_assign_log GROUP BY af_id) al ON af.af_id = al.af_id
+		LEFT JOIN
+			(SELECT af_id, MIN(CONCAT(confirm_date, ' ', confirm_time)) as confirm_at
+			 FROM statistics WHERE af_id > 0 GROUP BY af_id) st ON af.af_id = st.af_id
+		WHERE af.state <> ?
+	`
+	params := []interface{}{feedbackStateWithdrawn}
+
+	if from := c.Query("from"); from != "" {
+		query += " AND af.af_date >= ?"
+		params = append(params, from)
+	}
+	if to := c.Query("to"); to != "" {
+		query += " AND af.af_date <= ?"
+		params = append(params, to)
+	}
+	if provinceID := c.Query("province_id"); provinceID != "" {
+		query += " AND af.province_id = ?"
+		params = append(params, provinceID)
+	}
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取时限达标统计失败",
+			"error":   err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	type complianceCounter struct {
+		AssignTotal   int
+		AssignOnTime  int
+		ConfirmTotal  int
+		ConfirmOnTime int
+	}
+
+	now := time.Now()
+	var overall complianceCounter
+	byGrade := make(map[int]*complianceCounter)
+
+	for rows.Next() {
+		var grade, state int
+		var afDate, afTime string
+		var assignAt, confirmAt sql.NullString
+
+		if err := rows.Scan(&grade, &afDate, &afTime, &state, &assignAt, &confirmAt); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "解析时限达标统计失败",
+				"error":   err.Error(),
+			})
+		}
+
+		reportedAt, err := parseFeedbackTime(afDate, afTime)
+		if err != nil {
+			continue
+		}
+		sla := slaForGrade(slas, grade)
+		assignDeadline := reportedAt.Add(time.Duration(sla.AssignMinutes) * time.Minute)
+		confirmDeadline := reportedAt.Add(time.Duration(sla.ConfirmMinutes) * time.Minute)
+
+		counter, ok := byGrade[grade]
+		if !ok {
+			counter = &complianceCounter{}
+			byGrade[grade] = counter
+		}
+
+		// 已指派的按实际指派时间判断；未指派且已超过时限的计为未达标；尚在时限内的不计入
+		if assignAt.Valid {
+			if assignedAt, err := time.ParseInLocation(feedbackTimeLayout, assignAt.String, time.Local); err == nil {
+				onTime := !assignedAt.After(assignDeadline)
+				for _, ct := range []*complianceCounter{&overall, counter} {
+					ct.AssignTotal++
+					if onTime {
+						ct.AssignOnTime++
+					}
+				}
+			}
+		} else if state == 0 && now.After(assignDeadline) {
+			overall.AssignTotal++
+			counter.AssignTotal++
+		}
+
+		if confirmAt.Valid {
+			if confirmedAt, err := time.ParseInLocation(feedbackTimeLayout, confirmAt.String, time.Local); err == nil {
+				onTime := !confirmedAt.After(confirmDeadline)
+				for _, ct := range []*complianceCounter{&overall, counter} {
+					ct.ConfirmTotal++
+					if onTime {
+						ct.ConfirmOnTime++
+					}
+				}
+			}
+		} else if (state == 0 || state == 1) && now.After(confirmDeadline) {
+			overall.ConfirmTotal++
+			counter.ConfirmTotal++
+		}
+	}
+
+	var gradeList []fiber.Map
+	for grade := 1; grade <= 6; grade++ {
+		counter, ok := byGrade[grade]
+		if !ok {
+			counter = &complianceCounter{}
+		}
+		sla := slaForGrade(slas, grade)
+		gradeList = append(gradeList, fiber.Map{
+			"grade":              grade,
+			"assign_minutes":     sla.AssignMinutes,
+			"confirm_minutes":    sla.ConfirmMinutes,
+			"assign_total":       counter.AssignTotal,
+			"assign_on_time":     counter.AssignOnTime,
+			"assign_compliance":  percentage(counter.AssignOnTime, counter.AssignTotal),
+			"confirm_total":      counter.ConfirmTotal,
+			"confirm_on_time":    counter.ConfirmOnTime,
+			"confirm_compliance": percentage(counter.ConfirmOnTime, counter.ConfirmTotal),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"assign_total":       overall.AssignTotal,
+			"assign_on_time":     overall.AssignOnTime,
+			"assign_compliance":  percentage(overall.AssignOnTime, overall.AssignTotal),
+			"confirm_total":      overall.ConfirmTotal,
+			"confirm_on_time":    overall.ConfirmOnTime,
+			"confirm_compliance": percentage(overall.ConfirmOnTime, overall.ConfirmTotal),
+			"grades":             gradeList,
+		},
+	})
+}
+
+// GetMyEscalations 获取升级给当前管理员的超时反馈，支持通过state参数筛选处理状态
+func GetMyEscalations(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id")
+	if adminID == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	query := `
+		SELECT
+			fe.fe_id, fe.af_id, fe.escalate_date, fe.escalate_time, fe.state,
+			fo.stage, fo.deadline, fo.resolved,
+			af.address, af.estimated_grade, af.state,
+			IFNULL(p.province_name, '') as province_name,
+			IFNULL(ct.city_name, '') as city_name
+		FROM
+			feedback_escalation fe
+		JOIN
+			feedback_overdue fo ON fe.fo_id = fo.fo_id
+		JOIN
+			aqi_feedback af ON fe.af_id = af.af_id
+		LEFT JOIN
+			grid_province p ON af.province_id = p.province_id
+		LEFT JOIN
+			grid_city ct ON af.city_id = ct.city_id
+		WHERE
+			fe.admin_id = ?
+	`
+	params := []interface{}{adminID}
+
+	if stateParam := c.Query("state"); stateParam != "" {
+		if state, err := strconv.Atoi(stateParam); err == nil && (state == 0 || state == 1) {
+			query += " AND fe.state = ?"
+			params = append(params, state)
+		}
+	}
+	query += " ORDER BY fe.fe_id DESC"
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取升级记录失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var escalationList []fiber.Map
+	for rows.Next() {
+		var escalation models.FeedbackEscalation
+		var stage, resolved, estimatedGrade, feedbackState int
+		var deadline, address, provinceName, cityName string
+
+		err := rows.Scan(
+			&escalation.FeID, &escalation.AfID, &escalation.EscalateDate, &escalation.EscalateTime, &escalation.State,
+			&stage, &deadline, &resolved,
+			&address, &estimatedGrade, &feedbackState,
+			&provinceName, &cityName,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理升级记录失败",
+				"details": err.Error(),
+			})
+		}
+
+		escalationList = append(escalationList, fiber.Map{
+			"id":              escalation.FeID,
+			"feedback_id":     escalation.AfID,
+			"escalate_date":   escalation.EscalateDate,
+			"escalate_time":   escalation.EscalateTime,
+			"state":           escalation.State,
+			"stage":           stage,
+			"stage_text":      getSLAStageText(stage),
+			"deadline":        deadline,
+			"resolved":        resolved == 1,
+			"address":         address,
+			"estimated_grade": estimatedGrade,
+			"feedback_state":  feedbackState,
+			"province_name":   provinceName,
+			"city_name":       cityName,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": escalationList,
+	})
+}
+
+// HandleEscalation 将升级给当前管理员的记录标记为已处理
+func HandleEscalation(c *fiber.Ctx) error {
+	adminID := c.Locals("user_id")
+	if adminID == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	escalationID := c.Params("id")
+	if escalationID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "缺少升级记录ID",
+		})
+	}
+
+	var req struct {
+		Remarks string `json:"remarks"`
+	}
+	// 备注为可选项
+	_ = c.BodyParser(&req)
+
+	// 检查升级记录是否存在且属于当前管理员
+	var count int
+	err := database.DB.QueryRow(
+		"SELECT COUNT(*) FROM feedback_escalation WHERE fe_id = ? AND admin_id = ?",
+		escalationID, adminID,
+	).Scan(&count)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "数据库查询失败",
+		})
+	}
+
+	if count == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "升级记录不存在",
+		})
+	}
+
+	var remarks interface{}
+	if req.Remarks != "" {
+		remarks = req.Remarks
+	}
+
+	_, err = database.DB.Exec(
+		"UPDATE feedback_escalation SET state = 1, remarks = ? WHERE fe_id = ? AND admin_id = ?",
+		remarks, escalationID, adminID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "更新升级记录失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "升级记录已处理",
+	})
+}
+
+// 获取超时环节文本描述
+func getSLAStageText(stage int) string {
+	switch stage {
+	case slaStageAssign:
+		return "指派超时"
+	case slaStageConfirm:
+		return "确认超时"
+	default:
+		return "未知环节"
+	}
+}
@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"epss-backend/database"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// 获取省份分组AQI超标统计
+// 获取省份分组AQI超标统计，支持from、to、province_id和city_id参数
 func GetProvinceAQIStats(c *fiber.Ctx) error {
 	db := database.DB
 
@@ -22,26 +26,47 @@ func GetProvinceAQIStats(c *fiber.Ctx) error {
 
 	var results []ProvinceStats
 
+	filters, filterParams, err := buildStatsFilters(c, "s")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "查询参数无效",
+			"error":   err.Error(),
+		})
+	}
+
+	// 超标限值由超标判定标准得出
+	standard, err := loadComplianceStandard()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取超标判定标准失败",
+			"error":   err.Error(),
+		})
+	}
+
 	// 查询各省份的AQI超标统计
 	query := `
 		SELECT 
 			p.province_name,
 			p.province_id,
-			SUM(CASE WHEN s.so2_value > 150 THEN 1 ELSE 0 END) as so2_exceed_count,
-			SUM(CASE WHEN s.co_value > 4 THEN 1 ELSE 0 END) as co_exceed_count,
-			SUM(CASE WHEN s.spm_value > 75 THEN 1 ELSE 0 END) as pm25_exceed_count,
-			SUM(CASE WHEN s.aqi_id > 2 THEN 1 ELSE 0 END) as aqi_exceed_count
+			SUM(CASE WHEN s.so2_value > ? THEN 1 ELSE 0 END) as so2_exceed_count,
+			SUM(CASE WHEN s.co_value > ? THEN 1 ELSE 0 END) as co_exceed_count,
+			SUM(CASE WHEN s.spm_value > ? THEN 1 ELSE 0 END) as pm25_exceed_count,
+			SUM(CASE WHEN s.aqi_id > ? THEN 1 ELSE 0 END) as aqi_exceed_count
 		FROM 
 			statistics s
 		JOIN 
 			grid_province p ON s.province_id = p.province_id
+		WHERE 1 = 1` + filters + `
 		GROUP BY 
 			p.province_id, p.province_name
 		ORDER BY 
 			p.province_name
 	`
 
-	rows, err := db.Query(query)
+	params := append([]interface{}{standard.SO2.Limit, standard.CO.Limit, standard.SPM.Limit, standard.AQI.Limit}, filterParams...)
+	rows, err := db.Query(query, params...)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -64,12 +89,13 @@ func GetProvinceAQIStats(c *fiber.Ctx) error {
 	}
 
 	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    results,
+		"success":  true,
+		"data":     results,
+		"standard": standard,
 	})
 }
 
-// 获取AQI指数分布统计
+// 获取AQI指数分布统计，支持from、to、province_id和city_id参数
 func GetAQILevelStats(c *fiber.Ctx) error {
 	db := database.DB
 
@@ -77,10 +103,29 @@ func GetAQILevelStats(c *fiber.Ctx) error {
 		Level      string `json:"level"`
 		LevelValue int    `json:"level_value"`
 		Count      int    `json:"count"`
+		Exceeding  bool   `json:"exceeding"`
 	}
 
 	var results []AQILevelStats
 
+	filters, params, err := buildStatsFilters(c, "s")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "查询参数无效",
+			"error":   err.Error(),
+		})
+	}
+
+	standard, err := loadComplianceStandard()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取超标判定标准失败",
+			"error":   err.Error(),
+		})
+	}
+
 	// 查询AQI指数分布统计
 	// 使用aqi表和statistics表关联查询
 	query := `
@@ -92,13 +137,14 @@ func GetAQILevelStats(c *fiber.Ctx) error {
 			statistics s
 		JOIN
 			aqi a ON s.aqi_id = a.aqi_id
+		WHERE 1 = 1` + filters + `
 		GROUP BY 
 			a.aqi_id, a.chinese_explain
 		ORDER BY 
 			a.aqi_id
 	`
 
-	rows, err := db.Query(query)
+	rows, err := db.Query(query, params...)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -117,166 +163,354 @@ func GetAQILevelStats(c *fiber.Ctx) error {
 				"error":   err.Error(),
 			})
 		}
+		stat.Exceeding = standard.aqiExceeds(stat.LevelValue)
 		results = append(results, stat)
 	}
 
 	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    results,
+		"success":  true,
+		"data":     results,
+		"standard": standard,
 	})
 }
 
+// 趋势统计的时间粒度
+const (
+	trendGranularityDay     = "day"
+	trendGranularityWeek    = "week"
+	trendGranularityMonth   = "month"
+	trendGranularityQuarter = "quarter"
+	trendGranularityYear    = "year"
+)
+
+// 趋势统计最多返回的周期数量
+const maxTrendPeriods = 1000
+
+// trendStats 一个统计周期内的检测数量、超标数量和各污染物平均浓度
+type trendStats struct {
+	Period          string  `json:"period"`
+	Month           string  `json:"month,omitempty"` // 按月统计时与period相同，兼容原有的按月趋势
+	TotalCount      int     `json:"total_count"`
+	ExceedCount     int     `json:"exceed_count"`
+	SO2ExceedCount  int     `json:"so2_exceed_count"`
+	COExceedCount   int     `json:"co_exceed_count"`
+	PM25ExceedCount int     `json:"pm25_exceed_count"`
+	SO2Avg          float64 `json:"so2_avg"`
+	COAvg           float64 `json:"co_avg"`
+	PM25Avg         float64 `json:"pm25_avg"`
+
+	so2Sum, coSum, spmSum int64
+}
+
 // 获取AQI指数趋势统计
+// 支持from、to（确认日期）、granularity（day、week、month、quarter、year，默认month）、province_id和city_id参数
+// 未指定from和to时按timeRange参数统计：12months（默认，最近12个月）或all（全部数据）
+// 返回的周期按时间顺序排列，没有数据的周期计数为0
 func GetAQITrendStats(c *fiber.Ctx) error {
 	db := database.DB
 
-	type MonthlyStats struct {
-		Month       string `json:"month"`
-		ExceedCount int    `json:"exceed_count"`
+	granularity := c.Query("granularity", trendGranularityMonth)
+	switch granularity {
+	case trendGranularityDay, trendGranularityWeek, trendGranularityMonth, trendGranularityQuarter, trendGranularityYear:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "查询参数无效",
+			"error":   "granularity必须为day、week、month、quarter或year",
+		})
+	}
+
+	filters, params, err := buildStatsFilters(c, "s")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "查询参数无效",
+			"error":   err.Error(),
+		})
 	}
 
-	var results []MonthlyStats
+	// 统计范围的起止日期，用于补齐没有数据的周期
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if value := c.Query("to"); value != "" {
+		to, _ = time.ParseInLocation("2006-01-02", value, time.Local)
+	}
+	var from time.Time
+	if value := c.Query("from"); value != "" {
+		from, _ = time.ParseInLocation("2006-01-02", value, time.Local)
+	} else if c.Query("to") == "" && c.Query("timeRange", "12months") != "all" {
+		// 默认统计包括本月在内的最近12个月
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local).AddDate(0, -11, 0)
+		filters += " AND s.confirm_date >= ?"
+		params = append(params, from.Format("2006-01-02"))
+	}
 
-	// 获取查询参数
-	timeRange := c.Query("timeRange", "12months") // 默认查询过去12个月
+	standard, err := loadComplianceStandard()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取超标判定标准失败",
+			"error":   err.Error(),
+		})
+	}
 
-	// 构建SQL查询
+	// 按日汇总后再合并到各统计周期
 	query := `
-		SELECT 
-			CONCAT(SUBSTRING(confirm_date, 1, 7)) as month,
-			SUM(CASE WHEN aqi_id > 2 THEN 1 ELSE 0 END) as exceed_count
-		FROM 
-			statistics
+		SELECT
+			s.confirm_date,
+			COUNT(*) as total_count,
+			SUM(CASE WHEN s.aqi_id > ? THEN 1 ELSE 0 END) as exceed_count,
+			SUM(CASE WHEN s.so2_value > ? THEN 1 ELSE 0 END) as so2_exceed_count,
+			SUM(CASE WHEN s.co_value > ? THEN 1 ELSE 0 END) as co_exceed_count,
+			SUM(CASE WHEN s.spm_value > ? THEN 1 ELSE 0 END) as pm25_exceed_count,
+			SUM(s.so2_value) as so2_sum,
+			SUM(s.co_value) as co_sum,
+			SUM(s.spm_value) as spm_sum
+		FROM
+			statistics s
+		WHERE 1 = 1` + filters + `
+		GROUP BY
+			s.confirm_date
+		ORDER BY
+			s.confirm_date
 	`
+	params = append([]interface{}{standard.AQI.Limit, standard.SO2.Limit, standard.CO.Limit, standard.SPM.Limit}, params...)
 
-	// 根据时间范围参数添加WHERE子句
-	var startDate time.Time
-	now := time.Now()
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取AQI指数趋势统计失败",
+			"error":   err.Error(),
+		})
+	}
+	defer rows.Close()
 
-	if timeRange == "all" {
-		// 不添加时间限制，查询所有数据
-		query += `
-		GROUP BY 
-			month
-		ORDER BY 
-			month
-		`
-		
-		rows, err := db.Query(query)
-		if err != nil {
+	periods := make(map[string]*trendStats)
+	for rows.Next() {
+		var date string
+		var day trendStats
+		if err := rows.Scan(&date, &day.TotalCount, &day.ExceedCount, &day.SO2ExceedCount, &day.COExceedCount, &day.PM25ExceedCount,
+			&day.so2Sum, &day.coSum, &day.spmSum); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"success": false,
-				"message": "获取AQI指数趋势统计失败",
+				"message": "解析AQI指数趋势统计失败",
 				"error":   err.Error(),
 			})
 		}
-		defer rows.Close()
-
-		for rows.Next() {
-			var stat MonthlyStats
-			if err := rows.Scan(&stat.Month, &stat.ExceedCount); err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"success": false,
-					"message": "解析AQI指数趋势统计失败",
-					"error":   err.Error(),
-				})
-			}
-			results = append(results, stat)
-		}
-	} else {
-		// 默认查询过去12个月
-		startDate = now.AddDate(-1, 0, 0)
-		
-		query += `
-		WHERE 
-			STR_TO_DATE(confirm_date, '%Y-%m-%d') >= ?
-		GROUP BY 
-			month
-		ORDER BY 
-			month
-		`
-		
-		rows, err := db.Query(query, startDate.Format("2006-01-02"))
+		t, err := time.ParseInLocation("2006-01-02", date, time.Local)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"success": false,
-				"message": "获取AQI指数趋势统计失败",
-				"error":   err.Error(),
-			})
+			continue
+		}
+		// 统计全部数据时从最早的数据开始补齐
+		if from.IsZero() || t.Before(from) {
+			from = t
 		}
-		defer rows.Close()
 
-		for rows.Next() {
-			var stat MonthlyStats
-			if err := rows.Scan(&stat.Month, &stat.ExceedCount); err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		label := trendPeriodLabel(trendPeriodStart(t, granularity), granularity)
+		stat, ok := periods[label]
+		if !ok {
+			stat = &trendStats{}
+			periods[label] = stat
+		}
+		stat.TotalCount += day.TotalCount
+		stat.ExceedCount += day.ExceedCount
+		stat.SO2ExceedCount += day.SO2ExceedCount
+		stat.COExceedCount += day.COExceedCount
+		stat.PM25ExceedCount += day.PM25ExceedCount
+		stat.so2Sum += day.so2Sum
+		stat.coSum += day.coSum
+		stat.spmSum += day.spmSum
+	}
+
+	results := []trendStats{}
+	if !from.IsZero() {
+		for start := trendPeriodStart(from, granularity); !start.After(to); start = nextTrendPeriod(start, granularity) {
+			if len(results) >= maxTrendPeriods {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 					"success": false,
-					"message": "解析AQI指数趋势统计失败",
-					"error":   err.Error(),
+					"message": "查询参数无效",
+					"error":   fmt.Sprintf("统计周期超过%d个，请缩小时间范围或增大统计粒度", maxTrendPeriods),
 				})
 			}
-			results = append(results, stat)
-		}
 
-		// 如果没有数据，生成过去12个月的空数据
-		if len(results) == 0 && timeRange == "12months" {
-			for i := 0; i < 12; i++ {
-				month := now.AddDate(0, -i, 0).Format("2006-01")
-				results = append(results, MonthlyStats{Month: month, ExceedCount: 0})
+			stat := trendStats{}
+			if existing, ok := periods[trendPeriodLabel(start, granularity)]; ok {
+				stat = *existing
+			}
+			stat.Period = trendPeriodLabel(start, granularity)
+			if granularity == trendGranularityMonth {
+				stat.Month = stat.Period
 			}
+			if stat.TotalCount > 0 {
+				stat.SO2Avg = math.Round(float64(stat.so2Sum)/float64(stat.TotalCount)*100) / 100
+				stat.COAvg = math.Round(float64(stat.coSum)/float64(stat.TotalCount)*100) / 100
+				stat.PM25Avg = math.Round(float64(stat.spmSum)/float64(stat.TotalCount)*100) / 100
+			}
+			results = append(results, stat)
 		}
 	}
 
 	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    results,
+		"success":     true,
+		"data":        results,
+		"granularity": granularity,
+		"standard":    standard,
 	})
 }
 
-// 获取空气质量检测数量实时统计
-func GetAQIRealtimeStats(c *fiber.Ctx) error {
-	db := database.DB
+// trendPeriodStart 返回日期所在统计周期的第一天，按周统计时每周从周一开始
+func trendPeriodStart(t time.Time, granularity string) time.Time {
+	switch granularity {
+	case trendGranularityWeek:
+		return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+	case trendGranularityMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	case trendGranularityQuarter:
+		return time.Date(t.Year(), (t.Month()-1)/3*3+1, 1, 0, 0, 0, 0, t.Location())
+	case trendGranularityYear:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return t
+	}
+}
 
-	type RealtimeStats struct {
-		TotalCount     int `json:"total_count"`
-		GoodCount      int `json:"good_count"`
-		ExceedingCount int `json:"exceeding_count"`
+// nextTrendPeriod 返回下一个统计周期的第一天
+func nextTrendPeriod(start time.Time, granularity string) time.Time {
+	switch granularity {
+	case trendGranularityWeek:
+		return start.AddDate(0, 0, 7)
+	case trendGranularityMonth:
+		return start.AddDate(0, 1, 0)
+	case trendGranularityQuarter:
+		return start.AddDate(0, 3, 0)
+	case trendGranularityYear:
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 0, 1)
 	}
+}
 
-	var stats RealtimeStats
+// trendPeriodLabel 返回统计周期的名称：日和周为第一天的日期，月为2006-01，季度为2006-Q1，年为2006
+func trendPeriodLabel(start time.Time, granularity string) string {
+	switch granularity {
+	case trendGranularityMonth:
+		return start.Format("2006-01")
+	case trendGranularityQuarter:
+		return fmt.Sprintf("%d-Q%d", start.Year(), (int(start.Month())-1)/3+1)
+	case trendGranularityYear:
+		return start.Format("2006")
+	default:
+		return start.Format("2006-01-02")
+	}
+}
 
-	// 查询总检测数量
-	row := db.QueryRow("SELECT COUNT(*) FROM statistics")
-	if err := row.Scan(&stats.TotalCount); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"success": false,
-			"message": "获取检测总数量失败",
-			"error":   err.Error(),
-		})
+// buildStatsFilters 根据from、to（确认日期，格式为2006-01-02）、province_id和city_id参数生成统计查询条件
+// alias为statistics表的别名，位置审核驳回的实测数据始终排除
+func buildStatsFilters(c *fiber.Ctx, alias string) (string, []interface{}, error) {
+	conditions := " AND " + validStatisticsCondition(alias)
+	params := []interface{}{}
+
+	var from, to time.Time
+	if value := c.Query("from"); value != "" {
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return "", nil, errors.New("from必须为2006-01-02格式的日期")
+		}
+		from = t
+		conditions += " AND " + alias + ".confirm_date >= ?"
+		params = append(params, value)
+	}
+	if value := c.Query("to"); value != "" {
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return "", nil, errors.New("to必须为2006-01-02格式的日期")
+		}
+		to = t
+		conditions += " AND " + alias + ".confirm_date <= ?"
+		params = append(params, value)
+	}
+	if !from.IsZero() && !to.IsZero() && from.After(to) {
+		return "", nil, errors.New("from不能晚于to")
 	}
 
-	// 查询良好检测数量 (AQI <= 2, 对应优和良)
-	row = db.QueryRow("SELECT COUNT(*) FROM statistics WHERE aqi_id <= 2")
-	if err := row.Scan(&stats.GoodCount); err != nil {
+	if value := c.Query("province_id"); value != "" {
+		provinceID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", nil, errors.New("province_id必须为数字")
+		}
+		conditions += " AND " + alias + ".province_id = ?"
+		params = append(params, provinceID)
+	}
+	if value := c.Query("city_id"); value != "" {
+		cityID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", nil, errors.New("city_id必须为数字")
+		}
+		conditions += " AND " + alias + ".city_id = ?"
+		params = append(params, cityID)
+	}
+
+	return conditions, params, nil
+}
+
+// realtimeStats 空气质量检测数量实时统计
+type realtimeStats struct {
+	TotalCount     int `json:"total_count"`
+	GoodCount      int `json:"good_count"`
+	ExceedingCount int `json:"exceeding_count"`
+}
+
+// 获取空气质量检测数量实时统计
+func GetAQIRealtimeStats(c *fiber.Ctx) error {
+	standard, err := loadComplianceStandard()
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
-			"message": "获取良好检测数量失败",
+			"message": "获取超标判定标准失败",
 			"error":   err.Error(),
 		})
 	}
 
-	// 查询超标检测数量 (AQI > 2, 对应轻度污染及以上)
-	row = db.QueryRow("SELECT COUNT(*) FROM statistics WHERE aqi_id > 2")
-	if err := row.Scan(&stats.ExceedingCount); err != nil {
+	stats, message, err := loadRealtimeStats(standard)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
-			"message": "获取超标检测数量失败",
+			"message": message,
 			"error":   err.Error(),
 		})
 	}
 
 	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    stats,
+		"success":  true,
+		"data":     stats,
+		"standard": standard,
 	})
 }
+
+// loadRealtimeStats 按超标判定标准查询实时统计数据，失败时返回错误说明
+func loadRealtimeStats(standard complianceStandard) (realtimeStats, string, error) {
+	db := database.DB
+
+	var stats realtimeStats
+
+	// 查询总检测数量
+	row := db.QueryRow("SELECT COUNT(*) FROM statistics s WHERE " + validStatisticsCondition("s"))
+	if err := row.Scan(&stats.TotalCount); err != nil {
+		return stats, "获取检测总数量失败", err
+	}
+
+	// 查询良好检测数量 (未达到视为超标的AQI级别)
+	row = db.QueryRow("SELECT COUNT(*) FROM statistics s WHERE s.aqi_id <= ? AND "+validStatisticsCondition("s"), standard.AQI.Limit)
+	if err := row.Scan(&stats.GoodCount); err != nil {
+		return stats, "获取良好检测数量失败", err
+	}
+
+	// 查询超标检测数量 (达到视为超标的AQI级别)
+	row = db.QueryRow("SELECT COUNT(*) FROM statistics s WHERE s.aqi_id > ? AND "+validStatisticsCondition("s"), standard.AQI.Limit)
+	if err := row.Scan(&stats.ExceedingCount); err != nil {
+		return stats, "获取超标检测数量失败", err
+	}
+
+	return stats, "", nil
+}
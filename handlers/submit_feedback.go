@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"epss-backend/database"
+	"epss-backend/realtime"
 	"fmt"
 	"time"
 
@@ -20,11 +21,14 @@ func SubmitFeedback(c *fiber.Ctx) error {
 
 	// 解析请求体
 	type FeedbackRequest struct {
-		ProvinceID     int64  `json:"province_id"`
-		CityID         int64  `json:"city_id"`
-		Address        string `json:"address"`
-		Information    string `json:"information"`
-		EstimatedGrade int    `json:"estimated_grade"`
+		ProvinceID     int64    `json:"province_id"`
+		CityID         int64    `json:"city_id"`
+		Address        string   `json:"address"`
+		Information    string   `json:"information"`
+		EstimatedGrade int      `json:"estimated_grade"`
+		Latitude       *float64 `json:"latitude"`  // 纬度（可选）
+		Longitude      *float64 `json:"longitude"` // 经度（可选）
+		Accuracy       *float64 `json:"accuracy"`  // 定位精度，单位米（可选）
 	}
 
 	var request FeedbackRequest
@@ -42,19 +46,37 @@ func SubmitFeedback(c *fiber.Ctx) error {
 		})
 	}
 
+	location := GeoPoint{Latitude: request.Latitude, Longitude: request.Longitude, Accuracy: request.Accuracy}
+	if !location.Valid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "定位信息无效，经纬度必须同时提供且在有效范围内",
+		})
+	}
+	latitude, longitude, accuracy := location.dbValues()
+
 	// 获取当前日期和时间
 	now := time.Now()
 	afDate := now.Format("2006-01-02")
 	afTime := now.Format("15:04:05")
 
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "启动数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
 	// 插入反馈数据
 	query := `
 		INSERT INTO aqi_feedback 
-		(tel_id, province_id, city_id, address, information, estimated_grade, af_date, af_time, gm_id, state) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 0, 0)
+		(tel_id, province_id, city_id, address, information, estimated_grade, af_date, af_time, gm_id, state,
+		 latitude, longitude, location_accuracy) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 0, 0, ?, ?, ?)
 	`
 
-	result, err := database.DB.Exec(
+	result, err := tx.Exec(
 		query,
 		telID,
 		request.ProvinceID,
@@ -64,6 +86,9 @@ func SubmitFeedback(c *fiber.Ctx) error {
 		request.EstimatedGrade,
 		afDate,
 		afTime,
+		latitude,
+		longitude,
+		accuracy,
 	)
 
 	if err != nil {
@@ -82,10 +107,77 @@ func SubmitFeedback(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+	// 与同一城市未完成的反馈比对，重复时并入同一事件组
+	reportedAt, _ := parseFeedbackTime(afDate, afTime)
+	match, err := detectIncidentGroup(tx, duplicateCandidate{
+		AfID:       afID,
+		Address:    request.Address,
+		Latitude:   nullFloatFromPointer(request.Latitude),
+		Longitude:  nullFloatFromPointer(request.Longitude),
+		ReportedAt: reportedAt,
+	}, request.ProvinceID, request.CityID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "检测重复反馈失败",
+			"details": err.Error(),
+		})
+	}
+
+	// 自动指派给事件组负责的网格员时，与手动指派一样通知该网格员
+	if match != nil && match.GmID > 0 {
+		if err := notifyTaskAssigned(tx, int(afID), int(match.GmID), now); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "写入通知失败",
+				"details": err.Error(),
+			})
+		}
+	}
+
+	// 通知订阅了新反馈的外部系统，AQI级别按预估等级筛选
+	err = enqueueWebhookEvent(tx, webhookEventFeedbackSubmitted, request.ProvinceID, request.EstimatedGrade, fiber.Map{
+		"feedback_id": afID,
+		"province_id": request.ProvinceID,
+		"city_id": request.CityID,
+		"address": request.Address,
+		"information": request.Information,
+		"estimated_grade": request.EstimatedGrade,
+		"af_date": afDate,
+		"af_time": afTime,
+		"latitude": request.Latitude,
+		"longitude": request.Longitude,
+	}, now)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "写入外部回调失败",
+			"details": err.Error(),
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "提交数据库事务失败",
+			"details": err.Error(),
+		})
+	}
+
+	// 推送新反馈事件
+	publishFeedbackEvents(realtime.TopicFeedbackCreated, []int64{afID})
+	if match != nil && match.GmID > 0 {
+		publishFeedbackEvents(realtime.TopicFeedbackAssigned, []int64{afID})
+	}
+
+	response := fiber.Map{
 		"message": "反馈数据提交成功",
 		"feedback_id": afID,
 		"tel_id": telID,
 		"submit_time": fmt.Sprintf("%s %s", afDate, afTime),
-	})
+		"incident_group_id": 0,
+	}
+	if match != nil {
+		response["message"] = "反馈数据提交成功，已有相同地点的反馈正在处理，已合并处理"
+		response["incident_group_id"] = match.IgID
+		response["duplicate_of"] = match.DuplicateOf
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response)
 }
@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/database"
+	"epss-backend/models"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 离线同步记录的处理结果
+const (
+	syncResultPending  = 0 // 处理中
+	syncResultAccepted = 1 // 已接收
+	syncResultConflict = 2 // 冲突，任务状态已变化
+	syncResultInvalid  = 3 // 数据无效
+)
+
+// 一次同步最多提交的实测数据条数
+const maxSyncItems = 50
+
+// 设备时间允许超前服务器时间的范围
+const syncClockSkew = 10 * time.Minute
+
+var clientIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// syncMeasurementItem 离线缓存的一条实测数据
+type syncMeasurementItem struct {
+	aqiMeasurementRequest
+	ClientID   string `json:"client_id"`   // 客户端生成的UUID
+	DeviceDate string `json:"device_date"` // 设备上的测量日期
+	DeviceTime string `json:"device_time"` // 设备上的测量时间
+}
+
+// SyncMeasurements 网格员批量同步离线缓存的实测数据
+// 每条数据以client_id去重，重复提交时直接返回首次处理的结果；任务已改派、已确认等情况逐条报告冲突
+func SyncMeasurements(c *fiber.Ctx) error {
+	gmID := c.Locals("user_gm_id")
+	if gmID == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	var req struct {
+		Items []syncMeasurementItem `json:"items"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "无效的请求格式",
+			"details": err.Error(),
+		})
+	}
+	if len(req.Items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "请提供需要同步的实测数据",
+		})
+	}
+	if len(req.Items) > maxSyncItems {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "一次最多同步50条实测数据",
+		})
+	}
+
+	results := make([]fiber.Map, 0, len(req.Items))
+	summary := map[int]int{}
+	for _, item := range req.Items {
+		result := syncMeasurement(gmID, item, time.Now())
+		if code, ok := result["result"].(int); ok {
+			summary[code]++
+		} else {
+			summary[syncResultPending]++
+		}
+		results = append(results, result)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "同步完成",
+		"data": fiber.Map{
+			"accepted": summary[syncResultAccepted],
+			"conflict": summary[syncResultConflict],
+			"invalid":  summary[syncResultInvalid],
+			"failed":   summary[syncResultPending],
+			"results":  results,
+		},
+	})
+}
+
+// syncMeasurement 处理一条离线实测数据，返回该条的处理结果
+// 同步记录与实测数据在同一事务中写入，重复提交直接读取已有结果；处理出错时整体回滚，客户端可以重试
+func syncMeasurement(gmID interface{}, item syncMeasurementItem, now time.Time) fiber.Map {
+	response := fiber.Map{
+		"client_id":   item.ClientID,
+		"feedback_id": item.FeedbackID,
+	}
+	invalid := func(message string) fiber.Map {
+		response["result"] = syncResultInvalid
+		response["result_text"] = getSyncResultText(syncResultInvalid)
+		response["message"] = message
+		return response
+	}
+	if !clientIDPattern.MatchString(item.ClientID) {
+		return invalid("client_id必须为UUID格式")
+	}
+	measuredAt, err := parseFeedbackTime(item.DeviceDate, item.DeviceTime)
+	if err != nil {
+		return invalid("设备时间格式必须为YYYY-MM-DD和HH:MM:SS")
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		response["error"] = fmt.Sprintf("启动数据库事务失败: %v", err)
+		return response
+	}
+	defer tx.Rollback()
+
+	// 同一client_id并发提交时，后到的请求等待先到的事务结束
+	result, err := tx.Exec(`
+		INSERT IGNORE INTO sync_record (gm_id, client_id, af_id, device_date, device_time, sync_date, sync_time, result)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, gmID, item.ClientID, item.FeedbackID, item.DeviceDate, item.DeviceTime,
+		now.Format("2006-01-02"), now.Format("15:04:05"), syncResultPending)
+	if err != nil {
+		response["error"] = fmt.Sprintf("记录同步数据失败: %v", err)
+		return response
+	}
+	srID, _ := result.LastInsertId()
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		tx.Rollback()
+		return previousSyncResult(gmID, item.ClientID, response)
+	}
+
+	code, message, saved, err := applySyncMeasurement(tx, gmID, item, measuredAt, now)
+	if err != nil {
+		response["error"] = err.Error()
+		return response
+	}
+
+	var statisticsID interface{} = 0
+	if saved != nil {
+		statisticsID = saved.id
+	}
+	_, err = tx.Exec(
+		"UPDATE sync_record SET result = ?, statistics_id = ?, message = ? WHERE sr_id = ?",
+		code, statisticsID, message, srID,
+	)
+	if err != nil {
+		response["error"] = fmt.Sprintf("更新同步记录失败: %v", err)
+		return response
+	}
+	if err := tx.Commit(); err != nil {
+		response["error"] = fmt.Sprintf("提交数据库事务失败: %v", err)
+		return response
+	}
+	if saved != nil {
+		saved.publish()
+		response["data"] = saved.data
+	}
+
+	response["result"] = code
+	response["result_text"] = getSyncResultText(code)
+	response["message"] = message
+	response["duplicate"] = false
+	return response
+}
+
+// applySyncMeasurement 校验并保存离线实测数据，测量时间取设备时间
+// 返回处理结果、说明，接收时同时返回已保存的实测数据；error 仅表示服务器内部错误，此时调用方回滚事务
+func applySyncMeasurement(tx *sql.Tx, gmID interface{}, item syncMeasurementItem, measuredAt, now time.Time) (int, string, *savedMeasurement, error) {
+	if measuredAt.After(now.Add(syncClockSkew)) {
+		return syncResultInvalid, "设备时间晚于服务器时间，请校准设备时钟", nil, nil
+	}
+	if message := validateAQIMeasurement(item.aqiMeasurementRequest); message != "" {
+		return syncResultInvalid, message, nil, nil
+	}
+
+	if item.FeedbackID > 0 {
+		var assignedGmID int64
+		var state int
+		err := tx.QueryRow(
+			"SELECT gm_id, state FROM aqi_feedback WHERE af_id = ? FOR UPDATE", item.FeedbackID,
+		).Scan(&assignedGmID, &state)
+		if err == sql.ErrNoRows {
+			return syncResultInvalid, "关联的反馈不存在", nil, nil
+		}
+		if err != nil {
+			return 0, "", nil, fmt.Errorf("查询任务信息失败: %v", err)
+		}
+		if fmt.Sprint(assignedGmID) != fmt.Sprint(gmID) {
+			if assignedGmID == 0 {
+				return syncResultConflict, "任务已退回，等待重新指派", nil, nil
+			}
+			return syncResultConflict, "任务已改派给其他网格员", nil, nil
+		}
+		if state != 1 {
+			return syncResultConflict, "任务当前状态为" + getStateText(state) + "，不能提交实测数据", nil, nil
+		}
+	}
+
+	saved, err := saveAQIMeasurementTx(tx, gmID, item.aqiMeasurementRequest, measuredAt)
+	if err == errFeedbackNotInProgress {
+		return syncResultConflict, err.Error(), nil, nil
+	}
+	if err != nil {
+		return 0, "", nil, err
+	}
+	return syncResultAccepted, saved.message, saved, nil
+}
+
+// previousSyncResult 读取同一client_id首次处理的结果
+func previousSyncResult(gmID interface{}, clientID string, response fiber.Map) fiber.Map {
+	var code int
+	var statisticsID int64
+	var message sql.NullString
+	err := database.DB.QueryRow(
+		"SELECT result, statistics_id, message FROM sync_record WHERE gm_id = ? AND client_id = ?",
+		gmID, clientID,
+	).Scan(&code, &statisticsID, &message)
+	if err != nil {
+		response["error"] = fmt.Sprintf("查询同步记录失败: %v", err)
+		return response
+	}
+
+	if code == syncResultPending {
+		response["error"] = "该数据正在处理中，请稍后重试"
+		return response
+	}
+	response["result"] = code
+	response["result_text"] = getSyncResultText(code)
+	response["message"] = message.String
+	response["duplicate"] = true
+	if statisticsID > 0 {
+		response["data"] = fiber.Map{"id": statisticsID}
+	}
+	return response
+}
+
+// GetSyncChanges 获取上次同步以来的任务变化
+// since为上次返回的sync_token，不传时返回当前所有处理中的任务；
+// changed为仍属于当前网格员且有变化的任务，removed为已改派或退回、不再属于当前网格员的任务
+func GetSyncChanges(c *fiber.Ctx) error {
+	gmID := c.Locals("user_gm_id")
+	if gmID == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	var since int64 = -1
+	if token := c.Query("since"); token != "" {
+		value, err := strconv.ParseInt(token, 10, 64)
+		if err != nil || value < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "无效的同步令牌",
+			})
+		}
+		since = value
+	}
+
+	// 令牌取数据库时间，查询使用 >= 比较，同一秒内的修改会在下次同步时重复返回
+	var syncToken int64
+	if err := database.DB.QueryRow("SELECT UNIX_TIMESTAMP()").Scan(&syncToken); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取同步令牌失败",
+			"details": err.Error(),
+		})
+	}
+
+	query := `
+		SELECT
+			af_id, tel_id, province_id, city_id, address, information, estimated_grade,
+			af_date, af_time, assign_date, assign_time, state, remarks,
+			latitude, longitude, location_accuracy, ig_id,
+			DATE_FORMAT(update_time, '%Y-%m-%d %H:%i:%s')
+		FROM
+			aqi_feedback
+		WHERE
+			gm_id = ?
+	`
+	params := []interface{}{gmID}
+	if since < 0 {
+		query += " AND state = 1"
+	} else {
+		query += " AND state > 0 AND update_time >= FROM_UNIXTIME(?)"
+		params = append(params, since)
+	}
+	query += " ORDER BY af_id ASC"
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取任务变化失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	changed := []fiber.Map{}
+	for rows.Next() {
+		var task models.AqiFeedback
+		err := rows.Scan(
+			&task.AfID, &task.TelID, &task.ProvinceID, &task.CityID, &task.Address, &task.Information, &task.EstimatedGrade,
+			&task.AfDate, &task.AfTime, &task.AssignDate, &task.AssignTime, &task.State, &task.Remarks,
+			&task.Latitude, &task.Longitude, &task.LocationAccuracy, &task.IgID,
+			&task.UpdateTime,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理任务数据失败",
+				"details": err.Error(),
+			})
+		}
+
+		changed = append(changed, fiber.Map{
+			"id":                task.AfID,
+			"tel_id":            task.TelID,
+			"province_id":       task.ProvinceID,
+			"city_id":           task.CityID,
+			"address":           task.Address,
+			"information":       task.Information,
+			"estimated_grade":   task.EstimatedGrade,
+			"af_date":           task.AfDate,
+			"af_time":           task.AfTime,
+			"assign_date":       task.AssignDate.String,
+			"assign_time":       task.AssignTime.String,
+			"state":             task.State,
+			"state_text":        getStateText(task.State),
+			"remarks":           task.Remarks.String,
+			"latitude":          nullFloatValue(task.Latitude),
+			"longitude":         nullFloatValue(task.Longitude),
+			"location_accuracy": nullFloatValue(task.LocationAccuracy),
+			"incident_group_id": task.IgID,
+			"update_time":       task.UpdateTime,
+		})
+	}
+	rows.Close()
+
+	// 曾指派给当前网格员、现在已不属于当前网格员的任务
+	removed := []fiber.Map{}
+	if since >= 0 {
+		rows, err := database.DB.Query(`
+			SELECT af.af_id, af.gm_id, af.state
+			FROM aqi_feedback af
+			WHERE af.gm_id <> ? AND af.update_time >= FROM_UNIXTIME(?)
+				AND af.af_id IN (SELECT l.af_id FROM feedback_assign_log l WHERE l.gm_id = ?)
+			ORDER BY af.af_id ASC
+		`, gmID, since, gmID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "获取已移除任务失败",
+				"details": err.Error(),
+			})
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var afID, assignedGmID int64
+			var state int
+			if err := rows.Scan(&afID, &assignedGmID, &state); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":   "处理任务数据失败",
+					"details": err.Error(),
+				})
+			}
+			reason := "任务已改派给其他网格员"
+			if assignedGmID == 0 {
+				reason = "任务已退回，等待重新指派"
+			}
+			removed = append(removed, fiber.Map{
+				"id":         afID,
+				"state":      state,
+				"state_text": getStateText(state),
+				"reason":     reason,
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"data": fiber.Map{
+			"full":       since < 0,
+			"changed":    changed,
+			"removed":    removed,
+			"sync_token": strconv.FormatInt(syncToken, 10),
+		},
+	})
+}
+
+// 获取同步结果文本描述
+func getSyncResultText(result int) string {
+	switch result {
+	case syncResultPending:
+		return "处理中"
+	case syncResultAccepted:
+		return "已接收"
+	case syncResultConflict:
+		return "冲突"
+	case syncResultInvalid:
+		return "无效"
+	default:
+		return "未知"
+	}
+}
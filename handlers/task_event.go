@@ -0,0 +1,499 @@
+package handlers
+
+import (
+	"database/sql"
+	"epss-backend/database"
+	"epss-backend/models"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 任务事件类型
+const (
+	taskEventAccepted = 1 // 已接单
+	taskEventEnRoute  = 2 // 出发前往
+	taskEventArrived  = 3 // 到达现场
+	taskEventMeasured = 4 // 完成实测，提交实测数据时自动记录
+)
+
+// sqlExecer *sql.DB 和 *sql.Tx 共有的执行方法
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// recordTaskEvent 记录任务事件，反馈和事件都有定位时记录两者的距离
+func recordTaskEvent(db sqlExecer, feedbackID, gmID interface{}, eventType int, at time.Time, location GeoPoint, distance interface{}, remarks string) (int64, error) {
+	latitude, longitude, accuracy := location.dbValues()
+
+	var remarksValue interface{}
+	if remarks != "" {
+		remarksValue = remarks
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO task_event (af_id, gm_id, event_type, event_date, event_time, latitude, longitude, location_accuracy, distance, remarks)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, feedbackID, gmID, eventType, at.Format("2006-01-02"), at.Format("15:04:05"), latitude, longitude, accuracy, distance, remarksValue)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// AddTaskEvent 网格员记录任务进度（1:已接单; 2:出发前往; 3:到达现场），可携带定位
+// 事件必须按顺序记录，可以跳过中间环节；完成实测在提交实测数据时自动记录
+func AddTaskEvent(c *fiber.Ctx) error {
+	gmID := c.Locals("user_gm_id")
+	if gmID == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "未授权访问",
+		})
+	}
+
+	feedbackID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的反馈ID",
+		})
+	}
+
+	var req struct {
+		GeoPoint
+		EventType int    `json:"event_type"`
+		Remarks   string `json:"remarks"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "请求数据格式错误",
+			"details": err.Error(),
+		})
+	}
+	if req.EventType < taskEventAccepted || req.EventType > taskEventArrived {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "事件类型必须为1（已接单）、2（出发前往）或3（到达现场）",
+		})
+	}
+	if !req.GeoPoint.Valid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "定位信息无效，经纬度必须同时提供且在有效范围内",
+		})
+	}
+	req.Remarks = strings.TrimSpace(req.Remarks)
+	if len([]rune(req.Remarks)) > 200 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "备注不能超过200个字符",
+		})
+	}
+
+	var feedbackLat, feedbackLng sql.NullFloat64
+	var state int
+	var assignedAt string
+	err = database.DB.QueryRow(
+		"SELECT state, latitude, longitude, IFNULL(CONCAT(assign_date, ' ', assign_time), '') FROM aqi_feedback WHERE af_id = ? AND gm_id = ?",
+		feedbackID, gmID,
+	).Scan(&state, &feedbackLat, &feedbackLng, &assignedAt)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "任务不存在或未指派给当前网格员",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询任务信息失败",
+			"details": err.Error(),
+		})
+	}
+	if state != 1 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "只能记录处理中任务的进度",
+			"state": state,
+		})
+	}
+
+	// 只检查本次指派之后的进度，同一网格员再次被指派时重新记录
+	var lastType int
+	err = database.DB.QueryRow(
+		"SELECT IFNULL(MAX(event_type), 0) FROM task_event WHERE af_id = ? AND gm_id = ? AND CONCAT(event_date, ' ', event_time) >= ?",
+		feedbackID, gmID, assignedAt,
+	).Scan(&lastType)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询任务进度失败",
+			"details": err.Error(),
+		})
+	}
+	if req.EventType <= lastType {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":      "任务进度已记录，不能重复或倒退",
+			"last_event": getTaskEventText(lastType),
+		})
+	}
+
+	var distance interface{}
+	if feedbackLat.Valid && feedbackLng.Valid && req.GeoPoint.Present() {
+		distance = int(math.Round(haversineDistance(feedbackLat.Float64, feedbackLng.Float64, *req.Latitude, *req.Longitude)))
+	}
+
+	now := time.Now()
+	eventID, err := recordTaskEvent(database.DB, feedbackID, gmID, req.EventType, now, req.GeoPoint, distance, req.Remarks)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "记录任务进度失败",
+			"details": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "任务进度记录成功",
+		"data": fiber.Map{
+			"id":              eventID,
+			"feedback_id":     feedbackID,
+			"event_type":      req.EventType,
+			"event_type_text": getTaskEventText(req.EventType),
+			"event_date":      now.Format("2006-01-02"),
+			"event_time":      now.Format("15:04:05"),
+			"distance":        distance,
+		},
+	})
+}
+
+// GetFeedbackTimeline 获取反馈的处理时间线：提交、指派、改派和网格员的任务事件
+// 监督员查看时不返回网格员的定位
+func GetFeedbackTimeline(c *fiber.Ctx) error {
+	feedbackID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "无效的反馈ID",
+		})
+	}
+
+	allowed, err := canAccessFeedback(c, feedbackID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询反馈信息失败",
+			"details": err.Error(),
+		})
+	}
+	if !allowed {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "反馈信息不存在或无权查看",
+		})
+	}
+	showLocation := c.Locals("user_type") != "supervisor"
+
+	type timelineEntry struct {
+		at   string
+		data fiber.Map
+	}
+	var entries []timelineEntry
+
+	var afDate, afTime string
+	err = database.DB.QueryRow("SELECT af_date, af_time FROM aqi_feedback WHERE af_id = ?", feedbackID).Scan(&afDate, &afTime)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "查询反馈信息失败",
+			"details": err.Error(),
+		})
+	}
+	entries = append(entries, timelineEntry{
+		at: afDate + " " + afTime,
+		data: fiber.Map{
+			"type":      "submitted",
+			"type_text": "提交反馈",
+			"date":      afDate,
+			"time":      afTime,
+		},
+	})
+
+	rows, err := database.DB.Query(`
+		SELECT
+			l.prev_gm_id, l.gm_id, l.assign_date, l.assign_time,
+			IFNULL(gm.gm_name, '') as grid_member_name
+		FROM
+			feedback_assign_log l
+		LEFT JOIN
+			grid_member gm ON l.gm_id = gm.gm_id
+		WHERE
+			l.af_id = ?
+	`, feedbackID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取指派记录失败",
+			"details": err.Error(),
+		})
+	}
+	for rows.Next() {
+		var prevGmID, gmID int64
+		var assignDate, assignTime, gmName string
+		if err := rows.Scan(&prevGmID, &gmID, &assignDate, &assignTime, &gmName); err != nil {
+			rows.Close()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理指派记录失败",
+				"details": err.Error(),
+			})
+		}
+		entryType, typeText := "assigned", "指派网格员"
+		if prevGmID > 0 {
+			entryType, typeText = "reassigned", "改派网格员"
+		}
+		entries = append(entries, timelineEntry{
+			at: assignDate + " " + assignTime,
+			data: fiber.Map{
+				"type":             entryType,
+				"type_text":        typeText,
+				"date":             assignDate,
+				"time":             assignTime,
+				"gm_id":            gmID,
+				"grid_member_name": gmName,
+			},
+		})
+	}
+	rows.Close()
+
+	rows, err = database.DB.Query(`
+		SELECT
+			te.te_id, te.gm_id, te.event_type, te.event_date, te.event_time,
+			te.latitude, te.longitude, te.distance, te.remarks,
+			IFNULL(gm.gm_name, '') as grid_member_name
+		FROM
+			task_event te
+		LEFT JOIN
+			grid_member gm ON te.gm_id = gm.gm_id
+		WHERE
+			te.af_id = ?
+	`, feedbackID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取任务事件失败",
+			"details": err.Error(),
+		})
+	}
+	for rows.Next() {
+		var event models.TaskEvent
+		var gmName string
+		err := rows.Scan(
+			&event.TeID, &event.GmID, &event.EventType, &event.EventDate, &event.EventTime,
+			&event.Latitude, &event.Longitude, &event.Distance, &event.Remarks,
+			&gmName,
+		)
+		if err != nil {
+			rows.Close()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理任务事件失败",
+				"details": err.Error(),
+			})
+		}
+
+		data := fiber.Map{
+			"type":             "task_event",
+			"type_text":        getTaskEventText(event.EventType),
+			"event_type":       event.EventType,
+			"date":             event.EventDate,
+			"time":             event.EventTime,
+			"gm_id":            event.GmID,
+			"grid_member_name": gmName,
+			"distance":         nullIntValue(event.Distance),
+			"remarks":          event.Remarks.String,
+		}
+		if showLocation {
+			data["latitude"] = nullFloatValue(event.Latitude)
+			data["longitude"] = nullFloatValue(event.Longitude)
+		}
+		entries = append(entries, timelineEntry{at: event.EventDate + " " + event.EventTime, data: data})
+	}
+	rows.Close()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].at < entries[j].at
+	})
+	timeline := make([]fiber.Map, 0, len(entries))
+	for _, entry := range entries {
+		timeline = append(timeline, entry.data)
+	}
+
+	return c.JSON(fiber.Map{
+		"data": timeline,
+	})
+}
+
+// GetResponseTimeStats 统计任务响应时间，按网格员（group_by=member，默认）或城市（group_by=city）分组
+// 接单、到达、完成时间均从最近一次指派开始计算，现场时间为到达至完成实测，单位为分钟
+// 支持from、to参数按指派日期筛选，以及province_id参数
+func GetResponseTimeStats(c *fiber.Ctx) error {
+	groupByCity := c.Query("group_by") == "city"
+
+	query := `
+		SELECT
+			af.af_id, af.gm_id, af.city_id, af.assign_date, af.assign_time,
+			IFNULL(gm.gm_name, '') as gm_name,
+			IFNULL(ct.city_name, '') as city_name,
+			te.event_type,
+			MIN(CONCAT(te.event_date, ' ', te.event_time)) as event_at
+		FROM
+			aqi_feedback af
+		JOIN
+			task_event te ON te.af_id = af.af_id AND te.gm_id = af.gm_id
+				AND CONCAT(te.event_date, ' ', te.event_time) >= CONCAT(af.assign_date, ' ', af.assign_time)
+		LEFT JOIN
+			grid_member gm ON af.gm_id = gm.gm_id
+		LEFT JOIN
+			grid_city ct ON af.city_id = ct.city_id
+		WHERE
+			af.gm_id > 0 AND af.assign_date IS NOT NULL
+	`
+	params := []interface{}{}
+
+	if from := c.Query("from"); from != "" {
+		query += " AND af.assign_date >= ?"
+		params = append(params, from)
+	}
+	if to := c.Query("to"); to != "" {
+		query += " AND af.assign_date <= ?"
+		params = append(params, to)
+	}
+	if provinceID := c.Query("province_id"); provinceID != "" {
+		query += " AND af.province_id = ?"
+		params = append(params, provinceID)
+	}
+	query += " GROUP BY af.af_id, af.gm_id, af.city_id, af.assign_date, af.assign_time, gm.gm_name, ct.city_name, te.event_type"
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "获取任务事件失败",
+			"error":   err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	// 汇总每个任务各事件的首次发生时间
+	type taskTiming struct {
+		groupID   int64
+		groupName string
+		assigned  time.Time
+		events    map[int]time.Time
+	}
+	tasks := make(map[int64]*taskTiming)
+	for rows.Next() {
+		var afID, gmID, cityID int64
+		var assignDate, assignTime, gmName, cityName, eventAt string
+		var eventType int
+		if err := rows.Scan(&afID, &gmID, &cityID, &assignDate, &assignTime, &gmName, &cityName, &eventType, &eventAt); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "解析任务事件失败",
+				"error":   err.Error(),
+			})
+		}
+
+		task, ok := tasks[afID]
+		if !ok {
+			assigned, err := parseFeedbackTime(assignDate, assignTime)
+			if err != nil {
+				continue
+			}
+			task = &taskTiming{groupID: gmID, groupName: gmName, assigned: assigned, events: make(map[int]time.Time)}
+			if groupByCity {
+				task.groupID, task.groupName = cityID, cityName
+			}
+			tasks[afID] = task
+		}
+		if at, err := time.ParseInLocation(feedbackTimeLayout, eventAt, time.Local); err == nil {
+			task.events[eventType] = at
+		}
+	}
+	rows.Close()
+
+	type durationStat struct {
+		count int
+		total float64
+	}
+	type groupStat struct {
+		id, name                         string
+		taskCount                        int
+		accept, arrive, onSite, complete durationStat
+	}
+	add := func(stat *durationStat, from, to time.Time, ok bool) {
+		if ok && !to.Before(from) {
+			stat.count++
+			stat.total += to.Sub(from).Minutes()
+		}
+	}
+	average := func(stat durationStat) interface{} {
+		if stat.count == 0 {
+			return nil
+		}
+		return math.Round(stat.total/float64(stat.count)*10) / 10
+	}
+
+	groups := make(map[int64]*groupStat)
+	for _, task := range tasks {
+		group, ok := groups[task.groupID]
+		if !ok {
+			group = &groupStat{id: strconv.FormatInt(task.groupID, 10), name: task.groupName}
+			groups[task.groupID] = group
+		}
+		group.taskCount++
+
+		accepted, hasAccepted := task.events[taskEventAccepted]
+		arrived, hasArrived := task.events[taskEventArrived]
+		measured, hasMeasured := task.events[taskEventMeasured]
+		add(&group.accept, task.assigned, accepted, hasAccepted)
+		add(&group.arrive, task.assigned, arrived, hasArrived)
+		add(&group.onSite, arrived, measured, hasArrived && hasMeasured)
+		add(&group.complete, task.assigned, measured, hasMeasured)
+	}
+
+	groupIDs := make([]int64, 0, len(groups))
+	for id := range groups {
+		groupIDs = append(groupIDs, id)
+	}
+	sort.Slice(groupIDs, func(i, j int) bool { return groupIDs[i] < groupIDs[j] })
+
+	idKey, nameKey := "gm_id", "gm_name"
+	if groupByCity {
+		idKey, nameKey = "city_id", "city_name"
+	}
+	results := make([]fiber.Map, 0, len(groups))
+	for _, id := range groupIDs {
+		group := groups[id]
+		results = append(results, fiber.Map{
+			idKey:                  id,
+			nameKey:                group.name,
+			"task_count":           group.taskCount,
+			"arrived_count":        group.arrive.count,
+			"measured_count":       group.complete.count,
+			"avg_accept_minutes":   average(group.accept),
+			"avg_arrive_minutes":   average(group.arrive),
+			"avg_on_site_minutes":  average(group.onSite),
+			"avg_complete_minutes": average(group.complete),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    results,
+	})
+}
+
+// 获取任务事件文本描述
+func getTaskEventText(eventType int) string {
+	switch eventType {
+	case taskEventAccepted:
+		return "已接单"
+	case taskEventEnRoute:
+		return "出发前往"
+	case taskEventArrived:
+		return "到达现场"
+	case taskEventMeasured:
+		return "完成实测"
+	default:
+		return "无"
+	}
+}
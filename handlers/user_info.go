@@ -43,12 +43,21 @@ func GetCurrentSupervisor(c *fiber.Ctx) error {
 		})
 	}
 
+	// 统计未读站内通知
+	unread, err := countUnreadNotifications("supervisor", telIDStr)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("统计未读通知失败: %v", err),
+		})
+	}
+
 	return c.JSON(fiber.Map{
 		"tel_id": telID2,
 		"real_name": realName,
 		"birthday": birthday,
 		"sex": sex,
 		"remarks": remarks,
+		"unread_notifications": unread,
 	})
 }
 
@@ -75,10 +84,19 @@ func GetCurrentAdmin(c *fiber.Ctx) error {
 		})
 	}
 
+	// 统计未读站内通知
+	unread, err := countUnreadNotifications("admin", fmt.Sprint(userID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "统计未读通知失败",
+		})
+	}
+
 	return c.JSON(fiber.Map{
-		"id":         admin.AdminID,
-		"admin_code": admin.AdminCode,
-		"remarks":    admin.Remarks.String,
+		"id":                   admin.AdminID,
+		"admin_code":           admin.AdminCode,
+		"remarks":              admin.Remarks.String,
+		"unread_notifications": unread,
 	})
 }
 
@@ -184,6 +202,14 @@ func GetSupervisorList(c *fiber.Ctx) error {
 	}
 	defer rows.Close()
 
+	// 根据历史反馈的预估等级与实测等级计算可信度
+	reliability, err := loadSupervisorReliability()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "计算公众监督员可信度失败",
+		})
+	}
+
 	// 构建公众监督员列表
 	var supervisorList []fiber.Map
 	for rows.Next() {
@@ -197,11 +223,12 @@ func GetSupervisorList(c *fiber.Ctx) error {
 		}
 
 		supervisorList = append(supervisorList, fiber.Map{
-			"tel_id":     telID,
-			"real_name":  realName,
-			"birthday":   birthday,
-			"sex":        sex,
-			"remarks":    remarks,
+			"tel_id":      telID,
+			"real_name":   realName,
+			"birthday":    birthday,
+			"sex":         sex,
+			"remarks":     remarks,
+			"reliability": reliabilityOf(reliability, telID),
 		})
 	}
 
@@ -245,17 +272,26 @@ func GetCurrentGridMember(c *fiber.Ctx) error {
 		})
 	}
 
+	// 统计未读站内通知
+	unread, err := countUnreadNotifications("member", fmt.Sprint(userID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("统计未读通知失败: %v", err),
+		})
+	}
+
 	return c.JSON(fiber.Map{
-		"id":            member.GmID,
-		"member_code":   member.GmCode,
-		"real_name":     member.GmName,
-		"province_id":   member.ProvinceID,
-		"city_id":       member.CityID,
-		"province_name": provinceName,
-		"city_name":     cityName,
-		"tel":           member.Tel,
-		"state":         member.State,
-		"remarks":       member.Remarks.String,
+		"id":                   member.GmID,
+		"member_code":          member.GmCode,
+		"real_name":            member.GmName,
+		"province_id":          member.ProvinceID,
+		"city_id":              member.CityID,
+		"province_name":        provinceName,
+		"city_name":            cityName,
+		"tel":                  member.Tel,
+		"state":                member.State,
+		"remarks":              member.Remarks.String,
+		"unread_notifications": unread,
 	})
 }
 
@@ -0,0 +1,644 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"epss-backend/config"
+	"epss-backend/database"
+	"epss-backend/models"
+	"epss-backend/notify"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 外部系统可以订阅的回调事件
+const (
+	webhookEventMeasurementSubmitted = "measurement_submitted" // 网格员提交了实测数据
+	webhookEventFeedbackSubmitted    = "feedback_submitted"    // 监督员提交了反馈，AQI级别为预估等级
+	webhookEventFeedbackConfirmed    = "feedback_confirmed"    // 反馈已由网格员实测确认
+)
+
+var webhookEvents = []string{webhookEventMeasurementSubmitted, webhookEventFeedbackSubmitted, webhookEventFeedbackConfirmed}
+
+// 回调投递状态
+const (
+	webhookStatePending   = 0 // 待投递
+	webhookStateDelivered = 1 // 已投递
+	webhookStateFailed    = 2 // 超过重试次数或订阅已停用，投递失败
+)
+
+// 每轮最多投递的回调数量
+const webhookBatchSize = 50
+
+var webhookClient = notify.NewWebhookChannel()
+
+// webhookMaxAttempts 读取回调的最大投递次数，默认8次
+func webhookMaxAttempts() int {
+	attempts := 8
+	if value := config.Config("WEBHOOK_MAX_ATTEMPTS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("警告: WEBHOOK_MAX_ATTEMPTS 配置无效 (%s), 使用默认值 %d", value, attempts)
+		} else {
+			attempts = parsed
+		}
+	}
+	return attempts
+}
+
+// enqueueWebhookEvent 为匹配事件类型、省份和AQI级别的已启用订阅各写入一条待投递的回调
+func enqueueWebhookEvent(db sqlExecQueryer, event string, provinceID interface{}, aqiLevel int, data fiber.Map, now time.Time) error {
+	rows, err := db.Query(`
+		SELECT ws_id FROM webhook_subscription
+		WHERE enabled = 1 AND FIND_IN_SET(?, events) > 0 AND (province_id = 0 OR province_id = ?) AND min_aqi_level <= ?
+	`, event, provinceID, aqiLevel)
+	if err != nil {
+		return err
+	}
+	var subscriptionIDs []int64
+	for rows.Next() {
+		var wsID int64
+		if err := rows.Scan(&wsID); err != nil {
+			rows.Close()
+			return err
+		}
+		subscriptionIDs = append(subscriptionIDs, wsID)
+	}
+	rows.Close()
+	if len(subscriptionIDs) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(fiber.Map{
+		"event":       event,
+		"occurred_at": now.Format(feedbackTimeLayout),
+		"data":        data,
+	})
+	if err != nil {
+		return err
+	}
+
+	date, clock := now.Format("2006-01-02"), now.Format("15:04:05")
+	for _, wsID := range subscriptionIDs {
+		_, err := db.Exec(`
+			INSERT INTO webhook_delivery (ws_id, event, payload, state, attempts, next_date, next_time, create_date, create_time)
+			VALUES (?, ?, ?, ?, 0, ?, ?, ?, ?)
+		`, wsID, event, string(payload), webhookStatePending, date, clock, date, clock)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueueMeasurementWebhooks 在保存实测数据的事务中通知外部系统，关联反馈时同时发送反馈确认事件
+func enqueueMeasurementWebhooks(db sqlExecQueryer, measurement fiber.Map, provinceID int64, aqiID, feedbackID int, groupConfirmed int64, now time.Time) error {
+	if err := enqueueWebhookEvent(db, webhookEventMeasurementSubmitted, provinceID, aqiID, measurement, now); err != nil {
+		return err
+	}
+	if feedbackID <= 0 {
+		return nil
+	}
+
+	confirmed := fiber.Map{"group_confirmed": groupConfirmed}
+	for key, value := range measurement {
+		confirmed[key] = value
+	}
+	return enqueueWebhookEvent(db, webhookEventFeedbackConfirmed, provinceID, aqiID, confirmed, now)
+}
+
+// StartWebhookDispatcher 启动后台任务，定期投递待发送的外部回调，失败时按递增间隔重试
+// 投递间隔通过 WEBHOOK_DISPATCH_INTERVAL 配置，默认每10秒一次
+func StartWebhookDispatcher() {
+	startPeriodicTask("投递外部回调", "WEBHOOK_DISPATCH_INTERVAL", 10*time.Second, dispatchWebhooks)
+}
+
+// dispatchWebhooks 领取并投递到期的回调，订阅已停用或已删除时直接标记为投递失败
+func dispatchWebhooks(now time.Time) error {
+	ids, err := claimOutboxRows("webhook_delivery", "wd_id", webhookStatePending, webhookBatchSize, now)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	rows, err := database.DB.Query(`
+		SELECT wd.wd_id, wd.event, wd.payload, wd.attempts, ws.url, ws.secret, ws.enabled
+		FROM webhook_delivery wd
+		LEFT JOIN webhook_subscription ws ON wd.ws_id = ws.ws_id
+		WHERE wd.wd_id IN (`+placeholders+`)
+		ORDER BY wd.wd_id ASC
+	`, ids...)
+	if err != nil {
+		return err
+	}
+	type pendingDelivery struct {
+		delivery    models.WebhookDelivery
+		url, secret sql.NullString
+		enabled     sql.NullInt64
+	}
+	var pending []pendingDelivery
+	for rows.Next() {
+		var p pendingDelivery
+		if err := rows.Scan(&p.delivery.WdID, &p.delivery.Event, &p.delivery.Payload, &p.delivery.Attempts, &p.url, &p.secret, &p.enabled); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, p)
+	}
+	rows.Close()
+
+	maxAttempts := webhookMaxAttempts()
+	return forEachConcurrently(len(pending), func(i int) error {
+		p := pending[i]
+		d := p.delivery
+		if !p.enabled.Valid || p.enabled.Int64 != 1 {
+			_, err := database.DB.Exec(
+				"UPDATE webhook_delivery SET state = ?, last_error = ? WHERE wd_id = ?",
+				webhookStateFailed, "回调订阅已停用或已删除", d.WdID,
+			)
+			return err
+		}
+
+		code, err := webhookClient.PostSigned(p.url.String, p.secret.String, d.Event, d.WdID, []byte(d.Payload))
+		attempts := d.Attempts + 1
+		deliveredAt := time.Now()
+		if err == nil {
+			_, err = database.DB.Exec(
+				"UPDATE webhook_delivery SET state = ?, attempts = ?, response_code = ?, last_error = NULL, delivered_date = ?, delivered_time = ? WHERE wd_id = ?",
+				webhookStateDelivered, attempts, code, deliveredAt.Format("2006-01-02"), deliveredAt.Format("15:04:05"), d.WdID,
+			)
+			return err
+		}
+
+		// 重试间隔与通知相同：从1分钟开始每次翻倍，最长1小时
+		state := webhookStatePending
+		if attempts >= maxAttempts {
+			state = webhookStateFailed
+		}
+		next := deliveredAt.Add(notificationRetryDelay(attempts))
+		message := err.Error()
+		if len([]rune(message)) > 500 {
+			message = string([]rune(message)[:500])
+		}
+		_, err = database.DB.Exec(
+			"UPDATE webhook_delivery SET state = ?, attempts = ?, response_code = ?, last_error = ?, next_date = ?, next_time = ? WHERE wd_id = ?",
+			state, attempts, code, message, next.Format("2006-01-02"), next.Format("15:04:05"), d.WdID,
+		)
+		return err
+	})
+}
+
+// newWebhookSecret 生成随机的签名密钥
+func newWebhookSecret() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(random), nil
+}
+
+// webhookRequest 添加和修改回调订阅的请求，修改时只需提供要修改的字段
+type webhookRequest struct {
+	Name         *string  `json:"name"`
+	URL          *string  `json:"url"`
+	Events       []string `json:"events"`
+	ProvinceID   *int64   `json:"province_id"`
+	MinAqiLevel  *int     `json:"min_aqi_level"`
+	Enabled      *bool    `json:"enabled"`
+	Remarks      *string  `json:"remarks"`
+	RotateSecret bool     `json:"rotate_secret"` // 仅修改时有效，重新生成签名密钥
+}
+
+// validateWebhookRequest 校验回调订阅的字段，数据有效时返回空字符串
+func validateWebhookRequest(req *webhookRequest) string {
+	if req.Name != nil {
+		*req.Name = strings.TrimSpace(*req.Name)
+		if *req.Name == "" || len([]rune(*req.Name)) > 50 {
+			return "名称不能为空且不能超过50个字符"
+		}
+	}
+	if req.URL != nil {
+		parsed, err := url.ParseRequestURI(*req.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return "回调地址必须为http或https地址"
+		}
+		if len(*req.URL) > 500 {
+			return "回调地址不能超过500个字符"
+		}
+	}
+	if req.Events != nil {
+		if len(req.Events) == 0 {
+			return "至少需要订阅一种事件"
+		}
+		for _, event := range req.Events {
+			if !isWebhookEvent(event) {
+				return "事件类型必须为" + strings.Join(webhookEvents, "、")
+			}
+		}
+	}
+	if req.ProvinceID != nil && *req.ProvinceID < 0 {
+		return "省份ID无效"
+	}
+	if req.MinAqiLevel != nil && (*req.MinAqiLevel < 0 || *req.MinAqiLevel > 6) {
+		return "最低AQI级别必须为0-6"
+	}
+	if req.Remarks != nil && len([]rune(*req.Remarks)) > 200 {
+		return "备注不能超过200个字符"
+	}
+	return ""
+}
+
+func isWebhookEvent(event string) bool {
+	for _, e := range webhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookEventsValue 将事件类型去重后保存为逗号分隔的字符串
+func webhookEventsValue(events []string) string {
+	var unique []string
+	for _, event := range webhookEvents {
+		for _, e := range events {
+			if e == event {
+				unique = append(unique, event)
+				break
+			}
+		}
+	}
+	return strings.Join(unique, ",")
+}
+
+// GetWebhookList 获取外部回调订阅列表，签名密钥不返回
+func GetWebhookList(c *fiber.Ctx) error {
+	rows, err := database.DB.Query(`
+		SELECT
+			ws.ws_id, ws.ws_name, ws.url, ws.events, ws.province_id, ws.min_aqi_level, ws.enabled,
+			ws.admin_id, ws.create_date, ws.create_time, ws.remarks,
+			IFNULL(p.province_name, '') as province_name,
+			(SELECT COUNT(*) FROM webhook_delivery wd WHERE wd.ws_id = ws.ws_id AND wd.state = ?) as pending_count,
+			(SELECT COUNT(*) FROM webhook_delivery wd WHERE wd.ws_id = ws.ws_id AND wd.state = ?) as failed_count
+		FROM
+			webhook_subscription ws
+		LEFT JOIN
+			grid_province p ON ws.province_id = p.province_id
+		ORDER BY ws.ws_id
+	`, webhookStatePending, webhookStateFailed)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取回调订阅列表失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	webhookList := []fiber.Map{}
+	for rows.Next() {
+		var ws models.WebhookSubscription
+		var provinceName string
+		var pendingCount, failedCount int
+		err := rows.Scan(
+			&ws.WsID, &ws.WsName, &ws.URL, &ws.Events, &ws.ProvinceID, &ws.MinAqiLevel, &ws.Enabled,
+			&ws.AdminID, &ws.CreateDate, &ws.CreateTime, &ws.Remarks,
+			&provinceName, &pendingCount, &failedCount,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理回调订阅数据失败",
+				"details": err.Error(),
+			})
+		}
+
+		webhookList = append(webhookList, fiber.Map{
+			"id":            ws.WsID,
+			"name":          ws.WsName,
+			"url":           ws.URL,
+			"events":        strings.Split(ws.Events, ","),
+			"province_id":   ws.ProvinceID,
+			"province_name": provinceName,
+			"min_aqi_level": ws.MinAqiLevel,
+			"enabled":       ws.Enabled == 1,
+			"admin_id":      ws.AdminID,
+			"create_date":   ws.CreateDate,
+			"create_time":   ws.CreateTime,
+			"remarks":       ws.Remarks.String,
+			"pending_count": pendingCount,
+			"failed_count":  failedCount,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": webhookList,
+	})
+}
+
+// AddWebhook 添加外部回调订阅，签名密钥只在创建时返回一次
+func AddWebhook(c *fiber.Ctx) error {
+	var req webhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的请求格式"})
+	}
+	if req.Name == nil || req.URL == nil || req.Events == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "名称、回调地址和事件类型为必填项"})
+	}
+	if message := validateWebhookRequest(&req); message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": message})
+	}
+
+	var provinceID int64
+	if req.ProvinceID != nil {
+		provinceID = *req.ProvinceID
+	}
+	minAqiLevel := 0
+	if req.MinAqiLevel != nil {
+		minAqiLevel = *req.MinAqiLevel
+	}
+	enabled := 1
+	if req.Enabled != nil && !*req.Enabled {
+		enabled = 0
+	}
+	var remarks interface{}
+	if req.Remarks != nil && *req.Remarks != "" {
+		remarks = *req.Remarks
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "生成签名密钥失败"})
+	}
+
+	now := time.Now()
+	result, err := database.DB.Exec(`
+		INSERT INTO webhook_subscription
+		(ws_name, url, secret, events, province_id, min_aqi_level, enabled, admin_id, create_date, create_time, remarks)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, *req.Name, *req.URL, secret, webhookEventsValue(req.Events), provinceID, minAqiLevel, enabled,
+		c.Locals("user_id"), now.Format("2006-01-02"), now.Format("15:04:05"), remarks)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "添加回调订阅失败",
+			"details": err.Error(),
+		})
+	}
+
+	wsID, _ := result.LastInsertId()
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "回调订阅添加成功，请妥善保存签名密钥",
+		"id":      wsID,
+		"secret":  secret,
+	})
+}
+
+// UpdateWebhook 修改外部回调订阅，rotate_secret为true时重新生成签名密钥并返回
+func UpdateWebhook(c *fiber.Ctx) error {
+	webhookID := c.Params("id")
+	if webhookID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "缺少回调订阅ID"})
+	}
+
+	var req webhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "无效的请求格式"})
+	}
+	if message := validateWebhookRequest(&req); message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": message})
+	}
+
+	var sets []string
+	var params []interface{}
+	if req.Name != nil {
+		sets = append(sets, "ws_name = ?")
+		params = append(params, *req.Name)
+	}
+	if req.URL != nil {
+		sets = append(sets, "url = ?")
+		params = append(params, *req.URL)
+	}
+	if req.Events != nil {
+		sets = append(sets, "events = ?")
+		params = append(params, webhookEventsValue(req.Events))
+	}
+	if req.ProvinceID != nil {
+		sets = append(sets, "province_id = ?")
+		params = append(params, *req.ProvinceID)
+	}
+	if req.MinAqiLevel != nil {
+		sets = append(sets, "min_aqi_level = ?")
+		params = append(params, *req.MinAqiLevel)
+	}
+	if req.Enabled != nil {
+		enabled := 0
+		if *req.Enabled {
+			enabled = 1
+		}
+		sets = append(sets, "enabled = ?")
+		params = append(params, enabled)
+	}
+	if req.Remarks != nil {
+		sets = append(sets, "remarks = ?")
+		params = append(params, *req.Remarks)
+	}
+	var secret string
+	if req.RotateSecret {
+		var err error
+		if secret, err = newWebhookSecret(); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "生成签名密钥失败"})
+		}
+		sets = append(sets, "secret = ?")
+		params = append(params, secret)
+	}
+	if len(sets) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "没有需要修改的字段"})
+	}
+
+	var count int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM webhook_subscription WHERE ws_id = ?", webhookID).Scan(&count); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "数据库查询失败"})
+	}
+	if count == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "回调订阅不存在"})
+	}
+
+	params = append(params, webhookID)
+	_, err := database.DB.Exec("UPDATE webhook_subscription SET "+strings.Join(sets, ", ")+" WHERE ws_id = ?", params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "修改回调订阅失败",
+			"details": err.Error(),
+		})
+	}
+
+	response := fiber.Map{"message": "回调订阅修改成功"}
+	if secret != "" {
+		response["secret"] = secret
+	}
+	return c.JSON(response)
+}
+
+// DeleteWebhook 删除外部回调订阅，投递记录保留，未投递的回调不再发送
+func DeleteWebhook(c *fiber.Ctx) error {
+	webhookID := c.Params("id")
+	if webhookID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "缺少回调订阅ID"})
+	}
+
+	result, err := database.DB.Exec("DELETE FROM webhook_subscription WHERE ws_id = ?", webhookID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "删除回调订阅失败"})
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "回调订阅不存在"})
+	}
+
+	return c.JSON(fiber.Map{"message": "回调订阅删除成功"})
+}
+
+// GetWebhookDeliveryList 获取回调投递记录，支持ws_id、state、event参数筛选，limit默认100
+func GetWebhookDeliveryList(c *fiber.Ctx) error {
+	query := `
+		SELECT
+			wd_id, ws_id, event, payload, state, attempts, next_date, next_time, response_code, last_error,
+			replay_of, create_date, create_time, delivered_date, delivered_time
+		FROM
+			webhook_delivery
+		WHERE 1 = 1
+	`
+	params := []interface{}{}
+
+	if wsID := c.Query("ws_id"); wsID != "" {
+		query += " AND ws_id = ?"
+		params = append(params, wsID)
+	}
+	if state := c.Query("state"); state != "" {
+		query += " AND state = ?"
+		params = append(params, state)
+	}
+	if event := c.Query("event"); event != "" {
+		query += " AND event = ?"
+		params = append(params, event)
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	query += " ORDER BY wd_id DESC LIMIT ?"
+	params = append(params, limit)
+
+	rows, err := database.DB.Query(query, params...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "获取投递记录失败",
+			"details": err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	deliveryList := []fiber.Map{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		err := rows.Scan(
+			&d.WdID, &d.WsID, &d.Event, &d.Payload, &d.State, &d.Attempts, &d.NextDate, &d.NextTime, &d.ResponseCode, &d.LastError,
+			&d.ReplayOf, &d.CreateDate, &d.CreateTime, &d.DeliveredDate, &d.DeliveredTime,
+		)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "处理投递记录失败",
+				"details": err.Error(),
+			})
+		}
+
+		deliveryList = append(deliveryList, fiber.Map{
+			"id":             d.WdID,
+			"webhook_id":     d.WsID,
+			"event":          d.Event,
+			"payload":        json.RawMessage(d.Payload),
+			"state":          d.State,
+			"state_text":     getWebhookStateText(d.State),
+			"attempts":       d.Attempts,
+			"next_date":      d.NextDate,
+			"next_time":      d.NextTime,
+			"response_code":  d.ResponseCode,
+			"last_error":     d.LastError.String,
+			"replay_of":      d.ReplayOf,
+			"create_date":    d.CreateDate,
+			"create_time":    d.CreateTime,
+			"delivered_date": d.DeliveredDate.String,
+			"delivered_time": d.DeliveredTime.String,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": deliveryList,
+	})
+}
+
+// ReplayWebhookDelivery 重新投递一条回调，以原内容新建一条投递记录，原记录不变
+func ReplayWebhookDelivery(c *fiber.Ctx) error {
+	deliveryID := c.Params("id")
+	if deliveryID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "缺少投递记录ID"})
+	}
+
+	var d models.WebhookDelivery
+	var enabled sql.NullInt64
+	err := database.DB.QueryRow(`
+		SELECT wd.wd_id, wd.ws_id, wd.event, wd.payload, ws.enabled
+		FROM webhook_delivery wd
+		LEFT JOIN webhook_subscription ws ON wd.ws_id = ws.ws_id
+		WHERE wd.wd_id = ?
+	`, deliveryID).Scan(&d.WdID, &d.WsID, &d.Event, &d.Payload, &enabled)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "投递记录不存在"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "数据库查询失败"})
+	}
+	if !enabled.Valid || enabled.Int64 != 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "回调订阅已停用或已删除，不能重新投递"})
+	}
+
+	now := time.Now()
+	date, clock := now.Format("2006-01-02"), now.Format("15:04:05")
+	result, err := database.DB.Exec(`
+		INSERT INTO webhook_delivery (ws_id, event, payload, state, attempts, next_date, next_time, replay_of, create_date, create_time)
+		VALUES (?, ?, ?, ?, 0, ?, ?, ?, ?, ?)
+	`, d.WsID, d.Event, d.Payload, webhookStatePending, date, clock, d.WdID, date, clock)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "重新投递失败",
+			"details": err.Error(),
+		})
+	}
+
+	replayID, _ := result.LastInsertId()
+
+	return c.JSON(fiber.Map{
+		"message": "已加入投递队列",
+		"id":      replayID,
+	})
+}
+
+// 获取回调投递状态文本描述
+func getWebhookStateText(state int) string {
+	switch state {
+	case webhookStatePending:
+		return "待投递"
+	case webhookStateDelivered:
+		return "已投递"
+	case webhookStateFailed:
+		return "投递失败"
+	default:
+		return "未知状态"
+	}
+}
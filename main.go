@@ -3,7 +3,10 @@ package main
 import (
 	"epss-backend/config"
 	"epss-backend/database"
+	"epss-backend/handlers"
+	"epss-backend/notify"
 	"epss-backend/routes"
+	"epss-backend/storage"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"log"
@@ -13,7 +16,34 @@ func main() {
 	// 连接数据库
 	database.Connect()
 
-	app := fiber.New()
+	// 初始化附件存储
+	storage.Setup()
+
+	// 初始化通知渠道
+	notify.Setup()
+
+	// 启动反馈处理时限检查任务
+	handlers.StartSLAMonitor()
+
+	// 启动网格员考勤状态维护任务
+	handlers.StartAttendanceMonitor()
+
+	// 启动过期幂等记录清理任务
+	handlers.StartIdempotencyCleanup()
+
+	// 启动通知发送任务
+	handlers.StartNotificationDispatcher()
+
+	// 启动外部回调投递任务
+	handlers.StartWebhookDispatcher()
+
+	// 启动告警规则检查任务
+	handlers.StartAlertMonitor()
+
+	app := fiber.New(fiber.Config{
+		// 附件上传单个文件最大10MB，预留表单字段的空间
+		BodyLimit: 12 * 1024 * 1024,
+	})
 
 	// 启用CORS
 	app.Use(cors.New())
@@ -10,6 +10,56 @@ type Admin struct {
 	Remarks   sql.NullString `json:"remarks"`
 }
 
+// AdminRegion 对应 'admin_region' 表，记录管理员负责的区域
+type AdminRegion struct {
+	ArID       int64          `json:"ar_id"`
+	AdminID    int64          `json:"admin_id"`
+	ProvinceID int64          `json:"province_id"`
+	CityID     int64          `json:"city_id"` // 0 表示整个省
+	Remarks    sql.NullString `json:"remarks"`
+}
+
+// AlertRecord 对应 'alert_record' 表，告警规则触发后产生的告警
+type AlertRecord struct {
+	AlID           int64          `json:"al_id"`
+	ArID           int64          `json:"ar_id"`
+	RuleType       int            `json:"rule_type"`
+	Metric         string         `json:"metric"`
+	ProvinceID     int64          `json:"province_id"`
+	CityID         int64          `json:"city_id"`
+	Value          int            `json:"value"`
+	StatisticsID   int64          `json:"statistics_id"`
+	Message        string         `json:"message"`
+	State          int            `json:"state"` // 0 未处理，1 已确认，2 已解决
+	AlertDate      string         `json:"alert_date"`
+	AlertTime      string         `json:"alert_time"`
+	AckAdminID     sql.NullInt64  `json:"ack_admin_id"`
+	AckDate        sql.NullString `json:"ack_date"`
+	AckTime        sql.NullString `json:"ack_time"`
+	ResolveAdminID sql.NullInt64  `json:"resolve_admin_id"`
+	ResolveDate    sql.NullString `json:"resolve_date"`
+	ResolveTime    sql.NullString `json:"resolve_time"`
+	ResolveRemarks sql.NullString `json:"resolve_remarks"`
+}
+
+// AlertRule 对应 'alert_rule' 表，管理员定义的实测数据告警规则
+type AlertRule struct {
+	ArID          int64          `json:"ar_id"`
+	ArName        string         `json:"ar_name"`
+	RuleType      int            `json:"rule_type"` // 1 单次超标，2 频次超标，3 上升趋势
+	Metric        string         `json:"metric"`    // so2、co、spm 或 aqi
+	Threshold     int            `json:"threshold"`
+	Occurrences   int            `json:"occurrences"`
+	WindowMinutes int            `json:"window_minutes"`
+	ProvinceID    int64          `json:"province_id"` // 0 表示全部省份
+	CityID        int64          `json:"city_id"`     // 0 表示省内全部城市
+	Enabled       int            `json:"enabled"`
+	AdminID       int64          `json:"admin_id"`
+	CreateDate    string         `json:"create_date"`
+	CreateTime    string         `json:"create_time"`
+	Remarks       sql.NullString `json:"remarks"`
+}
+
 // Aqi 对应 'aqi' 表
 type Aqi struct {
 	AqiID          int64          `json:"aqi_id"`
@@ -29,19 +79,161 @@ type Aqi struct {
 
 // AqiFeedback 对应 'aqi_feedback' 表
 type AqiFeedback struct {
-	AfID           int64          `json:"af_id"`
-	TelID          string         `json:"tel_id"`
-	ProvinceID     int64          `json:"province_id"`
-	CityID         int64          `json:"city_id"`
-	Address        string         `json:"address"`
-	Information    string         `json:"information"`
-	EstimatedGrade int            `json:"estimated_grade"`
-	AfDate         string         `json:"af_date"`
-	AfTime         string         `json:"af_time"`
-	GmID           int64          `json:"gm_id"`
-	AssignDate     sql.NullString `json:"assign_date"`
-	AssignTime     sql.NullString `json:"assign_time"`
-	State          int            `json:"state"`
+	AfID             int64           `json:"af_id"`
+	TelID            string          `json:"tel_id"`
+	ProvinceID       int64           `json:"province_id"`
+	CityID           int64           `json:"city_id"`
+	Address          string          `json:"address"`
+	Information      string          `json:"information"`
+	EstimatedGrade   int             `json:"estimated_grade"`
+	AfDate           string          `json:"af_date"`
+	AfTime           string          `json:"af_time"`
+	GmID             int64           `json:"gm_id"`
+	AssignDate       sql.NullString  `json:"assign_date"`
+	AssignTime       sql.NullString  `json:"assign_time"`
+	State            int             `json:"state"`
+	Remarks          sql.NullString  `json:"remarks"`
+	Latitude         sql.NullFloat64 `json:"latitude"`
+	Longitude        sql.NullFloat64 `json:"longitude"`
+	LocationAccuracy sql.NullFloat64 `json:"location_accuracy"` // 定位精度（米）
+	IgID             int64           `json:"ig_id"`             // 所属事件组，0 表示未归入事件组
+	UpdateTime       string          `json:"update_time"`       // 最后修改时间，由数据库自动维护
+	SlaRound         int             `json:"sla_round"`         // 处理轮次，申诉重新处理后加 1
+	SlaStart         sql.NullString  `json:"sla_start"`         // 本轮时限起算时间，为空时从反馈时间起算
+}
+
+// Attachment 对应 'attachment' 表，保存反馈和实测数据的附件
+type Attachment struct {
+	AttID        int64          `json:"att_id"`
+	AfID         int64          `json:"af_id"`
+	StatisticsID int64          `json:"statistics_id"`
+	FileName     string         `json:"file_name"`
+	ContentType  string         `json:"content_type"`
+	FileSize     int64          `json:"file_size"`
+	StorageKey   string         `json:"storage_key"`
+	ThumbKey     sql.NullString `json:"thumb_key"`
+	UploaderType string         `json:"uploader_type"`
+	UploaderID   string         `json:"uploader_id"`
+	UploadDate   string         `json:"upload_date"`
+	UploadTime   string         `json:"upload_time"`
+	Remarks      sql.NullString `json:"remarks"`
+}
+
+// ComplianceStandard 对应 'compliance_standard' 表，保存各指标视为超标的AQI级别
+type ComplianceStandard struct {
+	Metric      string         `json:"metric"`
+	ExceedLevel int            `json:"exceed_level"`
+	Remarks     sql.NullString `json:"remarks"`
+}
+
+// DispatchWeight 对应 'dispatch_weight' 表，保存指派优先级各因素的权重
+type DispatchWeight struct {
+	Factor  string         `json:"factor"`
+	Weight  float64        `json:"weight"`
+	Remarks sql.NullString `json:"remarks"`
+}
+
+// FeedbackAssignLog 对应 'feedback_assign_log' 表
+type FeedbackAssignLog struct {
+	LogID        int64          `json:"log_id"`
+	AfID         int64          `json:"af_id"`
+	PrevGmID     int64          `json:"prev_gm_id"`
+	GmID         int64          `json:"gm_id"`
+	AdminID      int64          `json:"admin_id"`
+	AssignDate   string         `json:"assign_date"`
+	AssignTime   string         `json:"assign_time"`
+	Remarks      sql.NullString `json:"remarks"`
+	RemoteAssign int            `json:"remote_assign"` // 指派时网格员负责区域与反馈区域是否不同
+}
+
+// FeedbackComment 对应 'feedback_comment' 表
+type FeedbackComment struct {
+	FcID        int64          `json:"fc_id"`
+	AfID        int64          `json:"af_id"`
+	AuthorType  string         `json:"author_type"` // admin、member 或 supervisor
+	AuthorID    string         `json:"author_id"`
+	Content     string         `json:"content"`
+	Internal    int            `json:"internal"` // 1 表示仅管理员和网格员可见
+	CommentDate string         `json:"comment_date"`
+	CommentTime string         `json:"comment_time"`
+	Remarks     sql.NullString `json:"remarks"`
+}
+
+// FeedbackDispute 对应 'feedback_dispute' 表，记录监督员对确认结果的申诉
+type FeedbackDispute struct {
+	DisputeID     int64          `json:"dispute_id"`
+	AfID          int64          `json:"af_id"`
+	TelID         string         `json:"tel_id"`
+	GmID          int64          `json:"gm_id"`
+	Reason        string         `json:"reason"`
+	DisputeDate   string         `json:"dispute_date"`
+	DisputeTime   string         `json:"dispute_time"`
+	State         int            `json:"state"` // 0 待复核，1 维持原结果，2 重新处理
+	AdminID       sql.NullInt64  `json:"admin_id"`
+	ReviewDate    sql.NullString `json:"review_date"`
+	ReviewTime    sql.NullString `json:"review_time"`
+	ReviewRemarks sql.NullString `json:"review_remarks"`
+}
+
+// FeedbackEditLog 对应 'feedback_edit_log' 表，记录监督员修改和撤回反馈的历史
+type FeedbackEditLog struct {
+	LogID             int64          `json:"log_id"`
+	AfID              int64          `json:"af_id"`
+	TelID             string         `json:"tel_id"`
+	Action            int            `json:"action"` // 1 修改，2 撤回
+	OldAddress        string         `json:"old_address"`
+	OldInformation    string         `json:"old_information"`
+	OldEstimatedGrade int            `json:"old_estimated_grade"`
+	NewAddress        string         `json:"new_address"`
+	NewInformation    string         `json:"new_information"`
+	NewEstimatedGrade int            `json:"new_estimated_grade"`
+	EditDate          string         `json:"edit_date"`
+	EditTime          string         `json:"edit_time"`
+	Remarks           sql.NullString `json:"remarks"`
+}
+
+// FeedbackEscalation 对应 'feedback_escalation' 表
+type FeedbackEscalation struct {
+	FeID         int64          `json:"fe_id"`
+	FoID         int64          `json:"fo_id"`
+	AfID         int64          `json:"af_id"`
+	AdminID      int64          `json:"admin_id"`
+	EscalateDate string         `json:"escalate_date"`
+	EscalateTime string         `json:"escalate_time"`
+	State        int            `json:"state"` // 0 未处理，1 已处理
+	Remarks      sql.NullString `json:"remarks"`
+}
+
+// FeedbackOverdue 对应 'feedback_overdue' 表
+type FeedbackOverdue struct {
+	FoID      int64  `json:"fo_id"`
+	AfID      int64  `json:"af_id"`
+	SlaRound  int    `json:"sla_round"` // 反馈的处理轮次
+	Stage     int    `json:"stage"`     // 1 指派超时，2 确认超时
+	Deadline  string `json:"deadline"`
+	FlagDate  string `json:"flag_date"`
+	FlagTime  string `json:"flag_time"`
+	Escalated int    `json:"escalated"`
+	Resolved  int    `json:"resolved"`
+}
+
+// FeedbackRating 对应 'feedback_rating' 表，记录监督员对处理结果的满意度评价
+type FeedbackRating struct {
+	RatingID int64          `json:"rating_id"`
+	AfID     int64          `json:"af_id"`
+	TelID    string         `json:"tel_id"`
+	GmID     int64          `json:"gm_id"`
+	Rating   int            `json:"rating"`
+	Comment  sql.NullString `json:"comment"`
+	RateDate string         `json:"rate_date"`
+	RateTime string         `json:"rate_time"`
+}
+
+// FeedbackSLA 对应 'feedback_sla' 表
+type FeedbackSLA struct {
+	Grade          int            `json:"grade"`
+	AssignMinutes  int            `json:"assign_minutes"`
+	ConfirmMinutes int            `json:"confirm_minutes"`
 	Remarks        sql.NullString `json:"remarks"`
 }
 
@@ -74,25 +266,171 @@ type GridProvince struct {
 	Remarks      sql.NullString `json:"remarks"`
 }
 
+// IdempotencyKey 对应 'idempotency_key' 表，保存带幂等键的请求及其原始响应
+type IdempotencyKey struct {
+	IkID         int64          `json:"ik_id"`
+	Scope        string         `json:"scope"`       // 请求者标识，如 member:3
+	IdemKey      string         `json:"idem_key"`
+	Fingerprint  string         `json:"fingerprint"` // 方法、地址和请求体的SHA-256
+	State        int            `json:"state"`       // 0 处理中，1 已完成
+	StatusCode   int            `json:"status_code"`
+	ContentType  sql.NullString `json:"content_type"`
+	ResponseBody sql.NullString `json:"response_body"`
+	CreateDate   string         `json:"create_date"`
+	CreateTime   string         `json:"create_time"`
+	LockExpire   string         `json:"lock_expire"` // 处理中的锁定截止时间，超过后相同请求可以接管
+}
+
+// IncidentGroup 对应 'incident_group' 表，将重复的反馈归为同一事件
+type IncidentGroup struct {
+	IgID         int64          `json:"ig_id"`
+	LeadAfID     int64          `json:"lead_af_id"`
+	ProvinceID   int64          `json:"province_id"`
+	CityID       int64          `json:"city_id"`
+	CreateDate   string         `json:"create_date"`
+	CreateTime   string         `json:"create_time"`
+	State        int            `json:"state"` // 0 处理中，1 已确认
+	StatisticsID int64          `json:"statistics_id"`
+	Remarks      sql.NullString `json:"remarks"`
+}
+
+// MemberAttendance 对应 'member_attendance' 表，记录网格员签到和签退
+type MemberAttendance struct {
+	AttID        int64           `json:"att_id"`
+	GmID         int64           `json:"gm_id"`
+	ShiftID      int64           `json:"shift_id"` // 0 表示班次外签到
+	CheckInDate  string          `json:"check_in_date"`
+	CheckInTime  string          `json:"check_in_time"`
+	CheckOutDate sql.NullString  `json:"check_out_date"`
+	CheckOutTime sql.NullString  `json:"check_out_time"`
+	Latitude     sql.NullFloat64 `json:"latitude"`
+	Longitude    sql.NullFloat64 `json:"longitude"`
+	Remarks      sql.NullString  `json:"remarks"`
+}
+
+// MemberLeave 对应 'member_leave' 表，记录网格员的请假申请
+type MemberLeave struct {
+	LeaveID       int64          `json:"leave_id"`
+	GmID          int64          `json:"gm_id"`
+	LeaveType     int            `json:"leave_type"`
+	StartDate     string         `json:"start_date"`
+	StartTime     string         `json:"start_time"`
+	EndDate       string         `json:"end_date"`
+	EndTime       string         `json:"end_time"`
+	Reason        string         `json:"reason"`
+	State         int            `json:"state"`
+	ApplyDate     string         `json:"apply_date"`
+	ApplyTime     string         `json:"apply_time"`
+	AdminID       sql.NullInt64  `json:"admin_id"`
+	ReviewDate    sql.NullString `json:"review_date"`
+	ReviewTime    sql.NullString `json:"review_time"`
+	ReviewRemarks sql.NullString `json:"review_remarks"`
+}
+
+// MemberShift 对应 'member_shift' 表，保存网格员的排班
+type MemberShift struct {
+	ShiftID   int64          `json:"shift_id"`
+	GmID      int64          `json:"gm_id"`
+	ShiftDate string         `json:"shift_date"`
+	StartTime string         `json:"start_time"`
+	EndTime   string         `json:"end_time"` // 早于上班时间表示次日下班
+	AdminID   int64          `json:"admin_id"`
+	Remarks   sql.NullString `json:"remarks"`
+}
+
+// Notification 对应 'notification' 表，用户的站内通知
+type Notification struct {
+	NtID       int64          `json:"nt_id"`
+	UserType   string         `json:"user_type"` // admin、member 或 supervisor
+	UserID     string         `json:"user_id"`   // admin_id、gm_id 或监督员 tel_id
+	Event      string         `json:"event"`
+	Title      string         `json:"title"`
+	Content    string         `json:"content"`
+	AfID       int64          `json:"af_id"`
+	IsRead     int            `json:"is_read"` // 0 未读，1 已读
+	CreateDate string         `json:"create_date"`
+	CreateTime string         `json:"create_time"`
+	ReadDate   sql.NullString `json:"read_date"`
+	ReadTime   sql.NullString `json:"read_time"`
+}
+
+// NotificationOutbox 对应 'notification_outbox' 表，与业务数据在同一事务中写入，由后台任务发送
+type NotificationOutbox struct {
+	NoID       int64          `json:"no_id"`
+	Event      string         `json:"event"`
+	UserType   string         `json:"user_type"` // admin、member 或 supervisor
+	UserID     string         `json:"user_id"`   // 监督员为手机号码
+	Channel    string         `json:"channel"`   // sms、email 或 webhook
+	Address    string         `json:"address"`
+	Locale     string         `json:"locale"`
+	Subject    string         `json:"subject"`
+	Content    string         `json:"content"`
+	AfID       int64          `json:"af_id"`
+	State      int            `json:"state"` // 0 待发送，1 已发送，2 发送失败
+	Attempts   int            `json:"attempts"`
+	NextDate   string         `json:"next_date"`
+	NextTime   string         `json:"next_time"`
+	LastError  sql.NullString `json:"last_error"`
+	CreateDate string         `json:"create_date"`
+	CreateTime string         `json:"create_time"`
+	SentDate   sql.NullString `json:"sent_date"`
+	SentTime   sql.NullString `json:"sent_time"`
+}
+
+// NotificationPreference 对应 'notification_preference' 表，保存用户在各渠道的接收设置
+type NotificationPreference struct {
+	NpID     int64  `json:"np_id"`
+	UserType string `json:"user_type"`
+	UserID   string `json:"user_id"`
+	Channel  string `json:"channel"`
+	Address  string `json:"address"`
+	Locale   string `json:"locale"`
+	Enabled  int    `json:"enabled"` // 1 启用，0 停用
+}
+
+// SensitiveArea 对应 'sensitive_area' 表，学校、医院等需要优先处理的区域
+type SensitiveArea struct {
+	SaID       int64          `json:"sa_id"`
+	SaName     string         `json:"sa_name"`
+	SaType     int            `json:"sa_type"`
+	ProvinceID int64          `json:"province_id"`
+	CityID     int64          `json:"city_id"`
+	Latitude   float64        `json:"latitude"`
+	Longitude  float64        `json:"longitude"`
+	Radius     int            `json:"radius"` // 影响半径（米）
+	Remarks    sql.NullString `json:"remarks"`
+}
+
 // Statistics 对应 'statistics' 表
 type Statistics struct {
-	ID          int64          `json:"id"`
-	ProvinceID  int64          `json:"province_id"`
-	CityID      int64          `json:"city_id"`
-	Address     string         `json:"address"`
-	SO2Value    int            `json:"so2_value"`
-	SO2Level    int            `json:"so2_level"`
-	COValue     int            `json:"co_value"`
-	COLevel     int            `json:"co_level"`
-	SPMValue    int            `json:"spm_value"`
-	SPMLevel    int            `json:"spm_level"`
-	AqiID       int64          `json:"aqi_id"`
-	ConfirmDate string         `json:"confirm_date"`
-	ConfirmTime string         `json:"confirm_time"`
-	GmID        int64          `json:"gm_id"`
-	FdID        string         `json:"fd_id"`
-	Information string         `json:"information"`
-	Remarks     sql.NullString `json:"remarks"`
+	ID                 int64           `json:"id"`
+	ProvinceID         int64           `json:"province_id"`
+	CityID             int64           `json:"city_id"`
+	Address            string          `json:"address"`
+	SO2Value           int             `json:"so2_value"`
+	SO2Level           int             `json:"so2_level"`
+	COValue            int             `json:"co_value"`
+	COLevel            int             `json:"co_level"`
+	SPMValue           int             `json:"spm_value"`
+	SPMLevel           int             `json:"spm_level"`
+	AqiID              int64           `json:"aqi_id"`
+	ConfirmDate        string          `json:"confirm_date"`
+	ConfirmTime        string          `json:"confirm_time"`
+	GmID               int64           `json:"gm_id"`
+	FdID               string          `json:"fd_id"`
+	Information        string          `json:"information"`
+	Remarks            sql.NullString  `json:"remarks"`
+	AfID               int64           `json:"af_id"`
+	Latitude           sql.NullFloat64 `json:"latitude"`
+	Longitude          sql.NullFloat64 `json:"longitude"`
+	LocationAccuracy   sql.NullFloat64 `json:"location_accuracy"` // 定位精度（米）
+	FenceDistance      sql.NullInt64   `json:"fence_distance"`    // 与反馈位置的距离（米）
+	FenceState         int             `json:"fence_state"`       // 0 无需校验，1 范围内，2 待审核，3 审核通过，4 审核驳回
+	FenceReviewerID    sql.NullInt64   `json:"fence_reviewer_id"`
+	FenceReviewDate    sql.NullString  `json:"fence_review_date"`
+	FenceReviewTime    sql.NullString  `json:"fence_review_time"`
+	FenceReviewRemarks sql.NullString  `json:"fence_review_remarks"`
+	SupersededAfID     int64           `json:"superseded_af_id"` // 申诉重新处理后失效的原关联反馈，0 表示未失效
 }
 
 // Supervisor 对应 'supervisor' 表
@@ -103,4 +441,68 @@ type Supervisor struct {
 	Birthday string         `json:"birthday"`
 	Sex      int            `json:"sex"` // 性别，约定 0 为女性，1 为男性
 	Remarks  string         `json:"remarks"`
-}
\ No newline at end of file
+}
+
+// SyncRecord 对应 'sync_record' 表，记录网格员离线同步的每条实测数据，用于幂等处理
+type SyncRecord struct {
+	SrID         int64          `json:"sr_id"`
+	GmID         int64          `json:"gm_id"`
+	ClientID     string         `json:"client_id"` // 客户端生成的UUID
+	AfID         int64          `json:"af_id"`
+	StatisticsID int64          `json:"statistics_id"`
+	DeviceDate   string         `json:"device_date"`
+	DeviceTime   string         `json:"device_time"`
+	SyncDate     string         `json:"sync_date"`
+	SyncTime     string         `json:"sync_time"`
+	Result       int            `json:"result"` // 0 处理中，1 已接收，2 冲突，3 无效
+	Message      sql.NullString `json:"message"`
+}
+
+// TaskEvent 对应 'task_event' 表，记录网格员处理任务过程中的节点
+type TaskEvent struct {
+	TeID             int64           `json:"te_id"`
+	AfID             int64           `json:"af_id"`
+	GmID             int64           `json:"gm_id"`
+	EventType        int             `json:"event_type"` // 1 已接单，2 出发前往，3 到达现场，4 完成实测
+	EventDate        string          `json:"event_date"`
+	EventTime        string          `json:"event_time"`
+	Latitude         sql.NullFloat64 `json:"latitude"`
+	Longitude        sql.NullFloat64 `json:"longitude"`
+	LocationAccuracy sql.NullFloat64 `json:"location_accuracy"`
+	Distance         sql.NullInt64   `json:"distance"` // 与反馈位置的距离（米）
+	Remarks          sql.NullString  `json:"remarks"`
+}
+// WebhookDelivery 对应 'webhook_delivery' 表，记录每次向外部系统投递的回调
+type WebhookDelivery struct {
+	WdID          int64          `json:"wd_id"`
+	WsID          int64          `json:"ws_id"`
+	Event         string         `json:"event"`
+	Payload       string         `json:"payload"`
+	State         int            `json:"state"` // 0 待投递，1 已投递，2 投递失败
+	Attempts      int            `json:"attempts"`
+	NextDate      string         `json:"next_date"`
+	NextTime      string         `json:"next_time"`
+	ResponseCode  int            `json:"response_code"`
+	LastError     sql.NullString `json:"last_error"`
+	ReplayOf      int64          `json:"replay_of"`
+	CreateDate    string         `json:"create_date"`
+	CreateTime    string         `json:"create_time"`
+	DeliveredDate sql.NullString `json:"delivered_date"`
+	DeliveredTime sql.NullString `json:"delivered_time"`
+}
+
+// WebhookSubscription 对应 'webhook_subscription' 表，外部系统（如环保局、执法队）订阅的回调
+type WebhookSubscription struct {
+	WsID        int64          `json:"ws_id"`
+	WsName      string         `json:"ws_name"`
+	URL         string         `json:"url"`
+	Secret      string         `json:"-"`
+	Events      string         `json:"events"`        // 逗号分隔的事件类型
+	ProvinceID  int64          `json:"province_id"`   // 0 表示全部省份
+	MinAqiLevel int            `json:"min_aqi_level"` // 0 表示不限
+	Enabled     int            `json:"enabled"`
+	AdminID     int64          `json:"admin_id"`
+	CreateDate  string         `json:"create_date"`
+	CreateTime  string         `json:"create_time"`
+	Remarks     sql.NullString `json:"remarks"`
+}
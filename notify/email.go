@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPConfig SMTP服务器的连接参数
+type SMTPConfig struct {
+	Host     string
+	Port     string // 默认 25
+	Username string // 为空时不进行认证
+	Password string
+	From     string
+}
+
+// SMTPMailer 通过SMTP发送邮件
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer 创建SMTP邮件渠道
+func NewSMTPMailer(cfg SMTPConfig) (*SMTPMailer, error) {
+	if cfg.Host == "" || cfg.From == "" {
+		return nil, errors.New("SMTP_HOST 和 SMTP_FROM 为必填项")
+	}
+	port := cfg.Port
+	if port == "" {
+		port = "25"
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return &SMTPMailer{
+		addr: cfg.Host + ":" + port,
+		auth: auth,
+		from: cfg.From,
+	}, nil
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	if strings.ContainsAny(msg.Address, "\r\n") {
+		return errors.New("无效的邮箱地址")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.Address)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(msg.Content)
+	b.WriteString("\r\n")
+
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{msg.Address}, []byte(b.String()))
+}
@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"log"
+	"sync"
+)
+
+// FakeChannel 本地测试用的渠道，不实际发送，只在内存中保留最近的通知
+type FakeChannel struct {
+	name string
+	mu   sync.Mutex
+	sent []Message
+	fail error
+}
+
+// 测试渠道在内存中保留的最大通知数量
+const fakeChannelCapacity = 100
+
+// NewFakeChannel 创建本地测试渠道
+func NewFakeChannel(name string) *FakeChannel {
+	return &FakeChannel{name: name}
+}
+
+func (f *FakeChannel) Send(msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail != nil {
+		return f.fail
+	}
+	if len(f.sent) >= fakeChannelCapacity {
+		f.sent = f.sent[1:]
+	}
+	f.sent = append(f.sent, msg)
+	log.Printf("[通知:%s] 模拟发送 %s 通知", f.name, msg.Event)
+	return nil
+}
+
+// Sent 返回最近模拟发送的通知
+func (f *FakeChannel) Sent() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.sent...)
+}
+
+// FailWith 之后的发送都返回 err，传入 nil 恢复正常，用于测试重试
+func (f *FakeChannel) FailWith(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fail = err
+}
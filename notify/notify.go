@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"epss-backend/config"
+	"errors"
+	"log"
+	"strings"
+)
+
+// 通知渠道名称
+const (
+	ChannelSMS     = "sms"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// ErrChannelDisabled 渠道未启用
+var ErrChannelDisabled = errors.New("通知渠道未启用")
+
+// Message 一条待发送的通知
+type Message struct {
+	Event   string // 通知事件，如 task_assigned
+	Address string // 接收地址：手机号、邮箱或回调地址
+	Subject string
+	Content string
+}
+
+// Channel 通知发送渠道
+type Channel interface {
+	// Send 发送通知，返回错误时由调用方决定是否重试
+	Send(msg Message) error
+}
+
+var channels = map[string]Channel{}
+
+// Get 获取已启用的渠道
+func Get(name string) (Channel, error) {
+	ch, ok := channels[name]
+	if !ok {
+		return nil, ErrChannelDisabled
+	}
+	return ch, nil
+}
+
+// Register 注册渠道，替换同名的已有渠道，ch 为 nil 时停用该渠道；用于测试时接入 FakeChannel
+func Register(name string, ch Channel) {
+	if ch == nil {
+		delete(channels, name)
+		return
+	}
+	channels[name] = ch
+}
+
+// Setup 根据配置初始化通知渠道
+// NOTIFY_SMS_BACKEND 可选 off（默认）、gateway 或 fake
+// NOTIFY_EMAIL_BACKEND 可选 off（默认）、smtp 或 fake
+// NOTIFY_WEBHOOK_BACKEND 可选 off（默认）、http 或 fake
+// fake 只用于本地测试，通知不会实际发送
+func Setup() {
+	switch backend := strings.ToLower(config.Config("NOTIFY_SMS_BACKEND")); backend {
+	case "fake":
+		warnFake("NOTIFY_SMS_BACKEND")
+		channels[ChannelSMS] = NewFakeChannel(ChannelSMS)
+	case "gateway":
+		sms, err := NewSMSGateway(config.Config("SMS_GATEWAY_URL"), config.Config("SMS_GATEWAY_TOKEN"))
+		if err != nil {
+			log.Fatalf("初始化短信网关失败: %v", err)
+		}
+		channels[ChannelSMS] = sms
+	case "", "off":
+	default:
+		log.Fatalf("错误: 不支持的 NOTIFY_SMS_BACKEND: %s", backend)
+	}
+
+	switch backend := strings.ToLower(config.Config("NOTIFY_EMAIL_BACKEND")); backend {
+	case "fake":
+		warnFake("NOTIFY_EMAIL_BACKEND")
+		channels[ChannelEmail] = NewFakeChannel(ChannelEmail)
+	case "smtp":
+		mailer, err := NewSMTPMailer(SMTPConfig{
+			Host:     config.Config("SMTP_HOST"),
+			Port:     config.Config("SMTP_PORT"),
+			Username: config.Config("SMTP_USERNAME"),
+			Password: config.Config("SMTP_PASSWORD"),
+			From:     config.Config("SMTP_FROM"),
+		})
+		if err != nil {
+			log.Fatalf("初始化SMTP邮件发送失败: %v", err)
+		}
+		channels[ChannelEmail] = mailer
+	case "", "off":
+	default:
+		log.Fatalf("错误: 不支持的 NOTIFY_EMAIL_BACKEND: %s", backend)
+	}
+
+	switch backend := strings.ToLower(config.Config("NOTIFY_WEBHOOK_BACKEND")); backend {
+	case "fake":
+		warnFake("NOTIFY_WEBHOOK_BACKEND")
+		channels[ChannelWebhook] = NewFakeChannel(ChannelWebhook)
+	case "http":
+		channels[ChannelWebhook] = NewWebhookChannel()
+	case "", "off":
+	default:
+		log.Fatalf("错误: 不支持的 NOTIFY_WEBHOOK_BACKEND: %s", backend)
+	}
+}
+
+func warnFake(key string) {
+	log.Printf("警告: %s 为 fake，该渠道的通知只标记为已发送，不会实际发送", key)
+}
@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SMSGateway 通过HTTP短信网关发送短信
+// 请求为 POST JSON {"phone": "...", "content": "..."}，网关返回2xx表示发送成功
+type SMSGateway struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewSMSGateway 创建短信网关渠道，token 非空时以 Bearer 方式放在 Authorization 头中
+func NewSMSGateway(gatewayURL, token string) (*SMSGateway, error) {
+	if gatewayURL == "" {
+		return nil, errors.New("SMS_GATEWAY_URL 为必填项")
+	}
+	if _, err := url.ParseRequestURI(gatewayURL); err != nil {
+		return nil, fmt.Errorf("无效的 SMS_GATEWAY_URL: %v", err)
+	}
+	return &SMSGateway{
+		url:    gatewayURL,
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (s *SMSGateway) Send(msg Message) error {
+	body, err := json.Marshal(map[string]string{
+		"phone":   msg.Address,
+		"content": msg.Content,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("短信网关返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// 通知事件
+const (
+	EventTaskAssigned      = "task_assigned"      // 任务指派给网格员
+	EventFeedbackConfirmed = "feedback_confirmed" // 反馈已由网格员实测确认
+	EventFeedbackEscalated = "feedback_escalated" // 反馈超时升级给管理员
+	EventCommentAdded      = "comment_added"      // 反馈下有新的留言，仅发送站内通知
+	EventAlertTriggered    = "alert_triggered"    // 实测数据触发告警规则
+)
+
+// 支持的语言，默认中文
+const (
+	LocaleZh = "zh"
+	LocaleEn = "en"
+)
+
+type messageTemplate struct {
+	subject *template.Template
+	content *template.Template
+}
+
+var templates = map[string]map[string]messageTemplate{
+	EventTaskAssigned: {
+		LocaleZh: newTemplate(
+			"新任务：反馈{{.FeedbackID}}",
+			"您有新的任务（反馈编号{{.FeedbackID}}），地址：{{.Address}}，预估等级：{{.Grade}}级，请尽快前往实测。",
+		),
+		LocaleEn: newTemplate(
+			"New task: feedback #{{.FeedbackID}}",
+			"You have been assigned feedback #{{.FeedbackID}} at {{.Address}} (estimated grade {{.Grade}}). Please measure on site as soon as possible.",
+		),
+	},
+	EventFeedbackConfirmed: {
+		LocaleZh: newTemplate(
+			"反馈已确认",
+			"您于{{.Date}}提交的反馈（{{.Address}}）已由网格员实测确认，实测空气质量等级：{{.Grade}}级（{{.GradeName}}）。",
+		),
+		LocaleEn: newTemplate(
+			"Feedback confirmed",
+			"Your feedback submitted on {{.Date}} ({{.Address}}) has been confirmed on site. Measured AQI grade: {{.Grade}}.",
+		),
+	},
+	EventFeedbackEscalated: {
+		LocaleZh: newTemplate(
+			"反馈超时：反馈{{.FeedbackID}}",
+			"反馈编号{{.FeedbackID}}（{{.Address}}）{{if eq .Stage 1}}超过指派时限仍未指派{{else}}超过确认时限仍未实测确认{{end}}，截止时间{{.Deadline}}，请及时处理。",
+		),
+		LocaleEn: newTemplate(
+			"Overdue: feedback #{{.FeedbackID}}",
+			"Feedback #{{.FeedbackID}} ({{.Address}}) {{if eq .Stage 1}}has not been assigned{{else}}has not been confirmed{{end}} before the deadline {{.Deadline}}. Please follow up.",
+		),
+	},
+	EventCommentAdded: {
+		LocaleZh: newTemplate(
+			"新留言：反馈{{.FeedbackID}}",
+			"{{if eq .AuthorType \"admin\"}}管理员{{else if eq .AuthorType \"member\"}}网格员{{else}}监督员{{end}}在反馈编号{{.FeedbackID}}（{{.Address}}）下留言：{{.Content}}",
+		),
+		LocaleEn: newTemplate(
+			"New comment on feedback #{{.FeedbackID}}",
+			"{{if eq .AuthorType \"admin\"}}An administrator{{else if eq .AuthorType \"member\"}}The grid member{{else}}The supervisor{{end}} commented on feedback #{{.FeedbackID}} ({{.Address}}): {{.Content}}",
+		),
+	},
+	EventAlertTriggered: {
+		LocaleZh: newTemplate(
+			"空气质量告警：{{.RuleName}}",
+			"{{.ProvinceName}}{{.CityName}}触发告警规则「{{.RuleName}}」：{{.Message}}，请及时处理。",
+		),
+		LocaleEn: newTemplate(
+			"Air quality alert: {{.RuleName}}",
+			"Alert rule \"{{.RuleName}}\" was triggered in city #{{.CityID}} ({{.Metric}}, value {{.Value}}, threshold {{.Threshold}}). Please follow up.",
+		),
+	},
+}
+
+func newTemplate(subject, content string) messageTemplate {
+	return messageTemplate{
+		subject: template.Must(template.New("subject").Option("missingkey=zero").Parse(subject)),
+		content: template.Must(template.New("content").Option("missingkey=zero").Parse(content)),
+	}
+}
+
+// NormalizeLocale 返回支持的语言，不支持时使用中文
+func NormalizeLocale(locale string) string {
+	if strings.HasPrefix(strings.ToLower(locale), LocaleEn) {
+		return LocaleEn
+	}
+	return LocaleZh
+}
+
+// Render 按语言渲染通知的标题和内容
+func Render(event, locale string, data map[string]interface{}) (string, string, error) {
+	byLocale, ok := templates[event]
+	if !ok {
+		return "", "", fmt.Errorf("未知的通知事件: %s", event)
+	}
+	tmpl := byLocale[NormalizeLocale(locale)]
+
+	var subject, content strings.Builder
+	if err := tmpl.subject.Execute(&subject, data); err != nil {
+		return "", "", err
+	}
+	if err := tmpl.content.Execute(&content, data); err != nil {
+		return "", "", err
+	}
+	return subject.String(), content.String(), nil
+}
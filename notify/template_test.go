@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderTaskAssigned(t *testing.T) {
+	data := map[string]interface{}{"FeedbackID": 12, "Address": "解放路100号", "Grade": 4}
+
+	tests := []struct {
+		locale  string
+		subject string
+		content string
+	}{
+		{LocaleZh, "新任务：反馈12", "您有新的任务（反馈编号12），地址：解放路100号，预估等级：4级，请尽快前往实测。"},
+		{LocaleEn, "New task: feedback #12", "You have been assigned feedback #12 at 解放路100号 (estimated grade 4). Please measure on site as soon as possible."},
+	}
+	for _, tt := range tests {
+		subject, content, err := Render(EventTaskAssigned, tt.locale, data)
+		if err != nil {
+			t.Fatalf("Render(%s) 返回错误: %v", tt.locale, err)
+		}
+		if subject != tt.subject {
+			t.Errorf("Render(%s) 标题 = %q, 期望 %q", tt.locale, subject, tt.subject)
+		}
+		if content != tt.content {
+			t.Errorf("Render(%s) 内容 = %q, 期望 %q", tt.locale, content, tt.content)
+		}
+	}
+}
+
+func TestRenderEscalatedStage(t *testing.T) {
+	tests := []struct {
+		locale string
+		stage  int
+		want   string
+	}{
+		{LocaleZh, 1, "超过指派时限仍未指派"},
+		{LocaleZh, 2, "超过确认时限仍未实测确认"},
+		{LocaleEn, 1, "has not been assigned"},
+		{LocaleEn, 2, "has not been confirmed"},
+	}
+	for _, tt := range tests {
+		data := map[string]interface{}{"FeedbackID": 7, "Address": "A", "Stage": tt.stage, "Deadline": "2026-01-02 08:00:00"}
+		_, content, err := Render(EventFeedbackEscalated, tt.locale, data)
+		if err != nil {
+			t.Fatalf("Render(%s, stage %d) 返回错误: %v", tt.locale, tt.stage, err)
+		}
+		if !strings.Contains(content, tt.want) || !strings.Contains(content, "2026-01-02 08:00:00") {
+			t.Errorf("Render(%s, stage %d) 内容 = %q, 应包含 %q 和截止时间", tt.locale, tt.stage, content, tt.want)
+		}
+	}
+}
+
+func TestRenderUnsupportedLocaleFallsBackToChinese(t *testing.T) {
+	data := map[string]interface{}{"Date": "2026-01-02", "Address": "A", "Grade": 2, "GradeName": "良"}
+	zhSubject, zhContent, err := Render(EventFeedbackConfirmed, LocaleZh, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, content, err := Render(EventFeedbackConfirmed, "fr", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != zhSubject || content != zhContent {
+		t.Errorf("不支持的语言应使用中文模板, 得到 %q / %q", subject, content)
+	}
+}
+
+func TestRenderUnknownEvent(t *testing.T) {
+	if _, _, err := Render("unknown_event", LocaleZh, nil); err == nil {
+		t.Error("未知的通知事件应返回错误")
+	}
+}
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := map[string]string{
+		"":      LocaleZh,
+		"zh":    LocaleZh,
+		"zh-CN": LocaleZh,
+		"en":    LocaleEn,
+		"EN-us": LocaleEn,
+		"ja":    LocaleZh,
+	}
+	for locale, want := range tests {
+		if got := NormalizeLocale(locale); got != want {
+			t.Errorf("NormalizeLocale(%q) = %q, 期望 %q", locale, got, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 带签名回调的请求头
+const (
+	HeaderEvent     = "X-EPSS-Event"
+	HeaderDelivery  = "X-EPSS-Delivery"
+	HeaderTimestamp = "X-EPSS-Timestamp"
+	HeaderSignature = "X-EPSS-Signature"
+)
+
+// WebhookChannel 将通知以JSON形式POST到用户配置的回调地址
+type WebhookChannel struct {
+	client *http.Client
+}
+
+// NewWebhookChannel 创建回调渠道
+func NewWebhookChannel() *WebhookChannel {
+	return &WebhookChannel{client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (w *WebhookChannel) Send(msg Message) error {
+	body, err := json.Marshal(map[string]string{
+		"event":   msg.Event,
+		"subject": msg.Subject,
+		"content": msg.Content,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.client.Post(msg.Address, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("回调地址返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign 计算回调签名：以 secret 为密钥对 "时间戳.请求体" 做 HMAC-SHA256，十六进制编码
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// PostSigned 向外部系统发送带签名的回调，返回响应状态码（未收到响应时为0）
+// 签名放在 X-EPSS-Signature 头中，格式为 sha256=<签名>，时间戳为发送请求的时间，接收方应校验签名和时间戳
+func (w *WebhookChannel) PostSigned(url, secret, event string, deliveryID int64, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(deliveryID, 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("回调地址返回状态码 %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
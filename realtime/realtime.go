@@ -0,0 +1,200 @@
+package realtime
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// 事件主题
+const (
+	TopicFeedbackCreated   = "feedback_created"   // 监督员提交了新的反馈
+	TopicFeedbackAssigned  = "feedback_assigned"  // 反馈已指派或改派给网格员
+	TopicFeedbackConfirmed = "feedback_confirmed" // 反馈已由网格员实测确认
+	TopicStats             = "stats"              // 实时统计数据变化
+	TopicAlert             = "alert"              // 实测数据触发告警规则
+)
+
+// topicRoles 各主题允许订阅的用户类型
+var topicRoles = map[string][]string{
+	TopicFeedbackCreated:   {"admin"},
+	TopicFeedbackAssigned:  {"admin", "member", "supervisor"},
+	TopicFeedbackConfirmed: {"admin", "member", "supervisor"},
+	TopicStats:             {"admin"},
+	TopicAlert:             {"admin"},
+}
+
+// 每个订阅者最多缓存的事件数量，超出时断开该订阅者，由客户端重连后重新拉取数据
+const subscriberBuffer = 64
+
+// ErrNoTopic 没有可订阅的主题
+var ErrNoTopic = errors.New("没有可订阅的主题")
+
+// Event 推送给客户端的事件
+type Event struct {
+	ID    uint64      `json:"id"`
+	Topic string      `json:"topic"`
+	Data  interface{} `json:"data"`
+	Time  string      `json:"time"`
+
+	// Recipients 管理员以外的接收者，用户类型 -> 用户编号；管理员接收所订阅主题的所有事件
+	Recipients map[string]string `json:"-"`
+}
+
+// Subscriber 一个客户端连接的订阅
+type Subscriber struct {
+	UserType string
+	UserID   string
+	Topics   []string
+
+	topics map[string]bool
+	events chan Event
+	hub    *Hub
+}
+
+// Events 返回事件通道，订阅被取消或客户端处理过慢时通道被关闭
+func (s *Subscriber) Events() <-chan Event {
+	return s.events
+}
+
+// Close 取消订阅
+func (s *Subscriber) Close() {
+	s.hub.remove(s)
+}
+
+// accepts 判断订阅者是否应收到该事件
+func (s *Subscriber) accepts(e Event) bool {
+	if !s.topics[e.Topic] {
+		return false
+	}
+	if s.UserType == "admin" {
+		return true
+	}
+	return e.Recipients[s.UserType] == s.UserID
+}
+
+// Hub 进程内的发布订阅中心，处理器在数据变化后发布事件，推送连接订阅事件
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscriber]struct{}
+	nextID      uint64
+}
+
+// NewHub 创建发布订阅中心
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[*Subscriber]struct{})}
+}
+
+// Default 全局的发布订阅中心
+var Default = NewHub()
+
+// AllowedTopics 返回用户类型可以订阅的主题
+func AllowedTopics(userType string) []string {
+	var topics []string
+	for _, topic := range []string{TopicFeedbackCreated, TopicFeedbackAssigned, TopicFeedbackConfirmed, TopicStats, TopicAlert} {
+		if roleAllowed(topic, userType) {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+// FilterTopics 返回 topics 中用户类型可以订阅的主题（去重），topics 为空时返回允许的所有主题
+func FilterTopics(userType string, topics []string) []string {
+	if len(topics) == 0 {
+		return AllowedTopics(userType)
+	}
+	var allowed []string
+	seen := make(map[string]bool)
+	for _, topic := range topics {
+		if roleAllowed(topic, userType) && !seen[topic] {
+			seen[topic] = true
+			allowed = append(allowed, topic)
+		}
+	}
+	return allowed
+}
+
+func roleAllowed(topic, userType string) bool {
+	for _, role := range topicRoles[topic] {
+		if role == userType {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribe 订阅事件，topics 为空时订阅该用户类型允许的所有主题，不允许的主题被忽略
+func (h *Hub) Subscribe(userType, userID string, topics []string) (*Subscriber, error) {
+	s := &Subscriber{
+		UserType: userType,
+		UserID:   userID,
+		Topics:   FilterTopics(userType, topics),
+		topics:   make(map[string]bool),
+		events:   make(chan Event, subscriberBuffer),
+		hub:      h,
+	}
+	if len(s.Topics) == 0 {
+		return nil, ErrNoTopic
+	}
+	for _, topic := range s.Topics {
+		s.topics[topic] = true
+	}
+
+	h.mu.Lock()
+	h.subscribers[s] = struct{}{}
+	h.mu.Unlock()
+	return s, nil
+}
+
+// HasSubscribers 判断是否有客户端订阅了该主题，没有时发布方可以跳过准备事件数据
+func (h *Hub) HasSubscribers(topic string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for s := range h.subscribers {
+		if s.topics[topic] {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish 发布事件，不会阻塞
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	e.ID = h.nextID
+	if e.Time == "" {
+		e.Time = time.Now().Format("2006-01-02 15:04:05")
+	}
+
+	for s := range h.subscribers {
+		if !s.accepts(e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			log.Printf("实时推送: %s %s 处理过慢，已断开", s.UserType, s.UserID)
+			delete(h.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+func (h *Hub) remove(s *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[s]; ok {
+		delete(h.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Publish 向全局发布订阅中心发布事件
+func Publish(e Event) {
+	Default.Publish(e)
+}
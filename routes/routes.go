@@ -24,8 +24,18 @@ func SetupRoutes(app *fiber.App) {
 		// 位置信息相关
 		public.Get("/location/provinces", handlers.GetProvinces)
 		public.Get("/location/cities/:province_id", handlers.GetCities)
+
+		// 附件下载（通过签名地址授权）
+		public.Get("/attachment/download/:id", handlers.DownloadAttachment)
 	}
 
+	// 实时推送（SSE和WebSocket），浏览器可通过 token 查询参数传递JWT
+	stream := api.Group("/stream")
+	stream.Use(handlers.StreamTokenAuth)
+	stream.Use(handlers.JWTMiddleware)
+	stream.Get("/events", handlers.StreamEvents)
+	stream.Get("/ws", handlers.RequireWebSocketUpgrade, handlers.StreamWebSocket)
+
 	// 认证相关路由
 	auth := api.Group("/auth")
 	auth.Post("/admin/login", handlers.AdminLogin)
@@ -37,6 +47,7 @@ func SetupRoutes(app *fiber.App) {
 	adminProtected := api.Group("/admin")
 	adminProtected.Use(handlers.JWTMiddleware)
 	adminProtected.Use(handlers.AdminOnly)
+	adminProtected.Use(handlers.IdempotencyMiddleware)
 
 	// 管理员功能
 	adminProtected.Post("/add", handlers.AddAdmin)
@@ -50,16 +61,86 @@ func SetupRoutes(app *fiber.App) {
 	adminProtected.Get("/member/list", handlers.GetGridMemberList)
 	adminProtected.Get("/supervisor/list", handlers.GetSupervisorList)
 	adminProtected.Delete("/supervisor/delete/:tel_id", handlers.DeleteSupervisor)
+	adminProtected.Get("/region/list", handlers.GetAdminRegionList)
+	adminProtected.Post("/region/add", handlers.AddAdminRegion)
+	adminProtected.Delete("/region/delete/:id", handlers.DeleteAdminRegion)
 	
 	// 管理员路由组
 	adminGroup := adminProtected.Group("")
 	{
 		// AQI相关
 		adminGroup.Get("/aqi/confirmed/list", handlers.GetAllConfirmedAQI)
+		adminGroup.Get("/aqi/attachment/list/:id", handlers.GetMeasurementAttachments)
+		adminGroup.Get("/aqi/geofence/review/list", handlers.GetGeofenceReviewList)
+		adminGroup.Post("/aqi/geofence/review/:id", handlers.ReviewGeofence)
 
 		// 反馈相关
 		adminGroup.Get("/feedback/list", handlers.GetAllFeedbacks)
 		adminGroup.Post("/feedback/assign", handlers.AssignFeedback)
+		adminGroup.Post("/feedback/assign/batch", handlers.BatchAssignFeedback)
+		adminGroup.Post("/feedback/transfer", handlers.TransferGridMemberTasks)
+		adminGroup.Get("/feedback/assign/log/:id", handlers.GetFeedbackAssignLog)
+		adminGroup.Get("/feedback/history/:id", handlers.GetFeedbackEditHistory)
+		adminGroup.Get("/feedback/attachment/list/:id", handlers.GetFeedbackAttachments)
+		adminGroup.Get("/feedback/comment/list/:id", handlers.GetFeedbackComments)
+		adminGroup.Post("/feedback/comment/add/:id", handlers.AddFeedbackComment)
+		adminGroup.Get("/feedback/timeline/:id", handlers.GetFeedbackTimeline)
+		adminGroup.Get("/feedback/dispute/list", handlers.GetDisputeList)
+		adminGroup.Post("/feedback/dispute/review/:id", handlers.ReviewDispute)
+
+		// 重复反馈事件组相关
+		adminGroup.Get("/incident/list", handlers.GetIncidentGroupList)
+		adminGroup.Get("/incident/members/:id", handlers.GetIncidentGroupMembers)
+		adminGroup.Post("/incident/unlink/:id", handlers.UnlinkIncidentFeedback)
+
+		// 指派调度队列相关
+		adminGroup.Get("/dispatch/queue", handlers.GetDispatchQueue)
+		adminGroup.Get("/dispatch/weights", handlers.GetDispatchWeights)
+		adminGroup.Post("/dispatch/weights/update", handlers.UpdateDispatchWeight)
+		adminGroup.Get("/dispatch/sensitive-area/list", handlers.GetSensitiveAreaList)
+		adminGroup.Post("/dispatch/sensitive-area/add", handlers.AddSensitiveArea)
+		adminGroup.Delete("/dispatch/sensitive-area/delete/:id", handlers.DeleteSensitiveArea)
+
+		// 考勤相关
+		adminGroup.Get("/attendance/list", handlers.GetAttendanceList)
+		adminGroup.Get("/attendance/availability", handlers.GetMemberAvailabilityList)
+		adminGroup.Get("/shift/list", handlers.GetShiftList)
+		adminGroup.Post("/shift/add", handlers.AddShifts)
+		adminGroup.Delete("/shift/delete/:id", handlers.DeleteShift)
+		adminGroup.Get("/leave/list", handlers.GetLeaveList)
+		adminGroup.Post("/leave/review/:id", handlers.ReviewLeave)
+
+		// 处理时限相关
+		adminGroup.Get("/sla/config", handlers.GetSLAConfig)
+		adminGroup.Post("/sla/config/update", handlers.UpdateSLAConfig)
+		adminGroup.Get("/sla/escalation/list", handlers.GetMyEscalations)
+		adminGroup.Post("/sla/escalation/handle/:id", handlers.HandleEscalation)
+
+		// 通知相关
+		adminGroup.Get("/notification/list", handlers.GetNotificationList)
+		adminGroup.Post("/notification/retry/:id", handlers.RetryNotification)
+		adminGroup.Get("/notification/preference", handlers.GetNotificationPreferences)
+		adminGroup.Post("/notification/preference/update", handlers.UpdateNotificationPreference)
+		adminGroup.Get("/notification/inbox", handlers.GetInboxNotifications)
+		adminGroup.Post("/notification/inbox/read/:id", handlers.MarkNotificationRead)
+		adminGroup.Post("/notification/inbox/read-all", handlers.MarkAllNotificationsRead)
+
+		// 外部回调相关
+		adminGroup.Get("/webhook/list", handlers.GetWebhookList)
+		adminGroup.Post("/webhook/add", handlers.AddWebhook)
+		adminGroup.Post("/webhook/update/:id", handlers.UpdateWebhook)
+		adminGroup.Delete("/webhook/delete/:id", handlers.DeleteWebhook)
+		adminGroup.Get("/webhook/delivery/list", handlers.GetWebhookDeliveryList)
+		adminGroup.Post("/webhook/delivery/replay/:id", handlers.ReplayWebhookDelivery)
+
+		// 告警相关
+		adminGroup.Get("/alert/rule/list", handlers.GetAlertRuleList)
+		adminGroup.Post("/alert/rule/add", handlers.AddAlertRule)
+		adminGroup.Post("/alert/rule/update/:id", handlers.UpdateAlertRule)
+		adminGroup.Delete("/alert/rule/delete/:id", handlers.DeleteAlertRule)
+		adminGroup.Get("/alert/list", handlers.GetAlertList)
+		adminGroup.Post("/alert/acknowledge/:id", handlers.AcknowledgeAlert)
+		adminGroup.Post("/alert/resolve/:id", handlers.ResolveAlert)
 
 		// 位置信息相关
 		adminGroup.Get("/location/provinces", handlers.GetProvinces)
@@ -67,27 +148,78 @@ func SetupRoutes(app *fiber.App) {
 
 		// 统计数据相关
 		adminGroup.Get("/stats/province", handlers.GetProvinceAQIStats)
+		adminGroup.Get("/stats/city", handlers.GetCityAQIStats)
+		adminGroup.Get("/stats/district", handlers.GetDistrictAQIStats)
 		adminGroup.Get("/stats/aqi-level", handlers.GetAQILevelStats)
 		adminGroup.Get("/stats/aqi-trend", handlers.GetAQITrendStats)
 		adminGroup.Get("/stats/aqi-realtime", handlers.GetAQIRealtimeStats)
+		adminGroup.Get("/stats/standard", handlers.GetComplianceStandard)
+		adminGroup.Post("/stats/standard/update", handlers.UpdateComplianceStandard)
+		adminGroup.Get("/stats/sla/overdue", handlers.GetOverdueFeedbacks)
+		adminGroup.Get("/stats/sla/compliance", handlers.GetSLAComplianceStats)
+		adminGroup.Get("/stats/geojson/measurements", handlers.GetMeasurementGeoJSON)
+		adminGroup.Get("/stats/geojson/feedback", handlers.GetOpenFeedbackGeoJSON)
+		adminGroup.Get("/stats/member-quality", handlers.GetGridMemberQualityStats)
+		adminGroup.Get("/stats/response-time", handlers.GetResponseTimeStats)
+		adminGroup.Get("/stats/member-performance", handlers.GetGridMemberPerformanceStats)
 	}
 
 	// 监督员相关路由
 	supervisorProtected := api.Group("/supervisor")
 	supervisorProtected.Use(handlers.JWTMiddleware)
 	supervisorProtected.Use(handlers.SupervisorOnly)
+	supervisorProtected.Use(handlers.IdempotencyMiddleware)
 	supervisorProtected.Get("/info", handlers.GetCurrentSupervisor) // 获取当前登录的监督员信息
 	supervisorProtected.Delete("/delete", handlers.DeleteSupervisorSelf)
 	supervisorProtected.Get("/feedback/list", handlers.GetSupervisorFeedbacks)
 	supervisorProtected.Post("/feedback/submit", handlers.SubmitFeedback)
+	supervisorProtected.Post("/feedback/update/:id", handlers.UpdateSupervisorFeedback)
+	supervisorProtected.Post("/feedback/withdraw/:id", handlers.WithdrawSupervisorFeedback)
+	supervisorProtected.Get("/feedback/history/:id", handlers.GetFeedbackEditHistory)
+	supervisorProtected.Post("/feedback/rate/:id", handlers.RateFeedback)
+	supervisorProtected.Post("/feedback/dispute/:id", handlers.DisputeFeedback)
+	supervisorProtected.Post("/feedback/attachment/upload/:id", handlers.UploadFeedbackAttachment)
+	supervisorProtected.Get("/feedback/attachment/list/:id", handlers.GetFeedbackAttachments)
+	supervisorProtected.Get("/feedback/comment/list/:id", handlers.GetFeedbackComments)
+	supervisorProtected.Post("/feedback/comment/add/:id", handlers.AddFeedbackComment)
+	supervisorProtected.Get("/feedback/timeline/:id", handlers.GetFeedbackTimeline)
+	supervisorProtected.Get("/aqi/attachment/list/:id", handlers.GetMeasurementAttachments)
+	supervisorProtected.Get("/notification/preference", handlers.GetNotificationPreferences)
+	supervisorProtected.Post("/notification/preference/update", handlers.UpdateNotificationPreference)
+	supervisorProtected.Get("/notification/inbox", handlers.GetInboxNotifications)
+	supervisorProtected.Post("/notification/inbox/read/:id", handlers.MarkNotificationRead)
+	supervisorProtected.Post("/notification/inbox/read-all", handlers.MarkAllNotificationsRead)
 
 	// 网格员相关路由
 	memberProtected := api.Group("/member")
 	memberProtected.Use(handlers.JWTMiddleware)
 	memberProtected.Use(handlers.GridMemberOnly)
+	memberProtected.Use(handlers.IdempotencyMiddleware)
 	memberProtected.Get("/info", handlers.GetCurrentGridMember) // 获取当前登录的网格员信息
 	memberProtected.Get("/feedback/list", handlers.GetGridMemberFeedbacks) // 获取分配给当前网格员的反馈任务
+	memberProtected.Get("/feedback/route", handlers.GetGridMemberRoute) // 根据当前位置规划未完成任务的访问顺序
+	memberProtected.Post("/feedback/event/:id", handlers.AddTaskEvent) // 记录任务进度（接单、出发、到达）
+	memberProtected.Get("/feedback/timeline/:id", handlers.GetFeedbackTimeline) // 查看任务的处理时间线
 	memberProtected.Post("/aqi/submit", handlers.SubmitAQIMeasurement) // 提交实测的AQI数据
+	memberProtected.Post("/sync/measurements", handlers.SyncMeasurements) // 批量同步离线缓存的实测数据
+	memberProtected.Get("/sync/changes", handlers.GetSyncChanges) // 获取上次同步以来的任务变化
+	memberProtected.Get("/feedback/attachment/list/:id", handlers.GetFeedbackAttachments) // 查看任务反馈的附件
+	memberProtected.Get("/feedback/comment/list/:id", handlers.GetFeedbackComments) // 查看任务的留言
+	memberProtected.Post("/feedback/comment/add/:id", handlers.AddFeedbackComment) // 在任务下留言
+	memberProtected.Post("/aqi/attachment/upload/:id", handlers.UploadMeasurementAttachment) // 为实测数据上传附件
+	memberProtected.Get("/aqi/attachment/list/:id", handlers.GetMeasurementAttachments)
+	memberProtected.Post("/attendance/check-in", handlers.CheckIn) // 签到
+	memberProtected.Post("/attendance/check-out", handlers.CheckOut) // 签退
+	memberProtected.Get("/attendance/list", handlers.GetAttendanceList) // 查看自己的考勤记录
+	memberProtected.Get("/shift/list", handlers.GetShiftList) // 查看自己的排班
+	memberProtected.Post("/leave/apply", handlers.ApplyLeave) // 提交请假申请
+	memberProtected.Post("/leave/cancel/:id", handlers.CancelLeave) // 取消请假
+	memberProtected.Get("/leave/list", handlers.GetLeaveList) // 查看自己的请假申请
+	memberProtected.Get("/notification/preference", handlers.GetNotificationPreferences) // 查看通知设置
+	memberProtected.Post("/notification/preference/update", handlers.UpdateNotificationPreference) // 修改通知设置
+	memberProtected.Get("/notification/inbox", handlers.GetInboxNotifications) // 查看站内通知
+	memberProtected.Post("/notification/inbox/read/:id", handlers.MarkNotificationRead) // 标记通知已读
+	memberProtected.Post("/notification/inbox/read-all", handlers.MarkAllNotificationsRead) // 全部标记已读
 
 	// 健康检查
 	api.Get("/health", func(c *fiber.Ctx) error {
@@ -20,6 +20,71 @@ CREATE TABLE `admins` (
   UNIQUE KEY `dis_code` (`admin_code`)
 ) ENGINE=InnoDB AUTO_INCREMENT=2 DEFAULT CHARSET=utf8;
 
+-- ----------------------------
+-- Table structure for admin_region
+-- ----------------------------
+DROP TABLE IF EXISTS `admin_region`;
+CREATE TABLE `admin_region` (
+  `ar_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '管理员负责区域编号',
+  `admin_id` int(11) NOT NULL COMMENT '系统管理员编号',
+  `province_id` int(11) NOT NULL COMMENT '负责省区域编号',
+  `city_id` int(11) NOT NULL DEFAULT '0' COMMENT '负责市区域编号（0表示整个省）',
+  `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
+  PRIMARY KEY (`ar_id`),
+  UNIQUE KEY `admin_region` (`admin_id`,`province_id`,`city_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for alert_record
+-- ----------------------------
+DROP TABLE IF EXISTS `alert_record`;
+CREATE TABLE `alert_record` (
+  `al_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '告警编号',
+  `ar_id` int(11) NOT NULL COMMENT '触发的告警规则编号',
+  `rule_type` int(11) NOT NULL COMMENT '规则类型: 1:单次超标; 2:频次超标; 3:上升趋势',
+  `metric` varchar(10) NOT NULL COMMENT '指标: so2, co, spm, aqi',
+  `province_id` int(11) NOT NULL COMMENT '所属省区域编号',
+  `city_id` int(11) NOT NULL COMMENT '所属市区域编号',
+  `value` int(11) NOT NULL COMMENT '触发值（单次超标和上升趋势为实测值，频次超标为超标次数）',
+  `statistics_id` int(11) NOT NULL DEFAULT '0' COMMENT '触发告警的实测数据编号（0表示由定时检查触发）',
+  `message` varchar(200) NOT NULL COMMENT '告警说明',
+  `state` int(11) NOT NULL DEFAULT '0' COMMENT '处理状态: 0:未处理; 1:已确认; 2:已解决',
+  `alert_date` varchar(20) NOT NULL COMMENT '告警日期',
+  `alert_time` varchar(20) NOT NULL COMMENT '告警时间',
+  `ack_admin_id` int(11) DEFAULT NULL COMMENT '确认的管理员编号',
+  `ack_date` varchar(20) DEFAULT NULL COMMENT '确认日期',
+  `ack_time` varchar(20) DEFAULT NULL COMMENT '确认时间',
+  `resolve_admin_id` int(11) DEFAULT NULL COMMENT '解决的管理员编号',
+  `resolve_date` varchar(20) DEFAULT NULL COMMENT '解决日期',
+  `resolve_time` varchar(20) DEFAULT NULL COMMENT '解决时间',
+  `resolve_remarks` varchar(200) DEFAULT NULL COMMENT '处理说明',
+  PRIMARY KEY (`al_id`),
+  KEY `rule_city_state` (`ar_id`,`city_id`,`state`),
+  KEY `state` (`state`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for alert_rule
+-- ----------------------------
+DROP TABLE IF EXISTS `alert_rule`;
+CREATE TABLE `alert_rule` (
+  `ar_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '告警规则编号',
+  `ar_name` varchar(50) NOT NULL COMMENT '规则名称',
+  `rule_type` int(11) NOT NULL COMMENT '规则类型: 1:单次超标; 2:频次超标; 3:上升趋势',
+  `metric` varchar(10) NOT NULL COMMENT '指标: so2, co, spm（浓度值）或 aqi（AQI级别）',
+  `threshold` int(11) NOT NULL COMMENT '阈值，实测值大于阈值视为超标（上升趋势时为最后一次实测值需超过的值）',
+  `occurrences` int(11) NOT NULL DEFAULT '1' COMMENT '频次超标的超标次数，上升趋势的连续上升次数',
+  `window_minutes` int(11) NOT NULL DEFAULT '0' COMMENT '频次超标和上升趋势的统计时间窗口（分钟）',
+  `province_id` int(11) NOT NULL DEFAULT '0' COMMENT '适用省份（0表示全部省份）',
+  `city_id` int(11) NOT NULL DEFAULT '0' COMMENT '适用城市（0表示省内全部城市）',
+  `enabled` int(11) NOT NULL DEFAULT '1' COMMENT '是否启用（0:停用; 1:启用）',
+  `admin_id` int(11) NOT NULL COMMENT '创建的管理员编号',
+  `create_date` varchar(20) NOT NULL COMMENT '创建日期',
+  `create_time` varchar(20) NOT NULL COMMENT '创建时间',
+  `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
+  PRIMARY KEY (`ar_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
 -- ----------------------------
 -- Table structure for aqi
 -- ----------------------------
@@ -58,11 +123,220 @@ CREATE TABLE `aqi_feedback` (
   `gm_id` int(11) NOT NULL DEFAULT '0' COMMENT '指派网格员编号',
   `assign_date` varchar(20) DEFAULT NULL COMMENT '指派日期',
   `assign_time` varchar(20) DEFAULT NULL COMMENT '指派时间',
-  `state` int(11) NOT NULL COMMENT '信息状态: 0:未指派; 1:已指派; 2:已确认',
+  `state` int(11) NOT NULL COMMENT '信息状态: 0:未指派; 1:已指派; 2:已确认; 3:已撤回; 4:申诉复核中',
   `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
-  PRIMARY KEY (`af_id`)
+  `latitude` decimal(10,7) DEFAULT NULL COMMENT '反馈位置纬度',
+  `longitude` decimal(10,7) DEFAULT NULL COMMENT '反馈位置经度',
+  `location_accuracy` decimal(10,2) DEFAULT NULL COMMENT '反馈定位精度（单位：米）',
+  `ig_id` int(11) NOT NULL DEFAULT '0' COMMENT '所属事件组编号（0表示未归入事件组）',
+  `update_time` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '最后修改时间（用于网格员离线同步）',
+  `sla_round` int(11) NOT NULL DEFAULT '0' COMMENT '处理轮次（申诉重新处理后加1）',
+  `sla_start` varchar(20) DEFAULT NULL COMMENT '本轮时限起算时间（为空时从反馈时间起算）',
+  PRIMARY KEY (`af_id`),
+  KEY `tel_id` (`tel_id`),
+  KEY `update_time` (`update_time`)
 ) ENGINE=InnoDB AUTO_INCREMENT=44 DEFAULT CHARSET=utf8;
 
+-- ----------------------------
+-- Table structure for attachment
+-- ----------------------------
+DROP TABLE IF EXISTS `attachment`;
+CREATE TABLE `attachment` (
+  `att_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '附件编号',
+  `af_id` int(11) NOT NULL DEFAULT '0' COMMENT '关联的反馈信息编号（0表示无关联）',
+  `statistics_id` int(11) NOT NULL DEFAULT '0' COMMENT '关联的实测数据编号（0表示无关联）',
+  `file_name` varchar(200) NOT NULL COMMENT '原始文件名',
+  `content_type` varchar(100) NOT NULL COMMENT '文件类型',
+  `file_size` int(11) NOT NULL COMMENT '文件大小（字节）',
+  `storage_key` varchar(200) NOT NULL COMMENT '文件存储路径',
+  `thumb_key` varchar(200) DEFAULT NULL COMMENT '缩略图存储路径（仅图片）',
+  `uploader_type` varchar(20) NOT NULL COMMENT '上传者角色: supervisor; member; admin',
+  `uploader_id` varchar(20) NOT NULL COMMENT '上传者编号（监督员手机号或网格员编号）',
+  `upload_date` varchar(20) NOT NULL COMMENT '上传日期',
+  `upload_time` varchar(20) NOT NULL COMMENT '上传时间',
+  `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
+  PRIMARY KEY (`att_id`),
+  KEY `af_id` (`af_id`),
+  KEY `statistics_id` (`statistics_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for compliance_standard
+-- ----------------------------
+DROP TABLE IF EXISTS `compliance_standard`;
+CREATE TABLE `compliance_standard` (
+  `metric` varchar(10) NOT NULL COMMENT '指标: so2:二氧化硫; co:一氧化碳; spm:悬浮颗粒物; aqi:AQI级别',
+  `exceed_level` int(11) NOT NULL COMMENT '视为超标的最低AQI级别，超标限值为aqi表中前一级别的浓度最大限值',
+  `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
+  PRIMARY KEY (`metric`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for dispatch_weight
+-- ----------------------------
+DROP TABLE IF EXISTS `dispatch_weight`;
+CREATE TABLE `dispatch_weight` (
+  `factor` varchar(20) NOT NULL COMMENT '优先级因素: grade:预估等级; trust:监督员可信度; cluster:重复反馈数量; waiting:等待时间; sensitive:敏感区域',
+  `weight` decimal(6,2) NOT NULL COMMENT '权重（非负数，按所有因素权重之和归一化）',
+  `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
+  PRIMARY KEY (`factor`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for feedback_assign_log
+-- ----------------------------
+DROP TABLE IF EXISTS `feedback_assign_log`;
+CREATE TABLE `feedback_assign_log` (
+  `log_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '指派记录编号',
+  `af_id` int(11) NOT NULL COMMENT '反馈信息编号',
+  `prev_gm_id` int(11) NOT NULL DEFAULT '0' COMMENT '原指派网格员编号（0表示首次指派）',
+  `gm_id` int(11) NOT NULL COMMENT '新指派网格员编号',
+  `admin_id` int(11) NOT NULL COMMENT '操作管理员编号（0表示系统自动指派）',
+  `assign_date` varchar(20) NOT NULL COMMENT '指派日期',
+  `assign_time` varchar(20) NOT NULL COMMENT '指派时间',
+  `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
+  `remote_assign` int(11) NOT NULL DEFAULT '0' COMMENT '是否异地指派（指派时网格员负责区域与反馈区域不同）',
+  PRIMARY KEY (`log_id`),
+  KEY `af_id` (`af_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for feedback_comment
+-- ----------------------------
+DROP TABLE IF EXISTS `feedback_comment`;
+CREATE TABLE `feedback_comment` (
+  `fc_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '留言编号',
+  `af_id` int(11) NOT NULL COMMENT '反馈信息编号',
+  `author_type` varchar(20) NOT NULL COMMENT '留言者类型: admin; member; supervisor',
+  `author_id` varchar(20) NOT NULL COMMENT '留言者编号（管理员编号、网格员编号或监督员手机号码）',
+  `content` varchar(1000) NOT NULL COMMENT '留言内容',
+  `internal` int(11) NOT NULL DEFAULT '0' COMMENT '是否内部留言: 0:监督员可见; 1:仅管理员和网格员可见',
+  `comment_date` varchar(20) NOT NULL COMMENT '留言日期',
+  `comment_time` varchar(20) NOT NULL COMMENT '留言时间',
+  `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
+  PRIMARY KEY (`fc_id`),
+  KEY `af_id` (`af_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for feedback_comment_read
+-- ----------------------------
+DROP TABLE IF EXISTS `feedback_comment_read`;
+CREATE TABLE `feedback_comment_read` (
+  `af_id` int(11) NOT NULL COMMENT '反馈信息编号',
+  `reader_type` varchar(20) NOT NULL COMMENT '阅读者类型: admin; member; supervisor',
+  `reader_id` varchar(20) NOT NULL COMMENT '阅读者编号',
+  `last_fc_id` int(11) NOT NULL DEFAULT '0' COMMENT '已读到的最后一条留言编号',
+  PRIMARY KEY (`af_id`, `reader_type`, `reader_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for feedback_dispute
+-- ----------------------------
+DROP TABLE IF EXISTS `feedback_dispute`;
+CREATE TABLE `feedback_dispute` (
+  `dispute_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '申诉编号',
+  `af_id` int(11) NOT NULL COMMENT '反馈信息编号',
+  `tel_id` varchar(20) NOT NULL COMMENT '申诉的公众监督员编号（即手机号码）',
+  `gm_id` int(11) NOT NULL COMMENT '被申诉的确认网格员编号',
+  `reason` varchar(400) NOT NULL COMMENT '申诉理由',
+  `dispute_date` varchar(20) NOT NULL COMMENT '申诉日期',
+  `dispute_time` varchar(20) NOT NULL COMMENT '申诉时间',
+  `state` int(11) NOT NULL DEFAULT '0' COMMENT '申诉状态: 0:待复核; 1:维持原结果; 2:重新处理',
+  `admin_id` int(11) DEFAULT NULL COMMENT '复核管理员编号',
+  `review_date` varchar(20) DEFAULT NULL COMMENT '复核日期',
+  `review_time` varchar(20) DEFAULT NULL COMMENT '复核时间',
+  `review_remarks` varchar(200) DEFAULT NULL COMMENT '复核意见',
+  PRIMARY KEY (`dispute_id`),
+  KEY `af_id` (`af_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for feedback_edit_log
+-- ----------------------------
+DROP TABLE IF EXISTS `feedback_edit_log`;
+CREATE TABLE `feedback_edit_log` (
+  `log_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '修改记录编号',
+  `af_id` int(11) NOT NULL COMMENT '反馈信息编号',
+  `tel_id` varchar(20) NOT NULL COMMENT '操作的公众监督员编号（即手机号码）',
+  `action` int(11) NOT NULL COMMENT '操作类型: 1:修改; 2:撤回',
+  `old_address` varchar(200) NOT NULL COMMENT '修改前的详细地址',
+  `old_information` varchar(400) NOT NULL COMMENT '修改前的反馈信息描述',
+  `old_estimated_grade` int(11) NOT NULL COMMENT '修改前的预估等级',
+  `new_address` varchar(200) NOT NULL COMMENT '修改后的详细地址',
+  `new_information` varchar(400) NOT NULL COMMENT '修改后的反馈信息描述',
+  `new_estimated_grade` int(11) NOT NULL COMMENT '修改后的预估等级',
+  `edit_date` varchar(20) NOT NULL COMMENT '操作日期',
+  `edit_time` varchar(20) NOT NULL COMMENT '操作时间',
+  `remarks` varchar(200) DEFAULT NULL COMMENT '修改或撤回原因',
+  PRIMARY KEY (`log_id`),
+  KEY `af_id` (`af_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for feedback_escalation
+-- ----------------------------
+DROP TABLE IF EXISTS `feedback_escalation`;
+CREATE TABLE `feedback_escalation` (
+  `fe_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '超时升级记录编号',
+  `fo_id` int(11) NOT NULL COMMENT '超时标记编号',
+  `af_id` int(11) NOT NULL COMMENT '反馈信息编号',
+  `admin_id` int(11) NOT NULL COMMENT '接收升级的管理员编号',
+  `escalate_date` varchar(20) NOT NULL COMMENT '升级日期',
+  `escalate_time` varchar(20) NOT NULL COMMENT '升级时间',
+  `state` int(11) NOT NULL DEFAULT '0' COMMENT '处理状态: 0:未处理; 1:已处理',
+  `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
+  PRIMARY KEY (`fe_id`),
+  KEY `admin_id` (`admin_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for feedback_overdue
+-- ----------------------------
+DROP TABLE IF EXISTS `feedback_overdue`;
+CREATE TABLE `feedback_overdue` (
+  `fo_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '超时标记编号',
+  `af_id` int(11) NOT NULL COMMENT '反馈信息编号',
+  `stage` int(11) NOT NULL COMMENT '超时环节: 1:指派超时; 2:确认超时',
+  `deadline` varchar(20) NOT NULL COMMENT '时限截止时间',
+  `flag_date` varchar(20) NOT NULL COMMENT '标记日期',
+  `flag_time` varchar(20) NOT NULL COMMENT '标记时间',
+  `escalated` int(11) NOT NULL DEFAULT '0' COMMENT '是否已升级: 0:否; 1:是',
+  `resolved` int(11) NOT NULL DEFAULT '0' COMMENT '是否已解除: 0:否; 1:是',
+  `sla_round` int(11) NOT NULL DEFAULT '0' COMMENT '反馈的处理轮次',
+  PRIMARY KEY (`fo_id`),
+  UNIQUE KEY `af_round_stage` (`af_id`,`sla_round`,`stage`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for feedback_rating
+-- ----------------------------
+DROP TABLE IF EXISTS `feedback_rating`;
+CREATE TABLE `feedback_rating` (
+  `rating_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '评价编号',
+  `af_id` int(11) NOT NULL COMMENT '反馈信息编号',
+  `tel_id` varchar(20) NOT NULL COMMENT '评价的公众监督员编号（即手机号码）',
+  `gm_id` int(11) NOT NULL COMMENT '被评价的确认网格员编号',
+  `rating` int(11) NOT NULL COMMENT '满意度评分（1-5）',
+  `comment` varchar(400) DEFAULT NULL COMMENT '评价内容',
+  `rate_date` varchar(20) NOT NULL COMMENT '评价日期',
+  `rate_time` varchar(20) NOT NULL COMMENT '评价时间',
+  PRIMARY KEY (`rating_id`),
+  UNIQUE KEY `af_id` (`af_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for feedback_sla
+-- ----------------------------
+DROP TABLE IF EXISTS `feedback_sla`;
+CREATE TABLE `feedback_sla` (
+  `grade` int(11) NOT NULL COMMENT '预估空气质量指数级别',
+  `assign_minutes` int(11) NOT NULL COMMENT '反馈后须完成指派的时限（分钟）',
+  `confirm_minutes` int(11) NOT NULL COMMENT '反馈后须完成实测确认的时限（分钟）',
+  `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
+  PRIMARY KEY (`grade`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
 -- ----------------------------
 -- Table structure for grid_city
 -- ----------------------------
@@ -87,7 +361,7 @@ CREATE TABLE `grid_member` (
   `province_id` int(11) NOT NULL COMMENT '网格区域：省编号',
   `city_id` int(11) NOT NULL COMMENT '网格区域：市编号',
   `tel` varchar(20) NOT NULL COMMENT '联系电话',
-  `state` int(11) NOT NULL DEFAULT '0' COMMENT '网格员状态（0:工作状态; 1:非工作状态（由考勤系统管理）; 2:其它）',
+  `state` int(11) NOT NULL DEFAULT '0' COMMENT '网格员状态（0:工作状态; 1:非工作状态（由考勤系统根据签到、班次和请假自动维护）; 2:其它（不参与自动维护））',
   `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
   PRIMARY KEY (`gm_id`),
   UNIQUE KEY `gm_code` (`gm_code`)
@@ -105,6 +379,187 @@ CREATE TABLE `grid_province` (
   PRIMARY KEY (`province_id`)
 ) ENGINE=InnoDB AUTO_INCREMENT=17 DEFAULT CHARSET=utf8;
 
+-- ----------------------------
+-- Table structure for idempotency_key
+-- ----------------------------
+DROP TABLE IF EXISTS `idempotency_key`;
+CREATE TABLE `idempotency_key` (
+  `ik_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '幂等记录编号',
+  `scope` varchar(60) NOT NULL COMMENT '请求者标识（用户类型和编号）',
+  `idem_key` varchar(100) NOT NULL COMMENT '请求头 Idempotency-Key 的值',
+  `fingerprint` char(64) NOT NULL COMMENT '请求指纹（方法、地址和请求体的SHA-256）',
+  `state` int(11) NOT NULL DEFAULT '0' COMMENT '处理状态: 0:处理中; 1:已完成',
+  `status_code` int(11) NOT NULL DEFAULT '0' COMMENT '原始响应状态码',
+  `content_type` varchar(100) DEFAULT NULL COMMENT '原始响应类型',
+  `response_body` mediumtext COMMENT '原始响应内容',
+  `create_date` varchar(20) NOT NULL COMMENT '首次请求日期',
+  `create_time` varchar(20) NOT NULL COMMENT '首次请求时间',
+  `lock_expire` varchar(20) NOT NULL COMMENT '处理中的锁定截止时间，超过后相同请求可以接管',
+  PRIMARY KEY (`ik_id`),
+  UNIQUE KEY `scope_key` (`scope`,`idem_key`),
+  KEY `create_date` (`create_date`,`create_time`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for incident_group
+-- ----------------------------
+DROP TABLE IF EXISTS `incident_group`;
+CREATE TABLE `incident_group` (
+  `ig_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '事件组编号',
+  `lead_af_id` int(11) NOT NULL COMMENT '首条反馈信息编号',
+  `province_id` int(11) NOT NULL COMMENT '所属省区域编号',
+  `city_id` int(11) NOT NULL COMMENT '所属市区域编号',
+  `create_date` varchar(20) NOT NULL COMMENT '创建日期',
+  `create_time` varchar(20) NOT NULL COMMENT '创建时间',
+  `state` int(11) NOT NULL DEFAULT '0' COMMENT '事件组状态: 0:处理中; 1:已确认',
+  `statistics_id` int(11) NOT NULL DEFAULT '0' COMMENT '确认该事件组的实测数据编号',
+  `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
+  PRIMARY KEY (`ig_id`),
+  KEY `province_city` (`province_id`, `city_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for member_attendance
+-- ----------------------------
+DROP TABLE IF EXISTS `member_attendance`;
+CREATE TABLE `member_attendance` (
+  `att_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '考勤记录编号',
+  `gm_id` int(11) NOT NULL COMMENT '网格员编号',
+  `shift_id` int(11) NOT NULL DEFAULT '0' COMMENT '对应的班次编号（0表示班次外签到）',
+  `check_in_date` varchar(20) NOT NULL COMMENT '签到日期',
+  `check_in_time` varchar(20) NOT NULL COMMENT '签到时间',
+  `check_out_date` varchar(20) DEFAULT NULL COMMENT '签退日期（未签退为NULL）',
+  `check_out_time` varchar(20) DEFAULT NULL COMMENT '签退时间（未签退为NULL）',
+  `latitude` decimal(10,7) DEFAULT NULL COMMENT '签到位置纬度',
+  `longitude` decimal(10,7) DEFAULT NULL COMMENT '签到位置经度',
+  `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
+  PRIMARY KEY (`att_id`),
+  KEY `gm_id` (`gm_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for member_leave
+-- ----------------------------
+DROP TABLE IF EXISTS `member_leave`;
+CREATE TABLE `member_leave` (
+  `leave_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '请假申请编号',
+  `gm_id` int(11) NOT NULL COMMENT '网格员编号',
+  `leave_type` int(11) NOT NULL DEFAULT '1' COMMENT '请假类型: 1:事假; 2:病假; 3:年假; 4:其他',
+  `start_date` varchar(20) NOT NULL COMMENT '开始日期',
+  `start_time` varchar(20) NOT NULL COMMENT '开始时间',
+  `end_date` varchar(20) NOT NULL COMMENT '结束日期',
+  `end_time` varchar(20) NOT NULL COMMENT '结束时间',
+  `reason` varchar(400) NOT NULL COMMENT '请假事由',
+  `state` int(11) NOT NULL DEFAULT '0' COMMENT '审批状态: 0:待审批; 1:已批准; 2:已驳回; 3:已取消',
+  `apply_date` varchar(20) NOT NULL COMMENT '申请日期',
+  `apply_time` varchar(20) NOT NULL COMMENT '申请时间',
+  `admin_id` int(11) DEFAULT NULL COMMENT '审批管理员编号',
+  `review_date` varchar(20) DEFAULT NULL COMMENT '审批日期',
+  `review_time` varchar(20) DEFAULT NULL COMMENT '审批时间',
+  `review_remarks` varchar(200) DEFAULT NULL COMMENT '审批意见',
+  PRIMARY KEY (`leave_id`),
+  KEY `gm_id` (`gm_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for member_shift
+-- ----------------------------
+DROP TABLE IF EXISTS `member_shift`;
+CREATE TABLE `member_shift` (
+  `shift_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '班次编号',
+  `gm_id` int(11) NOT NULL COMMENT '网格员编号',
+  `shift_date` varchar(20) NOT NULL COMMENT '班次日期',
+  `start_time` varchar(20) NOT NULL COMMENT '上班时间',
+  `end_time` varchar(20) NOT NULL COMMENT '下班时间（早于上班时间表示次日下班）',
+  `admin_id` int(11) NOT NULL COMMENT '排班管理员编号',
+  `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
+  PRIMARY KEY (`shift_id`),
+  UNIQUE KEY `member_shift` (`gm_id`,`shift_date`,`start_time`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for notification
+-- ----------------------------
+DROP TABLE IF EXISTS `notification`;
+CREATE TABLE `notification` (
+  `nt_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '站内通知编号',
+  `user_type` varchar(20) NOT NULL COMMENT '接收人类型: admin, member, supervisor',
+  `user_id` varchar(20) NOT NULL COMMENT '接收人编号（admin_id、gm_id或监督员tel_id）',
+  `event` varchar(40) NOT NULL COMMENT '通知事件: task_assigned, feedback_confirmed, feedback_escalated, comment_added',
+  `title` varchar(200) NOT NULL COMMENT '通知标题',
+  `content` varchar(1000) NOT NULL COMMENT '通知内容',
+  `af_id` int(11) NOT NULL DEFAULT '0' COMMENT '关联的反馈信息编号（0表示无关联）',
+  `is_read` int(11) NOT NULL DEFAULT '0' COMMENT '是否已读（0:未读; 1:已读）',
+  `create_date` varchar(20) NOT NULL COMMENT '通知日期',
+  `create_time` varchar(20) NOT NULL COMMENT '通知时间',
+  `read_date` varchar(20) DEFAULT NULL COMMENT '阅读日期',
+  `read_time` varchar(20) DEFAULT NULL COMMENT '阅读时间',
+  PRIMARY KEY (`nt_id`),
+  KEY `user_read` (`user_type`,`user_id`,`is_read`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for notification_outbox
+-- ----------------------------
+DROP TABLE IF EXISTS `notification_outbox`;
+CREATE TABLE `notification_outbox` (
+  `no_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '通知编号',
+  `event` varchar(40) NOT NULL COMMENT '通知事件: task_assigned, feedback_confirmed, feedback_escalated',
+  `user_type` varchar(20) NOT NULL COMMENT '接收人类型: admin, member, supervisor',
+  `user_id` varchar(20) NOT NULL COMMENT '接收人编号（监督员为手机号码）',
+  `channel` varchar(20) NOT NULL COMMENT '发送渠道: sms, email, webhook',
+  `address` varchar(200) NOT NULL COMMENT '接收地址',
+  `locale` varchar(10) NOT NULL DEFAULT 'zh' COMMENT '语言: zh, en',
+  `subject` varchar(200) NOT NULL COMMENT '通知标题',
+  `content` varchar(1000) NOT NULL COMMENT '通知内容',
+  `af_id` int(11) NOT NULL DEFAULT '0' COMMENT '关联的反馈信息编号（0表示无关联）',
+  `state` int(11) NOT NULL DEFAULT '0' COMMENT '发送状态: 0:待发送; 1:已发送; 2:发送失败',
+  `attempts` int(11) NOT NULL DEFAULT '0' COMMENT '已尝试发送次数',
+  `next_date` varchar(20) NOT NULL COMMENT '下次发送日期',
+  `next_time` varchar(20) NOT NULL COMMENT '下次发送时间',
+  `last_error` varchar(500) DEFAULT NULL COMMENT '最近一次发送失败的原因',
+  `create_date` varchar(20) NOT NULL COMMENT '创建日期',
+  `create_time` varchar(20) NOT NULL COMMENT '创建时间',
+  `sent_date` varchar(20) DEFAULT NULL COMMENT '发送成功日期',
+  `sent_time` varchar(20) DEFAULT NULL COMMENT '发送成功时间',
+  PRIMARY KEY (`no_id`),
+  KEY `state_next` (`state`,`next_date`,`next_time`),
+  KEY `user` (`user_type`,`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for notification_preference
+-- ----------------------------
+DROP TABLE IF EXISTS `notification_preference`;
+CREATE TABLE `notification_preference` (
+  `np_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '通知偏好编号',
+  `user_type` varchar(20) NOT NULL COMMENT '用户类型: admin, member, supervisor',
+  `user_id` varchar(20) NOT NULL COMMENT '用户编号（监督员为手机号码）',
+  `channel` varchar(20) NOT NULL COMMENT '发送渠道: sms, email, webhook',
+  `address` varchar(200) NOT NULL COMMENT '接收地址（手机号、邮箱或回调地址）',
+  `locale` varchar(10) NOT NULL DEFAULT 'zh' COMMENT '语言: zh, en',
+  `enabled` int(11) NOT NULL DEFAULT '1' COMMENT '是否启用（1:启用; 0:停用）',
+  PRIMARY KEY (`np_id`),
+  UNIQUE KEY `user_channel` (`user_type`,`user_id`,`channel`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for sensitive_area
+-- ----------------------------
+DROP TABLE IF EXISTS `sensitive_area`;
+CREATE TABLE `sensitive_area` (
+  `sa_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '敏感区域编号',
+  `sa_name` varchar(50) NOT NULL COMMENT '敏感区域名称',
+  `sa_type` int(11) NOT NULL DEFAULT '1' COMMENT '敏感区域类型: 1:学校; 2:医院; 3:养老机构; 4:其他',
+  `province_id` int(11) NOT NULL COMMENT '所属省区域编号',
+  `city_id` int(11) NOT NULL COMMENT '所属市区域编号',
+  `latitude` decimal(10,7) NOT NULL COMMENT '纬度',
+  `longitude` decimal(10,7) NOT NULL COMMENT '经度',
+  `radius` int(11) NOT NULL DEFAULT '1000' COMMENT '影响半径（单位：米）',
+  `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
+  PRIMARY KEY (`sa_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
 -- ----------------------------
 -- Table structure for statistics
 -- ----------------------------
@@ -127,6 +582,17 @@ CREATE TABLE `statistics` (
   `fd_id` varchar(20) NOT NULL COMMENT '反馈者编号（公众监督员电话号码）',
   `information` varchar(400) NOT NULL COMMENT '反馈信息描述',
   `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
+  `af_id` int(11) NOT NULL DEFAULT '0' COMMENT '关联的反馈信息编号（0表示无关联反馈）',
+  `latitude` decimal(10,7) DEFAULT NULL COMMENT '实测位置纬度',
+  `longitude` decimal(10,7) DEFAULT NULL COMMENT '实测位置经度',
+  `location_accuracy` decimal(10,2) DEFAULT NULL COMMENT '实测定位精度（单位：米）',
+  `fence_distance` int(11) DEFAULT NULL COMMENT '实测位置与反馈位置的距离（单位：米）',
+  `fence_state` int(11) NOT NULL DEFAULT '0' COMMENT '位置校验状态: 0:无需校验; 1:范围内; 2:待审核; 3:审核通过; 4:审核驳回',
+  `fence_reviewer_id` int(11) DEFAULT NULL COMMENT '位置审核管理员编号',
+  `fence_review_date` varchar(20) DEFAULT NULL COMMENT '位置审核日期',
+  `fence_review_time` varchar(20) DEFAULT NULL COMMENT '位置审核时间',
+  `fence_review_remarks` varchar(200) DEFAULT NULL COMMENT '位置审核意见',
+  `superseded_af_id` int(11) NOT NULL DEFAULT '0' COMMENT '申诉重新处理后失效的原关联反馈编号（0表示未失效）',
   PRIMARY KEY (`id`)
 ) ENGINE=InnoDB AUTO_INCREMENT=43 DEFAULT CHARSET=utf8;
 
@@ -144,6 +610,91 @@ CREATE TABLE `supervisor` (
   PRIMARY KEY (`tel_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
+-- ----------------------------
+-- Table structure for sync_record
+-- ----------------------------
+DROP TABLE IF EXISTS `sync_record`;
+CREATE TABLE `sync_record` (
+  `sr_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '同步记录编号',
+  `gm_id` int(11) NOT NULL COMMENT '网格员编号',
+  `client_id` varchar(36) NOT NULL COMMENT '客户端生成的UUID',
+  `af_id` int(11) NOT NULL DEFAULT '0' COMMENT '关联的反馈信息编号（0表示无关联）',
+  `statistics_id` int(11) NOT NULL DEFAULT '0' COMMENT '生成的实测数据编号（0表示未生成）',
+  `device_date` varchar(20) NOT NULL COMMENT '设备上的测量日期',
+  `device_time` varchar(20) NOT NULL COMMENT '设备上的测量时间',
+  `sync_date` varchar(20) NOT NULL COMMENT '同步日期',
+  `sync_time` varchar(20) NOT NULL COMMENT '同步时间',
+  `result` int(11) NOT NULL DEFAULT '0' COMMENT '处理结果: 0:处理中; 1:已接收; 2:冲突; 3:无效',
+  `message` varchar(200) DEFAULT NULL COMMENT '处理说明',
+  PRIMARY KEY (`sr_id`),
+  UNIQUE KEY `gm_client` (`gm_id`,`client_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for task_event
+-- ----------------------------
+DROP TABLE IF EXISTS `task_event`;
+CREATE TABLE `task_event` (
+  `te_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '任务事件编号',
+  `af_id` int(11) NOT NULL COMMENT '反馈信息编号',
+  `gm_id` int(11) NOT NULL COMMENT '网格员编号',
+  `event_type` int(11) NOT NULL COMMENT '事件类型: 1:已接单; 2:出发前往; 3:到达现场; 4:完成实测',
+  `event_date` varchar(20) NOT NULL COMMENT '事件日期',
+  `event_time` varchar(20) NOT NULL COMMENT '事件时间',
+  `latitude` decimal(10,7) DEFAULT NULL COMMENT '事件发生位置纬度',
+  `longitude` decimal(10,7) DEFAULT NULL COMMENT '事件发生位置经度',
+  `location_accuracy` decimal(10,2) DEFAULT NULL COMMENT '定位精度（单位：米）',
+  `distance` int(11) DEFAULT NULL COMMENT '事件发生位置与反馈位置的距离（单位：米）',
+  `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
+  PRIMARY KEY (`te_id`),
+  KEY `af_id` (`af_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for webhook_delivery
+-- ----------------------------
+DROP TABLE IF EXISTS `webhook_delivery`;
+CREATE TABLE `webhook_delivery` (
+  `wd_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '投递编号',
+  `ws_id` int(11) NOT NULL COMMENT '回调订阅编号',
+  `event` varchar(40) NOT NULL COMMENT '事件类型: measurement_submitted, feedback_submitted, feedback_confirmed',
+  `payload` text NOT NULL COMMENT '回调内容（JSON）',
+  `state` int(11) NOT NULL DEFAULT '0' COMMENT '投递状态: 0:待投递; 1:已投递; 2:投递失败',
+  `attempts` int(11) NOT NULL DEFAULT '0' COMMENT '已尝试投递次数',
+  `next_date` varchar(20) NOT NULL COMMENT '下次投递日期',
+  `next_time` varchar(20) NOT NULL COMMENT '下次投递时间',
+  `response_code` int(11) NOT NULL DEFAULT '0' COMMENT '最近一次投递的响应状态码（0表示未收到响应）',
+  `last_error` varchar(500) DEFAULT NULL COMMENT '最近一次投递失败的原因',
+  `replay_of` int(11) NOT NULL DEFAULT '0' COMMENT '重放的原投递编号（0表示非重放）',
+  `create_date` varchar(20) NOT NULL COMMENT '创建日期',
+  `create_time` varchar(20) NOT NULL COMMENT '创建时间',
+  `delivered_date` varchar(20) DEFAULT NULL COMMENT '投递成功日期',
+  `delivered_time` varchar(20) DEFAULT NULL COMMENT '投递成功时间',
+  PRIMARY KEY (`wd_id`),
+  KEY `state_next` (`state`,`next_date`,`next_time`),
+  KEY `ws_id` (`ws_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- ----------------------------
+-- Table structure for webhook_subscription
+-- ----------------------------
+DROP TABLE IF EXISTS `webhook_subscription`;
+CREATE TABLE `webhook_subscription` (
+  `ws_id` int(11) NOT NULL AUTO_INCREMENT COMMENT '回调订阅编号',
+  `ws_name` varchar(50) NOT NULL COMMENT '订阅名称（如对接的单位）',
+  `url` varchar(500) NOT NULL COMMENT '回调地址',
+  `secret` varchar(100) NOT NULL COMMENT '签名密钥',
+  `events` varchar(200) NOT NULL COMMENT '订阅的事件类型，逗号分隔',
+  `province_id` int(11) NOT NULL DEFAULT '0' COMMENT '只接收该省份的事件（0表示全部省份）',
+  `min_aqi_level` int(11) NOT NULL DEFAULT '0' COMMENT '只接收AQI级别不低于该值的事件（0表示不限）',
+  `enabled` int(11) NOT NULL DEFAULT '1' COMMENT '是否启用（0:停用; 1:启用）',
+  `admin_id` int(11) NOT NULL COMMENT '创建的管理员编号',
+  `create_date` varchar(20) NOT NULL COMMENT '创建日期',
+  `create_time` varchar(20) NOT NULL COMMENT '创建时间',
+  `remarks` varchar(200) DEFAULT NULL COMMENT '备注',
+  PRIMARY KEY (`ws_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
 -- ----------------------------
 -- Records 
 -- ----------------------------
@@ -154,45 +705,60 @@ INSERT INTO `aqi` VALUES ('3', '三', '轻度污染', '#FF7E00', '易感人群
 INSERT INTO `aqi` VALUES ('4', '四', '中度污染', '#FE0000', '进一步加剧易感人群症状，可能对健康人群心脏、呼吸系统有影响', '儿童、老年人及心脏病、呼吸系统疾病患者避免长时间、高强度的户外锻练，一般人群适量减少户外运动', '476', '800', '36', '60', '116', '150', null);
 INSERT INTO `aqi` VALUES ('5', '五', '重度污染', '#98004B', '心脏病和肺病患者症状显著加剧，运动耐受力降低，健康人群普遍出现症状', '儿童、老年人和心脏病、肺病患者应停留在室内，停止户外运动，-般人群减少户外运动', '801', '1600', '61', '90', '151', '250', null);
 INSERT INTO `aqi` VALUES ('6', '六', '严重污染', '#7E0123', '健康人群运动耐受力降低，有明显强烈症状，提前出现某些疾病', '儿童、老年人和病人应当留在室内，避免体力消耗，一般人群应避免户外活动', '1601', '2620', '91', '150', '251', '500', null);
-INSERT INTO `aqi_feedback` VALUES ('1', '13147859658', '1', '1', '朝阳区建国路123号', '空气能见度不足，稍有异味。', '3', '2022-01-26', '09:28:04', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('2', '13245871254', '2', '2', '塘沽区延庆街乐亭理', '空气中似乎有粉尘，呼吸不畅，刺激。', '5', '2022-02-26', '09:32:16', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('3', '13369852458', '3', '3', '昌平区临西路45-69号', '月朦胧，鸟朦胧，空气雾霾浓。', '5', '2022-03-26', '09:36:12', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('4', '13512345678', '4', '4', '阳泉区天镇街平顺胡同', '空气污染严重～昨天洗的车子，今天一层灰～真心伤不起。', '6', '2022-04-26', '09:37:38', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('5', '13512345688', '5', '5', '巴彦淖尔区奈曼旗', '扬尘飞沙扑面来，泥土气息撞满怀。', '5', '2022-05-26', '09:38:45', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('6', '13545645612', '6', '6', '浑南区彩霞路彩霞社区', '花朦胧，叶朦胧，医院排长队。', '4', '2022-06-26', '09:40:02', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('7', '13566987452', '6', '17', '清原满族自治县迎宾路', '每天漫天灰尘，出门一分钟，回来一身灰。', '6', '2022-07-26', '09:41:01', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('8', '13655669988', '7', '7', '集安区白山街白城社区', '环境污染，全球变暖，钓鱼人的环境越来越差。', '3', '2022-08-26', '09:42:02', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('9', '13688998874', '8', '8', '双城区海林路五常里', '近年来空气污染越发严重，PM2.5值越来越高，眼睛经常有异物感。', '4', '2022-08-26', '09:43:20', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('10', '13758745632', '9', '9', '徐汇区明水路集贤里', '环境脏了，脏了的不仅是环境，更是心情。', '5', '2022-09-26', '09:44:19', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('11', '13847895623', '10', '10', '江都区杜尔伯特街456号', 'PM2.5值越来越高，眼睛经常有异物感，导致眼部发病率急剧升高。', '5', '2022-09-26', '10:03:17', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('12', '13900240032', '11', '11', '金湖区响水路东海社区', '呼吸的空气里面都带各种污染，环境真是让人堪忧哦！', '6', '2022-10-26', '10:04:35', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('13', '13954754744', '12', '12', '天台区宁海街道1-123-3号', '雾霾，我们的生活条件也在不断提高，但是生活的环境真是不尽人意。', '4', '2022-01-26', '10:05:34', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('14', '14555889874', '13', '13', '南平区无为路7-789-9号', '身边都是乌烟瘴气，烟雾缭绕可能一个字，都会成为最致命的“导火线”。', '6', '2022-02-26', '10:06:31', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('15', '14955226688', '14', '14', '高安区永丰路玉山社区', '一阵狂风破青天，所谓雾霾成云烟，万千人财不胜冷，吾辈环工情何堪。', '3', '2022-02-26', '10:08:01', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('16', '15353245698', '15', '15', '临淄区胶南街444号', '连日阴雨，空气潮湿，阴云沉沉，心事重重。', '3', '2022-03-26', '10:09:08', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('17', '15544523687', '16', '16', '修武区登封街新乡社区', '身边都是乌烟瘴气，烟雾缭绕。', '4', '2022-04-26', '10:10:55', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('18', '15560023569', '1', '1', '怀柔区北辰街道78号', '人类的环境破坏已经变得千疮百孔，保护大自然，人人有责。', '5', '2022-04-26', '10:13:41', '1', '2022-11-27', '11:03:29', '2', null);
-INSERT INTO `aqi_feedback` VALUES ('19', '15655881122', '9', '9', ' 巨鹿区灵寿路安国里', '环境被破坏，地球在哭嚎。本色皆可期，全靠你我他。', '3', '2022-05-26', '10:18:15', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('20', '15800556874', '11', '11', '盐山县南皮街道56号', '环境污染天天喊，其实污染在传染，污染水源植物减', '4', '2022-05-26', '10:19:25', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('21', '17345988896', '6', '6', '和平区盐泉路456号', '扬尘飞沙扑面来，泥土气息撞满怀。', '3', '2022-05-26', '10:20:29', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('22', '17522112211', '13', '13', '南和区邢台街好好社区', '穹顶之下，雾霾锁城。环境污染是一个摆在所有人面前的问题。', '5', '2022-06-26', '10:21:25', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('23', '17645614561', '6', '17', '东光区高峰会胡同', '月朦胧，鸟朦胧，空气雾霾浓。', '5', '2022-06-26', '10:22:25', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('24', '17733658965', '15', '15', '大城区文安路阳泉胡同', '天空灰蒙蒙的一片、空气里散发着刺鼻的味道，让人感到压抑。', '4', '2022-06-26', '10:23:44', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('25', '18065895234', '14', '14', '长治区阳高路421号', '呼吸的空气里面都带各种污染，环境真是让人堪忧哦！', '6', '2022-07-26', '10:24:40', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('26', '18165214789', '8', '8', '静乐区丰镇路789号', '沙尘风暴又雾霾，保护环境皆有责。', '3', '2022-07-26', '10:25:46', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('27', '18558743311', '16', '16', '杭锦旗土默特左旗乌拉特社区', '每天漫天灰尘，出门一分钟，回来一身灰。', '5', '2022-07-26', '10:27:16', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('28', '18655441236', '2', '2', '和龙区柳河街1-123-1号', '花朦胧，叶朦胧，医院排长队', '3', '2022-08-26', '10:28:26', '1', '2022-11-27', '11:04:08', '2', null);
-INSERT INTO `aqi_feedback` VALUES ('29', '18925321123', '4', '4', '孙吴区廉颇路李牧社区', 'PM2.5值越来越高，眼睛经常有异物感，导致眼部发病率急剧升高。', '4', '2022-08-26', '10:29:46', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('30', '13147859658', '11', '11', '尚志区友谊路友谊社区', '环境污染了，污染了的不仅是环境，更是健康。', '5', '2022-08-26', '10:32:34', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('31', '13245871254', '10', '10', '仙居区仙女路仙人社区', '环境污染，全球变暖，钓鱼人的环境越来越差。', '3', '2022-09-26', '10:33:58', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('32', '13369852458', '8', '8', '界首区阜南街霍山街道', '一阵狂风破青天，所谓雾霾成云烟。', '5', '2022-09-26', '10:35:12', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('33', '13512345678', '16', '16', '肥西区分东路费义里', '清晨雾蒙蒙，世间万物皆胧罩，恰似人间仙境，雾霾满城，活吞天地，繁华遮尽，唯有心近。', '4', '2022-09-26', '10:36:56', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('34', '13512345688', '9', '9', '浦东区玉环路4-56-4号', '连日阴雨，空气潮湿，阴云沉沉，心事重重。', '4', '2022-09-26', '10:37:56', '9', '2022-11-25', '12:52:56', '1', null);
-INSERT INTO `aqi_feedback` VALUES ('35', '13545645612', '4', '4', '庆元区景宁畲族自治县', '如果地球生态失衡，自然灾害就会增多。', '3', '2022-10-26', '10:39:13', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('36', '13566987452', '10', '10', '明光区六安路五河社区', '地球在哭泣，恶劣天气频现，全球气候变暖，爱护我们的自然环境。', '6', '2022-10-26', '10:39:57', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('37', '13655669988', '12', '12', '建瓯区邵武大街7-8-9号', '起起伏伏，跌跌荡荡。归于平静，波澜不惊。', '5', '2022-10-26', '10:41:05', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('38', '13688998874', '6', '17', '甘井子区凌风街乘风社区', '月黑风高，空气浑浊，难道是杀人夜？', '4', '2022-10-27', '16:29:26', '0', null, null, '0', null);
-INSERT INTO `aqi_feedback` VALUES ('39', '13758745632', '4', '4', '西山区解放大路1-258-6号', '雾朦胧，鸟朦胧，一切都朦胧。', '3', '2022-11-03', '11:09:09', '4', '2022-11-25', '12:31:25', '1', null);
+INSERT INTO `aqi_feedback` VALUES ('1', '13147859658', '1', '1', '朝阳区建国路123号', '空气能见度不足，稍有异味。', '3', '2022-01-26', '09:28:04', '0', null, null, '0', null, null, null, null, '0', '2022-01-26 09:28:04', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('2', '13245871254', '2', '2', '塘沽区延庆街乐亭理', '空气中似乎有粉尘，呼吸不畅，刺激。', '5', '2022-02-26', '09:32:16', '0', null, null, '0', null, null, null, null, '0', '2022-02-26 09:32:16', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('3', '13369852458', '3', '3', '昌平区临西路45-69号', '月朦胧，鸟朦胧，空气雾霾浓。', '5', '2022-03-26', '09:36:12', '0', null, null, '0', null, null, null, null, '0', '2022-03-26 09:36:12', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('4', '13512345678', '4', '4', '阳泉区天镇街平顺胡同', '空气污染严重～昨天洗的车子，今天一层灰～真心伤不起。', '6', '2022-04-26', '09:37:38', '0', null, null, '0', null, null, null, null, '0', '2022-04-26 09:37:38', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('5', '13512345688', '5', '5', '巴彦淖尔区奈曼旗', '扬尘飞沙扑面来，泥土气息撞满怀。', '5', '2022-05-26', '09:38:45', '0', null, null, '0', null, null, null, null, '0', '2022-05-26 09:38:45', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('6', '13545645612', '6', '6', '浑南区彩霞路彩霞社区', '花朦胧，叶朦胧，医院排长队。', '4', '2022-06-26', '09:40:02', '0', null, null, '0', null, null, null, null, '0', '2022-06-26 09:40:02', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('7', '13566987452', '6', '17', '清原满族自治县迎宾路', '每天漫天灰尘，出门一分钟，回来一身灰。', '6', '2022-07-26', '09:41:01', '0', null, null, '0', null, null, null, null, '0', '2022-07-26 09:41:01', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('8', '13655669988', '7', '7', '集安区白山街白城社区', '环境污染，全球变暖，钓鱼人的环境越来越差。', '3', '2022-08-26', '09:42:02', '0', null, null, '0', null, null, null, null, '0', '2022-08-26 09:42:02', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('9', '13688998874', '8', '8', '双城区海林路五常里', '近年来空气污染越发严重，PM2.5值越来越高，眼睛经常有异物感。', '4', '2022-08-26', '09:43:20', '0', null, null, '0', null, null, null, null, '0', '2022-08-26 09:43:20', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('10', '13758745632', '9', '9', '徐汇区明水路集贤里', '环境脏了，脏了的不仅是环境，更是心情。', '5', '2022-09-26', '09:44:19', '0', null, null, '0', null, null, null, null, '0', '2022-09-26 09:44:19', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('11', '13847895623', '10', '10', '江都区杜尔伯特街456号', 'PM2.5值越来越高，眼睛经常有异物感，导致眼部发病率急剧升高。', '5', '2022-09-26', '10:03:17', '0', null, null, '0', null, null, null, null, '0', '2022-09-26 10:03:17', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('12', '13900240032', '11', '11', '金湖区响水路东海社区', '呼吸的空气里面都带各种污染，环境真是让人堪忧哦！', '6', '2022-10-26', '10:04:35', '0', null, null, '0', null, null, null, null, '0', '2022-10-26 10:04:35', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('13', '13954754744', '12', '12', '天台区宁海街道1-123-3号', '雾霾，我们的生活条件也在不断提高，但是生活的环境真是不尽人意。', '4', '2022-01-26', '10:05:34', '0', null, null, '0', null, null, null, null, '0', '2022-01-26 10:05:34', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('14', '14555889874', '13', '13', '南平区无为路7-789-9号', '身边都是乌烟瘴气，烟雾缭绕可能一个字，都会成为最致命的“导火线”。', '6', '2022-02-26', '10:06:31', '0', null, null, '0', null, null, null, null, '0', '2022-02-26 10:06:31', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('15', '14955226688', '14', '14', '高安区永丰路玉山社区', '一阵狂风破青天，所谓雾霾成云烟，万千人财不胜冷，吾辈环工情何堪。', '3', '2022-02-26', '10:08:01', '0', null, null, '0', null, null, null, null, '0', '2022-02-26 10:08:01', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('16', '15353245698', '15', '15', '临淄区胶南街444号', '连日阴雨，空气潮湿，阴云沉沉，心事重重。', '3', '2022-03-26', '10:09:08', '0', null, null, '0', null, null, null, null, '0', '2022-03-26 10:09:08', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('17', '15544523687', '16', '16', '修武区登封街新乡社区', '身边都是乌烟瘴气，烟雾缭绕。', '4', '2022-04-26', '10:10:55', '0', null, null, '0', null, null, null, null, '0', '2022-04-26 10:10:55', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('18', '15560023569', '1', '1', '怀柔区北辰街道78号', '人类的环境破坏已经变得千疮百孔，保护大自然，人人有责。', '5', '2022-04-26', '10:13:41', '1', '2022-11-27', '11:03:29', '2', null, null, null, null, '0', '2022-04-26 10:13:41', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('19', '15655881122', '9', '9', ' 巨鹿区灵寿路安国里', '环境被破坏，地球在哭嚎。本色皆可期，全靠你我他。', '3', '2022-05-26', '10:18:15', '0', null, null, '0', null, null, null, null, '0', '2022-05-26 10:18:15', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('20', '15800556874', '11', '11', '盐山县南皮街道56号', '环境污染天天喊，其实污染在传染，污染水源植物减', '4', '2022-05-26', '10:19:25', '0', null, null, '0', null, null, null, null, '0', '2022-05-26 10:19:25', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('21', '17345988896', '6', '6', '和平区盐泉路456号', '扬尘飞沙扑面来，泥土气息撞满怀。', '3', '2022-05-26', '10:20:29', '0', null, null, '0', null, null, null, null, '0', '2022-05-26 10:20:29', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('22', '17522112211', '13', '13', '南和区邢台街好好社区', '穹顶之下，雾霾锁城。环境污染是一个摆在所有人面前的问题。', '5', '2022-06-26', '10:21:25', '0', null, null, '0', null, null, null, null, '0', '2022-06-26 10:21:25', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('23', '17645614561', '6', '17', '东光区高峰会胡同', '月朦胧，鸟朦胧，空气雾霾浓。', '5', '2022-06-26', '10:22:25', '0', null, null, '0', null, null, null, null, '0', '2022-06-26 10:22:25', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('24', '17733658965', '15', '15', '大城区文安路阳泉胡同', '天空灰蒙蒙的一片、空气里散发着刺鼻的味道，让人感到压抑。', '4', '2022-06-26', '10:23:44', '0', null, null, '0', null, null, null, null, '0', '2022-06-26 10:23:44', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('25', '18065895234', '14', '14', '长治区阳高路421号', '呼吸的空气里面都带各种污染，环境真是让人堪忧哦！', '6', '2022-07-26', '10:24:40', '0', null, null, '0', null, null, null, null, '0', '2022-07-26 10:24:40', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('26', '18165214789', '8', '8', '静乐区丰镇路789号', '沙尘风暴又雾霾，保护环境皆有责。', '3', '2022-07-26', '10:25:46', '0', null, null, '0', null, null, null, null, '0', '2022-07-26 10:25:46', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('27', '18558743311', '16', '16', '杭锦旗土默特左旗乌拉特社区', '每天漫天灰尘，出门一分钟，回来一身灰。', '5', '2022-07-26', '10:27:16', '0', null, null, '0', null, null, null, null, '0', '2022-07-26 10:27:16', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('28', '18655441236', '2', '2', '和龙区柳河街1-123-1号', '花朦胧，叶朦胧，医院排长队', '3', '2022-08-26', '10:28:26', '1', '2022-11-27', '11:04:08', '2', null, null, null, null, '0', '2022-08-26 10:28:26', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('29', '18925321123', '4', '4', '孙吴区廉颇路李牧社区', 'PM2.5值越来越高，眼睛经常有异物感，导致眼部发病率急剧升高。', '4', '2022-08-26', '10:29:46', '0', null, null, '0', null, null, null, null, '0', '2022-08-26 10:29:46', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('30', '13147859658', '11', '11', '尚志区友谊路友谊社区', '环境污染了，污染了的不仅是环境，更是健康。', '5', '2022-08-26', '10:32:34', '0', null, null, '0', null, null, null, null, '0', '2022-08-26 10:32:34', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('31', '13245871254', '10', '10', '仙居区仙女路仙人社区', '环境污染，全球变暖，钓鱼人的环境越来越差。', '3', '2022-09-26', '10:33:58', '0', null, null, '0', null, null, null, null, '0', '2022-09-26 10:33:58', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('32', '13369852458', '8', '8', '界首区阜南街霍山街道', '一阵狂风破青天，所谓雾霾成云烟。', '5', '2022-09-26', '10:35:12', '0', null, null, '0', null, null, null, null, '0', '2022-09-26 10:35:12', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('33', '13512345678', '16', '16', '肥西区分东路费义里', '清晨雾蒙蒙，世间万物皆胧罩，恰似人间仙境，雾霾满城，活吞天地，繁华遮尽，唯有心近。', '4', '2022-09-26', '10:36:56', '0', null, null, '0', null, null, null, null, '0', '2022-09-26 10:36:56', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('34', '13512345688', '9', '9', '浦东区玉环路4-56-4号', '连日阴雨，空气潮湿，阴云沉沉，心事重重。', '4', '2022-09-26', '10:37:56', '9', '2022-11-25', '12:52:56', '1', null, null, null, null, '0', '2022-09-26 10:37:56', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('35', '13545645612', '4', '4', '庆元区景宁畲族自治县', '如果地球生态失衡，自然灾害就会增多。', '3', '2022-10-26', '10:39:13', '0', null, null, '0', null, null, null, null, '0', '2022-10-26 10:39:13', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('36', '13566987452', '10', '10', '明光区六安路五河社区', '地球在哭泣，恶劣天气频现，全球气候变暖，爱护我们的自然环境。', '6', '2022-10-26', '10:39:57', '0', null, null, '0', null, null, null, null, '0', '2022-10-26 10:39:57', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('37', '13655669988', '12', '12', '建瓯区邵武大街7-8-9号', '起起伏伏，跌跌荡荡。归于平静，波澜不惊。', '5', '2022-10-26', '10:41:05', '0', null, null, '0', null, null, null, null, '0', '2022-10-26 10:41:05', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('38', '13688998874', '6', '17', '甘井子区凌风街乘风社区', '月黑风高，空气浑浊，难道是杀人夜？', '4', '2022-10-27', '16:29:26', '0', null, null, '0', null, null, null, null, '0', '2022-10-27 16:29:26', '0', null);
+INSERT INTO `aqi_feedback` VALUES ('39', '13758745632', '4', '4', '西山区解放大路1-258-6号', '雾朦胧，鸟朦胧，一切都朦胧。', '3', '2022-11-03', '11:09:09', '4', '2022-11-25', '12:31:25', '1', null, null, null, null, '0', '2022-11-03 11:09:09', '0', null);
+INSERT INTO `compliance_standard` VALUES ('aqi', '3', null);
+INSERT INTO `compliance_standard` VALUES ('co', '3', null);
+INSERT INTO `compliance_standard` VALUES ('so2', '3', null);
+INSERT INTO `compliance_standard` VALUES ('spm', '3', null);
+INSERT INTO `dispatch_weight` VALUES ('cluster', '15.00', null);
+INSERT INTO `dispatch_weight` VALUES ('grade', '40.00', null);
+INSERT INTO `dispatch_weight` VALUES ('sensitive', '10.00', null);
+INSERT INTO `dispatch_weight` VALUES ('trust', '20.00', null);
+INSERT INTO `dispatch_weight` VALUES ('waiting', '15.00', null);
+INSERT INTO `feedback_sla` VALUES ('1', '1440', '4320', null);
+INSERT INTO `feedback_sla` VALUES ('2', '1440', '4320', null);
+INSERT INTO `feedback_sla` VALUES ('3', '720', '2880', null);
+INSERT INTO `feedback_sla` VALUES ('4', '240', '1440', null);
+INSERT INTO `feedback_sla` VALUES ('5', '120', '1440', null);
+INSERT INTO `feedback_sla` VALUES ('6', '60', '1440', null);
 INSERT INTO `grid_city` VALUES ('1', '北京市', '1', null);
 INSERT INTO `grid_city` VALUES ('2', '天津市', '2', null);
 INSERT INTO `grid_city` VALUES ('3', '石家庄市', '3', null);
@@ -260,45 +826,45 @@ INSERT INTO `grid_province` VALUES ('13', '福建省', '闽', null);
 INSERT INTO `grid_province` VALUES ('14', '江西省', '赣', null);
 INSERT INTO `grid_province` VALUES ('15', '山东省', '鲁', null);
 INSERT INTO `grid_province` VALUES ('16', '河南省', '豫', null);
-INSERT INTO `statistics` VALUES ('1', '1', '1', '怀柔区北辰街道78号', '425', '3', '42', '4', '56', '2', '4', '2022-04-26', '11:09:31', '1', '15560023569', '人类的环境破坏已经变得千疮百孔，保护大自然，人人有责。', null);
-INSERT INTO `statistics` VALUES ('2', '1', '1', '朝阳区建国路123号', '123', '2', '23', '3', '144', '4', '4', '2022-01-26', '11:16:08', '1', '13045825698', '空气能见度不足，稍有异味。', null);
-INSERT INTO `statistics` VALUES ('3', '2', '2', '和龙区柳河街1-123-1号', '124', '2', '6', '2', '45', '2', '2', '2022-08-26', '11:19:19', '2', '18655441236', '花朦胧，叶朦胧，医院排长队', null);
-INSERT INTO `statistics` VALUES ('4', '2', '2', '塘沽区延庆街乐亭理', '111', '2', '61', '5', '89', '3', '5', '2022-02-26', '11:19:56', '2', '13147859658', '空气中似乎有粉尘，呼吸不畅，刺激。', null);
-INSERT INTO `statistics` VALUES ('5', '3', '3', '昌平区临西路45-69号', '836', '5', '6', '2', '200', '5', '5', '2022-03-26', '11:21:38', '3', '13245871254', '月朦胧，鸟朦胧，空气雾霾浓。', null);
-INSERT INTO `statistics` VALUES ('6', '4', '4', '庆元区景宁畲族自治县', '566', '4', '12', '3', '44', '2', '4', '2022-10-26', '11:22:47', '4', '13369852458', '如果地球生态失衡，自然灾害就会增多。', null);
-INSERT INTO `statistics` VALUES ('7', '4', '4', '孙吴区廉颇路李牧社区', '78', '2', '56', '4', '111', '3', '4', '2022-08-26', '11:23:16', '4', '18925321123', 'PM2.5值越来越高，眼睛经常有异物感，导致眼部发病率急剧升高。', null);
-INSERT INTO `statistics` VALUES ('8', '4', '4', '阳泉区天镇街平顺胡同', '564', '4', '222', '6', '78', '3', '6', '2022-04-26', '11:23:48', '4', '13369852458', '空气污染严重～昨天洗的车子，今天一层灰～真心伤不起。', null);
-INSERT INTO `statistics` VALUES ('9', '5', '5', '巴彦淖尔区奈曼旗', '456', '3', '56', '4', '23', '1', '4', '2022-05-26', '11:26:21', '5', '13545645612', '扬尘飞沙扑面来，泥土气息撞满怀。', null);
-INSERT INTO `statistics` VALUES ('10', '6', '6', '浑南区彩霞路彩霞社区', '122', '2', '12', '2', '23', '1', '2', '2022-06-26', '11:27:50', '6', '13566987452', '花朦胧，叶朦胧，医院排长队。', null);
-INSERT INTO `statistics` VALUES ('11', '7', '7', '集安区白山街白城社区', '123', '2', '23', '3', '102', '3', '3', '2022-08-26', '11:28:42', '7', '13688998874', '环境污染，全球变暖，钓鱼人的环境越来越差。', null);
-INSERT INTO `statistics` VALUES ('12', '8', '8', '界首区阜南街霍山街道', '456', '3', '86', '5', '45', '2', '5', '2022-09-26', '11:29:30', '8', '13045825698', '一阵狂风破青天，所谓雾霾成云烟。', null);
-INSERT INTO `statistics` VALUES ('13', '8', '8', '双城区海林路五常里', '456', '3', '7', '2', '123', '4', '4', '2022-08-26', '11:29:59', '8', '13758745632', '近年来空气污染越发严重，PM2.5值越来越高，眼睛经常有异物感。', null);
-INSERT INTO `statistics` VALUES ('14', '8', '8', '静乐区丰镇路789号', '56', '2', '13', '3', '45', '2', '3', '2022-07-26', '11:30:30', '8', '18165214789', '沙尘风暴又雾霾，保护环境皆有责。', null);
-INSERT INTO `statistics` VALUES ('15', '9', '9', '浦东区玉环路4-56-4号', '456', '3', '7', '2', '178', '5', '5', '2022-09-26', '11:31:23', '9', '13245871254', '连日阴雨，空气潮湿，阴云沉沉，心事重重。', null);
-INSERT INTO `statistics` VALUES ('16', '9', '9', '徐汇区明水路集贤里', '566', '4', '6', '2', '77', '3', '4', '2022-09-26', '11:31:53', '9', '13847895623', '环境脏了，脏了的不仅是环境，更是心情。', null);
-INSERT INTO `statistics` VALUES ('17', '9', '9', '巨鹿区灵寿路安国里', '56', '2', '13', '3', '100', '3', '3', '2022-05-26', '11:32:28', '9', '15655881122', '环境被破坏，地球在哭嚎。本色皆可期，全靠你我他。', null);
-INSERT INTO `statistics` VALUES ('18', '10', '10', '明光区六安路五河社区', '2234', '6', '5', '1', '456', '6', '6', '2022-10-26', '11:33:43', '10', '13545645612', '地球在哭泣，恶劣天气频现，全球气候变暖，爱护我们的自然环境。', null);
-INSERT INTO `statistics` VALUES ('19', '10', '10', '江都区杜尔伯特街456号', '566', '4', '86', '5', '123', '4', '5', '2022-09-26', '11:34:16', '10', '13900240032', 'PM2.5值越来越高，眼睛经常有异物感，导致眼部发病率急剧升高。', null);
-INSERT INTO `statistics` VALUES ('20', '11', '11', '盐山县南皮街道56号', '566', '4', '9', '2', '145', '4', '4', '2022-05-26', '11:35:12', '11', '15800556874', '环境污染天天喊，其实污染在传染，污染水源植物减', null);
-INSERT INTO `statistics` VALUES ('21', '12', '12', '建瓯区邵武大街7-8-9号', '789', '4', '12', '3', '245', '5', '5', '2022-10-26', '11:36:08', '12', '13566987452', '起起伏伏，跌跌荡荡。归于平静，波澜不惊。', null);
-INSERT INTO `statistics` VALUES ('22', '12', '12', '天台区宁海街道1-123-3号', '568', '4', '56', '4', '111', '3', '4', '2022-01-26', '11:36:34', '12', '14555889874', '雾霾，我们的生活条件也在不断提高，但是生活的环境真是不尽人意。', null);
-INSERT INTO `statistics` VALUES ('23', '13', '13', '南和区邢台街好好社区', '1589', '5', '66', '5', '123', '4', '5', '2022-06-26', '11:40:26', '13', '17522112211', '穹顶之下，雾霾锁城。环境污染是一个摆在所有人面前的问题。', null);
-INSERT INTO `statistics` VALUES ('24', '13', '13', '南平区无为路7-789-9号', '456', '3', '156', '6', '145', '4', '6', '2022-02-26', '11:41:15', '13', '14955226688', '身边都是乌烟瘴气，烟雾缭绕可能一个字，都会成为最致命的“导火线”。', null);
-INSERT INTO `statistics` VALUES ('25', '14', '14', '长治区阳高路421号', '789', '4', '5', '1', '1234', '6', '6', '2022-07-26', '11:42:37', '14', '18065895234', '呼吸的空气里面都带各种污染，环境真是让人堪忧哦！', null);
-INSERT INTO `statistics` VALUES ('26', '14', '14', '高安区永丰路玉山社区', '456', '3', '12', '3', '45', '2', '3', '2022-02-26', '11:43:10', '14', '15353245698', '一阵狂风破青天，所谓雾霾成云烟，万千人财不胜冷，吾辈环工情何堪。', null);
-INSERT INTO `statistics` VALUES ('27', '15', '15', '大城区文安路阳泉胡同', '123', '2', '56', '4', '89', '3', '4', '2022-06-26', '11:44:00', '15', '17733658965', '天空灰蒙蒙的一片、空气里散发着刺鼻的味道，让人感到压抑。', null);
-INSERT INTO `statistics` VALUES ('28', '15', '15', '临淄区胶南街444号', '196', '3', '13', '3', '100', '3', '3', '2022-03-26', '11:44:36', '15', '15544523687', '连日阴雨，空气潮湿，阴云沉沉，心事重重。', null);
-INSERT INTO `statistics` VALUES ('29', '16', '16', '肥西区分东路费义里', '100', '2', '45', '4', '123', '4', '4', '2022-09-26', '12:51:39', '16', '13147859658', '清晨雾蒙蒙，世间万物皆胧罩，恰似人间仙境，雾霾满城，活吞天地...', null);
-INSERT INTO `statistics` VALUES ('30', '16', '16', '杭锦旗土默特左旗乌拉特社区', '123', '2', '56', '4', '245', '5', '5', '2022-07-26', '12:52:18', '16', '18558743311', '每天漫天灰尘，出门一分钟，回来一身灰。', null);
-INSERT INTO `statistics` VALUES ('31', '16', '16', '修武区登封街新乡社区', '455', '3', '12', '3', '123', '4', '4', '2022-04-26', '13:23:20', '16', '15544523687', '身边都是乌烟瘴气，烟雾缭绕。', null);
-INSERT INTO `statistics` VALUES ('32', '6', '6', '和平区盐泉路456号', '223', '3', '8', '2', '78', '3', '3', '2022-05-26', '13:24:14', '17', '17345988896', '扬尘飞沙扑面来，泥土气息撞满怀。', null);
-INSERT INTO `statistics` VALUES ('33', '6', '17', '东光区高峰会胡同', '456', '3', '45', '4', '78', '3', '4', '2022-06-26', '13:24:58', '18', '17645614561', '月朦胧，鸟朦胧，空气雾霾浓。', null);
-INSERT INTO `statistics` VALUES ('34', '6', '17', '清原满族自治县迎宾路', '456', '3', '235', '6', '156', '5', '6', '2022-07-26', '13:26:14', '20', '13655669988', '每天漫天灰尘，出门一分钟，回来一身灰。', null);
-INSERT INTO `statistics` VALUES ('35', '10', '10', '仙居区仙女路仙人社区', '455', '3', '6', '2', '89', '3', '3', '2022-09-26', '13:28:09', '32', '13147859658', '环境污染，全球变暖，钓鱼人的环境越来越差。', null);
-INSERT INTO `statistics` VALUES ('36', '11', '11', '金湖区响水路东海社区', '456', '3', '78', '5', '365', '6', '6', '2022-10-26', '13:28:50', '33', '13954754744', '呼吸的空气里面都带各种污染，环境真是让人堪忧哦！', null);
-INSERT INTO `statistics` VALUES ('37', '11', '11', '尚志区友谊路友谊社区', '897', '5', '23', '3', '156', '5', '5', '2022-08-26', '13:29:25', '33', '13045825698', '环境污染了，污染了的不仅是环境，更是健康。', null);
-INSERT INTO `statistics` VALUES ('38', '6', '17', '甘井子区凌风街乘风社区', '564', '4', '25', '3', '123', '4', '4', '2022-10-27', '17:01:52', '21', '17345988896', '月黑风高，空气浑浊，难道是杀人夜？', null);
-INSERT INTO `statistics` VALUES ('39', '4', '4', '西山区解放大路1-258-6号', '56', '2', '23', '3', '78', '3', '3', '2022-11-03', '11:10:57', '4', '17645614561', '雾朦胧，鸟朦胧，一切都朦胧。', null);
+INSERT INTO `statistics` VALUES ('1', '1', '1', '怀柔区北辰街道78号', '425', '3', '42', '4', '56', '2', '4', '2022-04-26', '11:09:31', '1', '15560023569', '人类的环境破坏已经变得千疮百孔，保护大自然，人人有责。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('2', '1', '1', '朝阳区建国路123号', '123', '2', '23', '3', '144', '4', '4', '2022-01-26', '11:16:08', '1', '13045825698', '空气能见度不足，稍有异味。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('3', '2', '2', '和龙区柳河街1-123-1号', '124', '2', '6', '2', '45', '2', '2', '2022-08-26', '11:19:19', '2', '18655441236', '花朦胧，叶朦胧，医院排长队', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('4', '2', '2', '塘沽区延庆街乐亭理', '111', '2', '61', '5', '89', '3', '5', '2022-02-26', '11:19:56', '2', '13147859658', '空气中似乎有粉尘，呼吸不畅，刺激。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('5', '3', '3', '昌平区临西路45-69号', '836', '5', '6', '2', '200', '5', '5', '2022-03-26', '11:21:38', '3', '13245871254', '月朦胧，鸟朦胧，空气雾霾浓。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('6', '4', '4', '庆元区景宁畲族自治县', '566', '4', '12', '3', '44', '2', '4', '2022-10-26', '11:22:47', '4', '13369852458', '如果地球生态失衡，自然灾害就会增多。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('7', '4', '4', '孙吴区廉颇路李牧社区', '78', '2', '56', '4', '111', '3', '4', '2022-08-26', '11:23:16', '4', '18925321123', 'PM2.5值越来越高，眼睛经常有异物感，导致眼部发病率急剧升高。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('8', '4', '4', '阳泉区天镇街平顺胡同', '564', '4', '222', '6', '78', '3', '6', '2022-04-26', '11:23:48', '4', '13369852458', '空气污染严重～昨天洗的车子，今天一层灰～真心伤不起。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('9', '5', '5', '巴彦淖尔区奈曼旗', '456', '3', '56', '4', '23', '1', '4', '2022-05-26', '11:26:21', '5', '13545645612', '扬尘飞沙扑面来，泥土气息撞满怀。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('10', '6', '6', '浑南区彩霞路彩霞社区', '122', '2', '12', '2', '23', '1', '2', '2022-06-26', '11:27:50', '6', '13566987452', '花朦胧，叶朦胧，医院排长队。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('11', '7', '7', '集安区白山街白城社区', '123', '2', '23', '3', '102', '3', '3', '2022-08-26', '11:28:42', '7', '13688998874', '环境污染，全球变暖，钓鱼人的环境越来越差。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('12', '8', '8', '界首区阜南街霍山街道', '456', '3', '86', '5', '45', '2', '5', '2022-09-26', '11:29:30', '8', '13045825698', '一阵狂风破青天，所谓雾霾成云烟。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('13', '8', '8', '双城区海林路五常里', '456', '3', '7', '2', '123', '4', '4', '2022-08-26', '11:29:59', '8', '13758745632', '近年来空气污染越发严重，PM2.5值越来越高，眼睛经常有异物感。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('14', '8', '8', '静乐区丰镇路789号', '56', '2', '13', '3', '45', '2', '3', '2022-07-26', '11:30:30', '8', '18165214789', '沙尘风暴又雾霾，保护环境皆有责。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('15', '9', '9', '浦东区玉环路4-56-4号', '456', '3', '7', '2', '178', '5', '5', '2022-09-26', '11:31:23', '9', '13245871254', '连日阴雨，空气潮湿，阴云沉沉，心事重重。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('16', '9', '9', '徐汇区明水路集贤里', '566', '4', '6', '2', '77', '3', '4', '2022-09-26', '11:31:53', '9', '13847895623', '环境脏了，脏了的不仅是环境，更是心情。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('17', '9', '9', '巨鹿区灵寿路安国里', '56', '2', '13', '3', '100', '3', '3', '2022-05-26', '11:32:28', '9', '15655881122', '环境被破坏，地球在哭嚎。本色皆可期，全靠你我他。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('18', '10', '10', '明光区六安路五河社区', '2234', '6', '5', '1', '456', '6', '6', '2022-10-26', '11:33:43', '10', '13545645612', '地球在哭泣，恶劣天气频现，全球气候变暖，爱护我们的自然环境。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('19', '10', '10', '江都区杜尔伯特街456号', '566', '4', '86', '5', '123', '4', '5', '2022-09-26', '11:34:16', '10', '13900240032', 'PM2.5值越来越高，眼睛经常有异物感，导致眼部发病率急剧升高。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('20', '11', '11', '盐山县南皮街道56号', '566', '4', '9', '2', '145', '4', '4', '2022-05-26', '11:35:12', '11', '15800556874', '环境污染天天喊，其实污染在传染，污染水源植物减', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('21', '12', '12', '建瓯区邵武大街7-8-9号', '789', '4', '12', '3', '245', '5', '5', '2022-10-26', '11:36:08', '12', '13566987452', '起起伏伏，跌跌荡荡。归于平静，波澜不惊。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('22', '12', '12', '天台区宁海街道1-123-3号', '568', '4', '56', '4', '111', '3', '4', '2022-01-26', '11:36:34', '12', '14555889874', '雾霾，我们的生活条件也在不断提高，但是生活的环境真是不尽人意。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('23', '13', '13', '南和区邢台街好好社区', '1589', '5', '66', '5', '123', '4', '5', '2022-06-26', '11:40:26', '13', '17522112211', '穹顶之下，雾霾锁城。环境污染是一个摆在所有人面前的问题。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('24', '13', '13', '南平区无为路7-789-9号', '456', '3', '156', '6', '145', '4', '6', '2022-02-26', '11:41:15', '13', '14955226688', '身边都是乌烟瘴气，烟雾缭绕可能一个字，都会成为最致命的“导火线”。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('25', '14', '14', '长治区阳高路421号', '789', '4', '5', '1', '1234', '6', '6', '2022-07-26', '11:42:37', '14', '18065895234', '呼吸的空气里面都带各种污染，环境真是让人堪忧哦！', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('26', '14', '14', '高安区永丰路玉山社区', '456', '3', '12', '3', '45', '2', '3', '2022-02-26', '11:43:10', '14', '15353245698', '一阵狂风破青天，所谓雾霾成云烟，万千人财不胜冷，吾辈环工情何堪。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('27', '15', '15', '大城区文安路阳泉胡同', '123', '2', '56', '4', '89', '3', '4', '2022-06-26', '11:44:00', '15', '17733658965', '天空灰蒙蒙的一片、空气里散发着刺鼻的味道，让人感到压抑。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('28', '15', '15', '临淄区胶南街444号', '196', '3', '13', '3', '100', '3', '3', '2022-03-26', '11:44:36', '15', '15544523687', '连日阴雨，空气潮湿，阴云沉沉，心事重重。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('29', '16', '16', '肥西区分东路费义里', '100', '2', '45', '4', '123', '4', '4', '2022-09-26', '12:51:39', '16', '13147859658', '清晨雾蒙蒙，世间万物皆胧罩，恰似人间仙境，雾霾满城，活吞天地...', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('30', '16', '16', '杭锦旗土默特左旗乌拉特社区', '123', '2', '56', '4', '245', '5', '5', '2022-07-26', '12:52:18', '16', '18558743311', '每天漫天灰尘，出门一分钟，回来一身灰。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('31', '16', '16', '修武区登封街新乡社区', '455', '3', '12', '3', '123', '4', '4', '2022-04-26', '13:23:20', '16', '15544523687', '身边都是乌烟瘴气，烟雾缭绕。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('32', '6', '6', '和平区盐泉路456号', '223', '3', '8', '2', '78', '3', '3', '2022-05-26', '13:24:14', '17', '17345988896', '扬尘飞沙扑面来，泥土气息撞满怀。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('33', '6', '17', '东光区高峰会胡同', '456', '3', '45', '4', '78', '3', '4', '2022-06-26', '13:24:58', '18', '17645614561', '月朦胧，鸟朦胧，空气雾霾浓。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('34', '6', '17', '清原满族自治县迎宾路', '456', '3', '235', '6', '156', '5', '6', '2022-07-26', '13:26:14', '20', '13655669988', '每天漫天灰尘，出门一分钟，回来一身灰。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('35', '10', '10', '仙居区仙女路仙人社区', '455', '3', '6', '2', '89', '3', '3', '2022-09-26', '13:28:09', '32', '13147859658', '环境污染，全球变暖，钓鱼人的环境越来越差。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('36', '11', '11', '金湖区响水路东海社区', '456', '3', '78', '5', '365', '6', '6', '2022-10-26', '13:28:50', '33', '13954754744', '呼吸的空气里面都带各种污染，环境真是让人堪忧哦！', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('37', '11', '11', '尚志区友谊路友谊社区', '897', '5', '23', '3', '156', '5', '5', '2022-08-26', '13:29:25', '33', '13045825698', '环境污染了，污染了的不仅是环境，更是健康。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('38', '6', '17', '甘井子区凌风街乘风社区', '564', '4', '25', '3', '123', '4', '4', '2022-10-27', '17:01:52', '21', '17345988896', '月黑风高，空气浑浊，难道是杀人夜？', null, '0', null, null, null, null, '0', null, null, null, null, '0');
+INSERT INTO `statistics` VALUES ('39', '4', '4', '西山区解放大路1-258-6号', '56', '2', '23', '3', '78', '3', '3', '2022-11-03', '11:10:57', '4', '17645614561', '雾朦胧，鸟朦胧，一切都朦胧。', null, '0', null, null, null, null, '0', null, null, null, null, '0');
 INSERT INTO `supervisor` VALUES ('13147859658', '123', '柯镇恶', '1984-12-09', '1', null);
 INSERT INTO `supervisor` VALUES ('13245871254', '123', '朱聪', '1985-02-07', '1', null);
 INSERT INTO `supervisor` VALUES ('13369852458', '123', '郭靖', '2000-10-12', '1', null);
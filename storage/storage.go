@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"epss-backend/config"
+	"errors"
+	"io"
+	"log"
+	"strings"
+)
+
+// ErrNotFound 文件不存在
+var ErrNotFound = errors.New("文件不存在")
+
+// Storage 附件存储后端
+type Storage interface {
+	// Save 保存文件内容，key 为存储路径（使用 / 分隔）
+	Save(key string, r io.Reader, size int64, contentType string) error
+	// Open 打开文件，调用方负责关闭
+	Open(key string) (io.ReadCloser, error)
+	// Delete 删除文件，文件不存在时不返回错误
+	Delete(key string) error
+}
+
+// Default 当前使用的存储后端
+var Default Storage
+
+// Setup 根据配置初始化存储后端
+// STORAGE_BACKEND 可选 local（默认）或 s3
+func Setup() {
+	backend := strings.ToLower(config.Config("STORAGE_BACKEND"))
+
+	switch backend {
+	case "", "local":
+		dir := config.Config("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "./uploads"
+		}
+		Default = NewLocalStorage(dir)
+		log.Printf("附件存储使用本地目录: %s", dir)
+	case "s3":
+		s3, err := NewS3Storage(S3Config{
+			Endpoint:  config.Config("S3_ENDPOINT"),
+			Region:    config.Config("S3_REGION"),
+			Bucket:    config.Config("S3_BUCKET"),
+			AccessKey: config.Config("S3_ACCESS_KEY"),
+			SecretKey: config.Config("S3_SECRET_KEY"),
+		})
+		if err != nil {
+			log.Fatalf("初始化S3存储失败: %v", err)
+		}
+		Default = s3
+		log.Printf("附件存储使用S3兼容存储: %s/%s", s3.endpoint, s3.bucket)
+	default:
+		log.Fatalf("错误: 不支持的 STORAGE_BACKEND: %s", backend)
+	}
+}